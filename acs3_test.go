@@ -0,0 +1,52 @@
+package sign4_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+var _ sign4.Signer = &sign4.ACS3{}
+
+func TestACS3(t *testing.T) {
+	// example of the Alibaba Cloud V3 signature documentation
+	r, _ := http.NewRequest("POST", "https://ecs.cn-shanghai.aliyuncs.com/?ImageId=win2019_1809_x64_dtc_zh-cn_40G_alibase_20230811.vhd&RegionId=cn-shanghai", nil)
+	r.Header.Set("x-acs-action", "RunInstances")
+	r.Header.Set("x-acs-version", "2014-05-26")
+	r.Header.Set("x-acs-date", "2023-10-26T10:22:32Z")
+	r.Header.Set("x-acs-signature-nonce", "3156853299f313e23d1673dc12e1703d")
+	s := &sign4.ACS3{AccessKeyID: "YourAccessKeyId", AccessKeySecret: "YourAccessKeySecret"}
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "ACS3-HMAC-SHA256 Credential=YourAccessKeyId,SignedHeaders=host;x-acs-action;x-acs-content-sha256;x-acs-date;x-acs-signature-nonce;x-acs-version,Signature=06563a9e1b43f5dfe96b81484da74bceab24a1d853912eee15083a6f0f3283c0"
+	if got := r.Header.Get("Authorization"); got != want {
+		t.Fatalf("wrong authorization\n%s\n%s", got, want)
+	}
+
+	r, _ = http.NewRequest("GET", "https://ecs.cn-shanghai.aliyuncs.com/", strings.NewReader(""))
+	s.SecurityToken = "token"
+	s.SignRequest(r, nil)
+	if r.Header.Get("x-acs-date") == "" || len(r.Header.Get("x-acs-signature-nonce")) != 32 ||
+		!strings.Contains(r.Header.Get("Authorization"), "x-acs-security-token;x-acs-signature-nonce,") {
+		t.Fatal("wrong default headers", r.Header)
+	}
+
+	// a clock and a fixed nonce source make signing repeatable
+	s = &sign4.ACS3{AccessKeyID: "YourAccessKeyId", AccessKeySecret: "YourAccessKeySecret",
+		Now:  func() time.Time { return time.Date(2023, 10, 26, 10, 22, 32, 0, time.UTC) },
+		Rand: bytes.NewReader(bytes.Repeat([]byte{0x31}, 32))}
+	var auths []string
+	for i := 0; i < 2; i++ {
+		r, _ = http.NewRequest("GET", "https://ecs.cn-shanghai.aliyuncs.com/", nil)
+		s.SignRequest(r, nil)
+		auths = append(auths, r.Header.Get("Authorization"))
+	}
+	if auths[0] != auths[1] || r.Header.Get("x-acs-date") != "2023-10-26T10:22:32Z" || r.Header.Get("x-acs-signature-nonce") != strings.Repeat("31", 16) {
+		t.Fatal("signing not repeatable", r.Header)
+	}
+}
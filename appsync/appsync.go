@@ -0,0 +1,211 @@
+// Package appsync signs AWS AppSync GraphQL requests with sign4 in the IAM auth mode,
+// over HTTP and on the realtime websocket endpoint.
+package appsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/datastream/aws"
+)
+
+// ServiceName is the signing name of AppSync
+const ServiceName = "appsync"
+
+// Protocol is the websocket subprotocol of the realtime endpoint
+const Protocol = "graphql-ws"
+
+// Request is a GraphQL operation
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQLError is an entry of the errors of a response
+type GraphQLError struct {
+	ErrorType string `json:"errorType"`
+	Message   string `json:"message"`
+}
+
+// Error is returned for a response with errors or a failed status
+type Error struct {
+	StatusCode int
+	Errors     []GraphQLError
+}
+
+func (e *Error) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("appsync: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("appsync: %d %s: %s", e.StatusCode, e.Errors[0].ErrorType, e.Errors[0].Message)
+}
+
+// Client sends operations to the GraphQL endpoint of an API
+type Client struct {
+	Signature  *sign4.Signature
+	HTTPClient *http.Client
+	// Endpoint is the GraphQL URL, https://<id>.appsync-api.<region>.amazonaws.com/graphql
+	// or the one of a custom domain
+	Endpoint string
+	// RealtimeEndpoint overrides the realtime URL derived from Endpoint
+	RealtimeEndpoint string
+}
+
+// NewClient returns a client of endpoint signing with s, the service name is forced to appsync
+func NewClient(s *sign4.Signature, endpoint string) *Client {
+	return &Client{Signature: s, Endpoint: endpoint}
+}
+
+// Authorization returns the headers of a request posting data to the endpoint with
+// path appended, signed: the form AppSync expects in the header query parameter of
+// the realtime handshake and in the extensions of its start messages
+func (c *Client) Authorization(data []byte, path string) (map[string]string, error) {
+	r, err := http.NewRequest("POST", strings.TrimSuffix(c.Endpoint, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Accept", "application/json, text/javascript")
+	r.Header.Set("Content-Encoding", "amz-1.0")
+	r.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	if err := c.Signature.WithService(ServiceName).SignRequest(r, nil); err != nil {
+		return nil, err
+	}
+	headers := map[string]string{
+		"accept":           r.Header.Get("Accept"),
+		"content-encoding": r.Header.Get("Content-Encoding"),
+		"content-type":     r.Header.Get("Content-Type"),
+		"host":             r.URL.Host,
+		"x-amz-date":       r.Header.Get("X-Amz-Date"),
+		"Authorization":    r.Header.Get("Authorization"),
+	}
+	if token := r.Header.Get("X-Amz-Security-Token"); token != "" {
+		headers["X-Amz-Security-Token"] = token
+	}
+	return headers, nil
+}
+
+// realtimeEndpoint is the realtime URL of the API, appsync-realtime-api for the
+// AppSync domains and /graphql/realtime for custom domains
+func (c *Client) realtimeEndpoint() (string, error) {
+	if c.RealtimeEndpoint != "" {
+		return c.RealtimeEndpoint, nil
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = "wss"
+	if strings.Contains(u.Host, ".appsync-api.") {
+		u.Host = strings.Replace(u.Host, ".appsync-api.", ".appsync-realtime-api.", 1)
+	} else {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/realtime"
+	}
+	return u.String(), nil
+}
+
+// RealtimeURL returns the URL of the realtime handshake with the signed header and
+// the empty payload query parameters, to dial with the graphql-ws subprotocol
+func (c *Client) RealtimeURL() (string, error) {
+	endpoint, err := c.realtimeEndpoint()
+	if err != nil {
+		return "", err
+	}
+	headers, err := c.Authorization([]byte("{}"), "/connect")
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(headers)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"header":  {base64.StdEncoding.EncodeToString(header)},
+		"payload": {base64.StdEncoding.EncodeToString([]byte("{}"))},
+	}
+	return endpoint + "?" + q.Encode(), nil
+}
+
+// StartMessage returns the start message of a subscription sent once the connection
+// is acknowledged, its operation is signed in the extensions
+func (c *Client) StartMessage(id string, req *Request) ([]byte, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := c.Authorization(data, "")
+	if err != nil {
+		return nil, err
+	}
+	var m struct {
+		ID      string `json:"id"`
+		Type    string `json:"type"`
+		Payload struct {
+			Data       string `json:"data"`
+			Extensions struct {
+				Authorization map[string]string `json:"authorization"`
+			} `json:"extensions"`
+		} `json:"payload"`
+	}
+	m.ID, m.Type = id, "start"
+	m.Payload.Data = string(data)
+	m.Payload.Extensions.Authorization = headers
+	return json.Marshal(&m)
+}
+
+// Do posts req signed and decodes the data of the response into out, a response with
+// errors returns an *Error
+func (c *Client) Do(ctx context.Context, req *Request, out interface{}) error {
+	if req.Query == "" {
+		return errors.New("appsync: missing query")
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequest("POST", c.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	if err := c.Signature.WithService(ServiceName).SignRequest(r, nil); err != nil {
+		return err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []GraphQLError  `json:"errors"`
+	}
+	if err := json.Unmarshal(b, &body); err != nil && resp.StatusCode < 300 {
+		return err
+	}
+	if resp.StatusCode >= 300 || len(body.Errors) > 0 {
+		return &Error{StatusCode: resp.StatusCode, Errors: body.Errors}
+	}
+	if out == nil || len(body.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body.Data, out)
+}
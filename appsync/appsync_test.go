@@ -0,0 +1,111 @@
+package appsync_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/appsync"
+)
+
+var verifier = &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := verifier.Verify(r)
+		if err != nil || s.Service != appsync.ServiceName {
+			t.Error("request not signed for appsync", err)
+		}
+		var req appsync.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.OperationName == "Fail" {
+			w.Write([]byte(`{"data":null,"errors":[{"errorType":"Unauthorized","message":"denied"}]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"getTodo":{"id":"` + req.Variables["id"].(string) + `"}}}`))
+	}))
+	defer server.Close()
+	c := appsync.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"}, server.URL+"/graphql")
+	var out struct {
+		GetTodo struct{ ID string } `json:"getTodo"`
+	}
+	err := c.Do(context.Background(), &appsync.Request{Query: "query($id: ID!) { getTodo(id: $id) { id } }", Variables: map[string]interface{}{"id": "1"}}, &out)
+	if err != nil || out.GetTodo.ID != "1" {
+		t.Fatal("wrong data", out, err)
+	}
+	err = c.Do(context.Background(), &appsync.Request{Query: "query { x }", OperationName: "Fail"}, nil)
+	var e *appsync.Error
+	if !errors.As(err, &e) || e.Errors[0].ErrorType != "Unauthorized" {
+		t.Fatal("wrong error", err)
+	}
+}
+
+// signed rebuilds the request the authorization headers were signed for and verifies it
+func signed(t *testing.T, headers map[string]string, target, body string) {
+	r, _ := http.NewRequest("POST", target, strings.NewReader(body))
+	for k, v := range headers {
+		if k != "host" {
+			r.Header.Set(k, v)
+		}
+	}
+	if r.Host != headers["host"] {
+		t.Fatal("wrong host", headers["host"])
+	}
+	if _, err := verifier.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRealtimeURL(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", SessionToken: "token", Region: "us-east-1"}
+	c := appsync.NewClient(s, "https://abc.appsync-api.us-east-1.amazonaws.com/graphql")
+	raw, err := c.RealtimeURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(raw)
+	if u.Scheme != "wss" || u.Host != "abc.appsync-realtime-api.us-east-1.amazonaws.com" || u.Path != "/graphql" || u.Query().Get("payload") != "e30=" {
+		t.Fatal("wrong realtime URL", raw)
+	}
+	b, _ := base64.StdEncoding.DecodeString(u.Query().Get("header"))
+	var headers map[string]string
+	if err := json.Unmarshal(b, &headers); err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Amz-Security-Token"] != "token" || headers["content-encoding"] != "amz-1.0" {
+		t.Fatal("wrong handshake headers", headers)
+	}
+	signed(t, headers, "https://abc.appsync-api.us-east-1.amazonaws.com/graphql/connect", "{}")
+
+	c = appsync.NewClient(s, "https://api.example.com/graphql")
+	if raw, _ := c.RealtimeURL(); !strings.HasPrefix(raw, "wss://api.example.com/graphql/realtime?") {
+		t.Fatal("wrong custom domain realtime URL", raw)
+	}
+}
+
+func TestStartMessage(t *testing.T) {
+	c := appsync.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"}, "https://abc.appsync-api.us-east-1.amazonaws.com/graphql")
+	b, err := c.StartMessage("1", &appsync.Request{Query: "subscription { onCreateTodo { id } }"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		ID      string
+		Type    string
+		Payload struct {
+			Data       string
+			Extensions struct{ Authorization map[string]string }
+		}
+	}
+	if err := json.Unmarshal(b, &m); err != nil || m.ID != "1" || m.Type != "start" {
+		t.Fatal("wrong start message", string(b), err)
+	}
+	signed(t, m.Payload.Extensions.Authorization, "https://abc.appsync-api.us-east-1.amazonaws.com/graphql", m.Payload.Data)
+}
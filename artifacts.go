@@ -0,0 +1,83 @@
+package sign4
+
+// Signing artifacts as JSON, to diff against the intermediate values of other
+// Signature Version 4 implementations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Artifacts is the signing context of a request. SigningKeyFingerprint is the hex
+// sha256 of the derived signing key, which tells keys apart without revealing them
+type Artifacts struct {
+	Algorithm             string    `json:"algorithm"`
+	AccessKey             string    `json:"access_key"`
+	Time                  time.Time `json:"time"`
+	Region                string    `json:"region"`
+	Service               string    `json:"service"`
+	Scope                 string    `json:"scope"`
+	SignedHeaders         []string  `json:"signed_headers"`
+	PayloadHash           string    `json:"payload_hash"`
+	CanonicalRequest      string    `json:"canonical_request"`
+	StringToSign          string    `json:"string_to_sign"`
+	SigningKeyFingerprint string    `json:"signing_key_fingerprint"`
+	Signature             string    `json:"signature"`
+}
+
+// Artifacts computes the signing context of a dated request, nil signedHeaders take
+// the signed headers of the Authorization header of r when it has one
+func (s *Signature) Artifacts(r *http.Request, signedHeaders map[string]bool) (*Artifacts, error) {
+	p := s.profile()
+	t, err := requestTime(r, p)
+	if err != nil {
+		return nil, err
+	}
+	if signedHeaders == nil && profileOf(r.Header.Get("Authorization")) != nil {
+		if _, _, signedHeaders, err = GetSignature(r); err != nil {
+			return nil, err
+		}
+	}
+	creds, err := s.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	sc := getScratch()
+	defer putScratch(sc)
+	payloadHash, err := s.payloadHash(sc, r)
+	if err != nil {
+		return nil, err
+	}
+	sc.appendCanonicalRequest(r, &s.Options, s.rules(), signedHeaders, payloadHash)
+	sc.appendStringToSign(p, t, s.Region, s.Service)
+	key, err := s.signingKey(p, creds.SecretKey, t)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(key.key)
+	a := &Artifacts{
+		Algorithm:             p.Algorithm,
+		AccessKey:             creds.AccessKey,
+		Time:                  t.UTC(),
+		Region:                s.Region,
+		Service:               s.Service,
+		Scope:                 string(appendScope(nil, p, t, s.Region, s.Service)),
+		SignedHeaders:         strings.Split(string(sc.appendSignedHeaders(nil, sc.signedKeys(r, signedHeaders))), ";"),
+		PayloadHash:           payloadHash,
+		CanonicalRequest:      string(sc.buf),
+		StringToSign:          string(sc.sts),
+		SigningKeyFingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+	a.Signature = string(sc.sign(key))
+	return a, nil
+}
+
+// JSON returns a as indented JSON
+func (a *Artifacts) JSON() []byte {
+	b, _ := json.MarshalIndent(a, "", "  ")
+	return b
+}
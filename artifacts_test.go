@@ -0,0 +1,48 @@
+package sign4_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestArtifacts(t *testing.T) {
+	// the key derivation example of the Signature Version 4 documentation
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Region: "us-east-1", Service: "iam"}
+	// a cache of its own keeps the 2012 key out of the process cache the benchmarks use
+	s = s.WithOptions(sign4.UseKeyCache(&sign4.MemoryCache{}))
+	r, _ := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	r.Header.Set("X-Amz-Date", "20120215T000000Z")
+	r.Header.Set("X-Amz-Meta-Name", "value")
+	if err := s.SignRequest(r, map[string]bool{"host": true, "x-amz-date": true}); err != nil {
+		t.Fatal(err)
+	}
+	a, err := s.Artifacts(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := hex.DecodeString("f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d")
+	fingerprint := sha256.Sum256(key)
+	if a.SigningKeyFingerprint != hex.EncodeToString(fingerprint[:]) {
+		t.Fatal("wrong signing key fingerprint", a.SigningKeyFingerprint)
+	}
+	if !strings.HasSuffix(r.Header.Get("Authorization"), "Signature="+a.Signature) {
+		t.Fatal("signature differs from SignRequest", a.Signature)
+	}
+	if strings.Join(a.SignedHeaders, ";") != "host;x-amz-date" || a.Scope != "20120215/us-east-1/iam/aws4_request" {
+		t.Fatal("wrong scope or signed headers", a.Scope, a.SignedHeaders)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(a.JSON(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["canonical_request"] != a.CanonicalRequest || decoded["time"] != "2012-02-15T00:00:00Z" ||
+		decoded["payload_hash"] != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatal("wrong json", string(a.JSON()))
+	}
+}
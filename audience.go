@@ -0,0 +1,115 @@
+package sign4
+
+// Signing one request for several key pairs, e.g. while consumers move to a new access key
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// AlternateAuthorizationHeader carries the Authorization values of the audiences of
+// SignAudiences, one value each in the order given
+const AlternateAuthorizationHeader = "X-Amz-Alternate-Authorization"
+
+// ErrAudienceToken is returned for an audience with a session token other than the one of
+// the signer, the token header is signed and can only hold one
+var ErrAudienceToken = errors.New("audience has another session token")
+
+// SignAudiences signs r with s into Authorization and with each of audiences into an
+// AlternateAuthorizationHeader value. The canonical request is built and hashed once, an
+// audience only adds its scope and its HMAC; its Region, Service, credentials and
+// KeyCache are used, r is canonicalized with the options and profile of s
+func (s *Signature) SignAudiences(r *http.Request, signedHeaders map[string]bool, audiences ...*Signature) error {
+	r.Header.Del(AlternateAuthorizationHeader)
+	sc := getScratch()
+	defer putScratch(sc)
+	primary, t, err := s.signRequest(sc, r, signedHeaders, nil)
+	if err != nil {
+		return err
+	}
+	p := s.profile()
+	auth := headerValue(r.Header, "Authorization")
+	// the signed headers part is the same for every audience
+	from, to := strings.Index(auth, ", SignedHeaders="), strings.LastIndex(auth, "=")+1
+	signed := auth[from:to]
+	var hash [64]byte
+	copy(hash[:], sc.sts[len(sc.sts)-len(hash):])
+	values := make([]string, 0, len(audiences))
+	for _, a := range audiences {
+		creds, err := a.signingCredentials()
+		if err != nil {
+			return err
+		}
+		if creds.SessionToken != "" && creds.SessionToken != primary.SessionToken {
+			return ErrAudienceToken
+		}
+		key, err := a.signingKey(p, creds.SecretKey, t)
+		if err != nil {
+			return err
+		}
+		sc.appendHashToSign(hash[:], p, t, a.Region, a.Service)
+		signature := sc.sign(key)
+		b := append(sc.buf[:0], p.Algorithm...)
+		b = append(b, " Credential="...)
+		b = append(b, creds.AccessKey...)
+		b = append(b, '/')
+		b = appendScope(b, p, t, a.Region, a.Service)
+		b = append(b, signed...)
+		sc.buf = append(b, signature...)
+		values = append(values, string(sc.buf))
+	}
+	r.Header[AlternateAuthorizationHeader] = values
+	return nil
+}
+
+// secretKeyError is a failed Verifier.SecretKey lookup, verify wraps it so that Verify
+// can try the alternates of a request signed with a key it doesn't know
+type secretKeyError struct {
+	err error
+}
+
+func (e *secretKeyError) Error() string {
+	return e.err.Error()
+}
+
+// fallsBack reports whether the alternates of a request may be tried after err, only a
+// signature that doesn't match or a key that isn't known; a replayed or skewed request
+// is rejected whatever its alternates
+func fallsBack(err error) bool {
+	if _, ok := err.(*secretKeyError); ok {
+		return true
+	}
+	return err == ErrSignatureMismatch
+}
+
+// verifyAlternates verifies r with each of its AlternateAuthorizationHeader values in
+// place of Authorization while they fail as fallsBack allows, err is returned when none
+// verifies. The Authorization signature is recorded with Replay as well, so the request
+// can't be replayed once per alternate
+func (v *Verifier) verifyAlternates(r *http.Request, err error) (*Signature, error) {
+	for _, alt := range r.Header.Values(AlternateAuthorizationHeader) {
+		c := *r
+		c.Header = r.Header.Clone()
+		c.Header.Set("Authorization", alt)
+		c.Header.Del(AlternateAuthorizationHeader)
+		s, altErr := v.verify(&c)
+		// a buffered body is put back into r for the next attempt and the handler
+		r.Body, r.GetBody = c.Body, c.GetBody
+		if altErr == nil {
+			if v.Replay != nil {
+				_, authHeader, _, _ := GetSignature(r)
+				if primary, err := getSignatureValue(authHeader); err == nil {
+					if err := v.checkReplay(primary); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return s, nil
+		}
+		if !fallsBack(altErr) {
+			return nil, altErr
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,91 @@
+package sign4_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+func TestSignAudiences(t *testing.T) {
+	// dated as the allocation tests, which share the key cache
+	date := sign4.Deterministic(time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC))
+	old := (&sign4.Signature{AccessKey: "AKIDOLD", SecretKey: "old-secret", Region: "us-east-1", Service: "execute-api"}).WithOptions(date)
+	rotated := (&sign4.Signature{AccessKey: "AKIDNEW", SecretKey: "new-secret", Region: "us-east-1", Service: "execute-api"}).WithOptions(date)
+	moved := rotated.WithRegion("eu-west-1")
+	newRequest := func() *http.Request {
+		r, _ := http.NewRequest("POST", "https://api.example.com/items", strings.NewReader(`{"id":1}`))
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+	r := newRequest()
+	if err := old.SignAudiences(r, nil, rotated, moved); err != nil {
+		t.Fatal(err)
+	}
+	alternates := r.Header.Values(sign4.AlternateAuthorizationHeader)
+	for i, s := range []*sign4.Signature{old, rotated, moved} {
+		fresh := newRequest()
+		if err := s.SignRequest(fresh, nil); err != nil {
+			t.Fatal(err)
+		}
+		got := r.Header.Get("Authorization")
+		if i > 0 {
+			got = alternates[i-1]
+		}
+		if got != fresh.Header.Get("Authorization") {
+			t.Fatal("audience signature differs from signing alone", got, fresh.Header.Get("Authorization"))
+		}
+	}
+	v := &sign4.Verifier{SecretKey: func(accessKey string) (string, error) {
+		if accessKey != "AKIDNEW" {
+			return "", sign4.ErrSignatureMismatch
+		}
+		return "new-secret", nil
+	}}
+	if _, err := v.Verify(r); err == nil {
+		t.Fatal("alternate accepted without Verifier.Alternates")
+	}
+	v.Alternates = true
+	if s, err := v.Verify(r); err != nil || s.AccessKey != "AKIDNEW" || s.Region != "us-east-1" {
+		t.Fatal("alternate not verified", s, err)
+	}
+	if err := old.SignRequest(r, nil); err != nil || len(r.Header.Values(sign4.AlternateAuthorizationHeader)) != 0 {
+		t.Fatal("alternates kept after signing again", err)
+	}
+	temporary := &sign4.Signature{AccessKey: "ASIDTEMP", SecretKey: "secret", SessionToken: "token", Region: "us-east-1", Service: "execute-api"}
+	if err := old.SignAudiences(newRequest(), nil, temporary); err != sign4.ErrAudienceToken {
+		t.Fatal("audience with its own session token signed", err)
+	}
+}
+
+func TestAlternatesReplay(t *testing.T) {
+	date := sign4.Deterministic(time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC))
+	old := (&sign4.Signature{AccessKey: "AKIDOLD", SecretKey: "old-secret", Region: "us-east-1", Service: "execute-api"}).WithOptions(date)
+	rotated := (&sign4.Signature{AccessKey: "AKIDNEW", SecretKey: "new-secret", Region: "us-east-1", Service: "execute-api"}).WithOptions(date)
+	r, _ := http.NewRequest("GET", "https://api.example.com/items", nil)
+	if err := old.SignAudiences(r, nil, rotated, rotated.WithRegion("eu-west-1")); err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]string{"AKIDNEW": "new-secret"}
+	v := &sign4.Verifier{Alternates: true, Replay: &sign4.MemoryCache{}, SecretKey: func(accessKey string) (string, error) {
+		if secret, ok := keys[accessKey]; ok {
+			return secret, nil
+		}
+		return "", errors.New("unknown access key")
+	}}
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+	// the first alternate is replayed, the second must not be tried
+	if _, err := v.Verify(r); err != sign4.ErrReplayed {
+		t.Fatal("replayed with another alternate", err)
+	}
+	r.Header.Del(sign4.AlternateAuthorizationHeader)
+	keys["AKIDOLD"] = "old-secret"
+	if _, err := v.Verify(r); err != sign4.ErrReplayed {
+		t.Fatal("Authorization not recorded", err)
+	}
+}
@@ -0,0 +1,488 @@
+package sign4
+
+// aws-chunked framing, a body sent as chunks each signed over the signature of the
+// one before, starting from the signature of the request (the seed)
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamingPayload is the payload hash of requests whose body is aws-chunked,
+// StreamingTrailerPayload of those whose body ends in a signed checksum trailer
+const (
+	StreamingPayload        = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	StreamingTrailerPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+)
+
+// DefaultChunkSize is the chunk size of a ChunkedWriter created with size zero
+const DefaultChunkSize = 64 * 1024
+
+// maxChunkSize bounds the chunk a ChunkedReader buffers for validation
+const maxChunkSize = 16 << 20
+
+// ErrMalformedChunk is returned for a body that isn't aws-chunked framing
+var ErrMalformedChunk = errors.New("malformed aws-chunked body")
+
+// ErrChunkedTruncated is returned for a body ending before its final chunk closed the
+// chain, ErrChunkedPadding for one continuing after it
+var (
+	ErrChunkedTruncated = errors.New("aws-chunked body ends before its final chunk")
+	ErrChunkedPadding   = errors.New("aws-chunked body continues after its final chunk")
+)
+
+// DecodedLengthError is returned when the payload of an aws-chunked body isn't as long as
+// its X-Amz-Decoded-Content-Length
+type DecodedLengthError struct {
+	Declared int64
+	// Decoded is the payload length up to the chunk found to break the declared length
+	Decoded int64
+}
+
+func (e *DecodedLengthError) Error() string {
+	return "aws-chunked payload of " + strconv.FormatInt(e.Decoded, 10) + " bytes, " + strconv.FormatInt(e.Declared, 10) + " declared"
+}
+
+// ErrChecksumMismatch is returned when the payload doesn't match the checksum of its trailer
+var ErrChecksumMismatch = errors.New("payload checksum does not match")
+
+// trailerSignatureHeader carries the signature of the trailer
+const trailerSignatureHeader = "x-amz-trailer-signature"
+
+// chunkChain signs chunks over the signature of the previous one
+type chunkChain struct {
+	key    *signingKey
+	prefix []byte
+	// scope is the date and scope lines shared by the chunk and trailer strings to sign
+	scope []byte
+	prev  []byte
+	sts   []byte
+}
+
+// newChunkChain returns the chain of chunks of a request signed at t with seedSignature
+func (s *Signature) newChunkChain(seedSignature string, t time.Time) (*chunkChain, error) {
+	creds, err := s.signingCredentials()
+	if err != nil {
+		return nil, err
+	}
+	p := s.profile()
+	key, err := s.signingKey(p, creds.SecretKey, t)
+	if err != nil {
+		return nil, err
+	}
+	scope := t.UTC().AppendFormat(nil, BasicDateFormat)
+	scope = append(scope, '\n')
+	scope = appendScope(scope, p, t, s.Region, s.Service)
+	scope = append(scope, '\n')
+	b := append([]byte(p.Algorithm), "-PAYLOAD\n"...)
+	return &chunkChain{key: key, prefix: append(b, scope...), scope: scope, prev: []byte(seedSignature)}, nil
+}
+
+// trailer returns the signature of the canonical trailer headers, each name:value\n,
+// following the final chunk
+func (c *chunkChain) trailer(canonical string) []byte {
+	sum := sha256.Sum256([]byte(canonical))
+	b := append(c.sts[:0], "AWS4-HMAC-SHA256-TRAILER\n"...)
+	b = append(b, c.scope...)
+	b = append(b, c.prev...)
+	b = append(b, '\n')
+	var digits [sha256.Size * 2]byte
+	hex.Encode(digits[:], sum[:])
+	c.sts = append(b, digits[:]...)
+	mac := c.key.mac()
+	mac.Write(c.sts)
+	hex.Encode(digits[:], mac.Sum(sum[:0]))
+	c.key.put(mac)
+	c.prev = append(c.prev[:0], digits[:]...)
+	return c.prev
+}
+
+// next returns the signature of a chunk and moves the chain to it
+func (c *chunkChain) next(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	b := append(c.sts[:0], c.prefix...)
+	b = append(b, c.prev...)
+	b = append(b, '\n')
+	b = append(b, emptyPayloadHash...)
+	b = append(b, '\n')
+	var digits [sha256.Size * 2]byte
+	hex.Encode(digits[:], sum[:])
+	c.sts = append(b, digits[:]...)
+	mac := c.key.mac()
+	mac.Write(c.sts)
+	hex.Encode(digits[:], mac.Sum(sum[:0]))
+	c.key.put(mac)
+	c.prev = append(c.prev[:0], digits[:]...)
+	return c.prev
+}
+
+// ChunkedLength is the length of a payload of n bytes framed in chunks of size, the
+// Content-Length of the request when X-Amz-Decoded-Content-Length is n
+func ChunkedLength(n int64, size int) int64 {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	chunk := func(n int64) int64 {
+		return int64(len(strconv.FormatInt(n, 16))) + int64(len(";chunk-signature=")) + 64 + 2 + n + 2
+	}
+	full := n / int64(size)
+	length := full*chunk(int64(size)) + chunk(0)
+	if rest := n % int64(size); rest > 0 {
+		length += chunk(rest)
+	}
+	return length
+}
+
+// ChunkedTrailerLength is ChunkedLength for a body ending in a checksum trailer of a
+func ChunkedTrailerLength(n int64, size int, a ChecksumAlgorithm) (int64, error) {
+	h, err := NewChecksum(a)
+	if err != nil {
+		return 0, err
+	}
+	checksum := len(a.Header()) + 1 + base64.StdEncoding.EncodedLen(h.Size()) + 2
+	signature := len(trailerSignatureHeader) + 1 + 64 + 2
+	// the trailer and its closing line replace the empty line of the final chunk
+	return ChunkedLength(n, size) + int64(checksum+signature), nil
+}
+
+// ChunkedWriter frames what is written to it in signed chunks, Close writes the last
+// chunk and the final empty one, it doesn't close the underlying writer
+type ChunkedWriter struct {
+	w     io.Writer
+	chain *chunkChain
+	buf   []byte
+	size  int
+	err   error
+	// checksum hashes the payload for the trailer, nil without one
+	checksum  hash.Hash
+	algorithm ChecksumAlgorithm
+	written   bool
+}
+
+// NewChunkedWriter returns a writer of the aws-chunked body of a request signed at t
+// with seedSignature, chunks are size bytes or DefaultChunkSize when size is zero
+func (s *Signature) NewChunkedWriter(w io.Writer, seedSignature string, t time.Time, size int) (*ChunkedWriter, error) {
+	chain, err := s.newChunkChain(seedSignature, t)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	return &ChunkedWriter{w: w, chain: chain, size: size, buf: make([]byte, 0, size)}, nil
+}
+
+// UseChecksum ends the body in a trailer carrying the checksum a of the payload and its
+// signature, for requests signed with StreamingTrailerPayload and an X-Amz-Trailer header
+// naming a.Header(); it must be called before the first Write
+func (cw *ChunkedWriter) UseChecksum(a ChecksumAlgorithm) error {
+	if cw.written || len(cw.buf) > 0 {
+		return errors.New("checksum chosen after writing")
+	}
+	h, err := NewChecksum(a)
+	if err != nil {
+		return err
+	}
+	cw.checksum, cw.algorithm = h, a
+	return nil
+}
+
+func (cw *ChunkedWriter) writeChunk(data []byte) error {
+	if cw.err != nil {
+		return cw.err
+	}
+	cw.written = true
+	if cw.checksum != nil {
+		cw.checksum.Write(data)
+	}
+	signature := cw.chain.next(data)
+	header := strconv.FormatInt(int64(len(data)), 16) + ";chunk-signature=" + string(signature) + "\r\n"
+	if _, cw.err = io.WriteString(cw.w, header); cw.err != nil {
+		return cw.err
+	}
+	if _, cw.err = cw.w.Write(data); cw.err != nil {
+		return cw.err
+	}
+	_, cw.err = io.WriteString(cw.w, "\r\n")
+	return cw.err
+}
+
+// Write buffers p and writes every chunk it completes
+func (cw *ChunkedWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if cw.err != nil {
+			return n, cw.err
+		}
+		k := cw.size - len(cw.buf)
+		if k > len(p) {
+			k = len(p)
+		}
+		cw.buf = append(cw.buf, p[:k]...)
+		p, n = p[k:], n+k
+		if len(cw.buf) == cw.size {
+			if err := cw.writeChunk(cw.buf); err != nil {
+				return n, err
+			}
+			cw.buf = cw.buf[:0]
+		}
+	}
+	return n, cw.err
+}
+
+// Close writes the buffered chunk and the final empty chunk
+func (cw *ChunkedWriter) Close() error {
+	if len(cw.buf) > 0 {
+		if err := cw.writeChunk(cw.buf); err != nil {
+			return err
+		}
+		cw.buf = cw.buf[:0]
+	}
+	var err error
+	if cw.checksum == nil {
+		err = cw.writeChunk(nil)
+	} else {
+		err = cw.writeTrailer()
+	}
+	if err != nil {
+		return err
+	}
+	cw.err = errors.New("write to closed ChunkedWriter")
+	return nil
+}
+
+// writeTrailer writes the final chunk followed by the signed checksum trailer
+func (cw *ChunkedWriter) writeTrailer() error {
+	if cw.err != nil {
+		return cw.err
+	}
+	final := "0;chunk-signature=" + string(cw.chain.next(nil)) + "\r\n"
+	line := cw.algorithm.Header() + ":" + base64.StdEncoding.EncodeToString(cw.checksum.Sum(nil))
+	signature := cw.chain.trailer(line + "\n")
+	_, cw.err = io.WriteString(cw.w, final+line+"\r\n"+trailerSignatureHeader+":"+string(signature)+"\r\n\r\n")
+	return cw.err
+}
+
+// ChunkedReader reads the payload of an aws-chunked body, each chunk is checked
+// against its signature before any of it is returned
+type ChunkedReader struct {
+	r     *bufio.Reader
+	chain *chunkChain
+	buf   []byte
+	// data is the unread part of the last checked chunk
+	data []byte
+	err  error
+	// checksum hashes the payload for the expected trailer, nil without one
+	checksum  hash.Hash
+	algorithm ChecksumAlgorithm
+	// declared is the expected payload length, -1 when unknown
+	declared int64
+	decoded  int64
+}
+
+// NewChunkedReader returns a reader of the payload of an aws-chunked body of a request
+// signed at t with seedSignature, e.g. the Signature returned by Verifier.Verify
+func (s *Signature) NewChunkedReader(r io.Reader, seedSignature string, t time.Time) (*ChunkedReader, error) {
+	chain, err := s.newChunkChain(seedSignature, t)
+	if err != nil {
+		return nil, err
+	}
+	return &ChunkedReader{r: bufio.NewReader(r), chain: chain, declared: -1}, nil
+}
+
+// ExpectLength fails the read with a *DecodedLengthError when the payload isn't n bytes,
+// the X-Amz-Decoded-Content-Length of the request; a chunk going past n is never returned
+func (cr *ChunkedReader) ExpectLength(n int64) {
+	cr.declared = n
+}
+
+// ExpectChecksum requires the body to end in a signed trailer with the checksum a of the
+// payload, the algorithm named by the X-Amz-Trailer header; the checksum is checked before
+// Read returns io.EOF. It must be called before the first Read
+func (cr *ChunkedReader) ExpectChecksum(a ChecksumAlgorithm) error {
+	h, err := NewChecksum(a)
+	if err != nil {
+		return err
+	}
+	cr.checksum, cr.algorithm = h, a
+	return nil
+}
+
+// readChunk reads the next chunk into cr.data once its signature checks
+func (cr *ChunkedReader) readChunk() error {
+	line, err := cr.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF {
+			return ErrChunkedTruncated
+		}
+		return err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	i := strings.Index(line, ";chunk-signature=")
+	if i < 0 {
+		return ErrMalformedChunk
+	}
+	n, err := strconv.ParseInt(line[:i], 16, 64)
+	if err != nil || n < 0 || n > maxChunkSize {
+		return ErrMalformedChunk
+	}
+	if int64(cap(cr.buf)) < n {
+		cr.buf = make([]byte, n)
+	}
+	chunk := cr.buf[:n]
+	if _, err := io.ReadFull(cr.r, chunk); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrChunkedTruncated
+		}
+		return err
+	}
+	if n == 0 && cr.checksum != nil {
+		if subtle.ConstantTimeCompare(cr.chain.next(nil), []byte(line[i+17:])) != 1 {
+			return ErrSignatureMismatch
+		}
+		if err := cr.readTrailer(); err != nil {
+			return err
+		}
+		return cr.end()
+	}
+	var crlf [2]byte
+	if _, err := io.ReadFull(cr.r, crlf[:]); err != nil || string(crlf[:]) != "\r\n" {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrChunkedTruncated
+		}
+		return ErrMalformedChunk
+	}
+	if subtle.ConstantTimeCompare(cr.chain.next(chunk), []byte(line[i+17:])) != 1 {
+		return ErrSignatureMismatch
+	}
+	if n == 0 {
+		return cr.end()
+	}
+	cr.decoded += n
+	if cr.declared >= 0 && cr.decoded > cr.declared {
+		return &DecodedLengthError{Declared: cr.declared, Decoded: cr.decoded}
+	}
+	if cr.checksum != nil {
+		cr.checksum.Write(chunk)
+	}
+	cr.data = chunk
+	return nil
+}
+
+// readTrailer checks the trailer after the final chunk, its signature and the checksum
+func (cr *ChunkedReader) readTrailer() error {
+	var canonical, checksum, signature string
+	for {
+		line, err := cr.r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return ErrChunkedTruncated
+			}
+			return err
+		}
+		line = strings.TrimSuffix(line, "\r\n")
+		if line == "" {
+			break
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 || len(canonical) > 4096 {
+			return ErrMalformedChunk
+		}
+		name, value := strings.ToLower(line[:i]), strings.TrimSpace(line[i+1:])
+		switch {
+		case name == trailerSignatureHeader:
+			signature = value
+		case name == cr.algorithm.Header():
+			checksum = value
+			fallthrough
+		default:
+			canonical += name + ":" + value + "\n"
+		}
+	}
+	if signature == "" || checksum == "" {
+		return ErrMalformedChunk
+	}
+	if subtle.ConstantTimeCompare(cr.chain.trailer(canonical), []byte(signature)) != 1 {
+		return ErrSignatureMismatch
+	}
+	if base64.StdEncoding.EncodeToString(cr.checksum.Sum(nil)) != checksum {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// end checks the body after its final chunk, io.EOF when the payload is complete
+func (cr *ChunkedReader) end() error {
+	if cr.declared >= 0 && cr.decoded != cr.declared {
+		return &DecodedLengthError{Declared: cr.declared, Decoded: cr.decoded}
+	}
+	if _, err := cr.r.ReadByte(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return ErrChunkedPadding
+	}
+	return io.EOF
+}
+
+// Read returns the payload of the checked chunks, io.EOF after the final chunk
+func (cr *ChunkedReader) Read(p []byte) (int, error) {
+	for len(cr.data) == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		cr.err = cr.readChunk()
+	}
+	n := copy(p, cr.data)
+	cr.data = cr.data[n:]
+	return n, nil
+}
+
+// VerifyChunked verifies a request with an aws-chunked body and returns the reader of its
+// payload, checking the chunks, the X-Amz-Decoded-Content-Length and, for
+// StreamingTrailerPayload, the checksum trailer named by X-Amz-Trailer
+func (v *Verifier) VerifyChunked(r *http.Request) (*Signature, *ChunkedReader, error) {
+	payload := headerValue(r.Header, "X-Amz-Content-Sha256")
+	if payload != StreamingPayload && payload != StreamingTrailerPayload {
+		return nil, nil, errors.New("request body is not aws-chunked")
+	}
+	declared, err := strconv.ParseInt(headerValue(r.Header, "X-Amz-Decoded-Content-Length"), 10, 64)
+	if err != nil || declared < 0 {
+		return nil, nil, errors.New("missing or wrong x-amz-decoded-content-length")
+	}
+	s, err := v.Verify(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	seed, err := getSignatureValue(headerValue(r.Header, "Authorization"))
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := requestTime(r, s.profile())
+	if err != nil {
+		return nil, nil, err
+	}
+	cr, err := s.NewChunkedReader(r.Body, seed, t)
+	if err != nil {
+		return nil, nil, err
+	}
+	cr.ExpectLength(declared)
+	if payload == StreamingTrailerPayload {
+		a, ok := ChecksumOf(headerValue(r.Header, "X-Amz-Trailer"))
+		if !ok {
+			return nil, nil, ErrUnknownChecksum
+		}
+		cr.ExpectChecksum(a)
+	}
+	return s, cr, nil
+}
@@ -0,0 +1,41 @@
+package sign4
+
+// Signing a batch of requests of one scope with one derived key
+
+import (
+	"net/http"
+	"time"
+)
+
+// batchScope is what the requests of a batch share: credentials, date and derived key
+type batchScope struct {
+	creds Credentials
+	t     time.Time
+	date  string
+	key   *signingKey
+}
+
+// SignAll signs requests of the scope of s as SignRequest does, reading the credentials,
+// deriving the signing key and formatting the date once for the batch. Undated requests
+// are all dated with the time of the call; it stops at the first request failing to sign
+func (s *Signature) SignAll(requests []*http.Request, signedHeaders map[string]bool) error {
+	creds, err := s.signingCredentials()
+	if err != nil {
+		return err
+	}
+	p := s.profile()
+	t := s.now()
+	key, err := s.signingKey(p, creds.SecretKey, t)
+	if err != nil {
+		return err
+	}
+	batch := &batchScope{creds: creds, t: t, date: t.UTC().Format(BasicDateFormat), key: key}
+	sc := getScratch()
+	defer putScratch(sc)
+	for _, r := range requests {
+		if _, _, err := s.signRequest(sc, r, signedHeaders, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package sign4_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// countingProvider counts the credential reads
+type countingProvider struct{ reads int }
+
+func (c *countingProvider) Credentials() (sign4.Credentials, error) {
+	c.reads++
+	return sign4.Credentials{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", SessionToken: "token"}, nil
+}
+
+func TestSignAll(t *testing.T) {
+	provider := &countingProvider{}
+	// dated as the allocation tests, which share the key cache
+	s := (&sign4.Signature{Provider: provider, Region: "us-east-1", Service: "s3"}).
+		WithOptions(sign4.Deterministic(time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC)))
+	requests := make([]*http.Request, 50)
+	for i := range requests {
+		requests[i], _ = http.NewRequest("GET", "https://bucket.s3.amazonaws.com/export/"+strconv.Itoa(i)+".csv", nil)
+	}
+	// a request dated on another day is signed with its own key
+	requests[7].Header.Set("X-Amz-Date", "20110908T120000Z")
+	if err := s.SignAll(requests, nil); err != nil {
+		t.Fatal(err)
+	}
+	if provider.reads != 1 {
+		t.Fatal("credentials read per request", provider.reads)
+	}
+	for i, r := range requests {
+		single, _ := http.NewRequest("GET", r.URL.String(), nil)
+		single.Header.Set("X-Amz-Date", r.Header.Get("X-Amz-Date"))
+		if err := s.SignRequest(single, nil); err != nil {
+			t.Fatal(err)
+		}
+		if r.Header.Get("Authorization") != single.Header.Get("Authorization") || r.Header.Get("X-Amz-Security-Token") != "token" {
+			t.Fatal("batch signature differs for request", i)
+		}
+	}
+	if requests[7].Header.Get("X-Amz-Date") != "20110908T120000Z" || requests[8].Header.Get("X-Amz-Date") != "20110909T233600Z" {
+		t.Fatal("wrong request dates")
+	}
+}
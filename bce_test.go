@@ -0,0 +1,62 @@
+package sign4_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+var _ sign4.Signer = &sign4.BCE{}
+
+func bceHMAC(key, data string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestBCE(t *testing.T) {
+	// example of the Baidu AI Cloud authentication documentation
+	r, _ := http.NewRequest("PUT", "http://bj.bcebos.com/v1/test/myfolder/readme.txt?partNumber=9&uploadId=a44cc9bab11cbd156984767aad637851", strings.NewReader("Example"))
+	r.Header.Set("Date", "Mon, 27 Apr 2015 16:23:49 +0800")
+	r.Header.Set("Content-Type", "text/plain")
+	r.Header.Set("Content-Length", "8")
+	r.Header.Set("Content-Md5", "NFzcPqhviddjRNnSOGo4rw==")
+	r.Header.Set("x-bce-date", "2015-04-27T08:23:49Z")
+	s := &sign4.BCE{AccessKeyID: "aekkaekkaekkaekkaekkaekkaekkaekk", SecretAccessKey: "bfcdbfcdbfcdbfcdbfcdbfcdbfcdbfcd"}
+	canonical, names := s.CanonicalRequest(r, nil)
+	want := "PUT\n/v1/test/myfolder/readme.txt\npartNumber=9&uploadId=a44cc9bab11cbd156984767aad637851\n" +
+		"content-length:8\ncontent-md5:NFzcPqhviddjRNnSOGo4rw%3D%3D\ncontent-type:text%2Fplain\nhost:bj.bcebos.com\nx-bce-date:2015-04-27T08%3A23%3A49Z"
+	if canonical != want {
+		t.Fatalf("wrong canonical request\n%s\n%s", canonical, want)
+	}
+	if names != "content-length;content-md5;content-type;host;x-bce-date" {
+		t.Fatal("wrong signed headers", names)
+	}
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	prefix := "bce-auth-v1/aekkaekkaekkaekkaekkaekkaekkaekk/2015-04-27T08:23:49Z/1800"
+	if got, want := r.Header.Get("Authorization"), prefix+"/"+names+"/"+bceHMAC(bceHMAC(s.SecretAccessKey, prefix), canonical); got != want {
+		t.Fatalf("wrong authorization\n%s\n%s", got, want)
+	}
+
+	r, _ = http.NewRequest("GET", "http://bj.bcebos.com/a%20b/c", nil)
+	s.SessionToken = "token"
+	s.Expiration = time.Hour
+	if err := s.SignRequest(r, map[string]bool{"host": true, "x-bce-security-token": true}); err != nil {
+		t.Fatal(err)
+	}
+	canonical, _ = s.CanonicalRequest(r, map[string]bool{"host": true, "x-bce-security-token": true})
+	if !strings.HasPrefix(canonical, "GET\n/a%20b/c\n\nhost:bj.bcebos.com\nx-bce-security-token:token") {
+		t.Fatal("wrong canonical request", canonical)
+	}
+	if a := r.Header.Get("Authorization"); r.Header.Get("x-bce-date") == "" || !strings.Contains(a, "/3600/host;x-bce-security-token/") {
+		t.Fatal("wrong authorization", a)
+	}
+}
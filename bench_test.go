@@ -0,0 +1,218 @@
+package sign4_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/datastream/aws"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// benchTime is the date of the benchmark requests, the signer clock follows it for the
+// key cache to hold their keys
+var benchTime = time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC)
+
+var benchSignature = sign4.Signature{
+	AccessKey: "AKIDEXAMPLE",
+	SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	Region:    "us-east-1",
+	Service:   "host",
+	Options:   sign4.Options{Now: func() time.Time { return benchTime }},
+}
+
+func headerOnlyRequest() *http.Request {
+	r, _ := http.NewRequest("GET", "http://host.foo.com/%20/foo", nil)
+	r.Header.Add("x-amz-date", "20110909T233600Z")
+	r.Header.Add("content-type", "application/json")
+	return r
+}
+
+func BenchmarkHexEncodeSHA256Hash(b *testing.B) {
+	body := []byte("foo=bar")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sign4.HexEncodeSHA256Hash(body)
+	}
+}
+
+func BenchmarkSignStringToSign(b *testing.B) {
+	tt, _ := time.Parse(time.RFC1123, "Mon, 09 Sep 2011 23:36:00 GMT")
+	key, _ := sign4.GenerateSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "host", tt)
+	stringToSign := "AWS4-HMAC-SHA256\n20110909T233600Z\n20110909/us-east-1/host/aws4_request\n69c45fb9fe3fd76442b5086e50b2e9fec8298358da957b293ef26e506fdfb54b"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sign4.SignStringToSign(stringToSign, key)
+	}
+}
+
+func BenchmarkCanonicalRequest(b *testing.B) {
+	r := headerOnlyRequest()
+	r.URL.RawQuery = "prefix=logs%2F&max-keys=100&list-type=2"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sign4.CanonicalRequest(r, nil)
+	}
+}
+
+func BenchmarkSignRequest(b *testing.B) {
+	s := benchSignature
+	r := headerOnlyRequest()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		// keeps the date, SignRequest dates a signed request again
+		r.Header.Del("Authorization")
+		s.SignRequest(r, nil)
+	}
+}
+
+func BenchmarkSignRequestLargeBody(b *testing.B) {
+	s := benchSignature
+	body := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	r := headerOnlyRequest()
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.Header.Del("Authorization")
+		s.SignRequest(r, nil)
+	}
+}
+
+func BenchmarkSignRequestFile(b *testing.B) {
+	s := benchSignature
+	f, err := ioutil.TempFile("", "sign4")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.Write(bytes.Repeat([]byte("0123456789abcdef"), 4*1024*1024))
+	f.Seek(0, 0)
+	r := headerOnlyRequest()
+	r.Body = f
+	b.SetBytes(64 * 1024 * 1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Header.Del("Authorization")
+		s.SignRequest(r, nil)
+	}
+}
+
+func BenchmarkSignAll(b *testing.B) {
+	s := benchSignature.WithOptions(sign4.Deterministic(time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC)))
+	requests := make([]*http.Request, 256)
+	for i := range requests {
+		requests[i] = headerOnlyRequest()
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		// the batch dates the requests
+		for _, r := range requests {
+			r.Header.Del("Authorization")
+			r.Header.Del("X-Amz-Date")
+		}
+		s.SignAll(requests, nil)
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	s := benchSignature
+	p := s.NewPipeline(0, 1024, nil)
+	defer p.Close()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			f, _ := p.Submit(context.Background(), headerOnlyRequest())
+			f.Wait()
+		}
+	})
+}
+
+func BenchmarkPreparedRequest(b *testing.B) {
+	s := benchSignature
+	p, _ := s.Prepare(headerOnlyRequest(), nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.Sign(benchTime, nil)
+	}
+}
+
+func BenchmarkGetSignatureFromString(b *testing.B) {
+	s := benchSignature
+	r := headerOnlyRequest()
+	s.SignRequest(r, nil)
+	authHeader := r.Header.Get("Authorization")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sign4.GetSignatureFromString(authHeader)
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	s := benchSignature
+	r := headerOnlyRequest()
+	s.SignRequest(r, nil)
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return s.SecretKey, nil }}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := v.Verify(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	s := benchSignature
+	requests := make([]*http.Request, 256)
+	for i := range requests {
+		requests[i] = headerOnlyRequest()
+		s.SignRequest(requests[i], nil)
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return s.SecretKey, nil }}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v.VerifyBatch(requests, 8)
+	}
+}
+
+func BenchmarkPresignBatch(b *testing.B) {
+	base := benchSignature
+	base.Service = "s3"
+	s := base.WithOptions(sign4.Deterministic(time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC)))
+	urls := make([]string, 1000)
+	for i := range urls {
+		urls[i] = "https://bucket.s3.amazonaws.com/prefix/" + strconv.Itoa(i) + ".jpg"
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.PresignBatch("GET", urls, time.Hour)
+	}
+}
+
+// allocation budgets of the hot paths, raise them only with a reason
+func TestSignAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	s := benchSignature
+	r := headerOnlyRequest()
+	s.SignRequest(r, nil)
+	if n := testing.AllocsPerRun(100, func() {
+		// a signed request is dated again, keep the date of the first signing
+		r.Header.Del("Authorization")
+		s.SignRequest(r, nil)
+	}); n > 6 {
+		t.Fatal("SignRequest allocations", n)
+	}
+	p, _ := s.Prepare(headerOnlyRequest(), nil)
+	if n := testing.AllocsPerRun(100, func() { p.Sign(benchTime, nil) }); n > 10 {
+		t.Fatal("PreparedRequest.Sign allocations", n)
+	}
+	if n := testing.AllocsPerRun(100, func() { sign4.HexEncodeSHA256Hash([]byte("foo=bar")) }); n > 1 {
+		t.Fatal("HexEncodeSHA256Hash allocations", n)
+	}
+}
@@ -0,0 +1,184 @@
+package sign4
+
+// Caches shared by the replay guard and the derived key cache, MemoryCache for one
+// process, the redis package for a fleet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Cache stores values with a time to live, values must not be modified after they are set
+type Cache interface {
+	// Get returns the value of key and whether it was found
+	Get(key string) ([]byte, bool, error)
+	// SetWithTTL stores value under key for ttl, a ttl <= 0 keeps it until it is evicted
+	SetWithTTL(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// DefaultMaxCacheEntries bounds a MemoryCache without MaxEntries
+const DefaultMaxCacheEntries = 10000
+
+// MemoryCache is an in-process Cache, the zero value is ready to use
+type MemoryCache struct {
+	// MaxEntries bounds the cache, expired entries are dropped when it is reached and
+	// all entries when none expired
+	MaxEntries int
+	// Now returns the current time, time.Now when nil
+	Now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+func (c *MemoryCache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (e *cacheEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// Get returns the value of key and whether it was found
+func (c *MemoryCache) Get(key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if e.expired(c.now()) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+// SetWithTTL stores value under key for ttl
+func (c *MemoryCache) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+	if _, ok := c.entries[key]; !ok {
+		c.evict(now)
+	}
+	e := cacheEntry{value: value}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+	c.entries[key] = e
+	return nil
+}
+
+// SetIfAbsent stores value under key for ttl unless it holds an unexpired value
+func (c *MemoryCache) SetIfAbsent(key string, value []byte, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setIfAbsent(c.now(), key, value, ttl), nil
+}
+
+// SetIfAbsentBatch is SetIfAbsent of each key under one lock
+func (c *MemoryCache) SetIfAbsentBatch(keys []string, value []byte, ttl time.Duration) ([]bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	stored := make([]bool, len(keys))
+	for i, key := range keys {
+		stored[i] = c.setIfAbsent(now, key, value, ttl)
+	}
+	return stored, nil
+}
+
+func (c *MemoryCache) setIfAbsent(now time.Time, key string, value []byte, ttl time.Duration) bool {
+	if e, ok := c.entries[key]; ok && !e.expired(now) {
+		return false
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.evict(now)
+	e := cacheEntry{value: value}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+	c.entries[key] = e
+	return true
+}
+
+// Delete removes key
+func (c *MemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+	return nil
+}
+
+// evict makes room for one entry
+func (c *MemoryCache) evict(now time.Time) {
+	max := c.MaxEntries
+	if max <= 0 {
+		max = DefaultMaxCacheEntries
+	}
+	if len(c.entries) < max {
+		return
+	}
+	for k, e := range c.entries {
+		if e.expired(now) {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) >= max {
+		c.entries = make(map[string]cacheEntry)
+	}
+}
+
+// UseKeyCache sets Options.KeyCache
+func UseKeyCache(c Cache) Option {
+	return func(o *Options) {
+		o.KeyCache = c
+	}
+}
+
+// sharedKeyID is the cache key of a derived signing key, the secret is hashed so
+// it isn't stored in a shared cache
+func sharedKeyID(p *Profile, secretKey, regionName, serviceName string, t time.Time) string {
+	h := sha256.New()
+	for _, s := range []string{p.Algorithm, p.KeyPrefix, p.Terminator, secretKey, t.UTC().Format(BasicDateFormatShort), regionName, serviceName} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return "sign4:key:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// signingKey returns the derived key of t from Options.KeyCache when set, from the
+// process cache otherwise, the key cache failing falls back to deriving the key
+func (s *Signature) signingKey(p *Profile, secretKey string, t time.Time) (*signingKey, error) {
+	if s.KeyCache == nil {
+		return signingKeys.get(p, secretKey, s.Region, s.Service, t, s.now())
+	}
+	id := sharedKeyID(p, secretKey, s.Region, s.Service, t)
+	if key, ok, err := s.KeyCache.Get(id); err == nil && ok && len(key) == sha256.Size {
+		return &signingKey{key: key}, nil
+	}
+	key, err := generateSigningKey(p, secretKey, s.Region, s.Service, t)
+	if err != nil {
+		return nil, err
+	}
+	// the key is valid for its UTC day, keep it a day past so skewed requests find it
+	s.KeyCache.SetWithTTL(id, key, 48*time.Hour)
+	return &signingKey{key: key}, nil
+}
@@ -0,0 +1,131 @@
+package sign4_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+var (
+	_ sign4.Cache            = &sign4.MemoryCache{}
+	_ sign4.BatchReplayStore = &sign4.MemoryCache{}
+)
+
+func TestMemoryCache(t *testing.T) {
+	now := time.Date(2015, 8, 30, 12, 0, 0, 0, time.UTC)
+	c := &sign4.MemoryCache{MaxEntries: 2, Now: func() time.Time { return now }}
+	c.SetWithTTL("a", []byte("1"), time.Minute)
+	c.SetWithTTL("b", []byte("2"), 0)
+	if v, ok, _ := c.Get("a"); !ok || string(v) != "1" {
+		t.Fatal("value not cached", v, ok)
+	}
+	now = now.Add(time.Minute)
+	if _, ok, _ := c.Get("a"); ok {
+		t.Fatal("expired value returned")
+	}
+	c.SetWithTTL("c", []byte("3"), time.Minute)
+	c.SetWithTTL("d", []byte("4"), time.Minute)
+	if _, ok, _ := c.Get("d"); !ok {
+		t.Fatal("value not cached when full")
+	}
+	c.Delete("d")
+	if _, ok, _ := c.Get("d"); ok {
+		t.Fatal("value not deleted")
+	}
+}
+
+func TestKeyCacheOption(t *testing.T) {
+	signed := func(s *sign4.Signature) string {
+		r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+		r.Header.Set("X-Amz-Date", "20150830T123600Z")
+		if err := s.SignRequest(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		return r.Header.Get("Authorization")
+	}
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	want := signed(s)
+	c := &sign4.MemoryCache{}
+	shared := s.WithOptions(sign4.UseKeyCache(c))
+	for i := 0; i < 2; i++ {
+		if got := signed(shared); got != want {
+			t.Fatal("wrong authorization with key cache", got)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	s.SignRequest(r, nil)
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }, Replay: &sign4.MemoryCache{}}
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(r); err != sign4.ErrReplayed {
+		t.Fatal("replayed request accepted", err)
+	}
+	bad := r.Clone(r.Context())
+	bad.Header.Set("X-Amz-Date", "20150830T123600Z")
+	if _, err := v.Verify(bad); err != sign4.ErrSignatureMismatch {
+		t.Fatal("wrong error", err)
+	}
+}
+
+func TestReplayBatch(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	var requests []*http.Request
+	for _, path := range []string{"/a", "/b", "/a"} {
+		r, _ := http.NewRequest("GET", "https://example.amazonaws.com"+path, nil)
+		r.Header.Set("X-Amz-Date", "20150830T123600Z")
+		s.SignRequest(r, nil)
+		requests = append(requests, r)
+	}
+	bad, _ := http.NewRequest("GET", "https://example.amazonaws.com/c", nil)
+	s.WithOptions().SignRequest(bad, nil)
+	bad.Header.Set("X-Amz-Date", "20150830T123600Z")
+	requests = append(requests, bad)
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }, Replay: &sign4.MemoryCache{}}
+	results := v.VerifyBatch(requests, 2)
+	if results[0].Err != nil || results[1].Err != nil || results[0].Signature == nil {
+		t.Fatal("first requests rejected", results)
+	}
+	if results[2].Err != sign4.ErrReplayed || results[2].Signature != nil {
+		t.Fatal("repeated request in the batch accepted", results[2])
+	}
+	if results[3].Err != sign4.ErrSignatureMismatch {
+		t.Fatal("wrong error", results[3].Err)
+	}
+	if _, err := v.Verify(requests[1]); err != sign4.ErrReplayed {
+		t.Fatal("batch signature not recorded", err)
+	}
+}
+
+func TestReplayBatchPresigned(t *testing.T) {
+	date := time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC)
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "s3"}
+	var requests []*http.Request
+	for _, path := range []string{"/a", "/b"} {
+		r, _ := http.NewRequest("GET", "https://bucket.s3.amazonaws.com"+path, nil)
+		u, err := s.WithOptions(sign4.Deterministic(date)).Presign(r, time.Hour, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, _ = http.NewRequest("GET", u.String(), nil)
+		requests = append(requests, r)
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }, Replay: &sign4.MemoryCache{}}
+	v.Options.Now = func() time.Time { return date }
+	for i := 0; i < 2; i++ {
+		for n, res := range v.VerifyBatch(requests, 2) {
+			if res.Err != nil {
+				t.Fatal("presigned URL rejected", i, n, res.Err)
+			}
+		}
+	}
+	if _, err := v.Verify(requests[0]); err != nil {
+		t.Fatal(err)
+	}
+}
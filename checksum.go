@@ -0,0 +1,77 @@
+package sign4
+
+// Checksums of S3's x-amz-checksum-algorithm, sent as trailers of aws-chunked bodies
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"strings"
+)
+
+// ChecksumAlgorithm is a value of x-amz-checksum-algorithm
+type ChecksumAlgorithm string
+
+// Checksum algorithms of S3
+const (
+	ChecksumCRC32  ChecksumAlgorithm = "CRC32"
+	ChecksumCRC32C ChecksumAlgorithm = "CRC32C"
+	ChecksumSHA1   ChecksumAlgorithm = "SHA1"
+	ChecksumSHA256 ChecksumAlgorithm = "SHA256"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksums holds the hash of each checksum algorithm; entries, e.g. CRC64NVME, may be
+// added before signing starts
+var Checksums = map[ChecksumAlgorithm]func() hash.Hash{
+	ChecksumCRC32:  func() hash.Hash { return crc32.NewIEEE() },
+	ChecksumCRC32C: func() hash.Hash { return crc32.New(castagnoli) },
+	ChecksumSHA1:   sha1.New,
+	ChecksumSHA256: sha256.New,
+}
+
+// ErrUnknownChecksum is returned for an algorithm missing from Checksums
+var ErrUnknownChecksum = errors.New("unknown checksum algorithm")
+
+// NewChecksum returns a hash of a, its base64 sum is the value of the Header of a
+func NewChecksum(a ChecksumAlgorithm) (hash.Hash, error) {
+	a = ChecksumAlgorithm(strings.ToUpper(string(a)))
+	newHash, ok := Checksums[a]
+	if !ok {
+		if FIPS && a == ChecksumSHA1 {
+			return nil, ErrNotApproved
+		}
+		return nil, ErrUnknownChecksum
+	}
+	return newHash(), nil
+}
+
+// Header is the lower case header carrying the checksum, x-amz-checksum-crc32c for CRC32C
+func (a ChecksumAlgorithm) Header() string {
+	return "x-amz-checksum-" + strings.ToLower(string(a))
+}
+
+// ChecksumOf returns the algorithm of a header carrying a checksum, false for other headers
+func ChecksumOf(header string) (ChecksumAlgorithm, bool) {
+	name := strings.ToLower(header)
+	if !strings.HasPrefix(name, "x-amz-checksum-") || name == "x-amz-checksum-algorithm" || name == "x-amz-checksum-type" {
+		return "", false
+	}
+	a := ChecksumAlgorithm(strings.ToUpper(name[len("x-amz-checksum-"):]))
+	_, ok := Checksums[a]
+	return a, ok
+}
+
+// Checksum returns the base64 checksum of data
+func Checksum(a ChecksumAlgorithm, data []byte) (string, error) {
+	h, err := NewChecksum(a)
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
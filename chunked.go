@@ -0,0 +1,109 @@
+package sign4
+
+// Bodies of unknown length, sent with Transfer-Encoding: chunked
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ChunkedPolicy says how SignRequest hashes a body of unknown length
+type ChunkedPolicy int
+
+// Chunked body policies
+const (
+	// ChunkedBuffer reads the body into memory up to Options.MaxBufferedBody and sends it with a length
+	ChunkedBuffer ChunkedPolicy = iota
+	// ChunkedUnsignedPayload signs UNSIGNED-PAYLOAD, for services whose rules send X-Amz-Content-Sha256
+	ChunkedUnsignedPayload
+	// ChunkedReject fails unless X-Amz-Content-Sha256 already carries the payload hash
+	ChunkedReject
+)
+
+// DefaultMaxBufferedBody is the ChunkedBuffer limit when Options.MaxBufferedBody is zero
+const DefaultMaxBufferedBody = 64 << 20
+
+// ErrChunkedBody is returned for a body of unknown length the chunked policy can't sign
+var ErrChunkedBody = errors.New("body of unknown length needs a payload hash")
+
+// ErrBodyTooLarge is returned when a chunked body exceeds Options.MaxBufferedBody
+var ErrBodyTooLarge = errors.New("chunked body exceeds the buffer limit")
+
+// UseChunkedPolicy sets Options.ChunkedBody
+func UseChunkedPolicy(p ChunkedPolicy) Option {
+	return func(o *Options) {
+		o.ChunkedBody = p
+	}
+}
+
+// unknownLength reports whether net/http would send the body of r chunked
+func unknownLength(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	for _, te := range r.TransferEncoding {
+		if te == "chunked" {
+			return true
+		}
+	}
+	if r.ContentLength > 0 {
+		return false
+	}
+	// regular files are hashed in place
+	if f, ok := r.Body.(statReaderAt); ok {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			return false
+		}
+	}
+	return true
+}
+
+// prepareChunked applies the chunked policy of s to a body of unknown length
+func (s *Signature) prepareChunked(r *http.Request) error {
+	if !unknownLength(r) || s.UnbufferedPayload && r.GetBody != nil {
+		return nil
+	}
+	rules := s.rules()
+	if rules.ContentSHA256 && r.Header.Get("X-Amz-Content-Sha256") != "" {
+		return nil
+	}
+	switch s.ChunkedBody {
+	case ChunkedUnsignedPayload:
+		if !rules.ContentSHA256 {
+			return ErrChunkedBody
+		}
+		if r.Header.Get("X-Amz-Trailer") != "" {
+			return &ConfigError{Field: "Options.ChunkedBody", Problem: "ChunkedUnsignedPayload can't send the X-Amz-Trailer " +
+				"checksum, write the body with a ChunkedWriter signed with StreamingTrailerPayload"}
+		}
+		r.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+		return nil
+	case ChunkedReject:
+		return ErrChunkedBody
+	}
+	limit := s.MaxBufferedBody
+	if limit <= 0 {
+		limit = DefaultMaxBufferedBody
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > limit {
+		return ErrBodyTooLarge
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	r.ContentLength = int64(len(data))
+	r.TransferEncoding = nil
+	if len(data) == 0 {
+		r.Body = http.NoBody
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package sign4_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+// pipeRequest returns a request whose body net/http would send chunked
+func pipeRequest(t *testing.T, body string) *http.Request {
+	pr, pw := io.Pipe()
+	go func() {
+		io.WriteString(pw, body)
+		pw.Close()
+	}()
+	r, _ := http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/key", pr)
+	if r.ContentLength != 0 {
+		t.Fatal("length known")
+	}
+	return r
+}
+
+func TestChunkedBody(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "s3"}
+	r := pipeRequest(t, "streamed body")
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r.ContentLength != 13 || r.Header.Get("X-Amz-Content-Sha256") == "UNSIGNED-PAYLOAD" {
+		t.Fatal("body not buffered", r.ContentLength, r.Header)
+	}
+	if err := s.WithOptions(func(o *sign4.Options) { o.MaxBufferedBody = 4 }).SignRequest(pipeRequest(t, "streamed body"), nil); err != sign4.ErrBodyTooLarge {
+		t.Fatal("limit not applied", err)
+	}
+
+	r = pipeRequest(t, "streamed body")
+	if err := s.WithOptions(sign4.UseChunkedPolicy(sign4.ChunkedUnsignedPayload)).SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" || !strings.Contains(r.Header.Get("Authorization"), "x-amz-content-sha256") {
+		t.Fatal("unsigned payload not signed", r.Header)
+	}
+	if err := s.WithService("execute-api").WithOptions(sign4.UseChunkedPolicy(sign4.ChunkedUnsignedPayload)).SignRequest(pipeRequest(t, "x"), nil); err != sign4.ErrChunkedBody {
+		t.Fatal("unsigned payload allowed without content sha256 rules", err)
+	}
+
+	reject := s.WithOptions(sign4.UseChunkedPolicy(sign4.ChunkedReject))
+	if err := reject.SignRequest(pipeRequest(t, "x"), nil); err != sign4.ErrChunkedBody {
+		t.Fatal("chunked body not rejected", err)
+	}
+	r = pipeRequest(t, "x")
+	r.Header.Set("X-Amz-Content-Sha256", "2d711642b726b04401627ca9fbac32f5c8530fb1903cc4db02258717921a4881")
+	if err := reject.SignRequest(r, nil); err != nil {
+		t.Fatal("preset hash rejected", err)
+	}
+}
@@ -0,0 +1,53 @@
+package sign4
+
+// Clock offset from the Date header of the service, for devices without a reliable clock
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ClockOffset sets Options.ClockOffset
+func ClockOffset(d time.Duration) Option {
+	return func(o *Options) {
+		o.ClockOffset = d
+	}
+}
+
+// ProbeClockOffset sends an unsigned HEAD to endpoint and returns how far the Date of
+// the response is from the local clock, any status carries a Date so error replies count.
+// The Date has second resolution, its middle is compared with the middle of the round trip
+func ProbeClockOffset(ctx context.Context, client *http.Client, endpoint string) (time.Duration, error) {
+	r, err := http.NewRequest("HEAD", endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	start := time.Now()
+	resp, err := client.Do(r.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	rtt := time.Since(start)
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, errors.New("response has no date")
+	}
+	return date.Add(500 * time.Millisecond).Sub(start.Add(rtt / 2)), nil
+}
+
+// SyncClock sets Options.ClockOffset from ProbeClockOffset, call it before s is used
+// by other goroutines
+func (s *Signature) SyncClock(ctx context.Context, client *http.Client, endpoint string) error {
+	offset, err := ProbeClockOffset(ctx, client, endpoint)
+	if err != nil {
+		return err
+	}
+	s.ClockOffset = offset
+	return nil
+}
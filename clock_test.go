@@ -0,0 +1,43 @@
+package sign4_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+func TestSyncClock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" || r.Header.Get("Authorization") != "" {
+			t.Error("probe not an unsigned HEAD", r.Method, r.Header)
+		}
+		w.Header().Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	if err := s.SyncClock(context.Background(), nil, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if d := s.ClockOffset + time.Hour; d < -2*time.Second || d > 2*time.Second {
+		t.Fatal("wrong offset", s.ClockOffset)
+	}
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	s.SignRequest(r, nil)
+	signed, _ := time.Parse(sign4.BasicDateFormat, r.Header.Get("X-Amz-Date"))
+	if d := time.Since(signed) - time.Hour; d < -3*time.Second || d > 3*time.Second {
+		t.Fatal("offset not applied", r.Header.Get("X-Amz-Date"))
+	}
+
+	noDate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Date"] = nil
+	}))
+	defer noDate.Close()
+	if _, err := sign4.ProbeClockOffset(context.Background(), nil, noDate.URL); err == nil {
+		t.Fatal("response without date accepted")
+	}
+}
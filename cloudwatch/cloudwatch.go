@@ -0,0 +1,213 @@
+// Package cloudwatch is a minimal CloudWatch PutMetricData client signed with sign4.
+package cloudwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/query"
+)
+
+// ServiceName is the signing name of the CloudWatch API
+const ServiceName = "monitoring"
+
+const version = "2010-08-01"
+
+// PutMetricData limits
+const (
+	MaxDatumsPerCall = 20
+	MaxPayloadSize   = 1024 * 1024
+)
+
+// Dimension of a metric
+type Dimension struct {
+	Name  string
+	Value string
+}
+
+// StatisticSet is a pre-aggregated set of samples
+type StatisticSet struct {
+	SampleCount float64
+	Sum         float64
+	Minimum     float64
+	Maximum     float64
+}
+
+// MetricDatum is one data point, set either Value or StatisticValues
+type MetricDatum struct {
+	MetricName        string
+	Dimensions        []Dimension
+	Timestamp         time.Time
+	Value             float64
+	StatisticValues   *StatisticSet
+	Unit              string
+	StorageResolution int
+}
+
+func (d *MetricDatum) encode(n int) string {
+	prefix := "MetricData.member." + strconv.Itoa(n) + "."
+	var a []string
+	add := func(k, v string) {
+		a = append(a, url.QueryEscape(prefix+k)+"="+url.QueryEscape(v))
+	}
+	add("MetricName", d.MetricName)
+	for i, dim := range d.Dimensions {
+		add(fmt.Sprintf("Dimensions.member.%d.Name", i+1), dim.Name)
+		add(fmt.Sprintf("Dimensions.member.%d.Value", i+1), dim.Value)
+	}
+	if !d.Timestamp.IsZero() {
+		add("Timestamp", d.Timestamp.UTC().Format(time.RFC3339))
+	}
+	if d.StatisticValues != nil {
+		add("StatisticValues.SampleCount", formatFloat(d.StatisticValues.SampleCount))
+		add("StatisticValues.Sum", formatFloat(d.StatisticValues.Sum))
+		add("StatisticValues.Minimum", formatFloat(d.StatisticValues.Minimum))
+		add("StatisticValues.Maximum", formatFloat(d.StatisticValues.Maximum))
+	} else {
+		add("Value", formatFloat(d.Value))
+	}
+	if d.Unit != "" {
+		add("Unit", d.Unit)
+	}
+	if d.StorageResolution != 0 {
+		add("StorageResolution", strconv.Itoa(d.StorageResolution))
+	}
+	return strings.Join(a, "&")
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Error is an error returned by the CloudWatch API
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Error>Code"`
+	Message    string `xml:"Error>Message"`
+	RequestID  string `xml:"RequestId"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("cloudwatch: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Client sends metric data
+type Client struct {
+	Signature  *sign4.Signature
+	HTTPClient *http.Client
+	// Endpoint overrides https://monitoring.<region>.amazonaws.com
+	Endpoint string
+}
+
+// NewClient returns a client signing with s, the service name is forced to monitoring
+func NewClient(s *sign4.Signature) *Client {
+	return &Client{Signature: s}
+}
+
+// PutMetricData sends data, split into calls within MaxDatumsPerCall and MaxPayloadSize
+func (c *Client) PutMetricData(ctx context.Context, namespace string, data []MetricDatum) error {
+	head := query.Body("PutMetricData", version, url.Values{"Namespace": {namespace}})
+	var batch []string
+	size := len(head)
+	for i := range data {
+		member := data[i].encode(len(batch) + 1)
+		if len(batch) == MaxDatumsPerCall || (len(batch) > 0 && size+1+len(member) > MaxPayloadSize) {
+			if err := c.post(ctx, head+"&"+strings.Join(batch, "&")); err != nil {
+				return err
+			}
+			batch = batch[:0]
+			size = len(head)
+			member = data[i].encode(1)
+		}
+		batch = append(batch, member)
+		size += 1 + len(member)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return c.post(ctx, head+"&"+strings.Join(batch, "&"))
+}
+
+func (c *Client) post(ctx context.Context, body string) error {
+	q := query.NewClient(c.Signature, ServiceName, version)
+	q.HTTPClient = c.HTTPClient
+	q.Endpoint = c.Endpoint
+	err := q.Post(ctx, body, nil)
+	var e *query.Error
+	if errors.As(err, &e) {
+		return &Error{StatusCode: e.StatusCode, Code: e.Code, Message: e.Message, RequestID: e.RequestID}
+	}
+	return err
+}
+
+// Publisher buffers data points and flushes them on a ticker
+type Publisher struct {
+	Client    *Client
+	Namespace string
+	// OnError receives errors of background flushes
+	OnError func(error)
+
+	mu     sync.Mutex
+	buffer []MetricDatum
+	done   chan struct{}
+	closed sync.Once
+	wg     sync.WaitGroup
+}
+
+// NewPublisher starts a publisher flushing every interval
+func NewPublisher(c *Client, namespace string, interval time.Duration) *Publisher {
+	p := &Publisher{Client: c, Namespace: namespace, done: make(chan struct{})}
+	p.wg.Add(1)
+	go p.loop(interval)
+	return p
+}
+
+func (p *Publisher) loop(interval time.Duration) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.Flush(context.Background()); err != nil && p.OnError != nil {
+				p.OnError(err)
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Add queues data points for the next flush
+func (p *Publisher) Add(data ...MetricDatum) {
+	p.mu.Lock()
+	p.buffer = append(p.buffer, data...)
+	p.mu.Unlock()
+}
+
+// Flush sends all queued data points
+func (p *Publisher) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	data := p.buffer
+	p.buffer = nil
+	p.mu.Unlock()
+	if len(data) == 0 {
+		return nil
+	}
+	return p.Client.PutMetricData(ctx, p.Namespace, data)
+}
+
+// Close stops the ticker and flushes the remaining data points, it may be called again
+func (p *Publisher) Close() error {
+	p.closed.Do(func() { close(p.done) })
+	p.wg.Wait()
+	return p.Flush(context.Background())
+}
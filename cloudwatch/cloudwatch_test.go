@@ -0,0 +1,83 @@
+package cloudwatch_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/cloudwatch"
+)
+
+func TestPutMetricData(t *testing.T) {
+	var mu sync.Mutex
+	var calls []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "PutMetricData" || r.Form.Get("Namespace") != "app" {
+			t.Error("wrong form", r.Form)
+		}
+		if r.Form.Get("MetricData.member.1.Dimensions.member.1.Name") != "host" {
+			t.Error("missing dimension", r.Form)
+		}
+		n := 0
+		for k := range r.Form {
+			if strings.HasSuffix(k, ".MetricName") {
+				n++
+			}
+		}
+		mu.Lock()
+		calls = append(calls, n)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	c := cloudwatch.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"})
+	c.Endpoint = server.URL
+	var data []cloudwatch.MetricDatum
+	for i := 0; i < 45; i++ {
+		data = append(data, cloudwatch.MetricDatum{
+			MetricName: "latency",
+			Dimensions: []cloudwatch.Dimension{{Name: "host", Value: "a"}},
+			Value:      float64(i),
+			Unit:       "Milliseconds",
+		})
+	}
+	data[0].StatisticValues = &cloudwatch.StatisticSet{SampleCount: 2, Sum: 3, Minimum: 1, Maximum: 2}
+	if err := c.PutMetricData(context.Background(), "app", data); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 || calls[0] != 20 || calls[2] != 5 {
+		t.Fatal("wrong batching", calls)
+	}
+	p := cloudwatch.NewPublisher(c, "app", time.Hour)
+	p.Add(data[:3]...)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 4 || calls[3] != 3 {
+		t.Fatal("publisher did not flush", calls)
+	}
+	if err := p.Close(); err != nil || len(calls) != 4 {
+		t.Fatal("second close", err, calls)
+	}
+}
+
+func TestPutMetricDataError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>InvalidParameterValue</Code><Message>bad</Message></Error><RequestId>id</RequestId></ErrorResponse>`))
+	}))
+	defer server.Close()
+	c := cloudwatch.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"})
+	c.Endpoint = server.URL
+	err := c.PutMetricData(context.Background(), "app", []cloudwatch.MetricDatum{{MetricName: "m", Value: 1}})
+	var e *cloudwatch.Error
+	if !errors.As(err, &e) || e.Code != "InvalidParameterValue" || e.RequestID != "id" {
+		t.Fatal("wrong error", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+func runCurl(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("curl", flag.ContinueOnError)
+	var f signFlags
+	fs.StringVar(&f.method, "X", "", "HTTP method, GET or POST with -d")
+	fs.StringVar(&f.body, "d", "", "request body, @file reads a file, @- reads stdin")
+	fs.Var(&f.headers, "H", "extra header \"Name: value\", repeatable")
+	f.registerSigning(fs)
+	include := fs.Bool("i", false, "print the response status and headers")
+	fail := fs.Bool("f", false, "fail on HTTP errors")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: sign4 curl [flags] URL")
+	}
+	f.url = fs.Arg(0)
+	if f.method == "" {
+		f.method = "GET"
+		if f.body != "" {
+			f.method = "POST"
+		}
+	}
+	r, _, err := f.request(stdin)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if *include {
+		fmt.Fprintf(stdout, "%s %s\n", resp.Proto, resp.Status)
+		var names []string
+		for name := range resp.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, v := range resp.Header[name] {
+				fmt.Fprintf(stdout, "%s: %s\n", name, v)
+			}
+		}
+		fmt.Fprintln(stdout)
+	}
+	if _, err := io.Copy(stdout, resp.Body); err != nil {
+		return err
+	}
+	if *fail && resp.StatusCode >= 400 {
+		return fmt.Errorf("the requested URL returned error: %s", resp.Status)
+	}
+	return nil
+}
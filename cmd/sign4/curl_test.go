@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestCurl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _, _, err := sign4.GetSignature(r)
+		if err != nil || s.Service != "execute-api" || s.Region != "eu-west-1" {
+			t.Error("wrong signature", s, err)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Test", "1")
+		w.Write([]byte(r.Method + " " + string(b)))
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	var out bytes.Buffer
+	err := runCurl([]string{"-i", "-d", "hello", "--region", "eu-west-1", "--service", "execute-api", server.URL + "/prod"}, nil, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "X-Test: 1\n") || !strings.HasSuffix(out.String(), "\nPOST hello") {
+		t.Fatal("wrong output", out.String())
+	}
+	if err := runCurl([]string{server.URL}, nil, &out); err == nil {
+		t.Fatal("service inferred from a non aws host")
+	}
+}
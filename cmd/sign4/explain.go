@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/datastream/aws"
+)
+
+// readOptional returns the content of file, or nothing when name is empty
+func readOptional(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(name)
+	return string(data), err
+}
+
+func runExplain(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	request := fs.String("request", "-", "raw HTTP request file, - reads stdin")
+	secretKey := fs.String("secret-key", os.Getenv("AWS_SECRET_ACCESS_KEY"), "secret access key")
+	authorization := fs.String("authorization", "", "expected Authorization header or hex signature, defaults to the one in the request")
+	canonicalRequest := fs.String("canonical-request", "", "file with the expected canonical request")
+	stringToSign := fs.String("string-to-sign", "", "file with the expected string to sign")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: sign4 explain [flags]\n       sign4 explain AUTHORIZATION EXPECTED_AUTHORIZATION")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var e *sign4.Explanation
+	var err error
+	if fs.NArg() == 2 {
+		if e, err = sign4.CompareAuthorization(fs.Arg(0), fs.Arg(1)); err != nil {
+			return err
+		}
+		return printExplanation(stdout, e)
+	}
+	if *secretKey == "" {
+		return errors.New("missing --secret-key")
+	}
+	r, err := readRequest(*request, stdin)
+	if err != nil {
+		return err
+	}
+	expected := sign4.Expected{Authorization: *authorization}
+	if expected.CanonicalRequest, err = readOptional(*canonicalRequest); err != nil {
+		return err
+	}
+	if expected.StringToSign, err = readOptional(*stringToSign); err != nil {
+		return err
+	}
+	h := *authorization
+	if _, _, _, err := sign4.GetSignatureFromString(h); err != nil {
+		h = r.Header.Get("Authorization")
+	}
+	s, _, _, err := sign4.GetSignatureFromString(h)
+	if err != nil {
+		return fmt.Errorf("credential scope: %v", err)
+	}
+	s.SecretKey = *secretKey
+	if e, err = s.Explain(r, expected); err != nil {
+		return err
+	}
+	return printExplanation(stdout, e)
+}
+
+// printExplanation prints the annotated computation and returns ErrSignatureMismatch when it diverges
+func printExplanation(w io.Writer, e *sign4.Explanation) error {
+	if e.Stage == "" {
+		fmt.Fprintln(w, "no divergence found")
+	} else {
+		fmt.Fprintf(w, "diverges at %s: %s\n", e.Stage, e.Detail)
+	}
+	if len(e.Lines) > 0 {
+		fmt.Fprintln(w, "\ncanonical request:")
+		for _, l := range e.Lines {
+			mark := " "
+			if l.Differs {
+				mark = "!"
+			}
+			fmt.Fprintf(w, "%s %-24s %s\n", mark, l.Note, l.Text)
+			if l.Differs {
+				fmt.Fprintf(w, "  %-24s %s\n", "expected", l.Want)
+			}
+		}
+	}
+	if e.StringToSign != "" {
+		fmt.Fprintf(w, "\nstring to sign:\n%s\n", e.StringToSign)
+	}
+	if e.Signature != "" {
+		fmt.Fprintf(w, "\nsignature: %s\n", e.Signature)
+	}
+	if e.Stage != "" {
+		return sign4.ErrSignatureMismatch
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestExplain(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "host"}
+	r, _ := http.NewRequest("GET", "http://host.foo.com/path?a=1", nil)
+	s.SignRequest(r, nil)
+	creq, _ := sign4.CanonicalRequest(r, map[string]bool{"host": true, "x-amz-date": true})
+	dir, err := ioutil.TempDir("", "explain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	creqFile := filepath.Join(dir, "creq")
+	ioutil.WriteFile(creqFile, []byte(creq), 0644)
+
+	// the server saw another query string than the client signed
+	r.URL.RawQuery = "a=2"
+	raw, _ := httputil.DumpRequest(r, true)
+	var out bytes.Buffer
+	err = runExplain([]string{"--secret-key", "secret", "--canonical-request", creqFile}, bytes.NewReader(raw), &out)
+	if err != sign4.ErrSignatureMismatch || !strings.HasPrefix(out.String(), `diverges at canonical request: query "a=2", expected "a=1"`) {
+		t.Fatal("query change not explained", err, out.String())
+	}
+	if !strings.Contains(out.String(), "! query                    a=2\n  expected                 a=1\n") {
+		t.Fatal("line not annotated", out.String())
+	}
+
+	out.Reset()
+	a := r.Header.Get("Authorization")
+	err = runExplain([]string{a, strings.Replace(a, "us-east-1", "eu-west-1", 1)}, nil, &out)
+	if err != sign4.ErrSignatureMismatch || !strings.HasPrefix(out.String(), "diverges at credential: region") {
+		t.Fatal("region change not explained", err, out.String())
+	}
+}
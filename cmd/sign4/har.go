@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/har"
+)
+
+func runHAR(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("har", flag.ContinueOnError)
+	var f signFlags
+	f.registerSigning(fs)
+	file := fs.String("file", "-", "HAR capture file, - reads stdin")
+	keys := keyFlags{}
+	fs.Var(keys, "verify-key", "verify requests signed with ACCESS_KEY:SECRET, repeatable")
+	resign := fs.Bool("resign", false, "sign every request with --access-key, --secret-key, --region and --service and compare")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	in := stdin
+	if *file != "-" {
+		fd, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		in = fd
+	}
+	entries, err := har.Decode(in)
+	if err != nil {
+		return err
+	}
+	var results []har.Result
+	if *resign {
+		if f.region == "" || f.service == "" {
+			return errors.New("--resign needs --region and --service")
+		}
+		creds, err := f.credentials()
+		if err != nil {
+			return err
+		}
+		s := &sign4.Signature{AccessKey: creds.AccessKey, SecretKey: creds.SecretKey, SessionToken: creds.SessionToken, Region: f.region, Service: f.service}
+		results = har.Resign(entries, s)
+	} else {
+		if f.accessKey != "" && f.secretKey != "" {
+			keys[f.accessKey] = f.secretKey
+		}
+		if len(keys) == 0 {
+			return errors.New("missing --verify-key or --access-key and --secret-key")
+		}
+		v := &sign4.Verifier{SecretKey: func(accessKey string) (string, error) {
+			if secret, ok := keys[accessKey]; ok {
+				return secret, nil
+			}
+			return "", errors.New("unknown access key")
+		}}
+		results = har.Verify(entries, v)
+	}
+	if err := har.WriteReport(stdout, results); err != nil {
+		return err
+	}
+	invalid := 0
+	for _, res := range results {
+		if res.Err != nil {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d requests don't validate", invalid, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/har"
+)
+
+func TestHAR(t *testing.T) {
+	date := time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC)
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "sqs"}
+	r, _ := http.NewRequest("GET", "https://sqs.us-east-1.amazonaws.com/?Action=ListQueues", nil)
+	s.WithOptions(sign4.Deterministic(date)).SignRequest(r, nil)
+	e := har.Entry{StartedDateTime: date, Request: har.Request{Method: "GET", URL: r.URL.String()}}
+	for name := range r.Header {
+		e.Request.Headers = append(e.Request.Headers, har.NameValue{Name: name, Value: r.Header.Get(name)})
+	}
+	e.Request.Headers = append(e.Request.Headers, har.NameValue{Name: "Host", Value: r.URL.Host})
+	doc := map[string]interface{}{"log": map[string]interface{}{"entries": []har.Entry{e}}}
+	data, _ := json.Marshal(doc)
+
+	var out bytes.Buffer
+	if err := runHAR([]string{"--verify-key", "AKIDEXAMPLE:secret"}, bytes.NewReader(data), &out); err != nil {
+		t.Fatal(err, out.String())
+	}
+	if !strings.Contains(out.String(), "valid") || !strings.HasSuffix(out.String(), "1 of 1 requests validate\n") {
+		t.Fatal("wrong report", out.String())
+	}
+	out.Reset()
+	args := []string{"--resign", "--access-key", "AKIDEXAMPLE", "--secret-key", "wrong", "--region", "us-east-1", "--service", "sqs"}
+	if err := runHAR(args, bytes.NewReader(data), &out); err == nil || !strings.Contains(out.String(), "signature does not match") {
+		t.Fatal("wrong key validates", err, out.String())
+	}
+}
@@ -0,0 +1,87 @@
+// Command sign4 signs, verifies and sends AWS Signature Version 4 requests.
+//
+//	sign4 sign --method PUT --url https://bucket.s3.amazonaws.com/key --region us-east-1 --service s3 --body @file
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	usage string
+	run   func(args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"sign":    {"sign a request and print the signing headers", runSign},
+	"verify":  {"verify the signature of a captured request", runVerify},
+	"curl":    {"sign and send a request, curl style", runCurl},
+	"proxy":   {"run a signing proxy to an endpoint", runProxy},
+	"explain": {"show which signing stage diverges from an expected signature", runExplain},
+	"vectors": {"generate interop test vectors for a matrix of edge cases", runVectors},
+	"har":     {"audit the signatures of requests in a HAR capture", runHAR},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: sign4 <command> [flags]")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "sign4:", err)
+		os.Exit(1)
+	}
+}
+
+// headerFlags collects repeated -H "Name: value" flags
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(v string) error {
+	if !strings.Contains(v, ":") {
+		return fmt.Errorf("header %q is not Name: value", v)
+	}
+	*h = append(*h, v)
+	return nil
+}
+
+// readBody returns a literal body, the content of @file, or stdin for @-
+func readBody(v string, stdin io.Reader) ([]byte, error) {
+	switch {
+	case v == "@-":
+		return ioutil.ReadAll(stdin)
+	case strings.HasPrefix(v, "@"):
+		return ioutil.ReadFile(v[1:])
+	}
+	return []byte(v), nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/config"
+	"github.com/datastream/aws/credentials"
+	"github.com/datastream/aws/endpoints"
+	"github.com/datastream/aws/transport"
+)
+
+// keyFlags collects repeated ACCESS_KEY:SECRET flags
+type keyFlags map[string]string
+
+func (k keyFlags) String() string {
+	var a []string
+	for key := range k {
+		a = append(a, key)
+	}
+	return strings.Join(a, ",")
+}
+
+func (k keyFlags) Set(v string) error {
+	i := strings.Index(v, ":")
+	if i <= 0 || i == len(v)-1 {
+		// v holds a secret, it isn't echoed
+		return errors.New("key is not ACCESS_KEY:SECRET")
+	}
+	k[v[:i]] = v[i+1:]
+	return nil
+}
+
+// newProxy builds the proxy handler from flags
+func newProxy(args []string) (string, *transport.Proxy, error) {
+	fs := flag.NewFlagSet("proxy", flag.ContinueOnError)
+	var f signFlags
+	f.registerSigning(fs)
+	listen := fs.String("listen", ":8080", "listen address")
+	target := fs.String("target", "", "upstream endpoint URL, e.g. https://search-logs-abc.eu-west-1.es.amazonaws.com")
+	inbound := keyFlags{}
+	fs.Var(inbound, "verify-key", "require inbound requests signed with ACCESS_KEY:SECRET, repeatable")
+	maxFailures := fs.Int("max-failures", 0, "block an access key for a minute after this many failed verifications in a minute, 0 disables")
+	configFile := fs.String("config", "", "JSON or YAML config file, flags override it")
+	if err := fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+	c := &config.Config{}
+	if *configFile != "" {
+		var err error
+		if c, err = config.Load(*configFile); err != nil {
+			return "", nil, err
+		}
+	}
+	if *target == "" {
+		return "", nil, errors.New("missing --target")
+	}
+	u, err := url.Parse(*target)
+	if err != nil || u.Host == "" {
+		return "", nil, fmt.Errorf("invalid --target %q", *target)
+	}
+	if f.region == "" {
+		f.region = c.Region
+	}
+	if f.service == "" {
+		f.service = c.Service
+	}
+	if f.region == "" || f.service == "" {
+		service, region, err := endpoints.Infer(u.Host)
+		if err != nil {
+			return "", nil, fmt.Errorf("%v, set --region and --service", err)
+		}
+		if f.region == "" {
+			f.region = region
+		}
+		if f.service == "" {
+			f.service = service
+		}
+	}
+	var creds credentials.Value
+	if *configFile != "" && f.accessKey == "" && f.secretKey == "" && f.profile == "" {
+		creds, err = c.Credential()
+	} else {
+		creds, err = f.credentials()
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	s := &sign4.Signature{
+		AccessKey: creds.AccessKey,
+		SecretKey: creds.SecretKey,
+		Region:    f.region,
+		Service:   f.service,
+		Options:   sign4.Options{UnbufferedPayload: c.Signing.UnbufferedPayload},
+	}
+	if err := s.Validate(); err != nil {
+		return "", nil, err
+	}
+	p := transport.NewProxy(u, s)
+	p.SessionToken = creds.SessionToken
+	p.Transport.SignedHeaders = c.SignedHeaders()
+	p.Verifier = c.Verifier()
+	if len(inbound) > 0 {
+		p.Verifier = &sign4.Verifier{SecretKey: func(accessKey string) (string, error) {
+			if secret, ok := inbound[accessKey]; ok {
+				return secret, nil
+			}
+			return "", errors.New("unknown access key")
+		}, MaxSkew: time.Duration(c.Verification.MaxSkew)}
+	}
+	if *maxFailures > 0 && p.Verifier != nil {
+		p.Limiter = &transport.FailureLimiter{Failures: *maxFailures}
+	}
+	return *listen, p, nil
+}
+
+func runProxy(args []string, stdin io.Reader, stdout io.Writer) error {
+	listen, p, err := newProxy(args)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "signing proxy on %s to %s\n", listen, p.Target)
+	log.SetOutput(stdout)
+	return http.ListenAndServe(listen, p)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewProxy(t *testing.T) {
+	listen, p, err := newProxy([]string{
+		"--listen", "127.0.0.1:9200", "--target", "https://search-logs-abc.eu-west-1.es.amazonaws.com",
+		"--access-key", "AKIDEXAMPLE", "--secret-key", "secret", "--verify-key", "AKIDCLIENT:client",
+		"--max-failures", "5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := p.Transport.Signature
+	if listen != "127.0.0.1:9200" || s.Service != "es" || s.Region != "eu-west-1" || p.Verifier == nil {
+		t.Fatal("wrong proxy", listen, s)
+	}
+	if p.Limiter == nil || p.Limiter.Failures != 5 {
+		t.Fatal("limiter not set", p.Limiter)
+	}
+	if secret, err := p.Verifier.SecretKey("AKIDCLIENT"); err != nil || secret != "client" {
+		t.Fatal("wrong inbound key", secret, err)
+	}
+	if _, _, err := newProxy([]string{"--access-key", "AKIDEXAMPLE", "--secret-key", "secret"}); err == nil {
+		t.Fatal("proxy without target")
+	}
+}
+
+func TestNewProxyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "proxy.yaml")
+	ioutil.WriteFile(name, []byte("region: eu-west-1\nservice: aoss\ncredentials:\n  access_key: AKIDEXAMPLE\n  secret_key: secret\nverification:\n  max_skew: 5m\n  keys:\n    AKIDCLIENT: client\n"), 0644)
+	_, p, err := newProxy([]string{"--config", name, "--target", "https://abc.eu-west-1.aoss.amazonaws.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Transport.Signature; s.Service != "aoss" || s.AccessKey != "AKIDEXAMPLE" || p.Verifier == nil || p.Verifier.MaxSkew != 5*time.Minute {
+		t.Fatal("config not applied", s, p.Verifier)
+	}
+}
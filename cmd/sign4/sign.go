@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/credentials"
+	"github.com/datastream/aws/endpoints"
+)
+
+// signFlags are shared by the commands building a request
+type signFlags struct {
+	method    string
+	url       string
+	region    string
+	service   string
+	body      string
+	accessKey string
+	secretKey string
+	token     string
+	profile   string
+	headers   headerFlags
+}
+
+func (f *signFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.method, "method", "GET", "HTTP method")
+	fs.StringVar(&f.url, "url", "", "request URL")
+	f.registerSigning(fs)
+	fs.StringVar(&f.body, "body", "", "request body, @file reads a file, @- reads stdin")
+	fs.Var(&f.headers, "H", "extra header \"Name: value\", repeatable")
+}
+
+// registerSigning registers the credential and scope flags
+func (f *signFlags) registerSigning(fs *flag.FlagSet) {
+	fs.StringVar(&f.region, "region", "", "signing region, inferred from the host when empty")
+	fs.StringVar(&f.service, "service", "", "signing service name, inferred from the host when empty")
+	fs.StringVar(&f.accessKey, "access-key", "", "access key id, defaults to the environment or profile")
+	fs.StringVar(&f.secretKey, "secret-key", "", "secret access key")
+	fs.StringVar(&f.token, "session-token", "", "session token")
+	fs.StringVar(&f.profile, "profile", "", "shared credentials file profile")
+}
+
+// credentials returns the flag credentials, falling back to the environment and shared file
+func (f *signFlags) credentials() (credentials.Value, error) {
+	if f.accessKey != "" || f.secretKey != "" {
+		if f.accessKey == "" || f.secretKey == "" {
+			return credentials.Value{}, errors.New("both --access-key and --secret-key are required")
+		}
+		return credentials.Value{AccessKey: f.accessKey, SecretKey: f.secretKey, SessionToken: f.token}, nil
+	}
+	v, err := credentials.Default(f.profile)
+	if err != nil {
+		return v, fmt.Errorf("no credentials: %v", err)
+	}
+	return v, nil
+}
+
+// request builds and signs the request, returning it with its body
+func (f *signFlags) request(stdin io.Reader) (*http.Request, []byte, error) {
+	if f.url == "" {
+		return nil, nil, errors.New("missing url")
+	}
+	creds, err := f.credentials()
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := readBody(f.body, stdin)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := http.NewRequest(strings.ToUpper(f.method), f.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	region, service := f.region, f.service
+	if region == "" || service == "" {
+		inferredService, inferredRegion, err := endpoints.Infer(r.URL.Host)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%v, set --region and --service", err)
+		}
+		if region == "" {
+			region = inferredRegion
+		}
+		if service == "" {
+			service = inferredService
+		}
+	}
+	for _, h := range f.headers {
+		i := strings.Index(h, ":")
+		r.Header.Add(strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:]))
+	}
+	payloadHash, _ := sign4.HexEncodeSHA256Hash(body)
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	s := &sign4.Signature{AccessKey: creds.AccessKey, SecretKey: creds.SecretKey, Region: region, Service: service}
+	if err := s.SignRequest(r, nil); err != nil {
+		return nil, nil, err
+	}
+	return r, body, nil
+}
+
+func runSign(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("sign", flag.ContinueOnError)
+	var f signFlags
+	f.register(fs)
+	curl := fs.Bool("curl", false, "print a complete curl command")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	r, _, err := f.request(stdin)
+	if err != nil {
+		return err
+	}
+	names := []string{"Authorization", "X-Amz-Date", "X-Amz-Content-Sha256", "X-Amz-Security-Token"}
+	if !*curl {
+		for _, name := range names {
+			if v := r.Header.Get(name); v != "" {
+				fmt.Fprintf(stdout, "%s: %s\n", name, v)
+			}
+		}
+		return nil
+	}
+	cmd := []string{"curl", "-X", r.Method}
+	for name, values := range r.Header {
+		for _, v := range values {
+			cmd = append(cmd, "-H", shellQuote(name+": "+v))
+		}
+	}
+	if f.body != "" {
+		data := f.body
+		if !strings.HasPrefix(data, "@") {
+			data = shellQuote(data)
+		}
+		cmd = append(cmd, "--data-binary", data)
+	}
+	cmd = append(cmd, shellQuote(r.URL.String()))
+	fmt.Fprintln(stdout, strings.Join(cmd, " "))
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	var out bytes.Buffer
+	err := runSign([]string{
+		"--method", "put", "--url", "https://bucket.s3.amazonaws.com/key", "--region", "us-east-1", "--service", "s3",
+		"--access-key", "AKIDEXAMPLE", "--secret-key", "secret", "--body", "@-",
+		"-H", "X-Amz-Date: 20110909T233600Z",
+	}, strings.NewReader("hello"), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "Authorization: AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20110909/us-east-1/s3/aws4_request") {
+		t.Fatal("wrong output", out.String())
+	}
+	if lines[2] != "X-Amz-Content-Sha256: 2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Fatal("wrong payload hash", lines[2])
+	}
+}
+
+func TestSignCurl(t *testing.T) {
+	var out bytes.Buffer
+	err := runSign([]string{
+		"--curl", "--method", "POST", "--url", "https://example.execute-api.us-east-1.amazonaws.com/prod/it's", "--region", "us-east-1",
+		"--service", "execute-api", "--access-key", "AKIDEXAMPLE", "--secret-key", "secret", "--body", "{}",
+	}, nil, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := out.String()
+	if !strings.HasPrefix(cmd, "curl -X POST ") || !strings.Contains(cmd, "--data-binary '{}'") || !strings.Contains(cmd, `it'\''s`) {
+		t.Fatal("wrong curl command", cmd)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/sign4test"
+)
+
+func runVectors(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("vectors", flag.ContinueOnError)
+	out := fs.String("out", "", "directory to write the cases to in the aws-sig-v4-test-suite layout, JSON on stdout when empty")
+	matrix := fs.String("matrix", "", "JSON file with the methods, paths, queries, headers and bodies to combine")
+	accessKey := fs.String("access-key", "AKIDEXAMPLE", "access key id")
+	secretKey := fs.String("secret-key", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "secret access key")
+	region := fs.String("region", "us-east-1", "signing region")
+	service := fs.String("service", "service", "signing service name")
+	date := fs.String("date", sign4test.Date.Format(sign4.BasicDateFormat), "signing time")
+	singleEncoding := fs.Bool("single-encoding", false, "encode paths once, the S3 way")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	t, err := time.Parse(sign4.BasicDateFormat, *date)
+	if err != nil {
+		return err
+	}
+	var m sign4test.Matrix
+	if *matrix != "" {
+		data, err := ioutil.ReadFile(*matrix)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+	}
+	s := &sign4.Signature{AccessKey: *accessKey, SecretKey: *secretKey, Region: *region, Service: *service}
+	if *singleEncoding {
+		s.ServiceRules = &sign4.ServiceRules{SingleEncoding: true}
+	}
+	vectors, err := sign4test.GenerateVectors(s, t, m)
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		return sign4test.WriteSuite(*out, vectors)
+	}
+	b, err := sign4test.MarshalVectors(vectors)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(b)
+	return err
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestVectors(t *testing.T) {
+	dir := t.TempDir()
+	matrix := filepath.Join(dir, "matrix.json")
+	ioutil.WriteFile(matrix, []byte(`{"methods": ["GET"], "paths": {"root": "/"}, "queries": {"none": ""}, "headers": {"none": {}}, "bodies": {"empty": ""}}`), 0644)
+	var out bytes.Buffer
+	if err := runVectors([]string{"--matrix", matrix}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	var vectors []struct{ Name, Signature string }
+	if err := json.Unmarshal(out.Bytes(), &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 1 || vectors[0].Signature != "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31" {
+		t.Fatal("wrong vectors", out.String())
+	}
+	if err := runVectors([]string{"--out", dir}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "post-utf8-vanilla-value-trim-form", "post-utf8-vanilla-value-trim-form.creq")); err != nil {
+		t.Fatal("suite not written", err)
+	}
+}
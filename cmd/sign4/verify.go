@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/verify"
+)
+
+// readRequest parses a raw HTTP/1.x request from a file, or stdin for "-"
+func readRequest(name string, stdin io.Reader) (*http.Request, error) {
+	in := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	// captures often use bare \n line endings
+	if !bytes.Contains(data, []byte("\r\n")) {
+		head, body := data, []byte(nil)
+		if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+			head, body = data[:i], data[i+2:]
+		}
+		data = append(bytes.Replace(head, []byte("\n"), []byte("\r\n"), -1), "\r\n\r\n"...)
+		data = append(data, body...)
+	}
+	br := bufio.NewReader(bytes.NewReader(data))
+	r, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 && len(r.TransferEncoding) == 0 && r.Header.Get("Content-Length") == "" {
+		// hand written captures may omit Content-Length, take the rest as body
+		body, _ = ioutil.ReadAll(br)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return r, nil
+}
+
+func runVerify(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	request := fs.String("request", "-", "raw HTTP request file, - reads stdin")
+	secretKey := fs.String("secret-key", os.Getenv("AWS_SECRET_ACCESS_KEY"), "secret access key")
+	expected := fs.String("canonical-request", "", "file with the canonical request the client built, to diff against")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *secretKey == "" {
+		return errors.New("missing --secret-key")
+	}
+	r, err := readRequest(*request, stdin)
+	if err != nil {
+		return err
+	}
+	s, authHeader, headers, err := sign4.GetSignature(r)
+	if err != nil {
+		return err
+	}
+	signedHeaders := make(map[string]bool)
+	for k := range headers {
+		signedHeaders[strings.ToLower(k)] = true
+	}
+	s.SecretKey = *secretKey
+	v := &verify.Verifier{SecretKey: func(string) (string, error) { return *secretKey, nil }}
+	_, verr := v.Verify(r)
+	if verr == nil {
+		fmt.Fprintf(stdout, "signature valid: %s/%s/%s\n", s.AccessKey, s.Region, s.Service)
+		return nil
+	}
+	if verr != sign4.ErrSignatureMismatch {
+		return verr
+	}
+	computed, err := s.GetStringToSign(r, signedHeaders)
+	if err != nil {
+		return err
+	}
+	canonicalRequest := sign4.RedactCanonicalRequest(computed.CanonicalRequest)
+	fmt.Fprintf(stdout, "presented authorization:\n%s\n\n", authHeader)
+	fmt.Fprintf(stdout, "computed canonical request:\n%s\n\n", canonicalRequest)
+	fmt.Fprintf(stdout, "computed string to sign:\n%s\n", computed.StringToSign)
+	if *expected != "" {
+		data, err := ioutil.ReadFile(*expected)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "\ncanonical request diff (- presented, + computed):")
+		diffLines(stdout, sign4.RedactCanonicalRequest(strings.TrimRight(string(data), "\n")), canonicalRequest)
+	}
+	return verr
+}
+
+// diffLines prints the lines of a and b that differ, position by position
+func diffLines(w io.Writer, a, b string) {
+	al, bl := strings.Split(a, "\n"), strings.Split(b, "\n")
+	n := len(al)
+	if len(bl) > n {
+		n = len(bl)
+	}
+	for i := 0; i < n; i++ {
+		var x, y string
+		if i < len(al) {
+			x = al[i]
+		}
+		if i < len(bl) {
+			y = bl[i]
+		}
+		if x == y {
+			fmt.Fprintf(w, "  %s\n", x)
+			continue
+		}
+		fmt.Fprintf(w, "- %s\n+ %s\n", x, y)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestVerify(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "host"}
+	r, _ := http.NewRequest("POST", "http://host.foo.com/path?a=1", strings.NewReader("foo=bar"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.SignRequest(r, nil)
+	raw, _ := httputil.DumpRequest(r, true)
+
+	var out bytes.Buffer
+	if err := runVerify([]string{"--secret-key", "secret"}, bytes.NewReader(raw), &out); err != nil {
+		t.Fatal(err, out.String())
+	}
+	if !strings.HasPrefix(out.String(), "signature valid: AKIDEXAMPLE/us-east-1/host") {
+		t.Fatal("wrong output", out.String())
+	}
+	out.Reset()
+	err := runVerify([]string{"--secret-key", "wrong"}, bytes.NewReader(raw), &out)
+	if err != sign4.ErrSignatureMismatch || !strings.Contains(out.String(), "computed canonical request:\nPOST\n/path\na=1\n") {
+		t.Fatal("mismatch not reported", err, out.String())
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	var out bytes.Buffer
+	diffLines(&out, "GET\n/\nb=1", "GET\n/\na=1")
+	if out.String() != "  GET\n  /\n- b=1\n+ a=1\n" {
+		t.Fatal("wrong diff", out.String())
+	}
+}
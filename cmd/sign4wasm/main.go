@@ -0,0 +1,67 @@
+//go:build js && wasm
+
+// Command sign4wasm presigns requests in the browser, it registers a sign4Presign
+// function on the global object for scripts to call:
+//
+//	GOOS=js GOARCH=wasm go build -o sign4.wasm github.com/datastream/aws/cmd/sign4wasm
+//
+//	const url = sign4Presign({method: "PUT", url: "https://bucket.s3.amazonaws.com/key",
+//		accessKey, secretKey, sessionToken, region: "us-east-1", service: "s3", expires: 900})
+//
+// Presigning needs no network or file system, the URL is computed in the page
+package main
+
+import (
+	"errors"
+	"net/http"
+	"syscall/js"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// presign returns the presigned URL of the request described by the fields of o
+func presign(o js.Value) (string, error) {
+	field := func(name string) string {
+		if v := o.Get(name); v.Type() == js.TypeString {
+			return v.String()
+		}
+		return ""
+	}
+	method := field("method")
+	if method == "" {
+		method = "GET"
+	}
+	expires := 15 * time.Minute
+	if v := o.Get("expires"); v.Type() == js.TypeNumber {
+		expires = time.Duration(v.Int()) * time.Second
+	}
+	s := &sign4.Signature{AccessKey: field("accessKey"), SecretKey: field("secretKey"), SessionToken: field("sessionToken"),
+		Region: field("region"), Service: field("service")}
+	if s.AccessKey == "" || s.SecretKey == "" || s.Region == "" || s.Service == "" {
+		return "", errors.New("accessKey, secretKey, region and service are required")
+	}
+	r, err := http.NewRequest(method, field("url"), nil)
+	if err != nil {
+		return "", err
+	}
+	u, err := s.Presign(r, expires, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func main() {
+	js.Global().Set("sign4Presign", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 1 || args[0].Type() != js.TypeObject {
+			panic(js.Global().Get("Error").New("sign4Presign takes an options object"))
+		}
+		u, err := presign(args[0])
+		if err != nil {
+			panic(js.Global().Get("Error").New(err.Error()))
+		}
+		return u
+	}))
+	select {}
+}
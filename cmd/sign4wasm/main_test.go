@@ -0,0 +1,33 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"strings"
+	"syscall/js"
+	"testing"
+)
+
+func TestPresign(t *testing.T) {
+	o := js.Global().Get("Object").New()
+	o.Set("method", "PUT")
+	o.Set("url", "https://bucket.s3.amazonaws.com/key")
+	o.Set("accessKey", "AKIDEXAMPLE")
+	o.Set("secretKey", "secret")
+	o.Set("sessionToken", "token")
+	o.Set("region", "us-east-1")
+	o.Set("service", "s3")
+	o.Set("expires", 60)
+	u, err := presign(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(u, "https://bucket.s3.amazonaws.com/key?X-Amz-Algorithm=AWS4-HMAC-SHA256") ||
+		!strings.Contains(u, "X-Amz-Expires=60") || !strings.Contains(u, "X-Amz-Security-Token=token") {
+		t.Fatal("wrong url", u)
+	}
+	o.Delete("secretKey")
+	if _, err := presign(o); err == nil {
+		t.Fatal("missing secret accepted")
+	}
+}
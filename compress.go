@@ -0,0 +1,49 @@
+package sign4
+
+// Compressed payloads are hashed as sent, after compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// GzipRequest compresses the body of r and sets Content-Encoding, call it before signing
+// instead of compressing after; bodies that already have a Content-Encoding are left as they
+// are, their encoded bytes are what gets hashed
+func GzipRequest(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody || r.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	compressed := b.Bytes()
+	r.Body = ioutil.NopCloser(bytes.NewReader(compressed))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	r.ContentLength = int64(len(compressed))
+	r.Header.Set("Content-Encoding", "gzip")
+	r.Header.Del("Content-Length")
+	return nil
+}
+
+// GzipPayload sets Options.GzipPayload
+func GzipPayload(on bool) Option {
+	return func(o *Options) {
+		o.GzipPayload = on
+	}
+}
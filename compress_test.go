@@ -0,0 +1,43 @@
+package sign4_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestGzipPayload(t *testing.T) {
+	body := strings.Repeat(`{"metric":"cpu","value":1}`, 100)
+	s := (&sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "es"}).WithOptions(sign4.GzipPayload(true))
+	r, _ := http.NewRequest("POST", "https://search.example.com/_bulk", strings.NewReader(body))
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Get("Content-Encoding") != "gzip" || r.ContentLength >= int64(len(body)) ||
+		!strings.Contains(r.Header.Get("Authorization"), "content-encoding;") {
+		t.Fatal("body not compressed before signing", r.Header, r.ContentLength)
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := r.GetBody()
+	zr, err := gzip.NewReader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadAll(zr); string(data) != body {
+		t.Fatal("wrong decompressed body")
+	}
+
+	// encoded bodies are hashed as they are
+	r, _ = http.NewRequest("POST", "https://search.example.com/_bulk", strings.NewReader("compressed"))
+	r.Header.Set("Content-Encoding", "br")
+	if err := sign4.GzipRequest(r); err != nil || r.ContentLength != int64(len("compressed")) {
+		t.Fatal("encoded body changed", err)
+	}
+}
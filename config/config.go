@@ -0,0 +1,296 @@
+// Package config loads signing, credential and verification settings from JSON or YAML files.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/credentials"
+	"github.com/datastream/aws/endpoints"
+	"github.com/datastream/aws/verify"
+)
+
+// Config describes a signer and the requests it accepts
+type Config struct {
+	Region       string       `json:"region"`
+	Service      string       `json:"service"`
+	Credentials  Credentials  `json:"credentials"`
+	Signing      Signing      `json:"signing"`
+	Verification Verification `json:"verification"`
+}
+
+// Credentials selects where the signing keys come from
+type Credentials struct {
+	// Source is "static", "env", "profile", or empty for the environment then the profile
+	Source       string `json:"source"`
+	Profile      string `json:"profile"`
+	File         string `json:"file"`
+	AccessKey    string `json:"access_key"`
+	SecretKey    string `json:"secret_key"`
+	SessionToken string `json:"session_token"`
+}
+
+type redactedCredentials Credentials
+
+// Format prints c with its secret key and session token redacted
+func (c Credentials) Format(f fmt.State, verb rune) {
+	c.SecretKey, c.SessionToken = sign4.Redact(c.SecretKey), sign4.Redact(c.SessionToken)
+	sign4.FormatRedacted(f, verb, redactedCredentials(c))
+}
+
+// Signing holds the signing options
+type Signing struct {
+	UnbufferedPayload bool `json:"unbuffered_payload"`
+	// SignedHeaders limits the signed headers, every header is signed when empty
+	SignedHeaders []string `json:"signed_headers"`
+}
+
+// Verification is the policy for inbound signed requests
+type Verification struct {
+	// MaxSkew rejects requests dated further from now
+	MaxSkew Duration `json:"max_skew"`
+	// Keys maps the accepted access keys to their secret keys
+	Keys map[string]string `json:"keys"`
+	// Partitions limits the accepted regions to partitions such as "aws" or "aws-cn"
+	Partitions []string `json:"partitions"`
+}
+
+// Duration is a time.Duration written as "5m" or a number of seconds
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string or seconds
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n float64
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("config: invalid duration %s", b)
+		}
+		*d = Duration(n * float64(time.Second))
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON writes the duration string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Parse decodes data in format "json" or "yaml"
+func Parse(data []byte, format string) (*Config, error) {
+	switch format {
+	case "json":
+	case "yaml", "yml":
+		v, err := parseYAML(data)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("config: unknown format %q", format)
+	}
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("config: %v", err)
+	}
+	return c, nil
+}
+
+// Load reads a .json, .yaml or .yml file and applies the environment overrides
+func Load(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data, strings.TrimPrefix(filepath.Ext(filename), "."))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ApplyEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ApplyEnv overrides settings from SIGN4_* variables, e.g. SIGN4_REGION or SIGN4_MAX_SKEW
+func (c *Config) ApplyEnv(getenv func(string) string) error {
+	strs := []struct {
+		name string
+		v    *string
+	}{
+		{"SIGN4_REGION", &c.Region},
+		{"SIGN4_SERVICE", &c.Service},
+		{"SIGN4_CREDENTIALS_SOURCE", &c.Credentials.Source},
+		{"SIGN4_PROFILE", &c.Credentials.Profile},
+		{"SIGN4_CREDENTIALS_FILE", &c.Credentials.File},
+		{"SIGN4_ACCESS_KEY", &c.Credentials.AccessKey},
+		{"SIGN4_SECRET_KEY", &c.Credentials.SecretKey},
+		{"SIGN4_SESSION_TOKEN", &c.Credentials.SessionToken},
+	}
+	for _, s := range strs {
+		if v := getenv(s.name); v != "" {
+			*s.v = v
+		}
+	}
+	if v := getenv("SIGN4_UNBUFFERED_PAYLOAD"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: SIGN4_UNBUFFERED_PAYLOAD: %v", err)
+		}
+		c.Signing.UnbufferedPayload = b
+	}
+	if v := getenv("SIGN4_SIGNED_HEADERS"); v != "" {
+		c.Signing.SignedHeaders = strings.Split(v, ",")
+	}
+	if v := getenv("SIGN4_MAX_SKEW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("config: SIGN4_MAX_SKEW: %v", err)
+		}
+		c.Verification.MaxSkew = Duration(d)
+	}
+	return nil
+}
+
+// Credential resolves the configured key source
+func (c *Config) Credential() (credentials.Value, error) {
+	cr := c.Credentials
+	switch cr.Source {
+	case "static":
+		if cr.AccessKey == "" || cr.SecretKey == "" {
+			return credentials.Value{}, errors.New("config: static credentials need access_key and secret_key")
+		}
+		return credentials.Value{AccessKey: cr.AccessKey, SecretKey: cr.SecretKey, SessionToken: cr.SessionToken}, nil
+	case "env":
+		return credentials.FromEnv()
+	case "profile":
+		return credentials.FromSharedFile(cr.File, cr.Profile)
+	case "":
+		if cr.AccessKey != "" && cr.SecretKey != "" {
+			return credentials.Value{AccessKey: cr.AccessKey, SecretKey: cr.SecretKey, SessionToken: cr.SessionToken}, nil
+		}
+		if cr.File != "" {
+			return credentials.FromSharedFile(cr.File, cr.Profile)
+		}
+		return credentials.Default(cr.Profile)
+	}
+	return credentials.Value{}, fmt.Errorf("config: unknown credentials source %q", cr.Source)
+}
+
+// Validate returns a *sign4.ConfigError naming the config key of the first setting that
+// can't sign or verify, without resolving credentials from the environment or files
+func (c *Config) Validate() error {
+	cr := c.Credentials
+	s := &sign4.Signature{
+		AccessKey: cr.AccessKey,
+		SecretKey: cr.SecretKey,
+		Region:    c.Region,
+		Service:   c.Service,
+		Options:   sign4.Options{UnbufferedPayload: c.Signing.UnbufferedPayload},
+	}
+	if cr.Source != "static" && (cr.Source != "" || cr.AccessKey == "" || cr.SecretKey == "") {
+		// the keys come from the environment or a file when signing
+		s.Provider = credentials.Value{}
+	}
+	switch cr.Source {
+	case "", "static", "env", "profile":
+	default:
+		return &sign4.ConfigError{Field: "credentials.source", Problem: "unknown source " + cr.Source + `, use "static", "env" or "profile"`}
+	}
+	if err := s.Validate(); err != nil {
+		if ce, ok := err.(*sign4.ConfigError); ok {
+			keys := map[string]string{"Region": "region", "Service": "service", "AccessKey": "credentials.access_key", "SecretKey": "credentials.secret_key"}
+			if key, ok := keys[ce.Field]; ok {
+				return &sign4.ConfigError{Field: key, Problem: ce.Problem}
+			}
+		}
+		return err
+	}
+	if c.Verification.MaxSkew < 0 {
+		return &sign4.ConfigError{Field: "verification.max_skew", Problem: "negative, zero disables the check, AWS allows 15m"}
+	}
+	for accessKey, secret := range c.Verification.Keys {
+		if err := sign4.ValidateAccessKey(accessKey); err != nil || secret == "" {
+			return &sign4.ConfigError{Field: "verification.keys", Problem: "access key " + strconv.Quote(accessKey) + " is malformed or has no secret key"}
+		}
+	}
+	for _, id := range c.Verification.Partitions {
+		if _, ok := endpoints.PartitionByID(id); !ok {
+			return &sign4.ConfigError{Field: "verification.partitions", Problem: "unknown partition " + id + ", e.g. aws, aws-cn or aws-us-gov"}
+		}
+	}
+	return nil
+}
+
+// Signature returns the configured signer and its session token
+func (c *Config) Signature() (*sign4.Signature, string, error) {
+	if c.Region == "" || c.Service == "" {
+		return nil, "", errors.New("config: region and service are required")
+	}
+	v, err := c.Credential()
+	if err != nil {
+		return nil, "", err
+	}
+	s := &sign4.Signature{
+		AccessKey: v.AccessKey,
+		SecretKey: v.SecretKey,
+		Region:    c.Region,
+		Service:   c.Service,
+		Options:   sign4.Options{UnbufferedPayload: c.Signing.UnbufferedPayload},
+	}
+	return s, v.SessionToken, nil
+}
+
+// SignedHeaders returns the signed headers set, nil signs every header
+func (c *Config) SignedHeaders() map[string]bool {
+	if len(c.Signing.SignedHeaders) == 0 {
+		return nil
+	}
+	m := make(map[string]bool, len(c.Signing.SignedHeaders))
+	for _, h := range c.Signing.SignedHeaders {
+		m[strings.ToLower(strings.TrimSpace(h))] = true
+	}
+	return m
+}
+
+// Verifier returns a verifier for the configured keys, nil when none are configured
+func (c *Config) Verifier() *verify.Verifier {
+	if len(c.Verification.Keys) == 0 {
+		return nil
+	}
+	keys := c.Verification.Keys
+	return &verify.Verifier{
+		SecretKey: func(accessKey string) (string, error) {
+			if secret, ok := keys[accessKey]; ok {
+				return secret, nil
+			}
+			return "", errors.New("config: unknown access key")
+		},
+		MaxSkew:    time.Duration(c.Verification.MaxSkew),
+		AllowScope: c.allowScope(),
+	}
+}
+
+// allowScope returns the partition allow list, nil when none is configured
+func (c *Config) allowScope() func(region, service string) bool {
+	if len(c.Verification.Partitions) == 0 {
+		return nil
+	}
+	return endpoints.AllowPartitions(c.Verification.Partitions...)
+}
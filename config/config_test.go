@@ -0,0 +1,138 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/config"
+)
+
+const yamlConfig = `# proxy in front of the logs domain
+region: eu-west-1
+service: es
+credentials:
+  source: static
+  access_key: AKIDEXAMPLE
+  secret_key: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+signing:
+  unbuffered_payload: true
+  signed_headers: [host, x-amz-date, content-type]
+verification:
+  max_skew: 5m
+  partitions: [aws]
+  keys:
+    AKIDCLIENT: 'client # secret'
+`
+
+const jsonConfig = `{
+  "region": "eu-west-1",
+  "service": "es",
+  "credentials": {"source": "static", "access_key": "AKIDEXAMPLE", "secret_key": "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+  "signing": {"unbuffered_payload": true, "signed_headers": ["host", "x-amz-date", "content-type"]},
+  "verification": {"max_skew": 300, "partitions": ["aws"], "keys": {"AKIDCLIENT": "client # secret"}}
+}`
+
+func TestParse(t *testing.T) {
+	y, err := config.Parse([]byte(yamlConfig), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := config.Parse([]byte(jsonConfig), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(y, j) {
+		t.Fatalf("yaml and json differ\n%+v\n%+v", y, j)
+	}
+	s, _, err := y.Signature()
+	if err != nil || s.Region != "eu-west-1" || s.Service != "es" || s.AccessKey != "AKIDEXAMPLE" || !s.UnbufferedPayload {
+		t.Fatal("wrong signature", s, err)
+	}
+	if h := y.SignedHeaders(); len(h) != 3 || !h["content-type"] {
+		t.Fatal("wrong signed headers", h)
+	}
+	v := y.Verifier()
+	if v == nil || v.MaxSkew != 5*time.Minute || !v.AllowScope("eu-west-1", "es") || v.AllowScope("cn-north-1", "es") {
+		t.Fatal("wrong verifier", v)
+	}
+	if secret, err := v.SecretKey("AKIDCLIENT"); err != nil || secret != "client # secret" {
+		t.Fatal("wrong verification key", secret, err)
+	}
+	if _, err := v.SecretKey("AKIDOTHER"); err == nil {
+		t.Fatal("unknown key accepted")
+	}
+}
+
+func TestParseYAMLSequences(t *testing.T) {
+	c, err := config.Parse([]byte("signing:\n  signed_headers:\n  - host\n  - 'x-amz-date'\nverification: {}\n"), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Signing.SignedHeaders, []string{"host", "x-amz-date"}) {
+		t.Fatal("wrong sequence", c.Signing.SignedHeaders)
+	}
+	for _, bad := range []string{"region: a\n  service: b\n", "region\n", "region: a\nregion: b\n", "region: \"a\n"} {
+		if _, err := config.Parse([]byte(bad), "yaml"); err == nil {
+			t.Fatalf("%q accepted", bad)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "sign4.yml")
+	ioutil.WriteFile(name, []byte(yamlConfig), 0644)
+	t.Setenv("SIGN4_REGION", "us-west-2")
+	t.Setenv("SIGN4_MAX_SKEW", "1m")
+	t.Setenv("SIGN4_UNBUFFERED_PAYLOAD", "false")
+	c, err := config.Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Region != "us-west-2" || c.Service != "es" || c.Verification.MaxSkew != config.Duration(time.Minute) || c.Signing.UnbufferedPayload {
+		t.Fatalf("env not applied %+v", c)
+	}
+	t.Setenv("SIGN4_MAX_SKEW", "soon")
+	if _, err := config.Load(name); err == nil {
+		t.Fatal("bad duration accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c, err := config.Parse([]byte(yamlConfig), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for key, change := range map[string]func(c *config.Config){
+		"region":                  func(c *config.Config) { c.Region = "" },
+		"credentials.access_key":  func(c *config.Config) { c.Credentials.AccessKey = "AKIDEXAMPLE wJalrXUtnFEMI" },
+		"credentials.source":      func(c *config.Config) { c.Credentials.Source = "vault" },
+		"verification.max_skew":   func(c *config.Config) { c.Verification.MaxSkew = -1 },
+		"verification.keys":       func(c *config.Config) { c.Verification.Keys["AKIDNOSECRET"] = "" },
+		"verification.partitions": func(c *config.Config) { c.Verification.Partitions = []string{"aws-moon"} },
+	} {
+		c, _ := config.Parse([]byte(yamlConfig), "yaml")
+		change(c)
+		var ce *sign4.ConfigError
+		if err := c.Validate(); !errors.As(err, &ce) || ce.Field != key {
+			t.Fatal("wrong error for", key, err)
+		}
+	}
+	c.Credentials = config.Credentials{Source: "env"}
+	if err := c.Validate(); err != nil {
+		t.Fatal("env keys checked", err)
+	}
+}
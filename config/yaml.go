@@ -0,0 +1,236 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The YAML subset used by config files: block maps and sequences, flow sequences,
+// plain, single and double quoted scalars and # comments. Anchors, tags and
+// multi-document streams are not supported.
+
+type yamlLine struct {
+	n      int
+	indent int
+	text   string
+}
+
+var yamlNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+func parseYAML(data []byte) (interface{}, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		text := strings.TrimRight(stripComment(raw), " \t\r")
+		if strings.TrimSpace(text) == "" || text == "---" {
+			continue
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("config: line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{i + 1, len(text) - len(trimmed), trimmed})
+	}
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	p := &yamlParser{lines: lines}
+	v, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("config: line %d: unexpected indentation", p.lines[p.pos].n)
+	}
+	return v, nil
+}
+
+// stripComment removes a # comment outside quotes
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block parses the map or sequence whose lines start at indent
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	l := p.lines[p.pos]
+	if l.text == "-" || strings.HasPrefix(l.text, "- ") {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	a := []interface{}{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || !(l.text == "-" || strings.HasPrefix(l.text, "- ")) {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			v, err := p.child(indent)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+			continue
+		}
+		if _, _, ok := splitKey(rest); ok {
+			// "- key: value" starts a map indented past the dash
+			p.lines[p.pos] = yamlLine{l.n, l.indent + len(l.text) - len(rest), rest}
+			v, err := p.mapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+			continue
+		}
+		v, err := scalar(rest, l.n)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+		p.pos++
+	}
+	return a, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("config: line %d: unexpected indentation", l.n)
+		}
+		key, value, ok := splitKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("config: line %d: expected key: value", l.n)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("config: line %d: duplicate key %q", l.n, key)
+		}
+		p.pos++
+		var v interface{}
+		var err error
+		if value == "" {
+			v, err = p.child(indent)
+		} else {
+			v, err = scalar(value, l.n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// child parses the block nested under a line at indent, or null when there is none;
+// a sequence may sit at the same indent as its parent key
+func (p *yamlParser) child(indent int) (interface{}, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	l := p.lines[p.pos]
+	if l.indent > indent || (l.indent == indent && strings.HasPrefix(l.text, "- ")) {
+		return p.block(l.indent)
+	}
+	return nil, nil
+}
+
+// splitKey splits "key: value" and "key:"
+func splitKey(s string) (string, string, bool) {
+	var key string
+	if s[0] == '"' || s[0] == '\'' {
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 {
+			return "", "", false
+		}
+		key, s = s[1:end+1], s[end+2:]
+		if !strings.HasPrefix(s, ":") {
+			return "", "", false
+		}
+		s = s[1:]
+	} else {
+		i := strings.Index(s, ": ")
+		if i < 0 {
+			if !strings.HasSuffix(s, ":") {
+				return "", "", false
+			}
+			i = len(s) - 1
+		}
+		key, s = s[:i], s[i+1:]
+	}
+	if s != "" && s[0] != ' ' {
+		return "", "", false
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(s), true
+}
+
+func scalar(s string, n int) (interface{}, error) {
+	switch {
+	case s == "":
+		return nil, nil
+	case s[0] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("config: line %d: bad double quoted string", n)
+		}
+		return v, nil
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("config: line %d: bad single quoted string", n)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	case s[0] == '[':
+		if s[len(s)-1] != ']' {
+			return nil, fmt.Errorf("config: line %d: unterminated flow sequence", n)
+		}
+		a := []interface{}{}
+		if inner := strings.TrimSpace(s[1 : len(s)-1]); inner != "" {
+			for _, item := range strings.Split(inner, ",") {
+				v, err := scalar(strings.TrimSpace(item), n)
+				if err != nil {
+					return nil, err
+				}
+				a = append(a, v)
+			}
+		}
+		return a, nil
+	case s == "{}":
+		return map[string]interface{}{}, nil
+	case s == "~" || s == "null":
+		return nil, nil
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	case yamlNumber.MatchString(s):
+		return json.Number(s), nil
+	}
+	return s, nil
+}
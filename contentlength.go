@@ -0,0 +1,74 @@
+package sign4
+
+// Signing Content-Length, which several S3-compatible stores require in SignedHeaders
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// ErrUnknownLength is returned by Options.SignContentLength for a body of unknown length
+// whose payload is signed
+var ErrUnknownLength = errors.New("content-length of the body is unknown")
+
+// SignContentLength sets Options.SignContentLength
+func SignContentLength(on bool) Option {
+	return func(o *Options) {
+		o.SignContentLength = on
+	}
+}
+
+// contentLength returns the length net/http sends the body of r with, the length of a
+// regular file body from its offset is set as r.ContentLength
+func contentLength(r *http.Request) (int64, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return 0, true
+	}
+	for _, te := range r.TransferEncoding {
+		if te == "chunked" {
+			return 0, false
+		}
+	}
+	if r.ContentLength > 0 {
+		return r.ContentLength, true
+	}
+	f, ok := r.Body.(statReaderAt)
+	if !ok {
+		return 0, false
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, false
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false
+	}
+	r.ContentLength = info.Size() - offset
+	return r.ContentLength, true
+}
+
+// signContentLength sets the Content-Length header of r and adds it to signedHeaders
+// when they are limited. A body of unknown length is only signed without the header
+// with an UNSIGNED-PAYLOAD hash
+func signContentLength(r *http.Request, signedHeaders map[string]bool) (map[string]bool, error) {
+	n, ok := contentLength(r)
+	if !ok {
+		if r.Header.Get("X-Amz-Content-Sha256") == unsignedPayload {
+			return signedHeaders, nil
+		}
+		return nil, ErrUnknownLength
+	}
+	r.Header.Set("Content-Length", strconv.FormatInt(n, 10))
+	if len(signedHeaders) == 0 || signedHeaders["content-length"] {
+		return signedHeaders, nil
+	}
+	m := make(map[string]bool, len(signedHeaders)+1)
+	for k, v := range signedHeaders {
+		m[k] = v
+	}
+	m["content-length"] = true
+	return m, nil
+}
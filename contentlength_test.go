@@ -0,0 +1,64 @@
+package sign4_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestSignContentLength(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "s3"}
+	s = s.WithOptions(sign4.SignContentLength(true))
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.Verify(r); err != nil {
+			t.Error("verification failed", err)
+		}
+		_, _, signed, _ := sign4.GetSignature(r)
+		if !signed["content-length"] {
+			t.Error("content-length not signed", r.Header.Get("Authorization"))
+		}
+	}))
+	defer server.Close()
+
+	r, _ := http.NewRequest("PUT", server.URL+"/bucket/key", strings.NewReader("hello"))
+	if err := s.SignRequest(r, map[string]bool{"host": true, "x-amz-date": true, "x-amz-content-sha256": true}); err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Get("Content-Length") != "5" {
+		t.Fatal("wrong content-length", r.Header)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	f, _ := ioutil.TempFile("", "sign4")
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.WriteString("0123456789")
+	f.Seek(4, io.SeekStart)
+	r, _ = http.NewRequest("PUT", server.URL+"/bucket/file", f)
+	if err := s.SignRequest(r, nil); err != nil || r.ContentLength != 6 || r.Header.Get("Content-Length") != "6" {
+		t.Fatal("wrong file length", err, r.ContentLength, r.Header)
+	}
+
+	streaming := s.WithOptions(sign4.UseChunkedPolicy(sign4.ChunkedReject))
+	r, _ = http.NewRequest("PUT", server.URL+"/bucket/stream", ioutil.NopCloser(strings.NewReader("data")))
+	r.Header.Set("X-Amz-Content-Sha256", "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7")
+	if err := streaming.SignRequest(r, nil); err != sign4.ErrUnknownLength {
+		t.Fatal("signed payload of unknown length", err)
+	}
+	r, _ = http.NewRequest("PUT", server.URL+"/bucket/stream", ioutil.NopCloser(strings.NewReader("data")))
+	r.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	if err := streaming.SignRequest(r, nil); err != nil || r.Header.Get("Content-Length") != "" {
+		t.Fatal("unsigned payload of unknown length", err, r.Header)
+	}
+}
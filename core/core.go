@@ -0,0 +1,332 @@
+// Package core is the Signature Version 4 computation of sign4 without net/http,
+// time, fmt or reflection, for firmware built with TinyGo signing requests to AWS
+// IoT or Kinesis. Requests are described by their components and the signature is
+// appended to caller buffers, a Signer allocates only when it derives the key of
+// a new day or grows its buffer.
+//
+//	s := &core.Signer{AccessKey: id, SecretKey: secret, Region: "us-east-1", Service: "iotdata"}
+//	r := &core.Request{Method: "POST", Path: "/topics/sensors", Query: []core.Param{{Name: "qos", Value: "1"}},
+//		Headers: []core.Header{{Name: "host", Value: endpoint}, {Name: "x-amz-date", Value: amzDate}},
+//		PayloadHash: core.PayloadHash(&sum, body)}
+//	auth, err := s.AppendAuthorization(auth[:0], r, amzDate)
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"hash"
+)
+
+// Algorithm is the algorithm of the signatures of this package
+const Algorithm = "AWS4-HMAC-SHA256"
+
+// EmptyPayloadHash is the hex sha256 of an empty payload
+const EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// ErrDate is returned for a date that isn't in the basic format 20060102T150405Z
+var ErrDate = errors.New("date is not in the basic format")
+
+// Header is a header to sign, every header of Request.Headers is signed
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Param is a query parameter, escaped the way it is sent, see AppendQueryEscape
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Request holds the components of a request. Path is the escaped path as sent,
+// it isn't normalized. Headers must include host, signing sorts Headers and Query
+// in place so they are not copied. The values of a repeated header are joined in
+// the order given, as sign4 does with Options.StrictHeaderValues
+type Request struct {
+	Method      string
+	Path        string
+	Query       []Param
+	Headers     []Header
+	PayloadHash string
+}
+
+// PayloadHash returns the hex sha256 of body, sum holds the digits
+func PayloadHash(sum *[sha256.Size * 2]byte, body []byte) string {
+	digest := sha256.Sum256(body)
+	encodeHex(sum[:], digest[:])
+	return string(sum[:])
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func lowerByte(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+const hexUpper = "0123456789ABCDEF"
+
+// encodeHex writes the lower case hex digits of src to dst, encoding/hex is left
+// out for the fmt it imports
+func encodeHex(dst, src []byte) {
+	const digits = "0123456789abcdef"
+	for i, c := range src {
+		dst[2*i], dst[2*i+1] = digits[c>>4], digits[c&15]
+	}
+}
+
+// AppendQueryEscape appends s escaped for a query name or value, every byte
+// except the unreserved ones is percent encoded
+func AppendQueryEscape(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isUnreserved(c) {
+			dst = append(dst, c)
+			continue
+		}
+		dst = append(dst, '%', hexUpper[c>>4], hexUpper[c&15])
+	}
+	return dst
+}
+
+// foldLess orders header names case-insensitively
+func foldLess(a, b string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ca, cb := lowerByte(a[i]), lowerByte(b[i])
+		if ca != cb {
+			return ca < cb
+		}
+	}
+	return len(a) < len(b)
+}
+
+func foldEqual(a, b string) bool {
+	return !foldLess(a, b) && !foldLess(b, a)
+}
+
+// paramLess orders parameters the way their name=value strings sort
+func paramLess(a, b Param) bool {
+	if a.Name == b.Name {
+		return a.Value < b.Value
+	}
+	for i := 0; i < len(a.Name) && i < len(b.Name); i++ {
+		if a.Name[i] != b.Name[i] {
+			return a.Name[i] < b.Name[i]
+		}
+	}
+	if len(a.Name) < len(b.Name) {
+		return '=' < b.Name[len(a.Name)]
+	}
+	return a.Name[len(b.Name)] < '='
+}
+
+// sortHeaders and sortParams are insertion sorts, stable and without the
+// reflection of package sort
+func sortHeaders(h []Header) {
+	for i := 1; i < len(h); i++ {
+		for j := i; j > 0 && foldLess(h[j].Name, h[j-1].Name); j-- {
+			h[j], h[j-1] = h[j-1], h[j]
+		}
+	}
+}
+
+func sortParams(p []Param) {
+	for i := 1; i < len(p); i++ {
+		for j := i; j > 0 && paramLess(p[j], p[j-1]); j-- {
+			p[j], p[j-1] = p[j-1], p[j]
+		}
+	}
+}
+
+// appendFolded appends v trimmed with its runs of spaces folded to one
+func appendFolded(dst []byte, v string) []byte {
+	start, end := 0, len(v)
+	for start < end && (v[start] == ' ' || v[start] == '\t') {
+		start++
+	}
+	for end > start && (v[end-1] == ' ' || v[end-1] == '\t') {
+		end--
+	}
+	for i := start; i < end; i++ {
+		if v[i] == ' ' && i > start && v[i-1] == ' ' {
+			continue
+		}
+		dst = append(dst, v[i])
+	}
+	return dst
+}
+
+// AppendSignedHeaders appends the ';' separated names of the sorted headers
+func AppendSignedHeaders(dst []byte, headers []Header) []byte {
+	for i, h := range headers {
+		if i > 0 && foldEqual(h.Name, headers[i-1].Name) {
+			continue
+		}
+		if i > 0 {
+			dst = append(dst, ';')
+		}
+		for j := 0; j < len(h.Name); j++ {
+			dst = append(dst, lowerByte(h.Name[j]))
+		}
+	}
+	return dst
+}
+
+// AppendCanonicalRequest sorts the headers and query of r and appends its canonical
+// request, singleEncoding keeps the path as sent the way S3 signs it while other
+// services encode it once more
+func AppendCanonicalRequest(dst []byte, r *Request, singleEncoding bool) []byte {
+	sortHeaders(r.Headers)
+	sortParams(r.Query)
+	dst = append(dst, r.Method...)
+	dst = append(dst, '\n')
+	path := r.Path
+	if path == "" {
+		path = "/"
+	}
+	for i := 0; i < len(path); i++ {
+		if c := path[i]; singleEncoding || isUnreserved(c) || c == '/' {
+			dst = append(dst, c)
+		} else {
+			dst = append(dst, '%', hexUpper[c>>4], hexUpper[c&15])
+		}
+	}
+	dst = append(dst, '\n')
+	for i, p := range r.Query {
+		if i > 0 {
+			dst = append(dst, '&')
+		}
+		dst = append(dst, p.Name...)
+		dst = append(dst, '=')
+		dst = append(dst, p.Value...)
+	}
+	dst = append(dst, '\n')
+	for i, h := range r.Headers {
+		if i > 0 && foldEqual(h.Name, r.Headers[i-1].Name) {
+			dst = append(dst[:len(dst)-1], ',')
+		} else {
+			for j := 0; j < len(h.Name); j++ {
+				dst = append(dst, lowerByte(h.Name[j]))
+			}
+			dst = append(dst, ':')
+		}
+		dst = appendFolded(dst, h.Value)
+		dst = append(dst, '\n')
+	}
+	dst = append(dst, '\n')
+	dst = AppendSignedHeaders(dst, r.Headers)
+	dst = append(dst, '\n')
+	return append(dst, r.PayloadHash...)
+}
+
+// checkDate reports whether amzDate is in the basic format
+func checkDate(amzDate string) error {
+	if len(amzDate) != 16 || amzDate[8] != 'T' || amzDate[15] != 'Z' {
+		return ErrDate
+	}
+	for i := 0; i < 15; i++ {
+		if c := amzDate[i]; i != 8 && (c < '0' || c > '9') {
+			return ErrDate
+		}
+	}
+	return nil
+}
+
+// AppendScope appends the credential scope of the day of amzDate
+func AppendScope(dst []byte, amzDate, region, service string) []byte {
+	dst = append(dst, amzDate[:8]...)
+	dst = append(dst, '/')
+	dst = append(dst, region...)
+	dst = append(dst, '/')
+	dst = append(dst, service...)
+	return append(dst, "/aws4_request"...)
+}
+
+// AppendStringToSign appends the string to sign of a canonical request
+func AppendStringToSign(dst []byte, canonicalRequest []byte, amzDate, region, service string) []byte {
+	digest := sha256.Sum256(canonicalRequest)
+	var digits [sha256.Size * 2]byte
+	encodeHex(digits[:], digest[:])
+	dst = append(dst, Algorithm+"\n"...)
+	dst = append(dst, amzDate...)
+	dst = append(dst, '\n')
+	dst = AppendScope(dst, amzDate, region, service)
+	dst = append(dst, '\n')
+	return append(dst, digits[:]...)
+}
+
+// DeriveKey computes the signing key of a day, date is yyyymmdd
+func DeriveKey(key *[sha256.Size]byte, secretKey, date, region, service string) {
+	mac := hmac.New(sha256.New, []byte("AWS4"+secretKey))
+	mac.Write([]byte(date))
+	mac.Sum(key[:0])
+	for _, data := range [...]string{region, service, "aws4_request"} {
+		mac = hmac.New(sha256.New, key[:])
+		mac.Write([]byte(data))
+		mac.Sum(key[:0])
+	}
+}
+
+// Signer signs with one key and scope, it keeps the derived key of the last day
+// and its buffer between calls and is not safe for concurrent use
+type Signer struct {
+	AccessKey string
+	SecretKey string
+	Region    string
+	Service   string
+	// SingleEncoding keeps paths as sent, for S3
+	SingleEncoding bool
+
+	date [8]byte
+	key  [sha256.Size]byte
+	mac  hash.Hash
+	sum  [sha256.Size]byte
+	buf  []byte
+	sts  []byte
+}
+
+// keyed returns the hmac of the day of amzDate
+func (s *Signer) keyed(amzDate string) hash.Hash {
+	if s.mac == nil || string(s.date[:]) != amzDate[:8] {
+		DeriveKey(&s.key, s.SecretKey, amzDate[:8], s.Region, s.Service)
+		copy(s.date[:], amzDate[:8])
+		s.mac = hmac.New(sha256.New, s.key[:])
+	}
+	s.mac.Reset()
+	return s.mac
+}
+
+// AppendSignature appends the hex signature of a string to sign dated amzDate
+func (s *Signer) AppendSignature(dst, stringToSign []byte, amzDate string) ([]byte, error) {
+	if err := checkDate(amzDate); err != nil {
+		return dst, err
+	}
+	mac := s.keyed(amzDate)
+	mac.Write(stringToSign)
+	var digits [sha256.Size * 2]byte
+	encodeHex(digits[:], mac.Sum(s.sum[:0]))
+	return append(dst, digits[:]...), nil
+}
+
+// AppendAuthorization appends the Authorization header value of r dated amzDate,
+// the date has to be sent in the x-amz-date header of r
+func (s *Signer) AppendAuthorization(dst []byte, r *Request, amzDate string) ([]byte, error) {
+	if err := checkDate(amzDate); err != nil {
+		return dst, err
+	}
+	s.buf = AppendCanonicalRequest(s.buf[:0], r, s.SingleEncoding)
+	s.sts = AppendStringToSign(s.sts[:0], s.buf, amzDate, s.Region, s.Service)
+	dst = append(dst, Algorithm+" Credential="...)
+	dst = append(dst, s.AccessKey...)
+	dst = append(dst, '/')
+	dst = AppendScope(dst, amzDate, s.Region, s.Service)
+	dst = append(dst, ", SignedHeaders="...)
+	dst = AppendSignedHeaders(dst, r.Headers)
+	dst = append(dst, ", Signature="...)
+	return s.AppendSignature(dst, s.sts, amzDate)
+}
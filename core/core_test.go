@@ -0,0 +1,73 @@
+package core_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/core"
+)
+
+func TestAppendAuthorization(t *testing.T) {
+	s := &core.Signer{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Region: "us-east-1", Service: "service"}
+	r := &core.Request{Method: "GET", Path: "/", Headers: []core.Header{
+		{Name: "X-Amz-Date", Value: "20150830T123600Z"}, {Name: "Host", Value: "example.amazonaws.com"}}, PayloadHash: core.EmptyPayloadHash}
+	auth, err := s.AppendAuthorization(nil, r, "20150830T123600Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the get-vanilla case of the published suite
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if string(auth) != want {
+		t.Fatalf("wrong authorization\n%s\n%s", auth, want)
+	}
+	if _, err := s.AppendAuthorization(nil, r, "2015-08-30"); err != core.ErrDate {
+		t.Fatal("wrong date accepted", err)
+	}
+	if n := testing.AllocsPerRun(100, func() { auth, _ = s.AppendAuthorization(auth[:0], r, "20150830T123600Z") }); n > hmacAllocs {
+		t.Fatal("AppendAuthorization allocations", n)
+	}
+}
+
+func TestMatchesSign4(t *testing.T) {
+	body := []byte(`{"temperature": 21}`)
+	var sum [64]byte
+	hr, _ := http.NewRequest("POST", "https://data-ats.iot.us-east-1.amazonaws.com/topics/a%20b/c?qos=1&retain=false&a-b=x", bytes.NewReader(body))
+	hr.Header.Set("X-Amz-Date", "20240102T030405Z")
+	hr.Header.Set("Content-Type", "application/json")
+	hr.Header.Add("X-Amz-Meta-List", "b")
+	hr.Header.Add("X-Amz-Meta-List", "  a   c ")
+	hr.Header.Set("X-Amz-Content-Sha256", core.PayloadHash(&sum, body))
+	signature := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "iotdata",
+		Options: sign4.Options{StrictHeaderValues: true}}
+	if err := signature.SignRequest(hr, nil); err != nil {
+		t.Fatal(err)
+	}
+	s := &core.Signer{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "iotdata"}
+	r := &core.Request{
+		Method: "POST",
+		Path:   "/topics/a%20b/c",
+		Query:  []core.Param{{Name: "retain", Value: "false"}, {Name: "qos", Value: "1"}, {Name: "a-b", Value: "x"}},
+		Headers: []core.Header{
+			{Name: "host", Value: "data-ats.iot.us-east-1.amazonaws.com"},
+			{Name: "x-amz-date", Value: "20240102T030405Z"},
+			{Name: "content-type", Value: "application/json"},
+			{Name: "x-amz-meta-list", Value: "b"},
+			{Name: "x-amz-meta-list", Value: "  a   c "},
+			{Name: "x-amz-content-sha256", Value: hr.Header.Get("X-Amz-Content-Sha256")},
+		},
+		PayloadHash: core.PayloadHash(&sum, body),
+	}
+	auth, err := s.AppendAuthorization(nil, r, "20240102T030405Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(auth) != hr.Header.Get("Authorization") {
+		t.Fatalf("differs from sign4\n%s\n%s", auth, hr.Header.Get("Authorization"))
+	}
+	if got := string(core.AppendQueryEscape(nil, "a b/ሴ")); got != "a%20b%2F%E1%88%B4" {
+		t.Fatal("wrong escape", got)
+	}
+}
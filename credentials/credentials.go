@@ -0,0 +1,148 @@
+// Package credentials loads AWS credentials from the environment, the shared credentials file,
+// the keychain of the operating system and files encrypted at rest.
+package credentials
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/datastream/aws"
+)
+
+// Value is a set of credentials
+type Value struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+type redactedValue Value
+
+// Format prints v with its secret key and session token redacted
+func (v Value) Format(f fmt.State, verb rune) {
+	v.SecretKey, v.SessionToken = sign4.Redact(v.SecretKey), sign4.Redact(v.SessionToken)
+	sign4.FormatRedacted(f, verb, redactedValue(v))
+}
+
+// ErrNotFound is returned when a source holds no credentials
+var ErrNotFound = errors.New("credentials: not found")
+
+// FromEnv reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+func FromEnv() (Value, error) {
+	v := Value{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if v.AccessKey == "" {
+		v.AccessKey = os.Getenv("AWS_ACCESS_KEY")
+	}
+	if v.SecretKey == "" {
+		v.SecretKey = os.Getenv("AWS_SECRET_KEY")
+	}
+	if v.AccessKey == "" || v.SecretKey == "" {
+		return Value{}, ErrNotFound
+	}
+	return v, nil
+}
+
+// SharedFilename returns AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials
+func SharedFilename() string {
+	if name := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); name != "" {
+		return name
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", "credentials")
+}
+
+// Profile returns AWS_PROFILE or "default"
+func Profile() string {
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		return p
+	}
+	return "default"
+}
+
+// FromSharedFile reads a profile of an ini style credentials file, empty arguments use SharedFilename and Profile
+func FromSharedFile(filename, profile string) (Value, error) {
+	if filename == "" {
+		filename = SharedFilename()
+	}
+	if profile == "" {
+		profile = Profile()
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Value{}, ErrNotFound
+		}
+		return Value{}, err
+	}
+	defer f.Close()
+	var v Value
+	found := false
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			section = strings.TrimPrefix(section, "profile ")
+			if section == profile {
+				found = true
+			}
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:i])), strings.TrimSpace(line[i+1:])
+		switch key {
+		case "aws_access_key_id":
+			v.AccessKey = value
+		case "aws_secret_access_key":
+			v.SecretKey = value
+		case "aws_session_token", "aws_security_token":
+			v.SessionToken = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Value{}, err
+	}
+	if !found {
+		return Value{}, fmt.Errorf("credentials: profile %q not found in %s", profile, filename)
+	}
+	if v.AccessKey == "" || v.SecretKey == "" {
+		return Value{}, fmt.Errorf("credentials: profile %q has no access key", profile)
+	}
+	return v, nil
+}
+
+// Default tries the environment, then the shared credentials file profile
+func Default(profile string) (Value, error) {
+	if profile == "" {
+		if v, err := FromEnv(); err == nil {
+			return v, nil
+		}
+	}
+	return FromSharedFile("", profile)
+}
+
+// Credentials returns v as signing keys, a Value is a sign4.CredentialsProvider
+func (v Value) Credentials() (sign4.Credentials, error) {
+	return sign4.Credentials{AccessKey: v.AccessKey, SecretKey: v.SecretKey, SessionToken: v.SessionToken}, nil
+}
@@ -0,0 +1,74 @@
+package credentials_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/credentials"
+)
+
+func TestFromSharedFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "credentials")
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "credentials")
+	ioutil.WriteFile(name, []byte(`
+[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = secret1
+
+; comment
+[dev]
+aws_access_key_id=AKIDDEV
+aws_secret_access_key=secret2
+aws_session_token=token
+`), 0600)
+	v, err := credentials.FromSharedFile(name, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.AccessKey != "AKIDDEV" || v.SecretKey != "secret2" || v.SessionToken != "token" {
+		t.Fatal("wrong credentials", v)
+	}
+	v, err = credentials.FromSharedFile(name, "default")
+	if err != nil || v.AccessKey != "AKIDDEFAULT" || v.SessionToken != "" {
+		t.Fatal("wrong default credentials", v, err)
+	}
+	if _, err := credentials.FromSharedFile(name, "missing"); err == nil {
+		t.Fatal("missing profile found")
+	}
+	if _, err := credentials.FromSharedFile(filepath.Join(dir, "none"), "dev"); err != credentials.ErrNotFound {
+		t.Fatal("expected not found", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	v, err := credentials.FromEnv()
+	if err != nil || v.AccessKey != "AKIDENV" || v.SecretKey != "secret" {
+		t.Fatal("wrong env credentials", v, err)
+	}
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SECRET_KEY", "")
+	if _, err := credentials.FromEnv(); err != credentials.ErrNotFound {
+		t.Fatal("expected not found", err)
+	}
+}
+
+func TestValueProvider(t *testing.T) {
+	s := &sign4.Signature{Provider: credentials.Value{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", SessionToken: "token"}}
+	c, err := s.Credentials()
+	if err != nil || c.AccessKey != "AKIDEXAMPLE" || c.SessionToken != "token" {
+		t.Fatal("wrong provider credentials", c, err)
+	}
+	v := credentials.Value{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", SessionToken: "token"}
+	if out := fmt.Sprintf("%+v %#v", v, v); strings.Contains(out, "secret") || strings.Contains(out, "token") || !strings.Contains(out, "AKIDEXAMPLE") {
+		t.Fatal("formatted credentials not redacted", out)
+	}
+}
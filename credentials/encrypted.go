@@ -0,0 +1,245 @@
+package credentials
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/jsonrpc"
+)
+
+// KeyWrapper encrypts the data key of an encrypted credentials file
+type KeyWrapper interface {
+	WrapKey(dataKey []byte) ([]byte, error)
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// ErrDecrypt is returned for a file that doesn't decrypt with the key it is read with
+var ErrDecrypt = errors.New("credentials: wrong key or corrupt file")
+
+// encryptedFile is the JSON layout of an encrypted credentials file, the credentials are
+// sealed with AES-256-GCM under a random data key stored wrapped
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// WriteEncryptedFile writes v encrypted to filename with mode 0600, replacing it atomically
+func WriteEncryptedFile(filename string, v Value, key KeyWrapper) error {
+	plaintext, err := json.Marshal(keychainSecret{AccessKey: v.AccessKey, SecretKey: v.SecretKey, SessionToken: v.SessionToken})
+	if err != nil {
+		return err
+	}
+	defer sign4.Zeroize(plaintext)
+	dataKey := make([]byte, 32)
+	defer sign4.Zeroize(dataKey)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	f := encryptedFile{Version: 1}
+	if f.Nonce, f.Ciphertext, err = seal(dataKey, plaintext); err != nil {
+		return err
+	}
+	if f.WrappedKey, err = key.WrapKey(dataKey); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".credentials")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// FromEncryptedFile decrypts a file of WriteEncryptedFile, ErrNotFound when it doesn't exist.
+// Decrypt once at startup, the returned Value is a sign4.CredentialsProvider
+func FromEncryptedFile(filename string, key KeyWrapper) (Value, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Value{}, ErrNotFound
+		}
+		return Value{}, err
+	}
+	var f encryptedFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return Value{}, err
+	}
+	if f.Version != 1 {
+		return Value{}, errors.New("credentials: unknown encrypted file version")
+	}
+	dataKey, err := key.UnwrapKey(f.WrappedKey)
+	if err != nil {
+		return Value{}, err
+	}
+	defer sign4.Zeroize(dataKey)
+	plaintext, err := open(dataKey, f.Nonce, f.Ciphertext)
+	if err != nil {
+		return Value{}, err
+	}
+	defer sign4.Zeroize(plaintext)
+	var s keychainSecret
+	if err := json.Unmarshal(plaintext, &s); err != nil {
+		return Value{}, err
+	}
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return Value{}, errors.New("credentials: encrypted file has no access key")
+	}
+	return Value{AccessKey: s.AccessKey, SecretKey: s.SecretKey, SessionToken: s.SessionToken}, nil
+}
+
+func seal(key, plaintext []byte) (nonce, ciphertext []byte, err error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, aead.Seal(nil, nonce, plaintext, nil), nil
+}
+
+func open(key, nonce, ciphertext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return cipher.NewGCM(block)
+}
+
+// PassphraseIterations are the PBKDF2-HMAC-SHA256 iterations a Passphrase derives its key with
+const PassphraseIterations = 600000
+
+// Passphrase wraps data keys with a key derived from a passphrase by PBKDF2-HMAC-SHA256,
+// the salt and iteration count are stored with the wrapped key
+type Passphrase string
+
+// WrapKey seals dataKey under the key of p and a new salt
+func (p Passphrase) WrapKey(dataKey []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	passphrase := []byte(p)
+	defer sign4.Zeroize(passphrase)
+	wrapKey := pbkdf2(passphrase, salt, PassphraseIterations)
+	defer sign4.Zeroize(wrapKey)
+	nonce, ciphertext, err := seal(wrapKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 4, 4+len(salt)+len(nonce)+len(ciphertext))
+	binary.BigEndian.PutUint32(b, PassphraseIterations)
+	b = append(b, salt...)
+	b = append(b, nonce...)
+	return append(b, ciphertext...), nil
+}
+
+// UnwrapKey opens a key of WrapKey
+func (p Passphrase) UnwrapKey(wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 4+16+12 {
+		return nil, ErrDecrypt
+	}
+	iterations := int(binary.BigEndian.Uint32(wrapped))
+	if iterations < 1 || iterations > 100*PassphraseIterations {
+		return nil, ErrDecrypt
+	}
+	salt, nonce := wrapped[4:20], wrapped[20:32]
+	passphrase := []byte(p)
+	defer sign4.Zeroize(passphrase)
+	wrapKey := pbkdf2(passphrase, salt, iterations)
+	defer sign4.Zeroize(wrapKey)
+	return open(wrapKey, nonce, wrapped[32:])
+}
+
+// pbkdf2 derives a 32 byte key, RFC 8018 with HMAC-SHA256 for one block
+func pbkdf2(password, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, password)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	sign4.Zeroize(u)
+	return key
+}
+
+// KMSKey wraps data keys with the KMS key KeyID, for hosts whose role may call kms:Encrypt
+// and kms:Decrypt but shouldn't keep the signing keys in the clear
+type KMSKey struct {
+	Client *jsonrpc.Client
+	KeyID  string
+}
+
+// NewKMSKey returns a wrapper calling KMS signed with s
+func NewKMSKey(s *sign4.Signature, keyID string) *KMSKey {
+	return &KMSKey{Client: jsonrpc.NewClient(s, "kms", "TrentService", "1.1"), KeyID: keyID}
+}
+
+// WrapKey encrypts dataKey with KMS
+func (k *KMSKey) WrapKey(dataKey []byte) ([]byte, error) {
+	var out struct {
+		CiphertextBlob []byte
+	}
+	err := k.Client.Do(context.Background(), "Encrypt", map[string]interface{}{"KeyId": k.KeyID, "Plaintext": dataKey}, &out)
+	return out.CiphertextBlob, err
+}
+
+// UnwrapKey decrypts a key of WrapKey with KMS
+func (k *KMSKey) UnwrapKey(wrapped []byte) ([]byte, error) {
+	var out struct {
+		Plaintext []byte
+	}
+	err := k.Client.Do(context.Background(), "Decrypt", map[string]interface{}{"KeyId": k.KeyID, "CiphertextBlob": wrapped}, &out)
+	return out.Plaintext, err
+}
@@ -0,0 +1,83 @@
+package credentials_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/credentials"
+)
+
+func TestEncryptedFilePassphrase(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "credentials")
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "credentials.enc")
+	v := credentials.Value{AccessKey: "AKIDEDGE", SecretKey: "secret", SessionToken: "token"}
+	if err := credentials.WriteEncryptedFile(name, v, credentials.Passphrase("correct horse")); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(name)
+	if strings.Contains(string(b), "AKIDEDGE") || strings.Contains(string(b), "secret") {
+		t.Fatal("credentials stored in the clear", string(b))
+	}
+	if fi, _ := os.Stat(name); fi.Mode().Perm() != 0600 {
+		t.Fatal("wrong file mode", fi.Mode())
+	}
+	got, err := credentials.FromEncryptedFile(name, credentials.Passphrase("correct horse"))
+	if err != nil || got != v {
+		t.Fatal("wrong decrypted credentials", got, err)
+	}
+	if _, err := credentials.FromEncryptedFile(name, credentials.Passphrase("wrong")); err != credentials.ErrDecrypt {
+		t.Fatal("wrong passphrase decrypted", err)
+	}
+	if _, err := credentials.FromEncryptedFile(filepath.Join(dir, "none"), credentials.Passphrase("x")); err != credentials.ErrNotFound {
+		t.Fatal("expected not found", err)
+	}
+}
+
+func TestEncryptedFileKMS(t *testing.T) {
+	// a fake KMS whose ciphertext is the plaintext reversed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			KeyID          string `json:"KeyId"`
+			Plaintext      []byte
+			CiphertextBlob []byte
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		if in.KeyID != "alias/edge" || !strings.Contains(r.Header.Get("Authorization"), "/kms/aws4_request") {
+			t.Error("wrong request", in.KeyID, r.Header)
+		}
+		reverse := func(b []byte) []byte {
+			out := make([]byte, len(b))
+			for i := range b {
+				out[len(b)-1-i] = b[i]
+			}
+			return out
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			json.NewEncoder(w).Encode(map[string][]byte{"CiphertextBlob": reverse(in.Plaintext)})
+		case "TrentService.Decrypt":
+			json.NewEncoder(w).Encode(map[string][]byte{"Plaintext": reverse(in.CiphertextBlob)})
+		}
+	}))
+	defer server.Close()
+	key := credentials.NewKMSKey(&sign4.Signature{AccessKey: "AKIDROLE", SecretKey: "secret", Region: "us-east-1"}, "alias/edge")
+	key.Client.Endpoint = server.URL
+	dir, _ := ioutil.TempDir("", "credentials")
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "credentials.enc")
+	if err := credentials.WriteEncryptedFile(name, credentials.Value{AccessKey: "AKIDEDGE", SecretKey: "secret"}, key); err != nil {
+		t.Fatal(err)
+	}
+	v, err := credentials.FromEncryptedFile(name, key)
+	if err != nil || v.AccessKey != "AKIDEDGE" || v.SecretKey != "secret" {
+		t.Fatal("wrong decrypted credentials", v, err)
+	}
+}
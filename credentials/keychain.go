@@ -0,0 +1,97 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/datastream/aws"
+)
+
+// Keychain keeps the credentials of a profile in the keychain of the operating system:
+// the macOS Keychain through security(1), the Windows Credential Manager, or a
+// freedesktop Secret Service through secret-tool(1) elsewhere. The keys are stored as
+// one JSON secret, so CLI tools don't need a plaintext credentials file
+type Keychain struct {
+	// Service names the entries, "sign4" when empty
+	Service string
+	// Profile is the account of the entry, Profile() when empty
+	Profile string
+}
+
+// ErrKeychainUnsupported is returned on systems without a supported keychain
+var ErrKeychainUnsupported = errors.New("credentials: no supported keychain")
+
+type keychainSecret struct {
+	AccessKey    string `json:"aws_access_key_id"`
+	SecretKey    string `json:"aws_secret_access_key"`
+	SessionToken string `json:"aws_session_token,omitempty"`
+}
+
+func (k Keychain) names() (service, account string, err error) {
+	service, account = k.Service, k.Profile
+	if service == "" {
+		service = "sign4"
+	}
+	if account == "" {
+		account = Profile()
+	}
+	if strings.ContainsAny(service+account, "\"\\\n") {
+		return "", "", fmt.Errorf("credentials: invalid keychain entry %q/%q", service, account)
+	}
+	return service, account, nil
+}
+
+// Load returns the stored credentials, ErrNotFound when there are none
+func (k Keychain) Load() (Value, error) {
+	service, account, err := k.names()
+	if err != nil {
+		return Value{}, err
+	}
+	b, err := keychainGet(service, account)
+	if err != nil {
+		return Value{}, err
+	}
+	defer sign4.Zeroize(b)
+	var s keychainSecret
+	if err := json.Unmarshal(b, &s); err != nil {
+		return Value{}, fmt.Errorf("credentials: keychain entry %s/%s: %v", service, account, err)
+	}
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return Value{}, fmt.Errorf("credentials: keychain entry %s/%s has no access key", service, account)
+	}
+	return Value{AccessKey: s.AccessKey, SecretKey: s.SecretKey, SessionToken: s.SessionToken}, nil
+}
+
+// Store saves v, replacing the stored credentials
+func (k Keychain) Store(v Value) error {
+	service, account, err := k.names()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(keychainSecret{AccessKey: v.AccessKey, SecretKey: v.SecretKey, SessionToken: v.SessionToken})
+	if err != nil {
+		return err
+	}
+	defer sign4.Zeroize(b)
+	return keychainSet(service, account, b)
+}
+
+// Delete removes the stored credentials, deleting missing ones isn't an error
+func (k Keychain) Delete() error {
+	service, account, err := k.names()
+	if err != nil {
+		return err
+	}
+	return keychainDelete(service, account)
+}
+
+// Credentials loads the stored credentials on every call, a Keychain is a sign4.CredentialsProvider
+func (k Keychain) Credentials() (sign4.Credentials, error) {
+	v, err := k.Load()
+	if err != nil {
+		return sign4.Credentials{}, err
+	}
+	return v.Credentials()
+}
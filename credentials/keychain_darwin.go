@@ -0,0 +1,47 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os/exec"
+
+	"github.com/datastream/aws"
+)
+
+// errSecItemNotFound is the exit status of security(1) for a missing item
+const errSecItemNotFound = 44
+
+func keychainGet(service, account string) ([]byte, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		var e *exec.ExitError
+		if errors.As(err, &e) && e.ExitCode() == errSecItemNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return bytes.TrimSuffix(out, []byte("\n")), nil
+}
+
+func keychainSet(service, account string, secret []byte) error {
+	// the secret goes through stdin in hex, it would show in the process list as an argument
+	cmd := exec.Command("security", "-i")
+	prefix := `add-generic-password -U -s "` + service + `" -a "` + account + `" -X `
+	input := make([]byte, len(prefix)+hex.EncodedLen(len(secret))+1)
+	copy(input, prefix)
+	hex.Encode(input[len(prefix):], secret)
+	input[len(input)-1] = '\n'
+	defer sign4.Zeroize(input)
+	cmd.Stdin = bytes.NewReader(input)
+	return cmd.Run()
+}
+
+func keychainDelete(service, account string) error {
+	err := exec.Command("security", "delete-generic-password", "-s", service, "-a", account).Run()
+	var e *exec.ExitError
+	if errors.As(err, &e) && e.ExitCode() == errSecItemNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !windows && !linux && !freebsd && !openbsd && !netbsd && !dragonfly
+
+package credentials
+
+func keychainGet(service, account string) ([]byte, error) {
+	return nil, ErrKeychainUnsupported
+}
+
+func keychainSet(service, account string, secret []byte) error {
+	return ErrKeychainUnsupported
+}
+
+func keychainDelete(service, account string) error {
+	return ErrKeychainUnsupported
+}
@@ -0,0 +1,37 @@
+package credentials_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/credentials"
+)
+
+// TestKeychain writes to the keychain of the system when SIGN4_KEYCHAIN_TEST is set, e.g.
+//
+//	SIGN4_KEYCHAIN_TEST=1 go test -run Keychain ./credentials
+func TestKeychain(t *testing.T) {
+	k := credentials.Keychain{Service: "sign4-test", Profile: "keychain"}
+	if _, err := (credentials.Keychain{Profile: `bad"name`}).Load(); err == nil {
+		t.Fatal("invalid entry name accepted")
+	}
+	if os.Getenv("SIGN4_KEYCHAIN_TEST") == "" {
+		t.Skip("SIGN4_KEYCHAIN_TEST not set")
+	}
+	defer k.Delete()
+	if err := k.Store(credentials.Value{AccessKey: "AKIDKEYCHAIN", SecretKey: "secret", SessionToken: "token"}); err != nil {
+		t.Fatal(err)
+	}
+	s := &sign4.Signature{Provider: k}
+	c, err := s.Credentials()
+	if err != nil || c.AccessKey != "AKIDKEYCHAIN" || c.SecretKey != "secret" || c.SessionToken != "token" {
+		t.Fatal("wrong keychain credentials", c, err)
+	}
+	if err := k.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Load(); err != credentials.ErrNotFound {
+		t.Fatal("deleted credentials found", err)
+	}
+}
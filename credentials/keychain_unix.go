@@ -0,0 +1,49 @@
+//go:build linux || freebsd || openbsd || netbsd || dragonfly
+
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+)
+
+// the Secret Service is reached through secret-tool(1) of libsecret
+
+func keychainGet(service, account string) ([]byte, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	var e *exec.ExitError
+	if errors.As(err, &e) && len(out) == 0 {
+		// secret-tool exits 1 without output for a missing item
+		return nil, ErrNotFound
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, ErrKeychainUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out, []byte("\n")), nil
+}
+
+func keychainSet(service, account string, secret []byte) error {
+	cmd := exec.Command("secret-tool", "store", "--label="+service+" "+account, "service", service, "account", account)
+	cmd.Stdin = bytes.NewReader(secret)
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrKeychainUnsupported
+	}
+	return err
+}
+
+func keychainDelete(service, account string) error {
+	err := exec.Command("secret-tool", "clear", "service", service, "account", account).Run()
+	var e *exec.ExitError
+	if errors.As(err, &e) {
+		return nil
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrKeychainUnsupported
+	}
+	return err
+}
@@ -0,0 +1,18 @@
+// Package sign4 signs and verifies AWS Signature Version 4 requests.
+//
+// The module is github.com/datastream/aws, this package sits at its root so the
+// import path stays github.com/datastream/aws:
+//
+//	import sign4 "github.com/datastream/aws"
+//
+// Subpackages build on it: verify is the import path of servers checking signed
+// requests, credentials and endpoints resolve keys and signing scopes, transport
+// signs outgoing requests and proxies, and appsync, cloudwatch,
+// iot, lambda, opensearch, sns and timestream are minimal service clients. oci
+// signs Oracle Cloud Infrastructure requests with its HTTP signature scheme,
+// s3express signs directory bucket requests with CreateSession credentials,
+// s3select runs S3 Select queries, query and jsonrpc build the requests of query
+// and X-Amz-Target JSON protocol APIs. redis shares replay records and derived
+// keys between verifiers, logging adapts application loggers to Logger. sign4test
+// has test doubles for code that signs or verifies.
+package sign4
@@ -0,0 +1,102 @@
+// Package endpoints infers the signing service and region from AWS endpoint host names.
+package endpoints
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// DefaultRegion signs global endpoints of the aws partition such as iam.amazonaws.com
+const DefaultRegion = "us-east-1"
+
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]*)?-[a-z]+-\d+$`)
+
+// signingNames maps endpoint prefixes to signing names where they differ
+var signingNames = map[string]string{
+	"email":             "ses",
+	"runtime.sagemaker": "sagemaker",
+	"data.iot":          "iotdata",
+	"data-ats.iot":      "iotdata",
+	"streams.dynamodb":  "dynamodb",
+	"runtime.lex":       "lex",
+	"aps-workspaces":    "aps",
+}
+
+// IsRegion reports whether s looks like a region name
+func IsRegion(s string) bool {
+	return regionPattern.MatchString(s)
+}
+
+// Infer returns the signing service and region of an AWS endpoint host, with or without port.
+// Dualstack, FIPS (kms-fips, us-east-1-fips, s3-fips-us-gov-west-1) and VPC endpoint
+// (vpce-id.ec2.us-east-1.vpce.amazonaws.com) names sign as the service endpoint
+func Infer(host string) (service, region string, err error) {
+	h := strings.ToLower(host)
+	if hostname, _, err := net.SplitHostPort(h); err == nil {
+		h = hostname
+	}
+	h = strings.TrimSuffix(h, ".")
+	partition, prefix, ok := partitionForHost(h)
+	if !ok {
+		return "", "", fmt.Errorf("endpoints: %q is not an AWS endpoint", host)
+	}
+	var labels []string
+	for _, label := range strings.Split(prefix, ".") {
+		// s3.dualstack.us-east-1 and fips endpoints sign as the plain service
+		if label != "dualstack" && label != "fips" {
+			labels = append(labels, label)
+		}
+	}
+	// VPC endpoint names, vpce-0a1b-2c3d.ec2.us-east-1.vpce, sign as the service endpoint
+	if n := len(labels); n > 1 && labels[n-1] == "vpce" && IsRegion(fipsRegion(labels[n-2])) {
+		labels = labels[:n-1]
+	}
+	for i, label := range labels {
+		// legacy s3-us-west-2 and s3-fips-us-gov-west-1 style
+		if strings.HasPrefix(label, "s3-") && IsRegion(fipsRegion(label[3:])) {
+			return "s3", fipsRegion(label[3:]), nil
+		}
+		label = fipsRegion(label)
+		if !IsRegion(label) {
+			continue
+		}
+		switch {
+		case i+1 < len(labels):
+			// search-domain.us-east-1.es
+			return signingName(labels[i+1:], labels[i+1]), label, nil
+		case i > 0:
+			// bucket.s3.us-west-2, api-id.execute-api.us-east-1
+			return signingName(labels[:i], labels[i-1]), label, nil
+		}
+		return "", "", fmt.Errorf("endpoints: no service in %q", host)
+	}
+	if len(labels) == 0 || labels[0] == "" {
+		return "", "", fmt.Errorf("endpoints: no service in %q", host)
+	}
+	// global endpoints, bucket.s3, iam, sts
+	return signingName(labels, labels[len(labels)-1]), partition.GlobalRegion, nil
+}
+
+// fipsRegion returns the region of a fips-us-gov-west-1 or us-east-1-fips label, other
+// labels unchanged
+func fipsRegion(label string) string {
+	if r := strings.TrimPrefix(label, "fips-"); r != label && IsRegion(r) {
+		return r
+	}
+	if r := strings.TrimSuffix(label, "-fips"); r != label && IsRegion(r) {
+		return r
+	}
+	return label
+}
+
+// signingName looks up the trailing labels of the service part, falling back to name
+func signingName(labels []string, name string) string {
+	for i := range labels {
+		if s, ok := signingNames[strings.Join(labels[i:], ".")]; ok {
+			return s
+		}
+	}
+	return strings.TrimSuffix(name, "-fips")
+}
@@ -0,0 +1,77 @@
+package endpoints_test
+
+import (
+	"testing"
+
+	"github.com/datastream/aws/endpoints"
+)
+
+func TestInfer(t *testing.T) {
+	for host, want := range map[string][2]string{
+		"s3.amazonaws.com":                                                          {"s3", "us-east-1"},
+		"bucket.s3.us-west-2.amazonaws.com":                                         {"s3", "us-west-2"},
+		"bucket.s3-eu-west-1.amazonaws.com":                                         {"s3", "eu-west-1"},
+		"iam.amazonaws.com":                                                         {"iam", "us-east-1"},
+		"ec2.ap-southeast-1.amazonaws.com:443":                                      {"ec2", "ap-southeast-1"},
+		"abc123.execute-api.us-east-1.amazonaws.com":                                {"execute-api", "us-east-1"},
+		"search-logs-xyz.eu-west-1.es.amazonaws.com":                                {"es", "eu-west-1"},
+		"email.us-east-1.amazonaws.com":                                             {"ses", "us-east-1"},
+		"runtime.sagemaker.us-east-2.amazonaws.com":                                 {"sagemaker", "us-east-2"},
+		"lambda.cn-north-1.amazonaws.com.cn":                                        {"lambda", "cn-north-1"},
+		"sts.us-gov-west-1.amazonaws.com":                                           {"sts", "us-gov-west-1"},
+		"monitoring.us-east-1.amazonaws.com":                                        {"monitoring", "us-east-1"},
+		"abcdef.data-ats.iot.us-east-1.amazonaws.com":                               {"iotdata", "us-east-1"},
+		"ingest.timestream.us-east-1.amazonaws.com":                                 {"timestream", "us-east-1"},
+		"streams.dynamodb.eu-central-1.amazonaws.com":                               {"dynamodb", "eu-central-1"},
+		"sqs.me-south-1.amazonaws.com":                                              {"sqs", "me-south-1"},
+		"iam.amazonaws.com.cn":                                                      {"iam", "cn-north-1"},
+		"iam.us-gov.amazonaws.com":                                                  {"iam", "us-gov-west-1"},
+		"ec2.us-iso-east-1.c2s.ic.gov":                                              {"ec2", "us-iso-east-1"},
+		"s3.dualstack.eu-west-1.amazonaws.com":                                      {"s3", "eu-west-1"},
+		"kms.us-east-2.api.aws":                                                     {"kms", "us-east-2"},
+		"kms-fips.us-east-1.amazonaws.com":                                          {"kms", "us-east-1"},
+		"s3-fips.dualstack.us-east-2.amazonaws.com":                                 {"s3", "us-east-2"},
+		"bucket.s3-fips-us-gov-west-1.amazonaws.com":                                {"s3", "us-gov-west-1"},
+		"dynamodb.us-gov-west-1-fips.amazonaws.com":                                 {"dynamodb", "us-gov-west-1"},
+		"sts.fips-us-gov-east-1.amazonaws.com":                                      {"sts", "us-gov-east-1"},
+		"ec2.us-west-2.api.aws":                                                     {"ec2", "us-west-2"},
+		"vpce-0a1b2c3d4e5f-abcdefgh.ec2.us-east-1.vpce.amazonaws.com":               {"ec2", "us-east-1"},
+		"vpce-0a1b2c3d4e5f-abcdefgh-us-east-1a.sqs.us-east-1.vpce.amazonaws.com":    {"sqs", "us-east-1"},
+		"bucket.vpce-1a2b3c4d-5e6f.s3.eu-west-1.vpce.amazonaws.com":                 {"s3", "eu-west-1"},
+		"vpce-0a1b2c3d4e5f-abcdefgh.runtime.sagemaker.us-east-1.vpce.amazonaws.com": {"sagemaker", "us-east-1"},
+		"vpce-0a1b2c3d4e5f-abcdefgh.execute-api.cn-north-1.vpce.amazonaws.com.cn":   {"execute-api", "cn-north-1"},
+	} {
+		service, region, err := endpoints.Infer(host)
+		if err != nil {
+			t.Fatal(host, err)
+		}
+		if service != want[0] || region != want[1] {
+			t.Fatal(host, service, region)
+		}
+	}
+	for _, host := range []string{"example.com", "amazonaws.com", "us-east-1.amazonaws.com", "us-east-1.vpce.amazonaws.com"} {
+		if _, _, err := endpoints.Infer(host); err == nil {
+			t.Fatal("inferred", host)
+		}
+	}
+}
+
+func TestPartitions(t *testing.T) {
+	for region, id := range map[string]string{
+		"us-east-1":      "aws",
+		"eu-central-2":   "aws",
+		"cn-northwest-1": "aws-cn",
+		"us-gov-east-1":  "aws-us-gov",
+		"us-iso-west-1":  "aws-iso",
+		"us-isob-east-1": "aws-iso-b",
+	} {
+		p, ok := endpoints.PartitionForRegion(region)
+		if !ok || p.ID != id {
+			t.Fatal(region, p, ok)
+		}
+	}
+	allow := endpoints.AllowPartitions("aws-cn", "aws-us-gov")
+	if !allow("cn-north-1", "s3") || !allow("us-gov-west-1", "s3") || allow("us-east-1", "s3") {
+		t.Fatal("wrong partition allow list")
+	}
+}
@@ -0,0 +1,95 @@
+package endpoints
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Partition is a group of regions sharing DNS suffixes and signing conventions
+type Partition struct {
+	ID                 string
+	DNSSuffix          string
+	DualStackDNSSuffix string
+	// GlobalRegion signs the global endpoints of the partition, such as IAM and STS
+	GlobalRegion string
+	regions      *regexp.Regexp
+}
+
+// Partitions are the known partitions, a host is matched against the longest suffix first
+var Partitions = []*Partition{
+	{ID: "aws", DNSSuffix: "amazonaws.com", DualStackDNSSuffix: "api.aws", GlobalRegion: "us-east-1",
+		regions: regexp.MustCompile(`^(us|eu|ap|sa|ca|me|af|il|mx)-\w+-\d+$`)},
+	{ID: "aws-cn", DNSSuffix: "amazonaws.com.cn", DualStackDNSSuffix: "api.amazonwebservices.com.cn", GlobalRegion: "cn-north-1",
+		regions: regexp.MustCompile(`^cn-\w+-\d+$`)},
+	{ID: "aws-us-gov", DNSSuffix: "amazonaws.com", DualStackDNSSuffix: "api.aws", GlobalRegion: "us-gov-west-1",
+		regions: regexp.MustCompile(`^us-gov-\w+-\d+$`)},
+	{ID: "aws-iso", DNSSuffix: "c2s.ic.gov", GlobalRegion: "us-iso-east-1",
+		regions: regexp.MustCompile(`^us-iso-\w+-\d+$`)},
+	{ID: "aws-iso-b", DNSSuffix: "sc2s.sgov.gov", GlobalRegion: "us-isob-east-1",
+		regions: regexp.MustCompile(`^us-isob-\w+-\d+$`)},
+}
+
+// HasRegion reports whether region belongs to p
+func (p *Partition) HasRegion(region string) bool {
+	return p.regions.MatchString(region)
+}
+
+// PartitionByID returns the partition named id
+func PartitionByID(id string) (*Partition, bool) {
+	for _, p := range Partitions {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// PartitionForRegion returns the partition of region
+func PartitionForRegion(region string) (*Partition, bool) {
+	for _, p := range Partitions {
+		if p.HasRegion(region) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// partitionForHost returns the partition whose DNS suffix ends h and the rest of h,
+// us-gov global endpoints (iam.us-gov.amazonaws.com) resolve to aws-us-gov
+func partitionForHost(h string) (*Partition, string, bool) {
+	var best *Partition
+	var prefix string
+	for _, p := range Partitions {
+		for _, suffix := range []string{p.DNSSuffix, p.DualStackDNSSuffix} {
+			if suffix == "" || !strings.HasSuffix(h, "."+suffix) {
+				continue
+			}
+			if best == nil || len(h)-len(suffix)-1 < len(prefix) {
+				best, prefix = p, h[:len(h)-len(suffix)-1]
+			}
+		}
+	}
+	if best != nil && best.ID == "aws" && strings.HasSuffix(prefix, ".us-gov") {
+		best, _ = PartitionByID("aws-us-gov")
+		prefix = strings.TrimSuffix(prefix, ".us-gov")
+	}
+	return best, prefix, best != nil
+}
+
+// AllowPartitions returns a sign4.Verifier AllowScope check accepting the regions of the partitions ids
+func AllowPartitions(ids ...string) func(region, service string) bool {
+	var allowed []*Partition
+	for _, id := range ids {
+		if p, ok := PartitionByID(id); ok {
+			allowed = append(allowed, p)
+		}
+	}
+	return func(region, service string) bool {
+		for _, p := range allowed {
+			if p.HasRegion(region) {
+				return true
+			}
+		}
+		return false
+	}
+}
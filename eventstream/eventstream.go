@@ -0,0 +1,279 @@
+// Package eventstream encodes and decodes application/vnd.amazon.eventstream messages,
+// Signer and Verifier sign and check the :chunk-signature chain of signed streams.
+//
+// Message =
+//
+//	TotalLength(4) + HeadersLength(4) + PreludeCRC(4) +
+//	Headers + Payload +
+//	MessageCRC(4)
+package eventstream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	preludeLen = 12
+	crcLen     = 4
+	// MaxMessageLength is the largest message accepted by the decoder
+	MaxMessageLength = 16 * 1024 * 1024
+)
+
+// header value types
+const (
+	typeTrue byte = iota
+	typeFalse
+	typeByte
+	typeInt16
+	typeInt32
+	typeInt64
+	typeBytes
+	typeString
+	typeTimestamp
+	typeUUID
+)
+
+// Header is a message header, Value is one of bool, int8, int16, int32, int64, []byte, string, time.Time or UUID
+type Header struct {
+	Name  string
+	Value interface{}
+}
+
+// UUID header value
+type UUID [16]byte
+
+// Headers of a message
+type Headers []Header
+
+// Get returns the value of the first header named name
+func (h Headers) Get(name string) interface{} {
+	for _, v := range h {
+		if v.Name == name {
+			return v.Value
+		}
+	}
+	return nil
+}
+
+// String returns a string header value, "" if missing or not a string
+func (h Headers) String(name string) string {
+	s, _ := h.Get(name).(string)
+	return s
+}
+
+// Message is one event-stream frame
+type Message struct {
+	Headers Headers
+	Payload []byte
+}
+
+// ErrChecksum is returned when a prelude or message crc doesn't match
+var ErrChecksum = errors.New("eventstream: checksum mismatch")
+
+// Decoder reads messages from a stream
+type Decoder struct {
+	r   io.Reader
+	buf []byte
+}
+
+// NewDecoder returns a decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads the next message, returning io.EOF at a clean end of stream
+func (d *Decoder) Decode() (*Message, error) {
+	m, _, err := d.DecodeRaw()
+	return m, err
+}
+
+// DecodeRaw reads the next message and also returns its encoded bytes
+func (d *Decoder) DecodeRaw() (*Message, []byte, error) {
+	var prelude [preludeLen]byte
+	if _, err := io.ReadFull(d.r, prelude[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, nil, fmt.Errorf("eventstream: truncated prelude")
+		}
+		return nil, nil, err
+	}
+	total := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, nil, ErrChecksum
+	}
+	if total > MaxMessageLength || total < preludeLen+crcLen || headersLen > total-preludeLen-crcLen {
+		return nil, nil, fmt.Errorf("eventstream: invalid message length %d", total)
+	}
+	if cap(d.buf) < int(total) {
+		d.buf = make([]byte, total)
+	}
+	raw := d.buf[:total]
+	copy(raw, prelude[:])
+	if _, err := io.ReadFull(d.r, raw[preludeLen:]); err != nil {
+		return nil, nil, fmt.Errorf("eventstream: truncated message: %v", err)
+	}
+	if crc32.ChecksumIEEE(raw[:total-crcLen]) != binary.BigEndian.Uint32(raw[total-crcLen:]) {
+		return nil, nil, ErrChecksum
+	}
+	headers, err := decodeHeaders(raw[preludeLen : preludeLen+headersLen])
+	if err != nil {
+		return nil, nil, err
+	}
+	payload := make([]byte, total-preludeLen-crcLen-headersLen)
+	copy(payload, raw[preludeLen+headersLen:total-crcLen])
+	encoded := make([]byte, total)
+	copy(encoded, raw)
+	return &Message{Headers: headers, Payload: payload}, encoded, nil
+}
+
+func decodeHeaders(b []byte) (Headers, error) {
+	var headers Headers
+	short := errors.New("eventstream: truncated header")
+	for len(b) > 0 {
+		n := int(b[0])
+		if len(b) < 1+n+1 {
+			return nil, short
+		}
+		h := Header{Name: string(b[1 : 1+n])}
+		t := b[1+n]
+		b = b[2+n:]
+		size := 0
+		switch t {
+		case typeTrue:
+			h.Value = true
+		case typeFalse:
+			h.Value = false
+		case typeByte:
+			size = 1
+		case typeInt16:
+			size = 2
+		case typeInt32:
+			size = 4
+		case typeInt64, typeTimestamp:
+			size = 8
+		case typeUUID:
+			size = 16
+		case typeBytes, typeString:
+			if len(b) < 2 {
+				return nil, short
+			}
+			size = 2 + int(binary.BigEndian.Uint16(b))
+		default:
+			return nil, fmt.Errorf("eventstream: unknown header type %d", t)
+		}
+		if len(b) < size {
+			return nil, short
+		}
+		v := b[:size]
+		switch t {
+		case typeByte:
+			h.Value = int8(v[0])
+		case typeInt16:
+			h.Value = int16(binary.BigEndian.Uint16(v))
+		case typeInt32:
+			h.Value = int32(binary.BigEndian.Uint32(v))
+		case typeInt64:
+			h.Value = int64(binary.BigEndian.Uint64(v))
+		case typeTimestamp:
+			h.Value = time.Unix(0, int64(binary.BigEndian.Uint64(v))*int64(time.Millisecond)).UTC()
+		case typeUUID:
+			var u UUID
+			copy(u[:], v)
+			h.Value = u
+		case typeBytes:
+			h.Value = append([]byte(nil), v[2:]...)
+		case typeString:
+			h.Value = string(v[2:])
+		}
+		headers = append(headers, h)
+		b = b[size:]
+	}
+	return headers, nil
+}
+
+// Encode returns the wire form of m
+func Encode(m *Message) ([]byte, error) {
+	headers, err := encodeHeaders(m.Headers)
+	if err != nil {
+		return nil, err
+	}
+	total := preludeLen + len(headers) + len(m.Payload) + crcLen
+	b := make([]byte, preludeLen, total)
+	binary.BigEndian.PutUint32(b[0:4], uint32(total))
+	binary.BigEndian.PutUint32(b[4:8], uint32(len(headers)))
+	binary.BigEndian.PutUint32(b[8:12], crc32.ChecksumIEEE(b[:8]))
+	b = append(b, headers...)
+	b = append(b, m.Payload...)
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.ChecksumIEEE(b))
+	return append(b, crc[:]...), nil
+}
+
+// encodeHeaders returns the wire form of headers
+func encodeHeaders(headers Headers) ([]byte, error) {
+	var hb bytes.Buffer
+	for _, h := range headers {
+		if len(h.Name) > 255 {
+			return nil, fmt.Errorf("eventstream: header name too long %q", h.Name)
+		}
+		hb.WriteByte(byte(len(h.Name)))
+		hb.WriteString(h.Name)
+		var tmp [8]byte
+		switch v := h.Value.(type) {
+		case bool:
+			if v {
+				hb.WriteByte(typeTrue)
+			} else {
+				hb.WriteByte(typeFalse)
+			}
+		case int8:
+			hb.WriteByte(typeByte)
+			hb.WriteByte(byte(v))
+		case int16:
+			hb.WriteByte(typeInt16)
+			binary.BigEndian.PutUint16(tmp[:], uint16(v))
+			hb.Write(tmp[:2])
+		case int32:
+			hb.WriteByte(typeInt32)
+			binary.BigEndian.PutUint32(tmp[:], uint32(v))
+			hb.Write(tmp[:4])
+		case int64:
+			hb.WriteByte(typeInt64)
+			binary.BigEndian.PutUint64(tmp[:], uint64(v))
+			hb.Write(tmp[:8])
+		case time.Time:
+			hb.WriteByte(typeTimestamp)
+			binary.BigEndian.PutUint64(tmp[:], uint64(v.UnixNano()/int64(time.Millisecond)))
+			hb.Write(tmp[:8])
+		case UUID:
+			hb.WriteByte(typeUUID)
+			hb.Write(v[:])
+		case []byte:
+			if len(v) > math.MaxUint16 {
+				return nil, fmt.Errorf("eventstream: header %q value too long, %d bytes", h.Name, len(v))
+			}
+			hb.WriteByte(typeBytes)
+			binary.BigEndian.PutUint16(tmp[:], uint16(len(v)))
+			hb.Write(tmp[:2])
+			hb.Write(v)
+		case string:
+			if len(v) > math.MaxUint16 {
+				return nil, fmt.Errorf("eventstream: header %q value too long, %d bytes", h.Name, len(v))
+			}
+			hb.WriteByte(typeString)
+			binary.BigEndian.PutUint16(tmp[:], uint16(len(v)))
+			hb.Write(tmp[:2])
+			hb.WriteString(v)
+		default:
+			return nil, fmt.Errorf("eventstream: unsupported header value %T", h.Value)
+		}
+	}
+	return hb.Bytes(), nil
+}
@@ -0,0 +1,64 @@
+package eventstream_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws/eventstream"
+)
+
+func TestRoundTrip(t *testing.T) {
+	ts := time.Unix(1369353600, 0).UTC()
+	m := &eventstream.Message{
+		Headers: eventstream.Headers{
+			{Name: ":event-type", Value: "PayloadChunk"},
+			{Name: "flag", Value: true},
+			{Name: "n", Value: int32(42)},
+			{Name: "bin", Value: []byte{1, 2}},
+			{Name: "ts", Value: ts},
+		},
+		Payload: []byte("hello"),
+	}
+	b, err := eventstream.Encode(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := eventstream.NewDecoder(bytes.NewReader(append(b, b...)))
+	for i := 0; i < 2; i++ {
+		got, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got.Payload) != "hello" || got.Headers.String(":event-type") != "PayloadChunk" {
+			t.Fatal("wrong message", got)
+		}
+		if got.Headers.Get("n") != int32(42) || got.Headers.Get("flag") != true || !got.Headers.Get("ts").(time.Time).Equal(ts) {
+			t.Fatal("wrong headers", got.Headers)
+		}
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Fatal("expected eof", err)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	b, _ := eventstream.Encode(&eventstream.Message{Payload: []byte("hello")})
+	b[len(b)-6] ^= 0xff
+	if _, err := eventstream.NewDecoder(bytes.NewReader(b)).Decode(); err != eventstream.ErrChecksum {
+		t.Fatal("expected checksum error", err)
+	}
+}
+
+func TestHeaderValueTooLong(t *testing.T) {
+	long := make([]byte, 1<<16)
+	for _, v := range []interface{}{long, string(long)} {
+		if _, err := eventstream.Encode(&eventstream.Message{Headers: eventstream.Headers{{Name: "big", Value: v}}}); err == nil {
+			t.Fatalf("%T header value of %d bytes encoded", v, len(long))
+		}
+	}
+	if _, err := eventstream.Encode(&eventstream.Message{Headers: eventstream.Headers{{Name: "big", Value: long[:1<<16-1]}}}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,197 @@
+package eventstream
+
+// Signed event streams, each event travels in an envelope message whose :chunk-signature
+// signs its :date header and payload over the signature of the envelope before it,
+// starting from the signature of the request (the seed)
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// ErrSignature is returned for an envelope whose :chunk-signature breaks the chain
+var ErrSignature = errors.New("eventstream: chunk signature mismatch")
+
+// ErrSkewed is returned for an envelope dated further than MaxSkew from now
+var ErrSkewed = errors.New("eventstream: envelope date too skewed")
+
+// chain holds the signature of the last envelope and the key of its day
+type chain struct {
+	signature *sign4.Signature
+	prev      []byte
+	day       string
+	key       []byte
+}
+
+// Format prints the chain with its key redacted, Signer and Verifier print as their chain
+func (c chain) Format(f fmt.State, verb rune) {
+	sign4.FormatRedacted(f, verb, struct {
+		Signature *sign4.Signature
+		Day, Key  string
+	}{c.signature, c.day, sign4.Redact(string(c.key))})
+}
+
+// sign returns the signature of an envelope dated t with payload
+func (c *chain) sign(t time.Time, payload []byte) ([]byte, error) {
+	creds, err := c.signature.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	if day := t.UTC().Format(sign4.BasicDateFormatShort); day != c.day || c.key == nil {
+		if c.key, err = sign4.GenerateSigningKey(creds.SecretKey, c.signature.Region, c.signature.Service, t); err != nil {
+			return nil, err
+		}
+		c.day = day
+	}
+	date, err := encodeHeaders(Headers{{Name: ":date", Value: t}})
+	if err != nil {
+		return nil, err
+	}
+	dateHash := sha256.Sum256(date)
+	payloadHash := sha256.Sum256(payload)
+	mac := hmac.New(sha256.New, c.key)
+	io.WriteString(mac, "AWS4-HMAC-SHA256-PAYLOAD\n"+t.UTC().Format(sign4.BasicDateFormat)+"\n"+
+		sign4.CredentialScope(t, c.signature.Region, c.signature.Service)+"\n"+hex.EncodeToString(c.prev)+"\n")
+	io.WriteString(mac, hex.EncodeToString(dateHash[:])+"\n"+hex.EncodeToString(payloadHash[:]))
+	return mac.Sum(nil), nil
+}
+
+// Signer wraps event messages in signed envelopes
+type Signer struct {
+	chain
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// NewSigner returns a signer of the events of a request signed by s with the hex
+// seedSignature
+func NewSigner(s *sign4.Signature, seedSignature string) (*Signer, error) {
+	seed, err := hex.DecodeString(seedSignature)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{chain: chain{signature: s, prev: seed}}, nil
+}
+
+// Sign returns the envelope of m, a nil m is the empty envelope ending the stream
+func (s *Signer) Sign(m *Message) (*Message, error) {
+	var payload []byte
+	if m != nil {
+		var err error
+		if payload, err = Encode(m); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC().Truncate(time.Millisecond)
+	signature, err := s.sign(t, payload)
+	if err != nil {
+		return nil, err
+	}
+	s.prev = signature
+	return &Message{Headers: Headers{{Name: ":date", Value: t}, {Name: ":chunk-signature", Value: signature}}, Payload: payload}, nil
+}
+
+// Verifier checks the envelopes of a signed stream and opens them
+type Verifier struct {
+	chain
+	// MaxSkew rejects envelopes dated further from now, zero disables the check
+	MaxSkew time.Duration
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// NewVerifier returns a verifier of the events of a request verified as s with the hex
+// seedSignature, s carries the secret key, e.g. the Signature returned by sign4.Verifier.Verify
+func NewVerifier(s *sign4.Signature, seedSignature string) (*Verifier, error) {
+	seed, err := hex.DecodeString(seedSignature)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{chain: chain{signature: s, prev: seed}}, nil
+}
+
+// Open checks the signature of an envelope and returns the message it carries,
+// io.EOF for the empty envelope ending the stream
+func (v *Verifier) Open(envelope *Message) (*Message, error) {
+	t, ok := envelope.Headers.Get(":date").(time.Time)
+	presented, _ := envelope.Headers.Get(":chunk-signature").([]byte)
+	if !ok || len(presented) != sha256.Size {
+		return nil, errors.New("eventstream: envelope without :date or :chunk-signature")
+	}
+	if v.MaxSkew > 0 {
+		now := time.Now
+		if v.Now != nil {
+			now = v.Now
+		}
+		if d := now().Sub(t); d > v.MaxSkew || d < -v.MaxSkew {
+			return nil, ErrSkewed
+		}
+	}
+	expected, err := v.sign(t, envelope.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(expected, presented) {
+		return nil, ErrSignature
+	}
+	v.prev = expected
+	if len(envelope.Payload) == 0 {
+		return nil, io.EOF
+	}
+	m, err := NewDecoder(bytes.NewReader(envelope.Payload)).Decode()
+	if err == io.EOF {
+		return nil, errors.New("eventstream: truncated envelope payload")
+	}
+	return m, err
+}
+
+// Event is a verified message of a stream or the error ending it
+type Event struct {
+	Message *Message
+	Err     error
+}
+
+// Events decodes the envelopes of r, verifies them with v and sends their messages,
+// the channel is closed after the final envelope, the first error or the end of ctx,
+// an error is sent as the last event
+func (v *Verifier) Events(ctx context.Context, r io.Reader) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		d := NewDecoder(r)
+		for {
+			envelope, err := d.Decode()
+			var m *Message
+			if err == io.EOF {
+				// a stream cut at a message boundary decodes cleanly
+				err = errors.New("eventstream: stream ended before its final envelope")
+			} else if err == nil {
+				if m, err = v.Open(envelope); err == io.EOF {
+					return
+				}
+			}
+			select {
+			case events <- Event{Message: m, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return events
+}
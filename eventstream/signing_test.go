@@ -0,0 +1,73 @@
+package eventstream_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/eventstream"
+)
+
+func signedStream(t *testing.T, s *sign4.Signature, seed string, payloads ...string) []byte {
+	signer, err := eventstream.NewSigner(s, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	signer.Now = func() time.Time { now = now.Add(time.Second); return now }
+	var stream bytes.Buffer
+	for _, p := range payloads {
+		m := &eventstream.Message{Headers: eventstream.Headers{{Name: ":event-type", Value: "AudioEvent"}}, Payload: []byte(p)}
+		if p == "" {
+			m = nil
+		}
+		envelope, err := signer.Sign(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := eventstream.Encode(envelope)
+		stream.Write(b)
+	}
+	return stream.Bytes()
+}
+
+func TestSignedEvents(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "transcribe"}
+	seed := "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	events := func(stream []byte) ([]string, error) {
+		v, err := eventstream.NewVerifier(s, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for e := range v.Events(context.Background(), bytes.NewReader(stream)) {
+			if e.Err != nil {
+				return got, e.Err
+			}
+			got = append(got, string(e.Message.Payload))
+		}
+		return got, nil
+	}
+	got, err := events(signedStream(t, s, seed, "a", "b", "c", ""))
+	if err != nil || len(got) != 3 || got[2] != "c" {
+		t.Fatal("wrong events", got, err)
+	}
+
+	// envelopes cut, reordered or signed over another seed break the chain
+	whole := signedStream(t, s, seed, "a", "b", "")
+	first := signedStream(t, s, seed, "a")
+	if _, err := events(whole[:len(first)]); err == nil {
+		t.Fatal("stream without its final envelope accepted")
+	}
+	if _, err := events(signedStream(t, s, "00"+seed[2:], "a", "")); err != eventstream.ErrSignature {
+		t.Fatal("other seed accepted", err)
+	}
+	tampered := append([]byte(nil), whole...)
+	tampered[len(first)-20] ^= 1
+	got, err = events(tampered)
+	if err == nil || len(got) != 0 {
+		t.Fatal("tampered envelope accepted", got, err)
+	}
+}
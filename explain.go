@@ -0,0 +1,232 @@
+package sign4
+
+// Explain where a Signature Version 4 computation diverges from an expected one
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Stages of the signature computation, in the order they are checked
+const (
+	StageCredential       = "credential"
+	StageSignedHeaders    = "signed headers"
+	StageCanonicalRequest = "canonical request"
+	StageStringToSign     = "string to sign"
+	StageSignature        = "signature"
+)
+
+// Expected holds what the other side computed, all fields are optional
+type Expected struct {
+	// Authorization is the expected Authorization header or a bare hex signature
+	Authorization string
+	// CanonicalRequest and StringToSign are the other side's intermediate values,
+	// e.g. from the body of a SignatureDoesNotMatch error
+	CanonicalRequest string
+	StringToSign     string
+}
+
+// ExplainLine is one canonical request line with what it encodes
+type ExplainLine struct {
+	Text string
+	// Note names the part of the request the line encodes, e.g. "method" or "header host"
+	Note string
+	// Want is the expected line when it differs
+	Want    string
+	Differs bool
+}
+
+// Explanation reports the first stage where two signature computations diverge, the values
+// of RedactedHeaders and session tokens are redacted in its canonical request and lines
+type Explanation struct {
+	// Stage is the first diverging stage, empty when everything matches
+	Stage string
+	// Detail describes the divergence
+	Detail           string
+	CanonicalRequest string
+	StringToSign     string
+	Signature        string
+	Lines            []ExplainLine
+}
+
+// authorization is a parsed Authorization header
+type authorization struct {
+	accessKey, date, region, service string
+	signedHeaders                    string
+	signature                        string
+}
+
+func parseAuthorization(h string) (*authorization, error) {
+	if _, _, _, err := GetSignatureFromString(h); err != nil {
+		return nil, err
+	}
+	a := &authorization{}
+	for _, f := range strings.FieldsFunc(h[len(profileOf(h).Algorithm):], func(c rune) bool { return c == ' ' || c == ',' }) {
+		switch {
+		case strings.HasPrefix(f, "Credential="):
+			parts := strings.Split(f[11:], "/")
+			a.accessKey, a.date, a.region, a.service = parts[0], parts[1], parts[2], parts[3]
+		case strings.HasPrefix(f, "SignedHeaders="):
+			a.signedHeaders = strings.ToLower(f[14:])
+		case strings.HasPrefix(f, "Signature="):
+			a.signature = f[10:]
+		}
+	}
+	return a, nil
+}
+
+// compare reports the first credential or signed headers difference of a and b
+func (a *authorization) compare(b *authorization) (string, string) {
+	fields := []struct{ name, a, b string }{
+		{"access key", a.accessKey, b.accessKey},
+		{"date", a.date, b.date},
+		{"region", a.region, b.region},
+		{"service", a.service, b.service},
+	}
+	for _, f := range fields {
+		if f.a != f.b {
+			return StageCredential, fmt.Sprintf("%s %q, expected %q", f.name, f.a, f.b)
+		}
+	}
+	if a.signedHeaders != b.signedHeaders {
+		return StageSignedHeaders, fmt.Sprintf("%q, expected %q", a.signedHeaders, b.signedHeaders)
+	}
+	return "", ""
+}
+
+// CompareAuthorization reports where Authorization header a diverges from expected header b,
+// without the request only the credential scope, signed headers and signature can be told apart
+func CompareAuthorization(a, b string) (*Explanation, error) {
+	x, err := parseAuthorization(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := parseAuthorization(b)
+	if err != nil {
+		return nil, err
+	}
+	e := &Explanation{Signature: x.signature}
+	if e.Stage, e.Detail = x.compare(y); e.Stage == "" && x.signature != y.signature {
+		e.Stage, e.Detail = StageSignature, "same scope and signed headers, the canonical request or secret key differ"
+	}
+	return e, nil
+}
+
+// Explain signs r with s and reports the first stage that diverges from expected,
+// an empty expected Authorization falls back to the Authorization header of r
+func (s *Signature) Explain(r *http.Request, expected Expected) (*Explanation, error) {
+	t, err := requestTime(r, s.profile())
+	if err != nil {
+		return nil, err
+	}
+	h := expected.Authorization
+	if h == "" {
+		h = r.Header.Get("Authorization")
+	}
+	var want *authorization
+	var signedHeaders map[string]bool
+	if profileOf(h) != nil {
+		if want, err = parseAuthorization(h); err != nil {
+			return nil, err
+		}
+		signedHeaders = make(map[string]bool)
+		for _, k := range strings.Split(want.signedHeaders, ";") {
+			signedHeaders[k] = true
+		}
+	} else if h != "" {
+		want = &authorization{signature: h}
+	}
+	creds, err := s.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	sc := getScratch()
+	defer putScratch(sc)
+	signature, err := s.signature(sc, r, signedHeaders, t, creds.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	e := &Explanation{
+		CanonicalRequest: RedactCanonicalRequest(string(sc.buf)),
+		StringToSign:     string(sc.sts),
+		Signature:        string(signature),
+	}
+	e.annotate(string(sc.buf), expected.CanonicalRequest)
+	if want != nil && want.accessKey != "" {
+		signedKeys := sc.signedKeys(r, signedHeaders)
+		got := &authorization{
+			accessKey:     creds.AccessKey,
+			date:          t.UTC().Format(BasicDateFormatShort),
+			region:        s.Region,
+			service:       s.Service,
+			signedHeaders: string(sc.appendSignedHeaders(nil, signedKeys)),
+		}
+		if e.Stage, e.Detail = got.compare(want); e.Stage != "" {
+			return e, nil
+		}
+	}
+	for _, l := range e.Lines {
+		if l.Differs {
+			e.Stage, e.Detail = StageCanonicalRequest, fmt.Sprintf("%s %q, expected %q", l.Note, l.Text, l.Want)
+			return e, nil
+		}
+	}
+	if expected.StringToSign != "" && strings.TrimRight(expected.StringToSign, "\n") != e.StringToSign {
+		e.Stage, e.Detail = StageStringToSign, "same canonical request, the date or credential scope differ"
+		return e, nil
+	}
+	if want != nil && want.signature != e.Signature {
+		e.Stage, e.Detail = StageSignature, "the secret key differs"
+		if expected.CanonicalRequest == "" {
+			e.Detail = "the canonical request or secret key differ, pass the expected canonical request to tell them apart"
+		}
+	}
+	return e, nil
+}
+
+// annotate splits the canonical request into lines and compares them with the expected one,
+// the lines are redacted after comparing
+func (e *Explanation) annotate(canonicalRequest, expected string) {
+	lines := strings.Split(canonicalRequest, "\n")
+	var want []string
+	if expected != "" {
+		want = strings.Split(strings.TrimRight(expected, "\n"), "\n")
+	}
+	headers := true
+	e.Lines = make([]ExplainLine, 0, len(lines))
+	for i, text := range lines {
+		l := ExplainLine{Text: text}
+		switch {
+		case i == 0:
+			l.Note = "method"
+		case i == 1:
+			l.Note = "uri"
+		case i == 2:
+			l.Note = "query"
+		case i == len(lines)-1:
+			l.Note = "payload hash"
+		case i == len(lines)-2:
+			l.Note = "signed headers"
+		case headers && text == "":
+			headers = false
+			l.Note = "end of headers"
+		default:
+			l.Note = "header " + text[:strings.Index(text+":", ":")]
+		}
+		if want != nil {
+			if i < len(want) {
+				l.Want = want[i]
+			}
+			l.Differs = l.Want != text
+			if !l.Differs {
+				l.Want = ""
+			}
+		}
+		l.Text, l.Want = redactLine(l.Note, l.Text), redactLine(l.Note, l.Want)
+		e.Lines = append(e.Lines, l)
+	}
+	if len(want) > len(lines) {
+		e.Lines = append(e.Lines, ExplainLine{Note: "extra line", Want: want[len(lines)], Differs: true})
+	}
+}
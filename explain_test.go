@@ -0,0 +1,77 @@
+package sign4_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestExplain(t *testing.T) {
+	s := sign4.Signature{
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:    "us-east-1",
+		Service:   "service",
+	}
+	newRequest := func() *http.Request {
+		r, _ := http.NewRequest("GET", "https://example.amazonaws.com/?Param1=value1", nil)
+		r.Header.Set("X-Amz-Date", "20150830T123600Z")
+		r.Header.Set("My-Header1", "value1")
+		return r
+	}
+	r := newRequest()
+	s.SignRequest(r, nil)
+	e, err := s.Explain(r, sign4.Expected{})
+	if err != nil || e.Stage != "" {
+		t.Fatal("signed request diverges", e, err)
+	}
+	if len(e.Lines) != 9 || e.Lines[0].Note != "method" || e.Lines[3].Note != "header host" || e.Lines[8].Note != "payload hash" {
+		t.Fatal("wrong annotations", e.Lines)
+	}
+	expected := sign4.Expected{Authorization: r.Header.Get("Authorization"), CanonicalRequest: e.CanonicalRequest}
+
+	// the client sent a different header value
+	r = newRequest()
+	r.Header.Set("My-Header1", "value2")
+	e, err = s.Explain(r, expected)
+	if err != nil || e.Stage != sign4.StageCanonicalRequest || !strings.Contains(e.Detail, "header my-header1") {
+		t.Fatal("header change not found", e, err)
+	}
+
+	// the client used another region
+	other := s
+	other.Region = "us-west-2"
+	e, err = other.Explain(newRequest(), expected)
+	if err != nil || e.Stage != sign4.StageCredential || !strings.Contains(e.Detail, "region") {
+		t.Fatal("region change not found", e, err)
+	}
+
+	// the client used another secret key
+	other = s
+	other.SecretKey = "wrong"
+	e, err = other.Explain(newRequest(), expected)
+	if err != nil || e.Stage != sign4.StageSignature || e.Detail != "the secret key differs" {
+		t.Fatal("secret key change not found", e, err)
+	}
+}
+
+func TestCompareAuthorization(t *testing.T) {
+	a := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	cases := []struct{ b, stage string }{
+		{a, ""},
+		{strings.Replace(a, "us-east-1", "eu-west-1", 1), sign4.StageCredential},
+		{strings.Replace(a, "host;x-amz-date", "host;range;x-amz-date", 1), sign4.StageSignedHeaders},
+		{strings.Replace(a, "5fa00fa3", "00000000", 1), sign4.StageSignature},
+	}
+	for _, c := range cases {
+		e, err := sign4.CompareAuthorization(a, c.b)
+		if err != nil || e.Stage != c.stage {
+			t.Fatal("wrong stage", c.stage, e, err)
+		}
+	}
+	if _, err := sign4.CompareAuthorization(a, "Bearer x"); err == nil {
+		t.Fatal("malformed header accepted")
+	}
+}
@@ -0,0 +1,109 @@
+package sign4
+
+// Fan-out signing, one logical request signed for several regions at once
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// Target is a region a request fans out to
+type Target struct {
+	Region string
+	// Endpoint is the scheme and host of the copy, e.g. https://kinesis.eu-west-1.amazonaws.com,
+	// empty keeps the URL of the request
+	Endpoint string
+}
+
+// hashedBody is a shared body with its payload hash
+type hashedBody struct {
+	*bytes.Reader
+	hash string
+}
+
+func (b *hashedBody) Close() error {
+	return nil
+}
+
+func (b *hashedBody) PayloadHash() string {
+	return b.hash
+}
+
+// SignFanOut returns a copy of r signed for each target. The body is read and hashed
+// once, the copies share it, their date and the credentials; r keeps its body
+func (s *Signature) SignFanOut(r *http.Request, targets []Target, signedHeaders map[string]bool) ([]*http.Request, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("fan-out without targets")
+	}
+	creds, err := s.signingCredentials()
+	if err != nil {
+		return nil, err
+	}
+	template := r.Clone(r.Context())
+	if r.GetBody != nil {
+		if template.Body, err = r.GetBody(); err != nil {
+			return nil, err
+		}
+	} else if r.Body != nil {
+		// RequestPayload puts the read body back into r
+		raw, err := RequestPayload(r)
+		if err != nil {
+			return nil, err
+		}
+		template.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	}
+	p := s.profile()
+	if _, err := requestTime(template, p); err != nil {
+		template.Header.Del("date")
+		template.Header.Set(p.DateHeader, s.now().UTC().Format(BasicDateFormat))
+	}
+	if s.GzipPayload {
+		if err := GzipRequest(template); err != nil {
+			return nil, err
+		}
+	}
+	data, err := RequestPayload(template)
+	if err != nil {
+		return nil, err
+	}
+	hash := emptyPayloadHash
+	if len(data) > 0 {
+		sum := sha256.Sum256(data)
+		hash = hex.EncodeToString(sum[:])
+	}
+	// the copies are signed with the credentials read above and without compressing again
+	signer := &Signature{AccessKey: creds.AccessKey, SecretKey: creds.SecretKey, SessionToken: creds.SessionToken,
+		Expiry: creds.Expiry, Service: s.Service, Options: s.Options}
+	signer.GzipPayload = false
+	signed := make([]*http.Request, 0, len(targets))
+	for _, target := range targets {
+		c := template.Clone(template.Context())
+		if target.Endpoint != "" {
+			u, err := url.Parse(target.Endpoint)
+			if err != nil {
+				return nil, err
+			}
+			c.URL.Scheme, c.URL.Host, c.Host = u.Scheme, u.Host, u.Host
+		}
+		c.ContentLength = int64(len(data))
+		c.Body = &hashedBody{Reader: bytes.NewReader(data), hash: hash}
+		c.GetBody = func() (io.ReadCloser, error) {
+			return &hashedBody{Reader: bytes.NewReader(data), hash: hash}, nil
+		}
+		if len(data) == 0 {
+			c.Body, c.GetBody = http.NoBody, nil
+		}
+		signer.Region = target.Region
+		if err := signer.SignRequest(c, signedHeaders); err != nil {
+			return nil, err
+		}
+		signed = append(signed, c)
+	}
+	return signed, nil
+}
@@ -0,0 +1,50 @@
+package sign4_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestSignFanOut(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Service: "kinesis"}
+	r, _ := http.NewRequest("POST", "https://kinesis.us-east-1.amazonaws.com/", strings.NewReader(`{"StreamName":"events"}`))
+	r.Header.Set("X-Amz-Target", "Kinesis_20131202.PutRecord")
+	targets := []sign4.Target{{Region: "us-east-1"}, {Region: "eu-west-1", Endpoint: "https://kinesis.eu-west-1.amazonaws.com"}}
+	signed, err := s.SignFanOut(r, targets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signed) != 2 || signed[0].Header.Get("X-Amz-Date") != signed[1].Header.Get("X-Amz-Date") {
+		t.Fatal("copies not dated alike", signed)
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	for i, c := range signed {
+		if c.Host != strings.TrimPrefix(targets[i].Endpoint, "https://") && targets[i].Endpoint != "" {
+			t.Fatal("wrong host", c.Host)
+		}
+		got, err := v.Verify(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Region != targets[i].Region {
+			t.Fatal("wrong region", got.Region)
+		}
+		b, _ := ioutil.ReadAll(c.Body)
+		if string(b) != `{"StreamName":"events"}` {
+			t.Fatal("wrong body", string(b))
+		}
+	}
+	if r.Header.Get("Authorization") != "" || r.Host != "kinesis.us-east-1.amazonaws.com" {
+		t.Fatal("request modified", r.Header)
+	}
+	if b, _ := ioutil.ReadAll(r.Body); string(b) != `{"StreamName":"events"}` {
+		t.Fatal("request body consumed", string(b))
+	}
+	if _, err := s.SignFanOut(r, nil, nil); err == nil {
+		t.Fatal("fan-out without targets accepted")
+	}
+}
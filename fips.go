@@ -0,0 +1,9 @@
+package sign4
+
+// FIPS 140 mode: builds with the fips tag refuse algorithms that aren't approved
+
+import "errors"
+
+// ErrNotApproved is returned in FIPS mode for an algorithm not approved by FIPS 140,
+// the SHA1 checksum or the SHA1 signatures of SNS messages
+var ErrNotApproved = errors.New("algorithm not approved in FIPS mode")
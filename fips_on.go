@@ -0,0 +1,12 @@
+//go:build fips
+
+package sign4
+
+// FIPS reports a build with the fips tag: SHA1 isn't offered as a checksum and SHA1
+// signatures aren't accepted. Signing only uses crypto/sha256 and crypto/hmac, which
+// GOEXPERIMENT=boringcrypto routes to its validated module
+const FIPS = true
+
+func init() {
+	delete(Checksums, ChecksumSHA1)
+}
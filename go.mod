@@ -0,0 +1,3 @@
+module github.com/datastream/aws
+
+go 1.17
@@ -0,0 +1,78 @@
+package sign4
+
+// Snapshots of the signed headers, to catch middleware changing a request after it was signed
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// SignedState is the Authorization header and the signed header values of a request as
+// they were when it was signed
+type SignedState struct {
+	Authorization string
+	// Headers are the canonical header entries by lower cased name
+	Headers map[string]string
+}
+
+// ModifiedError lists the signed headers changed or removed after signing
+type ModifiedError struct {
+	Headers []string
+}
+
+func (e *ModifiedError) Error() string {
+	return "signed headers modified after signing: " + strings.Join(e.Headers, ", ")
+}
+
+// Snapshot records the signed headers of a signed request
+func Snapshot(r *http.Request) (*SignedState, error) {
+	_, auth, signedHeaders, err := GetSignature(r)
+	if err != nil {
+		return nil, err
+	}
+	state := &SignedState{Authorization: auth, Headers: make(map[string]string, len(signedHeaders))}
+	for name := range signedHeaders {
+		state.Headers[name] = signedEntry(r, name)
+	}
+	return state, nil
+}
+
+// Check returns a *ModifiedError when a signed header of r differs from the snapshot
+func (s *SignedState) Check(r *http.Request) error {
+	var modified []string
+	if headerValue(r.Header, "Authorization") != s.Authorization {
+		modified = append(modified, "authorization")
+	}
+	for name, entry := range s.Headers {
+		if signedEntry(r, name) != entry {
+			modified = append(modified, name)
+		}
+	}
+	if len(modified) == 0 {
+		return nil
+	}
+	sort.Strings(modified)
+	return &ModifiedError{Headers: modified}
+}
+
+// signedEntry is the canonical header entry of name in r
+func signedEntry(r *http.Request, name string) string {
+	if name == "host" {
+		return CanonicalHeaderEntry(name, []string{requestHost(r)})
+	}
+	values := r.Header[http.CanonicalHeaderKey(name)]
+	if values == nil {
+		for k, v := range r.Header {
+			if strings.EqualFold(k, name) {
+				values = v
+				break
+			}
+		}
+	}
+	if values == nil {
+		// tells a removed header from an empty one
+		return name
+	}
+	return CanonicalHeaderEntry(name, values)
+}
@@ -0,0 +1,39 @@
+package sign4_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestSnapshot(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "execute-api"}
+	r, _ := http.NewRequest("POST", "https://api.example.com/items", strings.NewReader("{}"))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("User-Agent", "client/1.0")
+	r.Header.Set("X-Amz-Meta-Empty", "")
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	state, err := sign4.Snapshot(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Check(r); err != nil {
+		t.Fatal("unmodified request failed", err)
+	}
+	r.Header.Set("User-Agent", "client/1.0 middleware/2.0")
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Header.Del("X-Amz-Meta-Empty")
+	r.Header.Set("X-Unsigned", "added later")
+	var modified *sign4.ModifiedError
+	if err := state.Check(r); !errors.As(err, &modified) || strings.Join(modified.Headers, ",") != "content-type,user-agent,x-amz-meta-empty" {
+		t.Fatal("wrong modified headers", err)
+	}
+	if _, err := sign4.Snapshot(&http.Request{Header: http.Header{}}); err == nil {
+		t.Fatal("unsigned request snapshotted")
+	}
+}
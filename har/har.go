@@ -0,0 +1,256 @@
+// Package har audits the signatures of requests recorded in HTTP Archive (HAR) captures,
+// such as those saved by browser developer tools and debugging proxies.
+//
+// See http://www.softwareishard.com/blog/har-12-spec/
+package har
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// ErrUnsigned is the audit error of an entry carrying no signature
+var ErrUnsigned = errors.New("har: request not signed")
+
+// Entry is a recorded request, the response isn't read
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Request         Request   `json:"request"`
+}
+
+// Request is the request of an entry
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Headers     []NameValue `json:"headers"`
+	PostData    *PostData   `json:"postData,omitempty"`
+}
+
+// NameValue is a header of a request
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PostData is the body of a request, Encoding "base64" marks a binary Text
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Decode reads the entries of a HAR document
+func Decode(r io.Reader) ([]Entry, error) {
+	var doc struct {
+		Log struct {
+			Entries []Entry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("har: %v", err)
+	}
+	return doc.Log.Entries, nil
+}
+
+// HTTPRequest rebuilds the recorded request. HTTP/2 pseudo headers are dropped,
+// :authority becomes the host
+func (e *Entry) HTTPRequest() (*http.Request, error) {
+	var body []byte
+	if p := e.Request.PostData; p != nil {
+		body = []byte(p.Text)
+		if p.Encoding == "base64" {
+			var err error
+			if body, err = base64.StdEncoding.DecodeString(p.Text); err != nil {
+				return nil, fmt.Errorf("har: postData: %v", err)
+			}
+		}
+	}
+	r, err := http.NewRequest(e.Request.Method, e.Request.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range e.Request.Headers {
+		switch name := strings.ToLower(h.Name); {
+		case name == ":authority" || name == "host":
+			r.Host = h.Value
+		case name == "content-length" || strings.HasPrefix(name, ":"):
+		default:
+			r.Header.Add(h.Name, h.Value)
+		}
+	}
+	return r, nil
+}
+
+// Result is the audit outcome of the entry at Index, Err is nil when it validates
+type Result struct {
+	Index  int
+	Method string
+	URL    string
+	// AccessKey, Region and Service are the credential scope of the signature
+	AccessKey string
+	Region    string
+	Service   string
+	// Authorization is the header Resign computed, empty for Verify
+	Authorization string
+	Err           error
+}
+
+// newResult returns the result of entry i with the scope of the Authorization of r
+func newResult(i int, r *http.Request) Result {
+	res := Result{Index: i, Method: r.Method, URL: r.URL.String()}
+	if s, _, _, err := sign4.GetSignature(r); err == nil {
+		res.AccessKey, res.Region, res.Service = s.AccessKey, s.Region, s.Service
+	}
+	return res
+}
+
+// Verify verifies each entry with v as at the time it was captured, so expired presigned
+// URLs and clock skew are judged against the capture
+func Verify(entries []Entry, v *sign4.Verifier) []Result {
+	results := make([]Result, len(entries))
+	for i := range entries {
+		r, err := entries[i].HTTPRequest()
+		if err != nil {
+			results[i] = Result{Index: i, Method: entries[i].Request.Method, URL: entries[i].Request.URL, Err: err}
+			continue
+		}
+		results[i] = newResult(i, r)
+		if !sign4.IsSigned(r) {
+			results[i].Err = ErrUnsigned
+			continue
+		}
+		at := *v
+		if t := entries[i].StartedDateTime; !t.IsZero() {
+			at.Options.Now = func() time.Time { return t }
+		}
+		var s *sign4.Signature
+		s, results[i].Err = at.Verify(r)
+		if s != nil {
+			results[i].AccessKey, results[i].Region, results[i].Service = s.AccessKey, s.Region, s.Service
+		}
+	}
+	return results
+}
+
+// Resign signs each entry with s at its recorded date over the headers it signed, and
+// fails it with sign4.ErrSignatureMismatch when the recorded Authorization differs.
+// Unsigned entries are signed over every header and fail with ErrUnsigned, presigned
+// ones are verified with the keys of s
+func Resign(entries []Entry, s *sign4.Signature) []Result {
+	results := make([]Result, len(entries))
+	for i := range entries {
+		r, err := entries[i].HTTPRequest()
+		if err != nil {
+			results[i] = Result{Index: i, Method: entries[i].Request.Method, URL: entries[i].Request.URL, Err: err}
+			continue
+		}
+		results[i] = newResult(i, r)
+		results[i].Authorization, results[i].Err = resign(r, entries[i].StartedDateTime, s)
+		if results[i].AccessKey == "" {
+			creds, _ := s.Credentials()
+			results[i].AccessKey, results[i].Region, results[i].Service = creds.AccessKey, s.Region, s.Service
+		}
+	}
+	return results
+}
+
+// resign returns the Authorization s signs r with and whether it matches the recorded one
+func resign(r *http.Request, captured time.Time, s *sign4.Signature) (string, error) {
+	if sign4.IsPresigned(r) {
+		v := &sign4.Verifier{Options: s.Options, SecretKey: func(accessKey string) (string, error) {
+			creds, err := s.Credentials()
+			if err != nil || creds.AccessKey != accessKey {
+				return "", errors.New("har: presigned with another access key")
+			}
+			return creds.SecretKey, nil
+		}}
+		if !captured.IsZero() {
+			v.Options.Now = func() time.Time { return captured }
+		}
+		_, err := v.Verify(r)
+		return "", err
+	}
+	recorded := r.Header.Get("Authorization")
+	t := captured
+	var signedHeaders map[string]bool
+	if recorded != "" {
+		_, _, headers, err := sign4.GetSignature(r)
+		if err != nil {
+			return "", err
+		}
+		signedHeaders = headers
+		if d, err := time.Parse(sign4.BasicDateFormat, r.Header.Get("X-Amz-Date")); err == nil {
+			t = d
+		}
+	}
+	if t.IsZero() {
+		t = time.Now()
+	}
+	if err := s.WithOptions(sign4.Deterministic(t)).SignRequest(r, signedHeaders); err != nil {
+		return "", err
+	}
+	auth := r.Header.Get("Authorization")
+	switch {
+	case recorded == "":
+		return auth, ErrUnsigned
+	case auth != recorded:
+		return auth, sign4.ErrSignatureMismatch
+	}
+	return auth, nil
+}
+
+// WriteReport writes a line per result and how many validate
+func WriteReport(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tSTATUS\tMETHOD\tURL\tSCOPE\tERROR")
+	valid := 0
+	for _, res := range results {
+		status, msg := "valid", ""
+		switch res.Err {
+		case nil:
+			valid++
+		case ErrUnsigned:
+			status = "unsigned"
+		default:
+			status, msg = "invalid", res.Err.Error()
+		}
+		scope := ""
+		if res.AccessKey != "" {
+			scope = res.AccessKey + "/" + res.Region + "/" + res.Service
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", res.Index, status, res.Method, redactURL(res.URL), scope, msg)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d of %d requests validate\n", valid, len(results))
+	return err
+}
+
+// redactURL replaces the session token and signature of a presigned URL, either lets
+// a reader of the report send the request until it expires
+func redactURL(rawURL string) string {
+	i := strings.IndexByte(rawURL, '?')
+	if i < 0 {
+		return rawURL
+	}
+	pairs := strings.Split(rawURL[i+1:], "&")
+	for n, kv := range pairs {
+		name := strings.ToLower(kv)
+		if strings.HasPrefix(name, "x-amz-security-token=") || strings.HasPrefix(name, "x-amz-signature=") {
+			pairs[n] = kv[:strings.IndexByte(kv, '=')+1] + sign4.Redacted
+		}
+	}
+	return rawURL[:i+1] + strings.Join(pairs, "&")
+}
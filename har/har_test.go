@@ -0,0 +1,125 @@
+package har_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/har"
+)
+
+// captured is the capture time, the entries are signed then
+var captured = time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC)
+
+// entry records r as a browser would, HTTP/2 pseudo headers and lower cased names
+func entry(r *http.Request) har.Entry {
+	e := har.Entry{StartedDateTime: captured, Request: har.Request{Method: r.Method, URL: r.URL.String(), HTTPVersion: "HTTP/2.0"}}
+	e.Request.Headers = append(e.Request.Headers, har.NameValue{Name: ":authority", Value: r.URL.Host}, har.NameValue{Name: ":method", Value: r.Method})
+	for name, values := range r.Header {
+		for _, v := range values {
+			e.Request.Headers = append(e.Request.Headers, har.NameValue{Name: strings.ToLower(name), Value: v})
+		}
+	}
+	if r.Body != nil {
+		body, _ := ioutil.ReadAll(r.Body)
+		e.Request.PostData = &har.PostData{MimeType: r.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return e
+}
+
+func capture(t *testing.T, s *sign4.Signature) []byte {
+	s = s.WithOptions(sign4.Deterministic(captured))
+	var entries []har.Entry
+
+	r, _ := http.NewRequest("POST", "https://sqs.us-east-1.amazonaws.com/", strings.NewReader("Action=ListQueues"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.SignRequest(r, nil)
+	r.Body = ioutil.NopCloser(strings.NewReader("Action=ListQueues"))
+	entries = append(entries, entry(r))
+
+	tampered := entry(r)
+	tampered.Request.PostData.Text = "Action=DeleteQueue"
+	entries = append(entries, tampered)
+
+	r, _ = http.NewRequest("GET", "https://sqs.us-east-1.amazonaws.com/?Action=ListQueues", nil)
+	entries = append(entries, entry(r))
+
+	r, _ = http.NewRequest("GET", "https://bucket.s3.amazonaws.com/key", nil)
+	u, err := s.WithService("s3").Presign(r, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ = http.NewRequest("GET", u.String(), nil)
+	entries = append(entries, entry(r))
+
+	var doc struct {
+		Log struct {
+			Version string      `json:"version"`
+			Entries []har.Entry `json:"entries"`
+		} `json:"log"`
+	}
+	doc.Log.Version = "1.2"
+	doc.Log.Entries = entries
+	data, _ := json.Marshal(doc)
+	return data
+}
+
+func TestVerify(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Region: "us-east-1", Service: "sqs"}
+	entries, err := har.Decode(bytes.NewReader(capture(t, s)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &sign4.Verifier{MaxSkew: 15 * time.Minute, SecretKey: func(string) (string, error) { return s.SecretKey, nil }}
+	results := har.Verify(entries, v)
+	want := []error{nil, sign4.ErrSignatureMismatch, har.ErrUnsigned, nil}
+	for i, res := range results {
+		if res.Err != want[i] {
+			t.Fatal("wrong result", i, res.Err)
+		}
+	}
+	if results[3].Service != "s3" || results[0].AccessKey != "AKIDEXAMPLE" {
+		t.Fatal("wrong scope", results[0], results[3])
+	}
+
+	var out bytes.Buffer
+	har.WriteReport(&out, results)
+	if !strings.HasSuffix(out.String(), "2 of 4 requests validate\n") || !strings.Contains(out.String(), "X-Amz-Signature="+sign4.Redacted) {
+		t.Fatal("wrong report", out.String())
+	}
+}
+
+func TestResign(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Region: "us-east-1", Service: "sqs"}
+	entries, _ := har.Decode(bytes.NewReader(capture(t, s)))
+	results := har.Resign(entries, s)
+	want := []error{nil, sign4.ErrSignatureMismatch, har.ErrUnsigned, nil}
+	for i, res := range results {
+		if res.Err != want[i] {
+			t.Fatal("wrong result", i, res.Err)
+		}
+	}
+	if results[2].Authorization == "" || results[0].Authorization != entries[0].Request.Headers[authorization(entries[0])].Value {
+		t.Fatal("authorization not computed", results[0].Authorization, results[2].Authorization)
+	}
+
+	other := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "other", Region: "us-east-1", Service: "sqs"}
+	if res := har.Resign(entries, other); res[0].Err != sign4.ErrSignatureMismatch || res[3].Err == nil {
+		t.Fatal("other keys validate", res[0].Err, res[3].Err)
+	}
+}
+
+// authorization returns the index of the Authorization header of e
+func authorization(e har.Entry) int {
+	for i, h := range e.Request.Headers {
+		if h.Name == "authorization" {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,126 @@
+package sign4
+
+// Hashing of large payloads
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+)
+
+// HashChunkSize and HashReadAhead tune HashReaderAt
+var (
+	HashChunkSize = 4 * 1024 * 1024
+	HashReadAhead = 4
+)
+
+var chunkPool sync.Pool
+
+type hashChunk struct {
+	buf []byte
+	err error
+}
+
+// HashReaderAt returns the hex sha256 of the first size bytes of ra.
+// SHA-256 is sequential, so the chunks are read concurrently ahead of a single hasher.
+func HashReaderAt(ra io.ReaderAt, size int64) (string, error) {
+	h := sha256.New()
+	chunkSize := int64(HashChunkSize)
+	readAhead := HashReadAhead
+	if readAhead < 1 {
+		readAhead = 1
+	}
+	free := make(chan []byte, readAhead+1)
+	for i := 0; i < readAhead+1; i++ {
+		buf, _ := chunkPool.Get().([]byte)
+		free <- buf
+	}
+	defer func() {
+		for i := 0; i < readAhead+1; i++ {
+			select {
+			case buf := <-free:
+				if buf != nil {
+					chunkPool.Put(buf[:0])
+				}
+			default:
+			}
+		}
+	}()
+	pending := make(chan chan hashChunk, readAhead)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(pending)
+		for off := int64(0); off < size; off += chunkSize {
+			var buf []byte
+			select {
+			case buf = <-free:
+			case <-done:
+				return
+			}
+			n := chunkSize
+			if size-off < n {
+				n = size - off
+			}
+			if int64(cap(buf)) < n {
+				buf = make([]byte, n)
+			}
+			buf = buf[:n]
+			c := make(chan hashChunk, 1)
+			select {
+			case pending <- c:
+			case <-done:
+				return
+			}
+			go func(off int64) {
+				n, err := ra.ReadAt(buf, off)
+				if n == len(buf) {
+					// ReadAt may return io.EOF along with the last full chunk
+					err = nil
+				} else if err == nil || err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				c <- hashChunk{buf: buf, err: err}
+			}(off)
+		}
+	}()
+	for c := range pending {
+		chunk := <-c
+		if chunk.err != nil {
+			return "", chunk.err
+		}
+		h.Write(chunk.buf)
+		free <- chunk.buf
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type statReaderAt interface {
+	io.ReaderAt
+	io.Seeker
+	Stat() (os.FileInfo, error)
+}
+
+// hashFile hashes a regular file body from its current offset without consuming it
+func hashFile(body io.Reader) (string, bool, error) {
+	f, ok := body.(statReaderAt)
+	if !ok {
+		return "", false, nil
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false, nil
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", false, nil
+	}
+	size := info.Size() - offset
+	if size <= 0 {
+		return emptyPayloadHash, true, nil
+	}
+	hash, err := HashReaderAt(io.NewSectionReader(f, offset, size), size)
+	return hash, true, err
+}
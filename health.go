@@ -0,0 +1,95 @@
+package sign4
+
+// Sign and verify round trips for the readiness probes of gateways
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HealthCheck signs a synthetic request with s and verifies it with v, it fails when the
+// credentials of s can't be fetched or v doesn't accept what s signs, e.g. after one of
+// them was rotated. A nonce in the query keeps the check clear of v.Replay
+func HealthCheck(s *Signature, v *Verifier) error {
+	r, err := healthRequest(context.Background(), "https://sign4-healthcheck."+s.Region+".amazonaws.com/healthcheck")
+	if err != nil {
+		return err
+	}
+	if err := s.SignRequest(r, nil); err != nil {
+		return fmt.Errorf("sign4: health check signing: %w", err)
+	}
+	if _, err := v.Verify(r); err != nil {
+		return fmt.Errorf("sign4: health check verification: %w", err)
+	}
+	return nil
+}
+
+// healthRequest returns a GET of rawURL with a random nonce parameter
+func healthRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	var nonce [8]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	r, err := http.NewRequest("GET", rawURL+sep+"sign4-nonce="+hex.EncodeToString(nonce[:]), nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.WithContext(ctx), nil
+}
+
+// Readiness is an http.Handler answering readiness probes with HealthCheck, and with a
+// signed request to Endpoint when it is set
+type Readiness struct {
+	Signature *Signature
+	Verifier  *Verifier
+	// Endpoint, when set, receives a signed GET that must be answered with a status below 400
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// Check runs the health check and calls Endpoint
+func (h *Readiness) Check(ctx context.Context) error {
+	if err := HealthCheck(h.Signature, h.Verifier); err != nil {
+		return err
+	}
+	if h.Endpoint == "" {
+		return nil
+	}
+	r, err := healthRequest(ctx, h.Endpoint)
+	if err != nil {
+		return err
+	}
+	if err := h.Signature.SignRequest(r, nil); err != nil {
+		return fmt.Errorf("sign4: health check signing: %w", err)
+	}
+	client := h.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		return fmt.Errorf("sign4: health check endpoint: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("sign4: health check endpoint answered %s", resp.Status)
+	}
+	return nil
+}
+
+// ServeHTTP answers 200 when the check passes and 503 with the error otherwise
+func (h *Readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.Check(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
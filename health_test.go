@@ -0,0 +1,47 @@
+package sign4_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestHealthCheck(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "execute-api"}
+	secret := "secret"
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return secret, nil }, Replay: &sign4.MemoryCache{}}
+	for i := 0; i < 2; i++ {
+		if err := sign4.HealthCheck(s, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret = "rotated"
+	if err := sign4.HealthCheck(s, v); !errors.Is(err, sign4.ErrSignatureMismatch) {
+		t.Fatal("mismatched secret passed", err)
+	}
+	secret = "secret"
+
+	status := http.StatusOK
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.Verify(r); err != nil {
+			t.Error("unsigned probe", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer backend.Close()
+	h := &sign4.Readiness{Signature: s, Verifier: v, Endpoint: backend.URL + "/ping"}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Fatal("not ready", w.Code, w.Body.String())
+	}
+	status = http.StatusForbidden
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatal("ready with a failing endpoint", w.Code)
+	}
+}
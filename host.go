@@ -0,0 +1,69 @@
+package sign4
+
+// Canonical host header values, matching the Host header net/http sends
+
+import (
+	"net/http"
+	"strings"
+)
+
+// splitHost splits host into its name and ":port", bracketed IPv6 literals keep
+// their brackets and lose their zone
+func splitHost(host string) (name, port string) {
+	if strings.HasPrefix(host, "[") {
+		if i := strings.IndexByte(host, ']'); i > 0 {
+			name, port = host[:i+1], host[i+1:]
+			if j := strings.IndexByte(name, '%'); j > 0 {
+				name = name[:j] + "]"
+			}
+			return name, port
+		}
+		return host, ""
+	}
+	// a bare IPv6 address has no port
+	if strings.Count(host, ":") > 1 {
+		return host, ""
+	}
+	if i := strings.LastIndexByte(host, ':'); i >= 0 {
+		return host[:i], host[i:]
+	}
+	return host, ""
+}
+
+// appendHost appends the canonical form of host, labels with non-ASCII characters
+// lower cased and punycode encoded
+func appendHost(b []byte, host string) []byte {
+	if isASCII(host) && !(strings.HasPrefix(host, "[") && strings.Contains(host, "%")) {
+		return append(b, host...)
+	}
+	name, port := splitHost(host)
+	if strings.HasPrefix(name, "[") {
+		return append(append(b, name...), port...)
+	}
+	return append(appendIDN(b, name), port...)
+}
+
+// canonicalHost sets r.Host to the host net/http sends, without an IPv6 zone or
+// an empty port, and without the default port of the scheme unless p.HostPort
+func (p *Profile) canonicalHost(r *http.Request) {
+	r.Host = requestHost(r)
+	name, port := splitHost(r.Host)
+	if port == ":" || !p.HostPort && defaultPort(r.URL.Scheme) == port {
+		port = ""
+	}
+	if len(name)+len(port) != len(r.Host) {
+		r.Host = name + port
+	}
+}
+
+// defaultPort is the port of scheme dropped from the signed host, ws and wss are
+// dialed as http and https
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "https", "wss":
+		return ":443"
+	case "http", "ws":
+		return ":80"
+	}
+	return ""
+}
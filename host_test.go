@@ -0,0 +1,46 @@
+package sign4_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestIPv6Host(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "s3"}
+	for _, c := range []struct {
+		url, host string
+		p         *sign4.Profile
+	}{
+		{"http://[::1]:9000/bucket", "[::1]:9000", sign4.AWS},
+		{"http://[::1]/bucket", "[::1]", sign4.AWS},
+		{"http://[::1]:80/bucket", "[::1]", sign4.AWS},
+		{"https://[2001:db8::443]/bucket", "[2001:db8::443]", sign4.AWS},
+		{"https://[2001:db8::1]:443/bucket", "[2001:db8::1]:443", sign4.MinIO},
+		{"http://[fe80::1%25en0]:9000/bucket", "[fe80::1]:9000", sign4.MinIO},
+		{"http://127.0.0.1:9000/bucket", "127.0.0.1:9000", sign4.AWS},
+	} {
+		r, _ := http.NewRequest("GET", c.url, nil)
+		r.Header.Set("X-Amz-Date", "20150830T123600Z")
+		if err := s.WithOptions(sign4.UseProfile(c.p)).SignRequest(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		if r.Host != c.host {
+			t.Errorf("%s: host %s want %s", c.url, r.Host, c.host)
+		}
+		if h := sign4.CanonicalHeaders(r, map[string]bool{"host": true}); h != "host:"+c.host+"\n" {
+			t.Errorf("%s: canonical headers %q", c.url, h)
+		}
+	}
+
+	// a verifier sees the host the request was sent with
+	r, _ := http.NewRequest("PUT", "http://[::1]:9000/bucket/key", strings.NewReader("data"))
+	s.SignRequest(r, nil)
+	r.Host = "[::1]:9000"
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,39 @@
+package sign4
+
+// HTTP/2 requests, the host comes from :authority and frameworks bridging h2 may
+// store lower case field names or pseudo headers straight into http.Header
+
+import (
+	"net/http"
+	"strings"
+)
+
+// headerValue is h.Get(key) that also finds keys not in canonical form
+func headerValue(h http.Header, key string) string {
+	if v := h.Get(key); v != "" {
+		return v
+	}
+	for k, v := range h {
+		if len(v) > 0 && v[0] != "" && strings.EqualFold(k, key) {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// requestHost is the host r is signed with, r.Host, the :authority pseudo header
+// when a framework left r.Host empty, or the URL host
+func requestHost(r *http.Request) string {
+	if r.Host != "" {
+		return r.Host
+	}
+	if a := r.Header[":authority"]; len(a) > 0 && a[0] != "" {
+		return a[0]
+	}
+	return r.URL.Host
+}
+
+// isPseudoHeader reports whether key is an HTTP/2 pseudo header, they are never signed
+func isPseudoHeader(key string) bool {
+	return len(key) > 0 && key[0] == ':'
+}
@@ -0,0 +1,70 @@
+package sign4_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+func TestHTTP2(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Error("not an h2 request", r.Proto)
+		}
+		if _, err := v.Verify(r); err != nil {
+			t.Error("h2 request rejected", err)
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	for _, target := range []string{server.URL + "/a?b=c", server.URL + "/presigned"} {
+		r, _ := http.NewRequest("PUT", target, strings.NewReader("body"))
+		r.Header.Set("X-Amz-Meta-Name", "value")
+		if strings.HasSuffix(target, "/presigned") {
+			u, err := s.Presign(r, time.Minute, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.URL = u
+		} else if err := s.SignRequest(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := server.Client().Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatal("wrong status", resp.Status)
+		}
+	}
+}
+
+func TestHTTP2Headers(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	r.Header.Set("X-Amz-Meta-Name", "value")
+	s.SignRequest(r, nil)
+	// a bridge storing lower case field names and the pseudo headers, without r.Host
+	bridged, _ := http.NewRequest("GET", "/", nil)
+	bridged.Host = ""
+	bridged.Header = http.Header{
+		":authority":      {"example.amazonaws.com"},
+		":method":         {"GET"},
+		"authorization":   {r.Header.Get("Authorization")},
+		"x-amz-date":      {r.Header.Get("X-Amz-Date")},
+		"x-amz-meta-name": {"value"},
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	if _, err := v.Verify(bridged); err != nil {
+		t.Fatal("bridged h2 request rejected", err)
+	}
+}
@@ -0,0 +1,118 @@
+package sign4
+
+// Punycode host names, net/http sends internationalized hosts in their ASCII form
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// isASCII reports whether s has no multi-byte characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// appendIDN appends a host name, labels with non-ASCII characters lower cased and punycode encoded
+func appendIDN(b []byte, name string) []byte {
+	for i, label := range strings.Split(name, ".") {
+		if i > 0 {
+			b = append(b, '.')
+		}
+		if isASCII(label) {
+			b = appendLower(b, label)
+			continue
+		}
+		b = append(b, "xn--"...)
+		b = appendPunycode(b, strings.ToLower(label))
+	}
+	return b
+}
+
+// appendPunycode appends the RFC 3492 encoding of s
+func appendPunycode(b []byte, s string) []byte {
+	const (
+		base        = 36
+		tmin        = 1
+		tmax        = 26
+		skew        = 38
+		damp        = 700
+		initialBias = 72
+		initialN    = 128
+	)
+	digit := func(d int32) byte {
+		if d < 26 {
+			return byte('a' + d)
+		}
+		return byte('0' + d - 26)
+	}
+	adapt := func(delta, points int32, first bool) int32 {
+		if first {
+			delta /= damp
+		} else {
+			delta /= 2
+		}
+		delta += delta / points
+		k := int32(0)
+		for delta > ((base-tmin)*tmax)/2 {
+			delta /= base - tmin
+			k += base
+		}
+		return k + (base-tmin+1)*delta/(delta+skew)
+	}
+	runes := []rune(s)
+	basic := int32(0)
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			b = append(b, byte(r))
+			basic++
+		}
+	}
+	if basic > 0 {
+		b = append(b, '-')
+	}
+	n, delta, bias := int32(initialN), int32(0), int32(initialBias)
+	for h := basic; h < int32(len(runes)); {
+		m := int32(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += (m - n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := int32(base); ; k += base {
+				t := k - bias
+				if t < tmin {
+					t = tmin
+				} else if t > tmax {
+					t = tmax
+				}
+				if q < t {
+					break
+				}
+				b = append(b, digit(t+(q-t)%(base-t)))
+				q = (q - t) / (base - t)
+			}
+			b = append(b, digit(q))
+			bias = adapt(delta, h+1, h == basic)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return b
+}
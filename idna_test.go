@@ -0,0 +1,24 @@
+package sign4_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestIDNHost(t *testing.T) {
+	for host, want := range map[string]string{
+		"api.example.com":       "api.example.com",
+		"Bücher.Example":        "xn--bcher-kva.example",
+		"münchen.de:8443":       "xn--mnchen-3ya.de:8443",
+		"例え.テスト":                "xn--r8jz45g.xn--zckzah",
+		"api.παράδειγμα.δοκιμή": "api.xn--hxajbheg2az3al.xn--jxalpdlp",
+	} {
+		r, _ := http.NewRequest("GET", "https://example.com/", nil)
+		r.Host = host
+		if got := sign4.CanonicalHeaders(r, nil); got != "host:"+want+"\n" {
+			t.Errorf("%s: got %q want %s", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package iot publishes messages to AWS IoT Core topics over HTTPS signed with sign4,
+// for devices that can't keep an MQTT connection.
+package iot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/datastream/aws"
+)
+
+// ServiceName is the signing name of the IoT data plane
+const ServiceName = "iotdata"
+
+// Error is an error returned by the IoT data plane
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("iot: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Message is a message to publish
+type Message struct {
+	Topic string
+	// QoS is 0, at most once, or 1, at least once
+	QoS     int
+	Retain  bool
+	Payload []byte
+}
+
+// Client publishes to the data endpoint of an account
+type Client struct {
+	Signature  *sign4.Signature
+	HTTPClient *http.Client
+	// Endpoint is the data endpoint of the account, https://<prefix>-ats.iot.<region>.amazonaws.com
+	Endpoint string
+}
+
+// NewClient returns a client of endpoint signing with s, the service name is forced to iotdata
+func NewClient(s *sign4.Signature, endpoint string) *Client {
+	return &Client{Signature: s, Endpoint: endpoint}
+}
+
+// Publish sends a message to its topic
+func (c *Client) Publish(ctx context.Context, m *Message) error {
+	if m.Topic == "" {
+		return errors.New("iot: missing topic")
+	}
+	if m.QoS != 0 && m.QoS != 1 {
+		return errors.New("iot: qos is 0 or 1")
+	}
+	if c.Endpoint == "" {
+		return errors.New("iot: missing endpoint")
+	}
+	endpoint := c.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	q := url.Values{"qos": {fmt.Sprint(m.QoS)}}
+	if m.Retain {
+		q.Set("retain", "true")
+	}
+	// the topic is a single path segment, its slashes are escaped
+	u := strings.TrimSuffix(endpoint, "/") + "/topics/" + url.PathEscape(m.Topic) + "?" + q.Encode()
+	r, err := http.NewRequest("POST", u, bytes.NewReader(m.Payload))
+	if err != nil {
+		return err
+	}
+	r = r.WithContext(ctx)
+	if err := c.Signature.WithService(ServiceName).SignRequest(r, nil); err != nil {
+		return err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	e := &Error{StatusCode: resp.StatusCode, Code: resp.Header.Get("X-Amzn-Errortype")}
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		e.Message = body.Message
+	}
+	if i := strings.Index(e.Code, ":"); i >= 0 {
+		e.Code = e.Code[:i]
+	}
+	return e
+}
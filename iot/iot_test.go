@@ -0,0 +1,45 @@
+package iot_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/iot"
+)
+
+func TestPublish(t *testing.T) {
+	verifier := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s, err := verifier.Verify(r); err != nil || s.Service != iot.ServiceName {
+			t.Error("request not signed for iotdata", err)
+		}
+		if r.URL.EscapedPath() != "/topics/sensors%2Fkitchen" || r.URL.Query().Get("qos") != "1" || r.URL.Query().Get("retain") != "true" {
+			t.Error("wrong publish", r.URL)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) == "forbidden" {
+			w.Header().Set("X-Amzn-Errortype", "ForbiddenException:http://internal.amazon.com/")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"not authorized"}`))
+		}
+	}))
+	defer server.Close()
+	c := iot.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"}, server.URL)
+	m := &iot.Message{Topic: "sensors/kitchen", QoS: 1, Retain: true, Payload: []byte(`{"t":21}`)}
+	if err := c.Publish(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	m.Payload = []byte("forbidden")
+	var e *iot.Error
+	if err := c.Publish(context.Background(), m); !errors.As(err, &e) || e.Code != "ForbiddenException" || e.Message != "not authorized" {
+		t.Fatal("wrong error", err)
+	}
+	if err := c.Publish(context.Background(), &iot.Message{Topic: "a", QoS: 2}); err == nil {
+		t.Fatal("qos 2 accepted")
+	}
+}
@@ -0,0 +1,138 @@
+// Package jsonrpc builds and signs the X-Amz-Target JSON 1.0 and 1.1 requests of
+// DynamoDB, Kinesis, Firehose and other JSON protocol APIs.
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/datastream/aws"
+)
+
+// Error is an error returned by a JSON protocol API
+type Error struct {
+	StatusCode int
+	// Code is the exception name without its namespace
+	Code    string
+	Message string
+	// Body is the raw response for service specific error fields
+	Body []byte
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// DecodeError returns the *Error of a failed response, the code comes from __type of
+// the body or the X-Amzn-ErrorType header
+func DecodeError(status int, header http.Header, data []byte) *Error {
+	var body struct {
+		Type         string `json:"__type"`
+		Code         string `json:"code"`
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	json.Unmarshal(data, &body)
+	e := &Error{StatusCode: status, Code: body.Type, Message: body.Message, Body: data}
+	if e.Code == "" {
+		e.Code = body.Code
+	}
+	if e.Code == "" {
+		e.Code = header.Get("X-Amzn-Errortype")
+	}
+	// x-amzn-errortype: ValidationException:http://internal.amazon.com/coral/...
+	if i := strings.Index(e.Code, ":"); i >= 0 {
+		e.Code = e.Code[:i]
+	}
+	if i := strings.LastIndex(e.Code, "#"); i >= 0 {
+		e.Code = e.Code[i+1:]
+	}
+	if e.Message == "" {
+		e.Message = body.MessageUpper
+	}
+	return e
+}
+
+// Client calls one JSON protocol API
+type Client struct {
+	Signature  *sign4.Signature
+	HTTPClient *http.Client
+	// Service is the signing name, dynamodb, kinesis, firehose, ...
+	Service string
+	// TargetPrefix is the X-Amz-Target prefix of operations, DynamoDB_20120810, Kinesis_20131202, ...
+	TargetPrefix string
+	// Version is the JSON protocol version, 1.0 when empty
+	Version string
+	// Endpoint overrides https://<service>.<region>.amazonaws.com
+	Endpoint string
+}
+
+// NewClient returns a client of the service signing with s
+func NewClient(s *sign4.Signature, service, targetPrefix, version string) *Client {
+	return &Client{Signature: s, Service: service, TargetPrefix: targetPrefix, Version: version}
+}
+
+// NewRequest returns the signed POST of operation with in marshaled as the body,
+// a nil in sends {}
+func (c *Client) NewRequest(ctx context.Context, operation string, in interface{}) (*http.Request, error) {
+	body := []byte("{}")
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, err
+		}
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", c.Service, c.Signature.Region)
+	}
+	r, err := http.NewRequest("POST", strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r = r.WithContext(ctx)
+	version := c.Version
+	if version == "" {
+		version = "1.0"
+	}
+	r.Header.Set("Content-Type", "application/x-amz-json-"+version)
+	r.Header.Set("X-Amz-Target", c.TargetPrefix+"."+operation)
+	if err := c.Signature.WithService(c.Service).SignRequest(r, nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Do calls operation and decodes the response into out when it isn't nil,
+// failed calls return an *Error
+func (c *Client) Do(ctx context.Context, operation string, in, out interface{}) error {
+	r, err := c.NewRequest(ctx, operation, in)
+	if err != nil {
+		return err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return DecodeError(resp.StatusCode, resp.Header, data)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,76 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/jsonrpc"
+)
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/x-amz-json-1.1" || r.Header.Get("X-Amz-Target") != "Kinesis_20131202.DescribeStream" {
+			t.Error("wrong headers", r.Header)
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "/us-east-1/kinesis/aws4_request") || !strings.Contains(r.Header.Get("Authorization"), "x-amz-target") {
+			t.Error("wrong authorization", r.Header.Get("Authorization"))
+		}
+		if string(b) != `{"StreamName":"s"}` {
+			t.Error("wrong body", string(b))
+		}
+		w.Write([]byte(`{"StreamDescription":{"StreamStatus":"ACTIVE"}}`))
+	}))
+	defer server.Close()
+	c := jsonrpc.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"}, "kinesis", "Kinesis_20131202", "1.1")
+	c.Endpoint = server.URL
+	var out struct {
+		StreamDescription struct {
+			StreamStatus string
+		}
+	}
+	if err := c.Do(context.Background(), "DescribeStream", map[string]string{"StreamName": "s"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.StreamDescription.StreamStatus != "ACTIVE" {
+		t.Fatal("wrong response", out)
+	}
+
+	r, err := jsonrpc.NewClient(c.Signature, "dynamodb", "DynamoDB_20120810", "").NewRequest(context.Background(), "ListTables", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(r.Body)
+	if r.URL.String() != "https://dynamodb.us-east-1.amazonaws.com/" || r.Header.Get("Content-Type") != "application/x-amz-json-1.0" || string(b) != "{}" {
+		t.Fatal("wrong request", r.URL, r.Header, string(b))
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	e := jsonrpc.DecodeError(400, http.Header{}, []byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ResourceNotFoundException","message":"missing"}`))
+	if e.Code != "ResourceNotFoundException" || e.Message != "missing" || e.Error() != "jsonrpc: 400 ResourceNotFoundException: missing" {
+		t.Fatal("wrong error", e)
+	}
+	e = jsonrpc.DecodeError(400, http.Header{"X-Amzn-Errortype": {"ValidationException:http://internal.amazon.com/coral/com.amazon.coral.validate/"}}, []byte(`{"Message":"invalid"}`))
+	if e.Code != "ValidationException" || e.Message != "invalid" {
+		t.Fatal("wrong error", e)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ProvisionedThroughputExceededException","message":"slow down","Extra":1}`))
+	}))
+	defer server.Close()
+	c := jsonrpc.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"}, "firehose", "Firehose_20150804", "1.1")
+	c.Endpoint = server.URL
+	err := c.Do(context.Background(), "PutRecord", struct{}{}, nil)
+	if !errors.As(err, &e) || e.StatusCode != 400 || e.Code != "ProvisionedThroughputExceededException" || !strings.Contains(string(e.Body), `"Extra":1`) {
+		t.Fatal("wrong error", err)
+	}
+}
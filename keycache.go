@@ -0,0 +1,174 @@
+package sign4
+
+// Cache of derived signing keys, the key only changes daily per (secret, date, region, service)
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"hash"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxCachedKeys bounds the cache, it is cleared when full
+const maxCachedKeys = 1024
+
+// signingKeyID identifies a derived key by the sha256 of its secret, the secret isn't kept:
+// ByteCredentials hands out strings sharing the memory Zeroize wipes
+type signingKeyID struct {
+	profile *Profile
+	secret  [sha256.Size]byte
+	region  string
+	service string
+}
+
+func newSigningKeyID(p *Profile, secretKey, regionName, serviceName string) signingKeyID {
+	return signingKeyID{profile: p, secret: secretHash(secretKey), region: regionName, service: serviceName}
+}
+
+// secretHash returns the sha256 of secretKey, hashing a copy on the stack for usual lengths
+func secretHash(secretKey string) [sha256.Size]byte {
+	var buf [128]byte
+	if len(secretKey) <= len(buf) {
+		return sha256.Sum256(buf[:copy(buf[:], secretKey)])
+	}
+	return sha256.Sum256([]byte(secretKey))
+}
+
+type signingKey struct {
+	key  []byte
+	macs sync.Pool
+	// forgotten is set once the key is wiped, its hmacs are no longer pooled
+	forgotten uint32
+}
+
+// mac returns a hmac keyed with the signing key, return it with put
+func (k *signingKey) mac() hash.Hash {
+	if h, ok := k.macs.Get().(hash.Hash); ok {
+		return h
+	}
+	return hmac.New(sha256.New, k.key)
+}
+
+func (k *signingKey) put(h hash.Hash) {
+	if atomic.LoadUint32(&k.forgotten) == 1 {
+		return
+	}
+	h.Reset()
+	k.macs.Put(h)
+}
+
+// forget wipes the key and drops its pooled hmacs, their state is derived from the key
+func (k *signingKey) forget() {
+	atomic.StoreUint32(&k.forgotten, 1)
+	for k.macs.Get() != nil {
+	}
+	Zeroize(k.key)
+}
+
+type keyCache struct {
+	mu   sync.RWMutex
+	day  int64
+	keys map[signingKeyID]*signingKey
+	// next holds the keys of the day after day derived ahead by prepare
+	next map[signingKeyID]*signingKey
+}
+
+var signingKeys = &keyCache{}
+
+func utcDay(t time.Time) int64 {
+	return t.Unix() / 86400
+}
+
+// get returns the derived key for the date of t, now is the clock of the caller: the cache
+// holds the keys of its UTC day and of the day after, keys of other days are derived
+// uncached so a request date can't evict the keys in use
+func (c *keyCache) get(p *Profile, secretKey, regionName, serviceName string, t, now time.Time) (*signingKey, error) {
+	id := newSigningKeyID(p, secretKey, regionName, serviceName)
+	day, current := utcDay(t), c.roll(utcDay(now))
+	var k *signingKey
+	var ok bool
+	c.mu.RLock()
+	switch day {
+	case current:
+		k, ok = c.keys[id]
+	case current + 1:
+		k, ok = c.next[id]
+	}
+	c.mu.RUnlock()
+	if ok {
+		return k, nil
+	}
+	key, err := generateSigningKey(p, secretKey, regionName, serviceName, t)
+	if err != nil {
+		return nil, err
+	}
+	k = &signingKey{key: key}
+	if day != current && day != current+1 {
+		return k, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys *map[signingKeyID]*signingKey
+	switch day {
+	case c.day:
+		keys = &c.keys
+	case c.day + 1:
+		keys = &c.next
+	default:
+		// the clock moved on meanwhile
+		return k, nil
+	}
+	if *keys == nil || len(*keys) >= maxCachedKeys {
+		*keys = make(map[signingKeyID]*signingKey)
+	}
+	if cached, ok := (*keys)[id]; ok {
+		return cached, nil
+	}
+	(*keys)[id] = k
+	return k, nil
+}
+
+// roll moves the cache to the UTC day today and returns it, the keys prepared for the
+// day become the current ones at midnight, any other change of day clears the cache
+func (c *keyCache) roll(today int64) int64 {
+	c.mu.RLock()
+	current := c.day
+	c.mu.RUnlock()
+	if current == today {
+		return today
+	}
+	c.mu.Lock()
+	switch today {
+	case c.day:
+	case c.day + 1:
+		c.day, c.keys, c.next = today, c.next, nil
+	default:
+		c.day, c.keys, c.next = today, nil, nil
+	}
+	c.mu.Unlock()
+	return today
+}
+
+// prepare derives the key of the day of t ahead of its first use, the key of the day after
+// the one of now is kept apart so the keys in use aren't evicted before midnight
+func (c *keyCache) prepare(p *Profile, secretKey, regionName, serviceName string, t, now time.Time) error {
+	_, err := c.get(p, secretKey, regionName, serviceName, t, now)
+	return err
+}
+
+// forget drops and wipes the derived keys of secretKey
+func (c *keyCache) forget(secretKey string) {
+	secret := secretHash(secretKey)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, keys := range []map[signingKeyID]*signingKey{c.keys, c.next} {
+		for id, k := range keys {
+			if id.secret == secret {
+				k.forget()
+				delete(keys, id)
+			}
+		}
+	}
+}
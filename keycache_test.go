@@ -0,0 +1,126 @@
+package sign4
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"testing"
+	"time"
+)
+
+func TestKeyCache(t *testing.T) {
+	c := &keyCache{}
+	day1, _ := time.Parse(time.RFC1123, "Mon, 09 Sep 2011 23:36:00 GMT")
+	day2 := day1.Add(time.Hour)
+	k1, _ := c.get(AWS, "secret", "us-east-1", "host", day1, day1)
+	k2, _ := c.get(AWS, "secret", "us-east-1", "host", day1.Add(-time.Hour), day1.Add(-time.Hour))
+	if k1 != k2 {
+		t.Fatal("key not cached within a day")
+	}
+	want, _ := GenerateSigningKey("secret", "us-east-1", "host", day1)
+	if !bytes.Equal(k1.key, want) {
+		t.Fatal("wrong cached key")
+	}
+	k3, _ := c.get(AWS, "secret", "us-east-1", "host", day2, day2)
+	if k3 == k1 || len(c.keys) != 1 || c.day != utcDay(day2) {
+		t.Fatal("cache not invalidated at midnight")
+	}
+	k4, _ := c.get(AWS, "secret", "us-east-1", "host", day1, day2)
+	if k4 == k1 || c.keys[newSigningKeyID(AWS, "secret", "us-east-1", "host")] != k3 {
+		t.Fatal("older day evicted current keys")
+	}
+}
+
+func TestKeyCachePrepare(t *testing.T) {
+	c := &keyCache{}
+	today, _ := time.Parse(time.RFC1123, "Mon, 09 Sep 2011 23:59:00 GMT")
+	tomorrow := today.Add(2 * time.Minute)
+	current, _ := c.get(AWS, "secret", "us-east-1", "host", today, today)
+	if err := c.prepare(AWS, "secret", "us-east-1", "host", tomorrow, today); err != nil {
+		t.Fatal(err)
+	}
+	if k, _ := c.get(AWS, "secret", "us-east-1", "host", today, today); k != current || c.day != utcDay(today) {
+		t.Fatal("prepared key evicted the current day")
+	}
+	prepared := c.next[newSigningKeyID(AWS, "secret", "us-east-1", "host")]
+	want, _ := GenerateSigningKey("secret", "us-east-1", "host", tomorrow)
+	if prepared == nil || !bytes.Equal(prepared.key, want) {
+		t.Fatal("next day key not prepared")
+	}
+	if k, _ := c.get(AWS, "secret", "us-east-1", "host", tomorrow, tomorrow); k != prepared {
+		t.Fatal("prepared key not used after midnight")
+	}
+	if _, _ = c.get(AWS, "secret", "eu-west-1", "host", tomorrow, tomorrow); c.day != utcDay(tomorrow) || c.keys[newSigningKeyID(AWS, "secret", "us-east-1", "host")] != prepared {
+		t.Fatal("prepared keys not kept when the day rolled")
+	}
+}
+
+func TestKeyCacheForget(t *testing.T) {
+	c := &keyCache{}
+	day, _ := time.Parse(time.RFC1123, "Mon, 09 Sep 2011 23:59:00 GMT")
+	k, _ := c.get(AWS, "secret", "us-east-1", "host", day, day)
+	k.put(k.mac())
+	c.prepare(AWS, "secret", "us-east-1", "host", day.Add(time.Hour), day)
+	kept, _ := c.get(AWS, "other", "us-east-1", "host", day, day)
+	c.forget("secret")
+	if !bytes.Equal(k.key, make([]byte, len(k.key))) || len(c.keys) != 1 || len(c.next) != 0 {
+		t.Fatal("derived keys not wiped")
+	}
+	// a signer still holding the key returns its hmac after forget
+	k.put(hmac.New(sha256.New, k.key))
+	if k.macs.Get() != nil {
+		t.Fatal("hmac of a forgotten key pooled")
+	}
+	if c.keys[newSigningKeyID(AWS, "other", "us-east-1", "host")] != kept {
+		t.Fatal("keys of another secret dropped")
+	}
+}
+
+func TestKeyCacheZeroizedSecret(t *testing.T) {
+	c := &keyCache{}
+	day, _ := time.Parse(time.RFC1123, "Mon, 09 Sep 2011 23:36:00 GMT")
+	secret := []byte("byte-secret")
+	// ByteCredentials hands the secret out sharing its memory
+	k, _ := c.get(AWS, bytesString(secret), "us-east-1", "host", day, day)
+	Zeroize(secret)
+	if c.keys[newSigningKeyID(AWS, "byte-secret", "us-east-1", "host")] != k {
+		t.Fatal("cache key changed with the wiped secret")
+	}
+}
+
+func TestKeyCachePrepareNightly(t *testing.T) {
+	c := &keyCache{}
+	night, _ := time.Parse(time.RFC1123, "Mon, 09 Sep 2011 23:59:00 GMT")
+	c.get(AWS, "secret", "us-east-1", "host", night, night)
+	for i := 0; i < 2; i++ {
+		// before midnight the next day is prepared, after it its key is used
+		c.prepare(AWS, "secret", "us-east-1", "host", night.Add(time.Minute), night)
+		today, _ := c.get(AWS, "secret", "us-east-1", "host", night, night)
+		if c.day != utcDay(night) || c.keys[newSigningKeyID(AWS, "secret", "us-east-1", "host")] != today {
+			t.Fatal("keys in use evicted by the preparation of night", i)
+		}
+		night = night.Add(24 * time.Hour)
+		prepared := c.next[newSigningKeyID(AWS, "secret", "us-east-1", "host")]
+		if k, _ := c.get(AWS, "secret", "us-east-1", "host", night.Add(-23*time.Hour), night.Add(-23*time.Hour)); k != prepared || c.day != utcDay(night) {
+			t.Fatal("prepared keys not current after midnight", i, c.day)
+		}
+	}
+}
+
+func TestKeyCacheFutureDate(t *testing.T) {
+	c := &keyCache{}
+	now, _ := time.Parse(time.RFC1123, "Mon, 09 Sep 2011 23:36:00 GMT")
+	current, _ := c.get(AWS, "secret", "us-east-1", "host", now, now)
+	// a request dated ahead must not move the cache to its day
+	future, _ := c.get(AWS, "secret", "us-east-1", "host", now.Add(72*time.Hour), now)
+	want, _ := GenerateSigningKey("secret", "us-east-1", "host", now.Add(72*time.Hour))
+	if !bytes.Equal(future.key, want) {
+		t.Fatal("wrong key of the future date")
+	}
+	if c.day != utcDay(now) || len(c.next) != 0 {
+		t.Fatal("future date moved the cache", c.day)
+	}
+	if k, _ := c.get(AWS, "secret", "us-east-1", "host", now, now); k != current {
+		t.Fatal("future date evicted the current keys")
+	}
+}
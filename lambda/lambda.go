@@ -0,0 +1,220 @@
+// Package lambda is a minimal AWS Lambda invoke client signed with sign4.
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/eventstream"
+)
+
+// ServiceName is the signing name of the Lambda API
+const ServiceName = "lambda"
+
+// Invocation types
+const (
+	InvocationTypeRequestResponse = "RequestResponse"
+	InvocationTypeEvent           = "Event"
+	InvocationTypeDryRun          = "DryRun"
+)
+
+// InvokeInput describes an invocation
+type InvokeInput struct {
+	FunctionName string
+	// Qualifier selects a version or alias
+	Qualifier string
+	// InvocationType defaults to RequestResponse
+	InvocationType string
+	// LogType "Tail" returns the last 4KB of the execution log
+	LogType string
+	// ClientContext is base64 encoded client context
+	ClientContext string
+	Payload       []byte
+}
+
+// InvokeOutput is the invocation result
+type InvokeOutput struct {
+	StatusCode      int
+	FunctionError   string
+	ExecutedVersion string
+	LogResult       string
+	Payload         []byte
+}
+
+// Error is an error returned by the Lambda API
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("lambda: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Client invokes functions
+type Client struct {
+	Signature  *sign4.Signature
+	HTTPClient *http.Client
+	// Endpoint overrides https://lambda.<region>.amazonaws.com
+	Endpoint string
+}
+
+// NewClient returns a client signing with s, the service name is forced to lambda
+func NewClient(s *sign4.Signature) *Client {
+	return &Client{Signature: s}
+}
+
+// Invoke runs a function, Event invocations return once the event is queued
+func (c *Client) Invoke(ctx context.Context, in *InvokeInput) (*InvokeOutput, error) {
+	resp, err := c.do(ctx, "/2015-03-31/functions/", "/invocations", in)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	payload, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &InvokeOutput{
+		StatusCode:      resp.StatusCode,
+		FunctionError:   resp.Header.Get("X-Amz-Function-Error"),
+		ExecutedVersion: resp.Header.Get("X-Amz-Executed-Version"),
+		LogResult:       resp.Header.Get("X-Amz-Log-Result"),
+		Payload:         payload,
+	}, nil
+}
+
+// InvokeComplete is the final event of a response stream
+type InvokeComplete struct {
+	ErrorCode    string `json:"ErrorCode"`
+	ErrorDetails string `json:"ErrorDetails"`
+	LogResult    string `json:"LogResult"`
+}
+
+// ResponseStream reads the payload of InvokeWithResponseStream as it arrives
+type ResponseStream struct {
+	StatusCode      int
+	ExecutedVersion string
+	// Complete is set once the InvokeComplete event was read
+	Complete *InvokeComplete
+
+	body    io.ReadCloser
+	decoder *eventstream.Decoder
+	chunk   []byte
+}
+
+// Read returns payload chunk bytes, io.EOF after InvokeComplete
+func (s *ResponseStream) Read(p []byte) (int, error) {
+	for len(s.chunk) == 0 {
+		if s.Complete != nil {
+			return 0, io.EOF
+		}
+		m, err := s.decoder.Decode()
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		if m.Headers.String(":message-type") == "exception" {
+			return 0, &Error{StatusCode: s.StatusCode, Code: m.Headers.String(":exception-type"), Message: string(m.Payload)}
+		}
+		switch m.Headers.String(":event-type") {
+		case "PayloadChunk":
+			s.chunk = m.Payload
+		case "InvokeComplete":
+			s.Complete = &InvokeComplete{}
+			if err := json.Unmarshal(m.Payload, s.Complete); err != nil {
+				return 0, err
+			}
+		}
+	}
+	n := copy(p, s.chunk)
+	s.chunk = s.chunk[n:]
+	return n, nil
+}
+
+// Close closes the response body
+func (s *ResponseStream) Close() error {
+	return s.body.Close()
+}
+
+// InvokeWithResponseStream runs a function and streams its response payload
+func (c *Client) InvokeWithResponseStream(ctx context.Context, in *InvokeInput) (*ResponseStream, error) {
+	resp, err := c.do(ctx, "/2021-11-15/functions/", "/response-streaming-invocations", in)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseStream{
+		StatusCode:      resp.StatusCode,
+		ExecutedVersion: resp.Header.Get("X-Amz-Executed-Version"),
+		body:            resp.Body,
+		decoder:         eventstream.NewDecoder(resp.Body),
+	}, nil
+}
+
+func (c *Client) do(ctx context.Context, prefix, suffix string, in *InvokeInput) (*http.Response, error) {
+	if in.FunctionName == "" {
+		return nil, errors.New("lambda: missing function name")
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://lambda.%s.amazonaws.com", c.Signature.Region)
+	}
+	u := strings.TrimSuffix(endpoint, "/") + prefix + url.PathEscape(in.FunctionName) + suffix
+	if in.Qualifier != "" {
+		u += "?Qualifier=" + url.QueryEscape(in.Qualifier)
+	}
+	r, err := http.NewRequest("POST", u, bytes.NewReader(in.Payload))
+	if err != nil {
+		return nil, err
+	}
+	r = r.WithContext(ctx)
+	if in.InvocationType != "" {
+		r.Header.Set("X-Amz-Invocation-Type", in.InvocationType)
+	}
+	if in.LogType != "" {
+		r.Header.Set("X-Amz-Log-Type", in.LogType)
+	}
+	if in.ClientContext != "" {
+		r.Header.Set("X-Amz-Client-Context", in.ClientContext)
+	}
+	if err := c.Signature.WithService(ServiceName).SignRequest(r, nil); err != nil {
+		return nil, err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		e := &Error{StatusCode: resp.StatusCode, Code: resp.Header.Get("X-Amzn-Errortype")}
+		var body struct {
+			Type    string `json:"Type"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &body) == nil {
+			e.Message = body.Message
+		}
+		if i := strings.Index(e.Code, ":"); i >= 0 {
+			e.Code = e.Code[:i]
+		}
+		return nil, e
+	}
+	return resp, nil
+}
@@ -0,0 +1,98 @@
+package lambda_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/eventstream"
+	"github.com/datastream/aws/lambda"
+)
+
+func TestInvoke(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2015-03-31/functions/hello/invocations" || r.URL.Query().Get("Qualifier") != "live" {
+			t.Error("wrong path", r.URL)
+		}
+		if r.Header.Get("X-Amz-Invocation-Type") == lambda.InvocationTypeEvent {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if r.Header.Get("Authorization") == "" {
+			t.Error("request not signed")
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Amz-Executed-Version", "3")
+		w.Write(b)
+	}))
+	defer server.Close()
+	c := lambda.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"})
+	c.Endpoint = server.URL
+	out, err := c.Invoke(context.Background(), &lambda.InvokeInput{FunctionName: "hello", Qualifier: "live", Payload: []byte(`{"a":1}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out.Payload) != `{"a":1}` || out.ExecutedVersion != "3" {
+		t.Fatal("wrong output", out)
+	}
+	out, err = c.Invoke(context.Background(), &lambda.InvokeInput{FunctionName: "hello", Qualifier: "live", InvocationType: lambda.InvocationTypeEvent})
+	if err != nil || out.StatusCode != http.StatusAccepted {
+		t.Fatal("wrong event invocation", err)
+	}
+}
+
+func TestInvokeWithResponseStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2021-11-15/functions/hello/response-streaming-invocations" {
+			t.Error("wrong path", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		for _, chunk := range []string{"hello ", "world"} {
+			b, _ := eventstream.Encode(&eventstream.Message{
+				Headers: eventstream.Headers{{Name: ":event-type", Value: "PayloadChunk"}, {Name: ":message-type", Value: "event"}},
+				Payload: []byte(chunk),
+			})
+			w.Write(b)
+		}
+		b, _ := eventstream.Encode(&eventstream.Message{
+			Headers: eventstream.Headers{{Name: ":event-type", Value: "InvokeComplete"}, {Name: ":message-type", Value: "event"}},
+			Payload: []byte(`{"LogResult":"log"}`),
+		})
+		w.Write(b)
+	}))
+	defer server.Close()
+	c := lambda.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"})
+	c.Endpoint = server.URL
+	stream, err := c.InvokeWithResponseStream(context.Background(), &lambda.InvokeInput{FunctionName: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	b, err := ioutil.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello world" || stream.Complete == nil || stream.Complete.LogResult != "log" {
+		t.Fatal("wrong stream", string(b), stream.Complete)
+	}
+}
+
+func TestInvokeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-Errortype", "ResourceNotFoundException")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"Type":"User","message":"Function not found"}`))
+	}))
+	defer server.Close()
+	c := lambda.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"})
+	c.Endpoint = server.URL
+	_, err := c.Invoke(context.Background(), &lambda.InvokeInput{FunctionName: "missing"})
+	var e *lambda.Error
+	if !errors.As(err, &e) || e.Code != "ResourceNotFoundException" || e.Message != "Function not found" {
+		t.Fatal("wrong error", err)
+	}
+}
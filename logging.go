@@ -0,0 +1,65 @@
+package sign4
+
+// Diagnostics of signing and verification, reported through Options.Logger
+
+import "net/http"
+
+// LogLevel is the severity of a diagnostic
+type LogLevel int
+
+// Levels of diagnostics
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	}
+	return "error"
+}
+
+// Logger receives diagnostics as a message with alternating keys and values,
+// the logging package adapts log/slog, zap and logr loggers to it
+type Logger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+// UseLogger sets Options.Logger
+func UseLogger(l Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// logSigned reports a signed request at debug level
+func (s *Signature) logSigned(r *http.Request, accessKey, authorization string) {
+	s.Logger.Log(LogDebug, "signed request", "method", r.Method, "host", r.Host, "path", r.URL.EscapedPath(),
+		"access_key", accessKey, "region", s.Region, "service", s.Service, "authorization", authorization)
+}
+
+// logVerified reports the outcome of a verification, failures at warn level
+func (v *Verifier) logVerified(r *http.Request, s *Signature, err error) {
+	if v.Options.Logger == nil {
+		return
+	}
+	keyvals := []interface{}{"method", r.Method, "host", r.Host, "path", r.URL.EscapedPath(), "presigned", IsPresigned(r)}
+	if s != nil {
+		keyvals = append(keyvals, "access_key", s.AccessKey, "region", s.Region, "service", s.Service)
+	} else if parsed, _, _, perr := GetSignature(r); perr == nil {
+		keyvals = append(keyvals, "access_key", parsed.AccessKey, "region", parsed.Region, "service", parsed.Service)
+	}
+	if err != nil {
+		v.Options.Logger.Log(LogWarn, "verification failed", append(keyvals, "error", err)...)
+		return
+	}
+	v.Options.Logger.Log(LogDebug, "verified request", keyvals...)
+}
@@ -0,0 +1,91 @@
+// Package logging adapts the loggers of applications to sign4.Logger.
+//
+// zap and logr are matched by their method sets so this module doesn't depend on them:
+//
+//	s.WithOptions(sign4.UseLogger(logging.Zap(zapLogger.Sugar())))
+//	v.Options.Logger = logging.Logr(logrLogger)
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/datastream/aws"
+)
+
+// Func adapts a function to sign4.Logger
+type Func func(level sign4.LogLevel, msg string, keyvals ...interface{})
+
+// Log calls f
+func (f Func) Log(level sign4.LogLevel, msg string, keyvals ...interface{}) {
+	f(level, msg, keyvals...)
+}
+
+// Std writes diagnostics at level or above to l as "level msg key=value ..."
+func Std(l *log.Logger, level sign4.LogLevel) sign4.Logger {
+	return Func(func(lv sign4.LogLevel, msg string, keyvals ...interface{}) {
+		if lv < level {
+			return
+		}
+		var b strings.Builder
+		b.WriteString(lv.String())
+		b.WriteByte(' ')
+		b.WriteString(msg)
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			fmt.Fprintf(&b, " %v=%q", keyvals[i], fmt.Sprint(keyvals[i+1]))
+		}
+		l.Output(2, b.String())
+	})
+}
+
+// SugaredLogger is the part of *zap.SugaredLogger diagnostics are written to
+type SugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+// Zap adapts a *zap.SugaredLogger
+func Zap(l SugaredLogger) sign4.Logger {
+	return Func(func(level sign4.LogLevel, msg string, keyvals ...interface{}) {
+		switch level {
+		case sign4.LogDebug:
+			l.Debugw(msg, keyvals...)
+		case sign4.LogInfo:
+			l.Infow(msg, keyvals...)
+		case sign4.LogWarn:
+			l.Warnw(msg, keyvals...)
+		default:
+			l.Errorw(msg, keyvals...)
+		}
+	})
+}
+
+// LogrLogger is the part of logr.Logger diagnostics are written to
+type LogrLogger interface {
+	Info(msg string, keysAndValues ...interface{})
+	Error(err error, msg string, keysAndValues ...interface{})
+}
+
+// Logr adapts a logr.Logger, logr has no levels below error so debug, info and warn
+// diagnostics are Info with a level key, errors pass the error value of the diagnostic
+func Logr(l LogrLogger) sign4.Logger {
+	return Func(func(level sign4.LogLevel, msg string, keyvals ...interface{}) {
+		if level < sign4.LogError {
+			l.Info(msg, append([]interface{}{"level", level.String()}, keyvals...)...)
+			return
+		}
+		var err error
+		rest := make([]interface{}, 0, len(keyvals))
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			if e, ok := keyvals[i+1].(error); ok && keyvals[i] == "error" && err == nil {
+				err = e
+				continue
+			}
+			rest = append(rest, keyvals[i], keyvals[i+1])
+		}
+		l.Error(err, msg, rest...)
+	})
+}
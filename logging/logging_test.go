@@ -0,0 +1,71 @@
+package logging_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/logging"
+)
+
+// recorder stands in for zap and logr loggers
+type recorder struct {
+	lines []string
+}
+
+func (r *recorder) add(kind, msg string, kv []interface{}) {
+	r.lines = append(r.lines, fmt.Sprint(kind, " ", msg, " ", kv))
+}
+
+func (r *recorder) Debugw(msg string, kv ...interface{}) { r.add("debug", msg, kv) }
+func (r *recorder) Infow(msg string, kv ...interface{})  { r.add("info", msg, kv) }
+func (r *recorder) Warnw(msg string, kv ...interface{})  { r.add("warn", msg, kv) }
+func (r *recorder) Errorw(msg string, kv ...interface{}) { r.add("error", msg, kv) }
+func (r *recorder) Info(msg string, kv ...interface{})   { r.add("info", msg, kv) }
+func (r *recorder) Error(err error, msg string, kv ...interface{}) {
+	r.add(fmt.Sprint("error(", err, ")"), msg, kv)
+}
+
+func TestZap(t *testing.T) {
+	rec := &recorder{}
+	l := logging.Zap(rec)
+	l.Log(sign4.LogDebug, "a", "k", 1)
+	l.Log(sign4.LogWarn, "b")
+	l.Log(sign4.LogError, "c")
+	if got := strings.Join(rec.lines, "|"); got != "debug a [k 1]|warn b []|error c []" {
+		t.Fatal("wrong lines", got)
+	}
+}
+
+func TestLogr(t *testing.T) {
+	rec := &recorder{}
+	l := logging.Logr(rec)
+	l.Log(sign4.LogWarn, "a", "k", 1)
+	l.Log(sign4.LogError, "b", "k", 2, "error", errors.New("boom"))
+	if got := strings.Join(rec.lines, "|"); got != "info a [level warn k 1]|error(boom) b [k 2]" {
+		t.Fatal("wrong lines", got)
+	}
+}
+
+func TestStd(t *testing.T) {
+	var buf bytes.Buffer
+	l := logging.Std(log.New(&buf, "", 0), sign4.LogWarn)
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	s = s.WithOptions(sign4.UseLogger(l))
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	s.SignRequest(r, nil)
+	if buf.Len() != 0 {
+		t.Fatal("debug line written", buf.String())
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "other", nil }, Options: sign4.Options{Logger: l}}
+	v.Verify(r)
+	if got := buf.String(); got != `warn verification failed method="GET" host="example.amazonaws.com" path="/" presigned="false" `+
+		`access_key="AKIDEXAMPLE" region="us-east-1" service="service" error="signature does not match"`+"\n" {
+		t.Fatalf("wrong line %q", got)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/datastream/aws"
+)
+
+// Slog adapts a *slog.Logger, the levels map to slog.LevelDebug through slog.LevelError
+func Slog(l *slog.Logger) sign4.Logger {
+	return Func(func(level sign4.LogLevel, msg string, keyvals ...interface{}) {
+		lv := slog.LevelError
+		switch level {
+		case sign4.LogDebug:
+			lv = slog.LevelDebug
+		case sign4.LogInfo:
+			lv = slog.LevelInfo
+		case sign4.LogWarn:
+			lv = slog.LevelWarn
+		}
+		l.Log(context.Background(), lv, msg, keyvals...)
+	})
+}
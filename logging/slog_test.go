@@ -0,0 +1,33 @@
+//go:build go1.21
+
+package logging_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/logging"
+)
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		}}))
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	s = s.WithOptions(sign4.UseLogger(logging.Slog(l)))
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	s.SignRequest(r, nil)
+	if got := buf.String(); !strings.HasPrefix(got, `level=DEBUG msg="signed request" method=GET host=example.amazonaws.com`) ||
+		!strings.Contains(got, "access_key=AKIDEXAMPLE") {
+		t.Fatalf("wrong record %q", got)
+	}
+}
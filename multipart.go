@@ -0,0 +1,178 @@
+package sign4
+
+// Streaming multipart/form-data bodies, the parts are written once into the hasher
+// and again when the body is sent, so the body is never held in memory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// PayloadHasher is a body that knows its hex sha256, the signer uses the hash
+// instead of reading the body
+type PayloadHasher interface {
+	PayloadHash() string
+}
+
+// MultipartPart is a field or a file of a MultipartBody
+type MultipartPart struct {
+	FieldName string
+	// FileName and ContentType describe a file part, ContentType defaults to application/octet-stream
+	FileName    string
+	ContentType string
+	// Open returns the content of the part, it is called once per pass over the body
+	Open func() (io.ReadCloser, error)
+}
+
+// MultipartBody is a multipart/form-data body of parts that can be read again
+type MultipartBody struct {
+	Boundary string
+	Parts    []MultipartPart
+}
+
+// NewMultipartBody returns an empty body with a random boundary
+func NewMultipartBody() *MultipartBody {
+	return &MultipartBody{Boundary: multipart.NewWriter(nil).Boundary()}
+}
+
+// AddField adds a form field
+func (m *MultipartBody) AddField(name, value string) {
+	m.Parts = append(m.Parts, MultipartPart{FieldName: name, Open: func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(value)), nil
+	}})
+}
+
+// AddFile adds a file read from open on each pass
+func (m *MultipartBody) AddFile(fieldName, fileName, contentType string, open func() (io.ReadCloser, error)) {
+	m.Parts = append(m.Parts, MultipartPart{FieldName: fieldName, FileName: fileName, ContentType: contentType, Open: open})
+}
+
+// ContentType is the Content-Type header of the body
+func (m *MultipartBody) ContentType() string {
+	return "multipart/form-data; boundary=" + m.Boundary
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// WriteTo writes the encoded body to w
+func (m *MultipartBody) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	mw := multipart.NewWriter(cw)
+	if err := mw.SetBoundary(m.Boundary); err != nil {
+		return cw.n, err
+	}
+	for _, part := range m.Parts {
+		h := textproto.MIMEHeader{}
+		disposition := `form-data; name="` + quoteEscaper.Replace(part.FieldName) + `"`
+		if part.FileName != "" {
+			disposition += `; filename="` + quoteEscaper.Replace(part.FileName) + `"`
+			contentType := part.ContentType
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			h.Set("Content-Type", contentType)
+		} else if part.ContentType != "" {
+			h.Set("Content-Type", part.ContentType)
+		}
+		h.Set("Content-Disposition", disposition)
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return cw.n, err
+		}
+		content, err := part.Open()
+		if err != nil {
+			return cw.n, err
+		}
+		_, err = io.Copy(pw, content)
+		content.Close()
+		if err != nil {
+			return cw.n, err
+		}
+	}
+	err := mw.Close()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// NewRequest returns a request sending the body, its length and hash come from a first
+// pass over the parts and the body streams the parts again when it is read
+func (m *MultipartBody) NewRequest(method, url string) (*http.Request, error) {
+	h := sha256.New()
+	n, err := m.WriteTo(h)
+	if err != nil {
+		return nil, err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	r, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", m.ContentType())
+	r.ContentLength = n
+	r.GetBody = func() (io.ReadCloser, error) {
+		return &multipartReader{body: m, hash: hash}, nil
+	}
+	r.Body, _ = r.GetBody()
+	return r, nil
+}
+
+// multipartReader streams a MultipartBody through a pipe started on the first read
+type multipartReader struct {
+	body   *MultipartBody
+	hash   string
+	mu     sync.Mutex
+	pr     *io.PipeReader
+	closed bool
+}
+
+func (r *multipartReader) PayloadHash() string {
+	return r.hash
+}
+
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	if r.pr == nil {
+		pr, pw := io.Pipe()
+		r.pr = pr
+		go func() {
+			_, err := r.body.WriteTo(pw)
+			pw.CloseWithError(err)
+		}()
+	}
+	pr := r.pr
+	r.mu.Unlock()
+	return pr.Read(p)
+}
+
+// Close stops the writer of a started body
+func (r *multipartReader) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	pr := r.pr
+	r.mu.Unlock()
+	if pr != nil {
+		return pr.Close()
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package sign4_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestMultipartBody(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.Verify(r); err != nil {
+			t.Error("upload rejected", err)
+		}
+		f, header, err := r.FormFile("file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b, _ := ioutil.ReadAll(f)
+		if r.FormValue("key") != "uploads/a b.txt" || header.Filename != `a "b".txt` || string(b) != strings.Repeat("data", 1000) {
+			t.Error("wrong form", r.Form, header.Filename, len(b))
+		}
+	}))
+	defer server.Close()
+	var opened int32
+	m := sign4.NewMultipartBody()
+	m.AddField("key", "uploads/a b.txt")
+	m.AddFile("file", `a "b".txt`, "text/plain", func() (io.ReadCloser, error) {
+		atomic.AddInt32(&opened, 1)
+		return ioutil.NopCloser(strings.NewReader(strings.Repeat("data", 1000))), nil
+	})
+	r, err := m.NewRequest("POST", server.URL+"/upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Body.(sign4.PayloadHasher); !ok || r.ContentLength <= 4000 {
+		t.Fatal("body not hashed ahead", r.ContentLength)
+	}
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&opened) != 1 {
+		t.Fatal("signing read the body", opened)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || atomic.LoadInt32(&opened) != 2 {
+		t.Fatal("wrong upload", resp.Status, opened)
+	}
+}
@@ -0,0 +1,143 @@
+// Package oci signs Oracle Cloud Infrastructure API requests with the
+// draft-cavage HTTP signature scheme OCI uses.
+//
+// See https://docs.oracle.com/en-us/iaas/Content/API/Concepts/signingrequests.htm
+package oci
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// Signer signs requests with an API signing key
+type Signer struct {
+	TenancyID   string
+	UserID      string
+	Fingerprint string
+	Key         *rsa.PrivateKey
+}
+
+var _ sign4.Signer = &Signer{}
+
+// KeyID returns the keyId of the Authorization header
+func (s *Signer) KeyID() string {
+	return s.TenancyID + "/" + s.UserID + "/" + s.Fingerprint
+}
+
+// ParsePrivateKey decodes a PEM encoded PKCS #1 or PKCS #8 RSA key
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an rsa key")
+	}
+	return rsaKey, nil
+}
+
+// withBody reports whether OCI expects the body headers to be signed for method
+func withBody(method string) bool {
+	return method == "POST" || method == "PUT" || method == "PATCH"
+}
+
+// headerValue returns the value of a lower case header name of r
+func headerValue(r *http.Request, name string) string {
+	switch name {
+	case "(request-target)":
+		return strings.ToLower(r.Method) + " " + r.URL.RequestURI()
+	case "host":
+		if r.Host != "" {
+			return r.Host
+		}
+		return r.URL.Host
+	case "content-length":
+		if v := r.Header.Get(name); v != "" {
+			return v
+		}
+		return strconv.FormatInt(r.ContentLength, 10)
+	}
+	return strings.TrimSpace(r.Header.Get(name))
+}
+
+// SigningString returns the string signed for the headers of r, in order
+func SigningString(r *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		lines[i] = h + ": " + headerValue(r, h)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SignRequest sets the date, x-content-sha256 and content-type headers when missing
+// and the Signature Authorization header. date, (request-target) and host are always
+// signed, POST, PUT and PATCH add content-length, content-type and x-content-sha256,
+// other headers of signedHeaders follow in sorted order
+func (s *Signer) SignRequest(r *http.Request, signedHeaders map[string]bool) error {
+	if s.Key == nil {
+		return errors.New("missing private key")
+	}
+	if r.Header.Get("Date") == "" {
+		r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	headers := []string{"date", "(request-target)", "host"}
+	if withBody(r.Method) {
+		body, err := sign4.RequestPayload(r)
+		if err != nil {
+			return err
+		}
+		if r.ContentLength == 0 {
+			r.ContentLength = int64(len(body))
+		}
+		if r.Header.Get("Content-Type") == "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		if r.Header.Get("X-Content-Sha256") == "" {
+			sum := sha256.Sum256(body)
+			r.Header.Set("X-Content-Sha256", base64.StdEncoding.EncodeToString(sum[:]))
+		}
+		headers = append(headers, "content-length", "content-type", "x-content-sha256")
+	}
+	var extra []string
+	for k := range signedHeaders {
+		k = strings.ToLower(k)
+		known := false
+		for _, h := range headers {
+			known = known || h == k
+		}
+		if !known {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	headers = append(headers, extra...)
+	digest := sha256.Sum256([]byte(SigningString(r, headers)))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", `Signature version="1",keyId="`+s.KeyID()+`",algorithm="rsa-sha256",headers="`+
+		strings.Join(headers, " ")+`",signature="`+base64.StdEncoding.EncodeToString(sig)+`"`)
+	return nil
+}
@@ -0,0 +1,67 @@
+package oci_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws/oci"
+)
+
+var authRe = regexp.MustCompile(`^Signature version="1",keyId="([^"]+)",algorithm="rsa-sha256",headers="([^"]+)",signature="([^"]+)"$`)
+
+func TestSignRequest(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	parsed, err := oci.ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &oci.Signer{TenancyID: "ocid1.tenancy.oc1..aaa", UserID: "ocid1.user.oc1..bbb", Fingerprint: "20:3b:97", Key: parsed}
+	body := `{"compartmentId":"ocid1.compartment.oc1..ccc"}`
+	r, _ := http.NewRequest("POST", "https://iaas.us-phoenix-1.oraclecloud.com/20160918/vcns", strings.NewReader(body))
+	r.Header.Set("Date", "Thu, 05 Jan 2014 21:31:40 GMT")
+	r.Header.Set("opc-request-id", "req-1")
+	if err := s.SignRequest(r, map[string]bool{"opc-request-id": true}); err != nil {
+		t.Fatal(err)
+	}
+	m := authRe.FindStringSubmatch(r.Header.Get("Authorization"))
+	if m == nil {
+		t.Fatal("wrong authorization", r.Header.Get("Authorization"))
+	}
+	if m[1] != "ocid1.tenancy.oc1..aaa/ocid1.user.oc1..bbb/20:3b:97" ||
+		m[2] != "date (request-target) host content-length content-type x-content-sha256 opc-request-id" {
+		t.Fatal("wrong key id or headers", m[1], m[2])
+	}
+	sum := sha256.Sum256([]byte(body))
+	if got := r.Header.Get("X-Content-Sha256"); got != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Fatal("wrong x-content-sha256", got)
+	}
+	want := "date: Thu, 05 Jan 2014 21:31:40 GMT\n(request-target): post /20160918/vcns\nhost: iaas.us-phoenix-1.oraclecloud.com\n" +
+		"content-length: 46\ncontent-type: application/json\nx-content-sha256: " + r.Header.Get("X-Content-Sha256") + "\nopc-request-id: req-1"
+	signing := oci.SigningString(r, strings.Fields(m[2]))
+	if signing != want {
+		t.Fatalf("wrong signing string\n%s\n%s", signing, want)
+	}
+	sig, _ := base64.StdEncoding.DecodeString(m[3])
+	digest := sha256.Sum256([]byte(signing))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ = http.NewRequest("GET", "https://iaas.us-phoenix-1.oraclecloud.com/20160918/instances?compartmentId=ocid1", nil)
+	s.SignRequest(r, nil)
+	if m = authRe.FindStringSubmatch(r.Header.Get("Authorization")); m == nil || m[2] != "date (request-target) host" || r.Header.Get("Date") == "" {
+		t.Fatal("wrong get authorization", r.Header)
+	}
+	if !strings.Contains(oci.SigningString(r, strings.Fields(m[2])), "(request-target): get /20160918/instances?compartmentId=ocid1\n") {
+		t.Fatal("wrong request target")
+	}
+}
@@ -0,0 +1,309 @@
+// Package opensearch provides a bulk indexer for Amazon OpenSearch Service domains signed with sign4.
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/transport"
+)
+
+// Bulk actions
+const (
+	ActionIndex  = "index"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Item is one bulk operation
+type Item struct {
+	// Action defaults to index
+	Action string
+	// Index defaults to BulkIndexer.Index
+	Index string
+	ID    string
+	// Body is the document, or the update body for ActionUpdate, unused for ActionDelete
+	Body json.RawMessage
+	// OnFailure is called when the item is rejected, exhausts its retries or its _bulk
+	// request fails as a whole
+	OnFailure func(Item, *ItemError)
+}
+
+// ItemError describes a rejected item, Type is "bulk_request_failed" for the items of a
+// _bulk request that failed as a whole, Status is 0 when no response was received
+type ItemError struct {
+	Status int    `json:"-"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("opensearch: %d %s: %s", e.Status, e.Type, e.Reason)
+}
+
+// Stats counts processed items
+type Stats struct {
+	Flushed uint64
+	Indexed uint64
+	Failed  uint64
+	Retried uint64
+}
+
+// BulkIndexer batches items into _bulk requests
+type BulkIndexer struct {
+	// Endpoint is the domain endpoint, e.g. https://search-logs-abc.eu-west-1.es.amazonaws.com
+	Endpoint   string
+	Index      string
+	HTTPClient *http.Client
+	// FlushBytes is the ndjson size triggering a flush, default 5MB
+	FlushBytes int
+	// MaxRetries for items rejected with 429, default 5
+	MaxRetries int
+	// Backoff returns the delay before retry attempt n, default 100ms doubling up to 10s
+	Backoff func(n int) time.Duration
+
+	mu    sync.Mutex
+	items []Item
+	size  int
+	stats Stats
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	closeOnce sync.Once
+}
+
+// NewBulkIndexer returns an indexer sending items to endpoint through a signing transport, set flushInterval to 0 to only flush on size
+func NewBulkIndexer(s *sign4.Signature, endpoint, index string, flushInterval time.Duration) *BulkIndexer {
+	b := &BulkIndexer{
+		Endpoint:   endpoint,
+		Index:      index,
+		HTTPClient: transport.New(s).Client(),
+		done:       make(chan struct{}),
+	}
+	if flushInterval > 0 {
+		b.wg.Add(1)
+		go b.loop(flushInterval)
+	}
+	return b
+}
+
+func (b *BulkIndexer) loop(interval time.Duration) {
+	defer b.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.Flush(context.Background())
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// Add queues an item, flushing when the batch reaches FlushBytes
+func (b *BulkIndexer) Add(ctx context.Context, item Item) error {
+	if item.Action == "" {
+		item.Action = ActionIndex
+	}
+	if item.Index == "" {
+		item.Index = b.Index
+	}
+	b.mu.Lock()
+	b.items = append(b.items, item)
+	b.size += len(item.Body) + len(item.Index) + len(item.ID) + 32
+	full := b.size >= b.flushBytes()
+	b.mu.Unlock()
+	if full {
+		return b.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush sends the queued items, retrying items rejected with 429. When a _bulk request
+// fails, its items are counted as failed and handed to their OnFailure before the error
+// is returned, they aren't queued again
+func (b *BulkIndexer) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	items := b.items
+	b.items = nil
+	b.size = 0
+	b.mu.Unlock()
+	for attempt := 0; len(items) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > b.maxRetries() {
+				for _, item := range items {
+					b.fail(item, &ItemError{Status: http.StatusTooManyRequests, Type: "retries_exhausted", Reason: "too many requests"})
+				}
+				return nil
+			}
+			b.mu.Lock()
+			b.stats.Retried += uint64(len(items))
+			b.mu.Unlock()
+			select {
+			case <-time.After(b.backoff(attempt)):
+			case <-ctx.Done():
+				b.failBatch(items, ctx.Err())
+				return ctx.Err()
+			}
+		}
+		sent := items
+		var err error
+		if items, err = b.send(ctx, sent); err != nil {
+			b.failBatch(sent, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops the flush ticker and flushes the remaining items, it is safe to call again
+func (b *BulkIndexer) Close(ctx context.Context) error {
+	b.closeOnce.Do(func() { close(b.done) })
+	b.wg.Wait()
+	return b.Flush(ctx)
+}
+
+// Stats returns the counters
+func (b *BulkIndexer) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// send posts items and returns those to retry
+func (b *BulkIndexer) send(ctx context.Context, items []Item) ([]Item, error) {
+	var body bytes.Buffer
+	for _, item := range items {
+		meta := map[string]map[string]string{item.Action: {"_index": item.Index}}
+		if item.ID != "" {
+			meta[item.Action]["_id"] = item.ID
+		}
+		line, err := json.Marshal(meta)
+		if err != nil {
+			return nil, err
+		}
+		body.Write(line)
+		body.WriteByte('\n')
+		if item.Action != ActionDelete {
+			body.Write(bytes.TrimSpace(item.Body))
+			body.WriteByte('\n')
+		}
+	}
+	r, err := http.NewRequest("POST", strings.TrimSuffix(b.Endpoint, "/")+"/_bulk", &body)
+	if err != nil {
+		return nil, err
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := b.HTTPClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.stats.Flushed++
+	b.mu.Unlock()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return items, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &ItemError{Status: resp.StatusCode, Type: "bulk_request_failed", Reason: string(data)}
+	}
+	var result struct {
+		Errors bool                         `json:"errors"`
+		Items  []map[string]json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Items) != len(items) {
+		return nil, errors.New("opensearch: bulk response item count mismatch")
+	}
+	var retry []Item
+	indexed := 0
+	for i, entry := range result.Items {
+		var status struct {
+			Status int        `json:"status"`
+			Error  *ItemError `json:"error"`
+		}
+		for _, v := range entry {
+			json.Unmarshal(v, &status)
+		}
+		switch {
+		case status.Status == http.StatusTooManyRequests:
+			retry = append(retry, items[i])
+		case status.Status >= 300 || status.Error != nil:
+			if status.Error == nil {
+				status.Error = &ItemError{}
+			}
+			status.Error.Status = status.Status
+			b.fail(items[i], status.Error)
+		default:
+			indexed++
+		}
+	}
+	b.mu.Lock()
+	b.stats.Indexed += uint64(indexed)
+	b.mu.Unlock()
+	return retry, nil
+}
+
+func (b *BulkIndexer) fail(item Item, e *ItemError) {
+	b.mu.Lock()
+	b.stats.Failed++
+	b.mu.Unlock()
+	if item.OnFailure != nil {
+		item.OnFailure(item, e)
+	}
+}
+
+// failBatch fails the items of a _bulk request that failed with err
+func (b *BulkIndexer) failBatch(items []Item, err error) {
+	e, ok := err.(*ItemError)
+	if !ok {
+		e = &ItemError{Type: "bulk_request_failed", Reason: err.Error()}
+	}
+	for _, item := range items {
+		b.fail(item, e)
+	}
+}
+
+func (b *BulkIndexer) flushBytes() int {
+	if b.FlushBytes > 0 {
+		return b.FlushBytes
+	}
+	return 5 * 1024 * 1024
+}
+
+func (b *BulkIndexer) maxRetries() int {
+	if b.MaxRetries > 0 {
+		return b.MaxRetries
+	}
+	return 5
+}
+
+func (b *BulkIndexer) backoff(n int) time.Duration {
+	if b.Backoff != nil {
+		return b.Backoff(n)
+	}
+	d := 100 * time.Millisecond << uint(n-1)
+	if d > 10*time.Second || d <= 0 {
+		d = 10 * time.Second
+	}
+	return d
+}
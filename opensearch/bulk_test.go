@@ -0,0 +1,99 @@
+package opensearch_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/opensearch"
+)
+
+func TestBulkIndexer(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/_bulk" || r.Header.Get("Authorization") == "" {
+			t.Error("wrong bulk request", r.URL.Path)
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		var items []map[string]interface{}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var meta map[string]map[string]string
+			json.Unmarshal(scanner.Bytes(), &meta)
+			id := meta["index"]["_id"]
+			scanner.Scan()
+			status := 201
+			var e interface{}
+			switch {
+			case id == "bad":
+				status = 400
+				e = map[string]string{"type": "mapper_parsing_exception", "reason": "failed to parse"}
+			case id == "busy" && calls == 2:
+				status = 429
+			}
+			items = append(items, map[string]interface{}{"index": map[string]interface{}{"_id": id, "status": status, "error": e}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": true, "items": items})
+	}))
+	defer server.Close()
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "eu-west-1", Service: "es"}
+	b := opensearch.NewBulkIndexer(s, server.URL, "logs", 0)
+	b.Backoff = func(int) time.Duration { return time.Millisecond }
+	var failed *opensearch.ItemError
+	for _, id := range []string{"a", "bad", "busy"} {
+		item := opensearch.Item{ID: id, Body: json.RawMessage(`{"msg":"hello"}`)}
+		item.OnFailure = func(_ opensearch.Item, e *opensearch.ItemError) { failed = e }
+		if err := b.Add(context.Background(), item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stats := b.Stats()
+	if stats.Indexed != 2 || stats.Failed != 1 || calls != 3 {
+		t.Fatal("wrong stats", stats, calls)
+	}
+	if failed == nil || failed.Status != 400 || failed.Type != "mapper_parsing_exception" {
+		t.Fatal("wrong item error", failed)
+	}
+	if err := b.Close(context.Background()); err != nil || calls != 3 {
+		t.Fatal("second Close", err, calls)
+	}
+}
+
+func TestBulkIndexerRequestFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "cluster unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "eu-west-1", Service: "es"}
+	b := opensearch.NewBulkIndexer(s, server.URL, "logs", 0)
+	var failed []*opensearch.ItemError
+	for _, id := range []string{"a", "b"} {
+		item := opensearch.Item{ID: id, Body: json.RawMessage(`{"msg":"hello"}`)}
+		item.OnFailure = func(_ opensearch.Item, e *opensearch.ItemError) { failed = append(failed, e) }
+		b.Add(context.Background(), item)
+	}
+	if err := b.Flush(context.Background()); err == nil {
+		t.Fatal("failed bulk request not reported")
+	}
+	if stats := b.Stats(); stats.Failed != 2 || len(failed) != 2 || failed[0].Status != http.StatusServiceUnavailable {
+		t.Fatal("items of the failed request not failed", stats, failed)
+	}
+
+	server.Close()
+	b.Add(context.Background(), opensearch.Item{ID: "c", Body: json.RawMessage(`{}`)})
+	if err := b.Flush(context.Background()); err == nil || b.Stats().Failed != 3 {
+		t.Fatal("items of an unsent request not failed", err, b.Stats())
+	}
+}
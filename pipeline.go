@@ -0,0 +1,96 @@
+package sign4
+
+// A worker pool signing requests submitted by many producers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"sync"
+)
+
+// ErrPipelineClosed is returned when submitting to a closed Pipeline
+var ErrPipelineClosed = errors.New("signing pipeline closed")
+
+// Pending is a request submitted to a Pipeline, signed once Done is closed
+type Pending struct {
+	Request *http.Request
+	err     error
+	done    chan struct{}
+}
+
+// Done is closed once the request is signed or failed
+func (p *Pending) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait blocks until the request is signed and returns it
+func (p *Pending) Wait() (*http.Request, error) {
+	<-p.done
+	return p.Request, p.err
+}
+
+// Pipeline signs requests with a fixed number of workers, producers submit without
+// signing on their own goroutines. The workers share s, so its credentials and the
+// derived signing key are read from the same caches
+type Pipeline struct {
+	s             *Signature
+	signedHeaders map[string]bool
+	queue         chan *Pending
+	mu            sync.RWMutex
+	closed        bool
+	wg            sync.WaitGroup
+}
+
+// NewPipeline starts workers signing with s, GOMAXPROCS of them when workers is zero.
+// At most queue requests wait for a worker, Submit blocks beyond that
+func (s *Signature) NewPipeline(workers, queue int, signedHeaders map[string]bool) *Pipeline {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if queue < 0 {
+		queue = 0
+	}
+	p := &Pipeline{s: s, signedHeaders: signedHeaders, queue: make(chan *Pending, queue)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pipeline) work() {
+	defer p.wg.Done()
+	for pending := range p.queue {
+		pending.err = p.s.SignRequest(pending.Request, p.signedHeaders)
+		close(pending.done)
+	}
+}
+
+// Submit queues r for signing, waiting for room in the queue until ctx is done
+func (p *Pipeline) Submit(ctx context.Context, r *http.Request) (*Pending, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return nil, ErrPipelineClosed
+	}
+	pending := &Pending{Request: r, done: make(chan struct{})}
+	select {
+	case p.queue <- pending:
+		return pending, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops accepting requests and returns once the queued ones are signed
+func (p *Pipeline) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
@@ -0,0 +1,79 @@
+package sign4_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestPipeline(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "kinesis"}
+	p := s.NewPipeline(4, 16, nil)
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	var wg sync.WaitGroup
+	for producer := 0; producer < 8; producer++ {
+		wg.Add(1)
+		go func(producer int) {
+			defer wg.Done()
+			pending := make([]*sign4.Pending, 0, 100)
+			for i := 0; i < 100; i++ {
+				r, _ := http.NewRequest("POST", "https://kinesis.us-east-1.amazonaws.com/", strings.NewReader(`{"PartitionKey":"`+strconv.Itoa(producer*100+i)+`"}`))
+				f, err := p.Submit(context.Background(), r)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				pending = append(pending, f)
+			}
+			for _, f := range pending {
+				r, err := f.Wait()
+				if err == nil {
+					_, err = v.Verify(r)
+				}
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}(producer)
+	}
+	wg.Wait()
+	p.Close()
+	r, _ := http.NewRequest("POST", "https://kinesis.us-east-1.amazonaws.com/", nil)
+	if _, err := p.Submit(context.Background(), r); err != sign4.ErrPipelineClosed {
+		t.Fatal("closed pipeline accepted a request", err)
+	}
+}
+
+// blockingProvider holds signing until release is closed
+type blockingProvider struct{ release chan struct{} }
+
+func (b blockingProvider) Credentials() (sign4.Credentials, error) {
+	<-b.release
+	return sign4.Credentials{AccessKey: "AKIDEXAMPLE", SecretKey: "secret"}, nil
+}
+
+func TestPipelineBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	s := &sign4.Signature{Provider: blockingProvider{release}, Region: "us-east-1", Service: "firehose"}
+	p := s.NewPipeline(1, 0, nil)
+	r, _ := http.NewRequest("POST", "https://firehose.us-east-1.amazonaws.com/", nil)
+	first, err := p.Submit(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Submit(ctx, r.Clone(ctx)); err != context.Canceled {
+		t.Fatal("submitted past a full queue", err)
+	}
+	close(release)
+	if _, err := first.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+}
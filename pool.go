@@ -0,0 +1,283 @@
+package sign4
+
+// Pooled scratch state for the signing hot path
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// emptyPayloadHash is the hex sha256 of an empty payload
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+type scratch struct {
+	hash  hash.Hash
+	buf   []byte
+	sts   []byte
+	lower []byte
+	keys  []string
+	vals  []string
+	sum   [sha256.Size]byte
+	hex   [sha256.Size * 2]byte
+}
+
+// copyBufPool holds the large read buffers used to stream bodies into the hasher
+var copyBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 1024*1024)
+		return &b
+	},
+}
+
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		return &scratch{hash: sha256.New()}
+	},
+}
+
+func getScratch() *scratch {
+	return scratchPool.Get().(*scratch)
+}
+
+func putScratch(sc *scratch) {
+	// don't keep very large buffers alive
+	if cap(sc.buf) > 64*1024 {
+		sc.buf = nil
+	}
+	sc.keys = sc.keys[:0]
+	sc.vals = sc.vals[:0]
+	scratchPool.Put(sc)
+}
+
+// Len, Less and Swap sort keys by lower case name
+func (sc *scratch) Len() int           { return len(sc.keys) }
+func (sc *scratch) Less(i, j int) bool { return foldLess(sc.keys[i], sc.keys[j]) }
+func (sc *scratch) Swap(i, j int)      { sc.keys[i], sc.keys[j] = sc.keys[j], sc.keys[i] }
+
+func lowerByte(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func foldLess(a, b string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ca, cb := lowerByte(a[i]), lowerByte(b[i])
+		if ca != cb {
+			return ca < cb
+		}
+	}
+	return len(a) < len(b)
+}
+
+func appendLower(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		b = append(b, lowerByte(s[i]))
+	}
+	return b
+}
+
+// signedKeys collects and sorts the header keys to sign, it reports whether the host line is added from r.Host
+func (sc *scratch) signedKeys(r *http.Request, signedHeaders map[string]bool) bool {
+	sc.keys = sc.keys[:0]
+	for key := range r.Header {
+		if isPseudoHeader(key) {
+			continue
+		}
+		if len(signedHeaders) != 0 {
+			sc.lower = appendLower(sc.lower[:0], key)
+			if !signedHeaders[string(sc.lower)] {
+				continue
+			}
+		}
+		sc.keys = append(sc.keys, key)
+	}
+	sort.Sort(sc)
+	return headerValue(r.Header, "host") == "" || !signedHeaders["host"]
+}
+
+// appendSignedHeaders appends the ';' separated signedheaders list
+func (sc *scratch) appendSignedHeaders(b []byte, withHost bool) []byte {
+	for i, key := range sc.keys {
+		if withHost && foldLess("host", key) {
+			b = append(b, "host;"...)
+			withHost = false
+		}
+		b = appendLower(b, key)
+		if i < len(sc.keys)-1 {
+			b = append(b, ';')
+		}
+	}
+	if withHost {
+		if len(sc.keys) > 0 {
+			b = append(b, ';')
+		}
+		b = append(b, "host"...)
+	}
+	return b
+}
+
+// appendCanonicalHeaders appends the canonical headers of the keys collected by signedKeys
+func (sc *scratch) appendCanonicalHeaders(b []byte, r *http.Request, o *Options, withHost bool) []byte {
+	for _, key := range sc.keys {
+		if withHost && foldLess("host", key) {
+			b = append(b, "host:"...)
+			b = appendHost(b, requestHost(r))
+			b = append(b, '\n')
+			withHost = false
+		}
+		b = appendLower(b, key)
+		b = append(b, ':')
+		values, trim := r.Header[key], FoldHeaderValue
+		if !o.StrictHeaderValues {
+			if len(values) > 1 {
+				sc.vals = append(sc.vals[:0], values...)
+				sort.Strings(sc.vals)
+				values = sc.vals
+			}
+			trim = trimQuotedString
+		}
+		for i, v := range values {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = append(b, trim(v)...)
+		}
+		b = append(b, '\n')
+	}
+	if withHost {
+		b = append(b, "host:"...)
+		b = appendHost(b, requestHost(r))
+		b = append(b, '\n')
+	}
+	return b
+}
+
+// appendCanonicalRequest builds the canonical request of r into sc.buf
+func (sc *scratch) appendCanonicalRequest(r *http.Request, o *Options, rules *ServiceRules, signedHeaders map[string]bool, payloadHash string) {
+	withHost := sc.signedKeys(r, signedHeaders)
+	b := append(sc.buf[:0], r.Method...)
+	b = append(b, '\n')
+	b = append(b, canonicalPath(r.URL.EscapedPath(), rules)...)
+	b = append(b, '\n')
+	if o.RawQuery {
+		b = append(b, rawCanonicalQueryString(r.URL.RawQuery, o.BareEmptyQueryKeys)...)
+	} else {
+		b = append(b, canonicalQueryString(r, o.BareEmptyQueryKeys)...)
+	}
+	b = append(b, '\n')
+	b = sc.appendCanonicalHeaders(b, r, o, withHost)
+	b = append(b, '\n')
+	b = sc.appendSignedHeaders(b, withHost)
+	b = append(b, '\n')
+	sc.buf = append(b, payloadHash...)
+}
+
+// payloadHash returns the hex sha256 of the request body, unbuffered hashes a copy from
+// r.GetBody or seeks a seekable body back instead of buffering it into r.Body
+func (sc *scratch) payloadHash(r *http.Request, unbuffered bool) (string, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return emptyPayloadHash, nil
+	}
+	if h, ok := r.Body.(PayloadHasher); ok {
+		return h.PayloadHash(), nil
+	}
+	// regular files are hashed in place instead of being read into memory
+	if hash, ok, err := hashFile(r.Body); ok || err != nil {
+		return hash, err
+	}
+	if unbuffered {
+		if ok, err := sc.hashUnbuffered(r); ok || err != nil {
+			if err != nil {
+				return "", err
+			}
+			hex.Encode(sc.hex[:], sc.hash.Sum(sc.sum[:0]))
+			return string(sc.hex[:]), nil
+		}
+	}
+	data, err := RequestPayload(r)
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return emptyPayloadHash, nil
+	}
+	sc.hash.Reset()
+	sc.hash.Write(data)
+	hex.Encode(sc.hex[:], sc.hash.Sum(sc.sum[:0]))
+	return string(sc.hex[:]), nil
+}
+
+func (sc *scratch) hashUnbuffered(r *http.Request) (bool, error) {
+	copyBuf := copyBufPool.Get().(*[]byte)
+	defer copyBufPool.Put(copyBuf)
+	sc.hash.Reset()
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return false, err
+		}
+		defer body.Close()
+		_, err = io.CopyBuffer(sc.hash, body, *copyBuf)
+		return true, err
+	}
+	seeker, ok := r.Body.(io.Seeker)
+	if !ok {
+		return false, nil
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, nil
+	}
+	if _, err := io.CopyBuffer(sc.hash, r.Body, *copyBuf); err != nil {
+		return true, err
+	}
+	_, err = seeker.Seek(offset, io.SeekStart)
+	return true, err
+}
+
+// appendScope appends the credential scope
+func appendScope(b []byte, p *Profile, t time.Time, regionName, serviceName string) []byte {
+	b = t.UTC().AppendFormat(b, BasicDateFormatShort)
+	b = append(b, '/')
+	b = append(b, regionName...)
+	b = append(b, '/')
+	b = append(b, serviceName...)
+	b = append(b, '/')
+	return append(b, p.Terminator...)
+}
+
+// appendStringToSign builds the string to sign of the canonical request in sc.buf into sc.sts
+func (sc *scratch) appendStringToSign(p *Profile, t time.Time, regionName, serviceName string) {
+	sc.hash.Reset()
+	sc.hash.Write(sc.buf)
+	hex.Encode(sc.hex[:], sc.hash.Sum(sc.sum[:0]))
+	sc.appendHashToSign(sc.hex[:], p, t, regionName, serviceName)
+}
+
+// appendHashToSign builds the string to sign of a hex canonical request hash into sc.sts
+func (sc *scratch) appendHashToSign(hash []byte, p *Profile, t time.Time, regionName, serviceName string) {
+	b := append(sc.sts[:0], p.Algorithm...)
+	b = append(b, '\n')
+	b = t.UTC().AppendFormat(b, BasicDateFormat)
+	b = append(b, '\n')
+	b = appendScope(b, p, t, regionName, serviceName)
+	b = append(b, '\n')
+	sc.sts = append(b, hash...)
+}
+
+// sign returns the hex signature of sc.sts into sc.hex
+func (sc *scratch) sign(key *signingKey) []byte {
+	mac := key.mac()
+	mac.Write(sc.sts)
+	hex.Encode(sc.hex[:], mac.Sum(sc.sum[:0]))
+	key.put(mac)
+	return sc.hex[:]
+}
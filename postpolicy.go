@@ -0,0 +1,312 @@
+package sign4
+
+// Browser-based S3 uploads, a signed policy document in a multipart/form-data POST
+// instead of a signed request
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPolicyFormSize bounds the form fields before the file, as S3 does
+const maxPolicyFormSize = 20 * 1024
+
+// ErrPolicyExpired is returned for an upload after the expiration of its policy
+var ErrPolicyExpired = errors.New("post policy expired")
+
+// PolicyCondition is a condition of a POST policy, Op is "eq", "starts-with" or
+// "content-length-range"; Field has no leading $
+type PolicyCondition struct {
+	Op       string
+	Field    string
+	Value    string
+	Min, Max int64
+}
+
+// EqCondition requires a form field to be value
+func EqCondition(field, value string) PolicyCondition {
+	return PolicyCondition{Op: "eq", Field: field, Value: value}
+}
+
+// StartsWithCondition requires a form field to start with prefix, an empty prefix allows any value
+func StartsWithCondition(field, prefix string) PolicyCondition {
+	return PolicyCondition{Op: "starts-with", Field: field, Value: prefix}
+}
+
+// ContentLengthRange bounds the size of the uploaded file
+func ContentLengthRange(min, max int64) PolicyCondition {
+	return PolicyCondition{Op: "content-length-range", Min: min, Max: max}
+}
+
+// MarshalJSON writes the array form of the condition
+func (c PolicyCondition) MarshalJSON() ([]byte, error) {
+	if c.Op == "content-length-range" {
+		return json.Marshal([]interface{}{c.Op, c.Min, c.Max})
+	}
+	return json.Marshal([]string{c.Op, "$" + c.Field, c.Value})
+}
+
+// UnmarshalJSON reads the array and the {"field": "value"} forms of a condition
+func (c *PolicyCondition) UnmarshalJSON(b []byte) error {
+	var exact map[string]string
+	if json.Unmarshal(b, &exact) == nil {
+		if len(exact) != 1 {
+			return errors.New("post policy condition has more than one field")
+		}
+		for field, value := range exact {
+			*c = EqCondition(field, value)
+		}
+		return nil
+	}
+	var a []json.RawMessage
+	if err := json.Unmarshal(b, &a); err != nil || len(a) != 3 {
+		return errors.New("malformed post policy condition")
+	}
+	if err := json.Unmarshal(a[0], &c.Op); err != nil {
+		return err
+	}
+	c.Op = strings.ToLower(c.Op)
+	switch c.Op {
+	case "content-length-range":
+		if json.Unmarshal(a[1], &c.Min) != nil || json.Unmarshal(a[2], &c.Max) != nil {
+			return errors.New("malformed content-length-range")
+		}
+		return nil
+	case "eq", "starts-with":
+		if json.Unmarshal(a[1], &c.Field) != nil || json.Unmarshal(a[2], &c.Value) != nil || !strings.HasPrefix(c.Field, "$") {
+			return errors.New("malformed post policy condition")
+		}
+		c.Field = c.Field[1:]
+		return nil
+	}
+	return errors.New("unknown post policy condition " + c.Op)
+}
+
+// PostPolicy is the policy document of an upload form
+type PostPolicy struct {
+	Expiration time.Time         `json:"expiration"`
+	Conditions []PolicyCondition `json:"conditions"`
+}
+
+// PolicyConditionError is returned when a form field breaks or isn't covered by the policy
+type PolicyConditionError struct {
+	Field     string
+	Condition string
+}
+
+func (e *PolicyConditionError) Error() string {
+	return "post policy condition failed for " + e.Field + ": " + e.Condition
+}
+
+// SignPostPolicy signs policy for an upload form expiring after expires from now and
+// returns the form fields to send before the file, the credential, date and algorithm
+// conditions are added to the policy
+func (s *Signature) SignPostPolicy(policy PostPolicy, expires time.Duration) (map[string]string, error) {
+	creds, err := s.signingCredentials()
+	if err != nil {
+		return nil, err
+	}
+	p := s.profile()
+	t := s.now()
+	fields := map[string]string{
+		"x-amz-algorithm":  p.Algorithm,
+		"x-amz-credential": creds.AccessKey + "/" + string(appendScope(nil, p, t, s.Region, s.Service)),
+		"x-amz-date":       t.UTC().Format(BasicDateFormat),
+	}
+	if creds.SessionToken != "" {
+		fields["x-amz-security-token"] = creds.SessionToken
+	}
+	policy.Expiration = t.Add(expires).UTC().Truncate(time.Millisecond)
+	policy.Conditions = append([]PolicyCondition(nil), policy.Conditions...)
+	for _, field := range []string{"x-amz-algorithm", "x-amz-credential", "x-amz-date", "x-amz-security-token"} {
+		if v, ok := fields[field]; ok {
+			policy.Conditions = append(policy.Conditions, EqCondition(field, v))
+		}
+	}
+	doc, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	fields["policy"] = base64.StdEncoding.EncodeToString(doc)
+	key, err := s.signingKey(p, creds.SecretKey, t)
+	if err != nil {
+		return nil, err
+	}
+	fields["x-amz-signature"] = policySignature(key.key, fields["policy"])
+	return fields, nil
+}
+
+func policySignature(key []byte, policy string) string {
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, policy)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PostUpload is a verified upload form, File streams the file part and fails with a
+// *PolicyConditionError when its size leaves the content-length-range of the policy
+type PostUpload struct {
+	Signature *Signature
+	// Fields holds the form fields by lower case name, with bucket when it was given
+	Fields      map[string]string
+	Policy      PostPolicy
+	FileName    string
+	ContentType string
+	File        io.Reader
+}
+
+// VerifyPostPolicy reads the form fields of a POST policy upload to bucket up to the file
+// part and checks the policy signature, expiry and conditions, an empty bucket leaves
+// the bucket conditions unchecked
+func (v *Verifier) VerifyPostPolicy(r *http.Request, bucket string) (*PostUpload, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	u := &PostUpload{Fields: map[string]string{}}
+	size := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("post policy upload has no file")
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.ToLower(part.FormName())
+		if name == "file" {
+			u.FileName, u.ContentType, u.File = part.FileName(), part.Header.Get("Content-Type"), part
+			break
+		}
+		value, err := ioutil.ReadAll(io.LimitReader(part, int64(maxPolicyFormSize-size+1)))
+		if err != nil {
+			return nil, err
+		}
+		if size += len(value); size > maxPolicyFormSize {
+			return nil, errors.New("post policy form fields too large")
+		}
+		u.Fields[name] = string(value)
+	}
+	if bucket != "" {
+		u.Fields["bucket"] = bucket
+	}
+	if u.Signature, err = v.checkPolicySignature(u.Fields); err != nil {
+		return nil, err
+	}
+	doc, err := base64.StdEncoding.DecodeString(u.Fields["policy"])
+	if err != nil {
+		return nil, errors.New("malformed post policy")
+	}
+	if err := json.Unmarshal(doc, &u.Policy); err != nil {
+		return nil, err
+	}
+	if !v.Options.now().Before(u.Policy.Expiration) {
+		return nil, ErrPolicyExpired
+	}
+	if err := u.checkConditions(bucket != ""); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// checkPolicySignature checks x-amz-signature against the policy field
+func (v *Verifier) checkPolicySignature(fields map[string]string) (*Signature, error) {
+	for _, field := range []string{"policy", "x-amz-algorithm", "x-amz-credential", "x-amz-date", "x-amz-signature"} {
+		if fields[field] == "" {
+			return nil, errors.New("post policy upload has no " + field)
+		}
+	}
+	var p *Profile
+	for _, profile := range Profiles {
+		if profile.Algorithm == fields["x-amz-algorithm"] {
+			p = profile
+		}
+	}
+	if p == nil {
+		return nil, errors.New("unknown post policy algorithm")
+	}
+	s, err := getCredential("Credential="+fields["x-amz-credential"], p)
+	if err != nil {
+		return nil, err
+	}
+	if v.AllowScope != nil && !v.AllowScope(s.Region, s.Service) {
+		return nil, ErrScopeNotAllowed
+	}
+	t, err := time.Parse(BasicDateFormat, fields["x-amz-date"])
+	if err != nil {
+		return nil, errors.New("wrong post policy date")
+	}
+	profile := s.Profile
+	s.Options = v.Options
+	s.Profile = profile
+	if s.SecretKey, err = v.SecretKey(s.AccessKey); err != nil {
+		return nil, err
+	}
+	key, err := s.signingKey(s.profile(), s.SecretKey, t)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(policySignature(key.key, fields["policy"])), []byte(fields["x-amz-signature"])) != 1 {
+		return nil, ErrSignatureMismatch
+	}
+	return s, nil
+}
+
+// checkConditions matches the fields against the policy, every field other than policy,
+// x-amz-signature and x-ignore-* has to be covered by a condition
+func (u *PostUpload) checkConditions(checkBucket bool) error {
+	covered := map[string]bool{"policy": true, "x-amz-signature": true}
+	if !checkBucket {
+		covered["bucket"] = true
+	}
+	for _, c := range u.Policy.Conditions {
+		if c.Op == "content-length-range" {
+			u.File = &policyLengthReader{r: u.File, min: c.Min, max: c.Max}
+			continue
+		}
+		field := strings.ToLower(c.Field)
+		if field == "bucket" && !checkBucket {
+			continue
+		}
+		covered[field] = true
+		value := u.Fields[field]
+		if c.Op == "eq" && value != c.Value || c.Op == "starts-with" && !strings.HasPrefix(value, c.Value) {
+			return &PolicyConditionError{Field: field, Condition: c.Op + " " + strconv.Quote(c.Value)}
+		}
+	}
+	for field := range u.Fields {
+		if !covered[field] && !strings.HasPrefix(field, "x-ignore-") {
+			return &PolicyConditionError{Field: field, Condition: "not in the policy"}
+		}
+	}
+	return nil
+}
+
+// policyLengthReader enforces a content-length-range on the file part
+type policyLengthReader struct {
+	r        io.Reader
+	min, max int64
+	n        int64
+}
+
+func (l *policyLengthReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n, &PolicyConditionError{Field: "file", Condition: "content-length-range over " + strconv.FormatInt(l.max, 10)}
+	}
+	if err == io.EOF && l.n < l.min {
+		return n, &PolicyConditionError{Field: "file", Condition: "content-length-range under " + strconv.FormatInt(l.min, 10)}
+	}
+	return n, err
+}
@@ -0,0 +1,221 @@
+package sign4
+
+// Prepared requests keep the canonical parts that don't change between sends
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dateMarker, hashMarker and tokenMarker stand in for the date, X-Amz-Content-Sha256
+// and session token header values while preparing
+const (
+	dateMarker  = "\x00date\x00"
+	hashMarker  = "\x00sha256\x00"
+	tokenMarker = "\x00token\x00"
+)
+
+// ErrPreparedSessionToken is returned by PreparedRequest.Sign when the credentials gained
+// or lost a session token since the request was prepared, its signed headers would change
+var ErrPreparedSessionToken = errors.New("session token added or removed since the request was prepared")
+
+// PreparedRequest re-signs a request template with new dates and bodies
+// without canonicalizing its path, query and headers again
+type PreparedRequest struct {
+	signature Signature
+	template  *http.Request
+	// parts are the canonical request around markers, parts[i] is followed by markers[i]
+	parts   []string
+	markers []string
+	// payloadHash is the X-Amz-Content-Sha256 the template was given, "" hashes the body
+	payloadHash string
+	// contentSHA256 sends the body hash as X-Amz-Content-Sha256
+	contentSHA256 bool
+	// sessionToken sends the session token of the credentials each Sign reads
+	sessionToken  bool
+	signedHeaders string
+}
+
+// splitMarkers splits canonical at the markers it holds once each
+func splitMarkers(canonical string, markers ...string) ([]string, []string, error) {
+	var parts, found []string
+	for canonical != "" {
+		at, marker := -1, ""
+		for _, m := range markers {
+			if i := strings.Index(canonical, m); i >= 0 && (at < 0 || i < at) {
+				at, marker = i, m
+			}
+		}
+		if at < 0 {
+			break
+		}
+		for _, m := range found {
+			if m == marker {
+				return nil, nil, errors.New("fail to prepare request")
+			}
+		}
+		parts = append(parts, canonical[:at])
+		found = append(found, marker)
+		canonical = canonical[at+len(marker):]
+	}
+	return append(parts, canonical), found, nil
+}
+
+// Prepare canonicalizes r once, the date header is always signed and the
+// date, authorization and body of r are ignored; the session token header
+// is set from the credentials Sign reads, as they rotate
+func (s *Signature) Prepare(r *http.Request, signedHeaders map[string]bool) (*PreparedRequest, error) {
+	if err := s.checkTLS(r.URL); err != nil {
+		return nil, err
+	}
+	creds, err := s.signingCredentials()
+	if err != nil {
+		return nil, err
+	}
+	p := s.profile()
+	template := r.Clone(r.Context())
+	if IsSigned(template) {
+		template.Header.Del(p.TokenHeader)
+	}
+	prepared := &PreparedRequest{signature: *s, template: template}
+	if creds.SessionToken != "" && template.Header.Get(p.TokenHeader) == "" {
+		prepared.sessionToken = true
+		template.Header.Set(p.TokenHeader, tokenMarker)
+	}
+	template.Body = nil
+	template.GetBody = nil
+	template.ContentLength = 0
+	template.Header.Del("Authorization")
+	template.Header.Del("Date")
+	template.Header.Set(p.DateHeader, dateMarker)
+	if template.Host == "" {
+		template.Host = template.URL.Host
+	}
+	p.canonicalHost(template)
+	signedHeaders = p.signedHeaders(template, signedHeaders)
+	dateHeader := strings.ToLower(p.DateHeader)
+	if len(signedHeaders) != 0 && !signedHeaders[dateHeader] {
+		with := make(map[string]bool, len(signedHeaders)+1)
+		for k, v := range signedHeaders {
+			with[k] = v
+		}
+		with[dateHeader] = true
+		signedHeaders = with
+	}
+	// as SignRequest, the hash header is set after the signed headers were chosen
+	if s.rules().ContentSHA256 {
+		if prepared.payloadHash = template.Header.Get("X-Amz-Content-Sha256"); prepared.payloadHash == "" {
+			prepared.contentSHA256 = true
+			template.Header.Set("X-Amz-Content-Sha256", hashMarker)
+		}
+	}
+	sc := getScratch()
+	defer putScratch(sc)
+	sc.appendCanonicalRequest(template, &s.Options, s.rules(), signedHeaders, "")
+	parts, markers, err := splitMarkers(string(sc.buf), dateMarker, hashMarker, tokenMarker)
+	if err != nil {
+		return nil, err
+	}
+	dated := false
+	for _, m := range markers {
+		dated = dated || m == dateMarker
+	}
+	if !dated {
+		return nil, errors.New("fail to prepare request")
+	}
+	prepared.parts, prepared.markers = parts, markers
+	withHost := sc.signedKeys(template, signedHeaders)
+	sc.buf = sc.appendSignedHeaders(sc.buf[:0], withHost)
+	prepared.signedHeaders = string(sc.buf)
+	template.Header.Del(p.DateHeader)
+	if prepared.contentSHA256 {
+		template.Header.Del("X-Amz-Content-Sha256")
+	}
+	if prepared.sessionToken {
+		template.Header.Del(p.TokenHeader)
+	}
+	return prepared, nil
+}
+
+// Sign returns a signed copy of the template dated t carrying body, t defaults to the signer's clock
+func (p *PreparedRequest) Sign(t time.Time, body []byte) (*http.Request, error) {
+	if t.IsZero() {
+		t = p.signature.now()
+	}
+	s := &p.signature
+	creds, err := s.signingCredentials()
+	if err != nil {
+		return nil, err
+	}
+	profile := s.profile()
+	if (creds.SessionToken != "") != p.sessionToken && p.template.Header.Get(profile.TokenHeader) == "" {
+		return nil, ErrPreparedSessionToken
+	}
+	sc := getScratch()
+	defer putScratch(sc)
+	payloadHash := p.payloadHash
+	if payloadHash == "" {
+		payloadHash = emptyPayloadHash
+		if len(body) > 0 {
+			sc.hash.Reset()
+			sc.hash.Write(body)
+			hex.Encode(sc.hex[:], sc.hash.Sum(sc.sum[:0]))
+			payloadHash = string(sc.hex[:])
+		}
+	}
+	date := t.UTC().Format(BasicDateFormat)
+	b := append(sc.buf[:0], p.parts[0]...)
+	for i, marker := range p.markers {
+		switch marker {
+		case dateMarker:
+			b = append(b, date...)
+		case tokenMarker:
+			b = append(b, creds.SessionToken...)
+		default:
+			b = append(b, payloadHash...)
+		}
+		b = append(b, p.parts[i+1]...)
+	}
+	sc.buf = append(b, payloadHash...)
+	sc.appendStringToSign(profile, t, s.Region, s.Service)
+	key, err := s.signingKey(profile, creds.SecretKey, t)
+	if err != nil {
+		return nil, err
+	}
+	signature := sc.sign(key)
+	b = append(sc.buf[:0], profile.Algorithm...)
+	b = append(b, " Credential="...)
+	b = append(b, creds.AccessKey...)
+	b = append(b, '/')
+	b = appendScope(b, profile, t, s.Region, s.Service)
+	b = append(b, ", SignedHeaders="...)
+	b = append(b, p.signedHeaders...)
+	b = append(b, ", Signature="...)
+	sc.buf = append(b, signature...)
+
+	r := p.template.Clone(p.template.Context())
+	r.Header.Set(profile.DateHeader, date)
+	if p.sessionToken {
+		r.Header.Set(profile.TokenHeader, creds.SessionToken)
+	}
+	if p.contentSHA256 {
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	r.Header.Set("Authorization", string(sc.buf))
+	if len(body) > 0 {
+		r.ContentLength = int64(len(body))
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	} else {
+		r.Body = http.NoBody
+	}
+	return r, nil
+}
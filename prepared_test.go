@@ -0,0 +1,110 @@
+package sign4_test
+
+import (
+	"bytes"
+	"github.com/datastream/aws"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPreparedRequest(t *testing.T) {
+	s := sign4.Signature{
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:    "us-east-1",
+		Service:   "host",
+	}
+	template, _ := http.NewRequest("POST", "http://host.foo.com/poll?b=2&a=1", nil)
+	template.Header.Set("Content-Type", "application/json")
+	p, err := s.Prepare(template, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, body := range []string{"", `{"n":1}`} {
+		tt := time.Date(2011, 9, 9, 23, 36, i, 0, time.UTC)
+		r, err := p.Sign(tt, []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := http.NewRequest("POST", "http://host.foo.com/poll?b=2&a=1", bytes.NewReader([]byte(body)))
+		expected.Header.Set("Content-Type", "application/json")
+		expected.Header.Set("X-Amz-Date", tt.Format(sign4.BasicDateFormat))
+		s.SignRequest(expected, nil)
+		if r.Header.Get("Authorization") != expected.Header.Get("Authorization") {
+			t.Fatal(r.Header.Get("Authorization"), "miss match", expected.Header.Get("Authorization"))
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != body {
+			t.Fatal("wrong body", string(b))
+		}
+	}
+	if template.Header.Get("X-Amz-Date") != "" {
+		t.Fatal("template modified")
+	}
+}
+
+func TestPreparedRequestContentSHA256(t *testing.T) {
+	s := sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Region: "us-east-1", Service: "s3"}
+	for _, preset := range []string{"", "UNSIGNED-PAYLOAD"} {
+		template, _ := http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/a%20key", nil)
+		if preset != "" {
+			template.Header.Set("X-Amz-Content-Sha256", preset)
+		}
+		p, err := s.Prepare(template, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, body := range []string{"", "object data"} {
+			tt := time.Date(2011, 9, 9, 23, 36, i, 0, time.UTC)
+			r, err := p.Sign(tt, []byte(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, _ := http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/a%20key", bytes.NewReader([]byte(body)))
+			if preset != "" {
+				expected.Header.Set("X-Amz-Content-Sha256", preset)
+			}
+			expected.Header.Set("X-Amz-Date", tt.Format(sign4.BasicDateFormat))
+			if err := s.SignRequest(expected, nil); err != nil {
+				t.Fatal(err)
+			}
+			if r.Header.Get("X-Amz-Content-Sha256") != expected.Header.Get("X-Amz-Content-Sha256") {
+				t.Fatal("wrong content sha256", preset, r.Header.Get("X-Amz-Content-Sha256"))
+			}
+			if r.Header.Get("Authorization") != expected.Header.Get("Authorization") {
+				t.Fatal(preset, r.Header.Get("Authorization"), "miss match", expected.Header.Get("Authorization"))
+			}
+		}
+		if template.Header.Get("X-Amz-Content-Sha256") != preset {
+			t.Fatal("template modified")
+		}
+	}
+}
+
+func TestPreparedRequestRotatingToken(t *testing.T) {
+	creds := &sign4.ByteCredentials{AccessKey: "ASIDFIRST", SecretKey: []byte("first-secret"), SessionToken: "first-token"}
+	s := sign4.Signature{Provider: creds, Region: "us-east-1", Service: "sqs"}
+	template, _ := http.NewRequest("POST", "https://sqs.us-east-1.amazonaws.com/", nil)
+	p, err := s.Prepare(template, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds.AccessKey, creds.SecretKey, creds.SessionToken = "ASIDSECOND", []byte("second-secret"), "second-token"
+	tt := time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC)
+	r, err := p.Sign(tt, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := http.NewRequest("POST", "https://sqs.us-east-1.amazonaws.com/", nil)
+	expected.Header.Set("X-Amz-Date", tt.Format(sign4.BasicDateFormat))
+	s.SignRequest(expected, nil)
+	if r.Header.Get("X-Amz-Security-Token") != "second-token" || r.Header.Get("Authorization") != expected.Header.Get("Authorization") {
+		t.Fatal("signed with a stale session token", r.Header.Get("X-Amz-Security-Token"), r.Header.Get("Authorization"))
+	}
+	creds.SessionToken = ""
+	if _, err := p.Sign(tt, nil); err != sign4.ErrPreparedSessionToken {
+		t.Fatal("signed without the session token it prepared", err)
+	}
+}
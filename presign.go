@@ -0,0 +1,359 @@
+package sign4
+
+// Presigned URLs, the signature travels in the X-Amz- query parameters instead of
+// the Authorization header
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxPresignExpires is the longest X-Amz-Expires accepted, seven days
+const MaxPresignExpires = 7 * 24 * time.Hour
+
+// unsignedPayload is the payload hash S3 presigned URLs carry
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// ErrPresignExpired is returned when a presigned request is used after X-Amz-Expires
+var ErrPresignExpired = errors.New("presigned request expired")
+
+// presignPayloadHash is UNSIGNED-PAYLOAD for the S3 services and the empty payload
+// hash for the others, the body of a presigned request isn't known when signing
+func presignPayloadHash(rules *ServiceRules) string {
+	if rules.SingleEncoding {
+		return unsignedPayload
+	}
+	return emptyPayloadHash
+}
+
+// Presign returns the URL of r signed in its query, valid for expires from now,
+// only host is signed when signedHeaders is empty. The query parameters use the
+// X-Amz- names for every profile, r is not modified
+func (s *Signature) Presign(r *http.Request, expires time.Duration, signedHeaders map[string]bool) (*url.URL, error) {
+	if err := ValidatePresignExpires(expires); err != nil {
+		return nil, err
+	}
+	if err := s.checkTLS(r.URL); err != nil {
+		return nil, err
+	}
+	creds, err := s.signingCredentials()
+	if err != nil {
+		return nil, err
+	}
+	p := s.profile()
+	t := s.now()
+	r = r.Clone(r.Context())
+	u := *r.URL
+	r.URL = &u
+	p.canonicalHost(r)
+	if len(signedHeaders) == 0 {
+		signedHeaders = map[string]bool{"host": true}
+	}
+	sc := getScratch()
+	defer putScratch(sc)
+	withHost := sc.signedKeys(r, signedHeaders)
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", p.Algorithm)
+	q.Set("X-Amz-Credential", creds.AccessKey+"/"+string(appendScope(nil, p, t, s.Region, s.Service)))
+	q.Set("X-Amz-Date", t.UTC().Format(BasicDateFormat))
+	q.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	q.Set("X-Amz-SignedHeaders", string(sc.appendSignedHeaders(nil, withHost)))
+	if creds.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	u.RawQuery = strings.Replace(q.Encode(), "+", "%20", -1)
+	rules := s.rules()
+	sc.appendCanonicalRequest(r, &s.Options, rules, signedHeaders, presignPayloadHash(rules))
+	sc.appendStringToSign(p, t, s.Region, s.Service)
+	key, err := s.signingKey(p, creds.SecretKey, t)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery += "&X-Amz-Signature=" + string(sc.sign(key))
+	return &u, nil
+}
+
+// PresignBatch returns the URLs of PresignEach for rawURLs
+func (s *Signature) PresignBatch(method string, rawURLs []string, expires time.Duration) ([]*url.URL, error) {
+	urls := make([]*url.URL, 0, len(rawURLs))
+	err := s.PresignEach(method, rawURLs, expires, func(_ int, u *url.URL) error {
+		urls = append(urls, u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// PresignEach presigns a request of method to each of rawURLs, signing host only, and
+// passes the URLs to fn in order, an error of fn stops the batch. The date, signing
+// key and X-Amz- parameters are computed once for the batch, each URL is the one
+// Presign returns for it at that date
+func (s *Signature) PresignEach(method string, rawURLs []string, expires time.Duration, fn func(i int, u *url.URL) error) error {
+	if err := ValidatePresignExpires(expires); err != nil {
+		return err
+	}
+	creds, err := s.signingCredentials()
+	if err != nil {
+		return err
+	}
+	p := s.profile()
+	t := s.now()
+	key, err := s.signingKey(p, creds.SecretKey, t)
+	if err != nil {
+		return err
+	}
+	common := url.Values{
+		"X-Amz-Algorithm":     {p.Algorithm},
+		"X-Amz-Credential":    {creds.AccessKey + "/" + string(appendScope(nil, p, t, s.Region, s.Service))},
+		"X-Amz-Date":          {t.UTC().Format(BasicDateFormat)},
+		"X-Amz-Expires":       {strconv.FormatInt(int64(expires/time.Second), 10)},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	if creds.SessionToken != "" {
+		common.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	commonQuery := strings.Replace(common.Encode(), "+", "%20", -1)
+	rules := s.rules()
+	payloadHash := presignPayloadHash(rules)
+	hostOnly := map[string]bool{"host": true}
+	sc := getScratch()
+	defer putScratch(sc)
+	for i, rawURL := range rawURLs {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		if err := s.checkTLS(u); err != nil {
+			return err
+		}
+		if u.RawQuery == "" {
+			u.RawQuery = commonQuery
+		} else {
+			q := u.Query()
+			for k, v := range common {
+				q[k] = v
+			}
+			u.RawQuery = strings.Replace(q.Encode(), "+", "%20", -1)
+		}
+		r := &http.Request{Method: method, URL: u, Host: u.Host, Header: http.Header{}}
+		p.canonicalHost(r)
+		sc.appendCanonicalRequest(r, &s.Options, rules, hostOnly, payloadHash)
+		sc.appendStringToSign(p, t, s.Region, s.Service)
+		u.RawQuery += "&X-Amz-Signature=" + string(sc.sign(key))
+		if err := fn(i, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResponseOverrides are the headers S3 sets on the response of a presigned GET from
+// its response-* query parameters, they are signed with the rest of the query
+type ResponseOverrides struct {
+	ContentType        string
+	ContentLanguage    string
+	Expires            string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+}
+
+// responseOverrides pairs the query parameters of the overrides with their headers
+var responseOverrides = [...]struct{ param, header string }{
+	{"response-content-type", "Content-Type"},
+	{"response-content-language", "Content-Language"},
+	{"response-expires", "Expires"},
+	{"response-cache-control", "Cache-Control"},
+	{"response-content-disposition", "Content-Disposition"},
+	{"response-content-encoding", "Content-Encoding"},
+}
+
+func (o *ResponseOverrides) fields() [len(responseOverrides)]*string {
+	return [...]*string{&o.ContentType, &o.ContentLanguage, &o.Expires, &o.CacheControl, &o.ContentDisposition, &o.ContentEncoding}
+}
+
+// SetQuery sets the response-* parameters of the overrides that aren't empty
+func (o ResponseOverrides) SetQuery(q url.Values) {
+	for i, v := range o.fields() {
+		if *v != "" {
+			q.Set(responseOverrides[i].param, *v)
+		}
+	}
+}
+
+// SetHeaders sets the headers of the overrides that aren't empty, for gateways serving
+// verified presigned GETs
+func (o ResponseOverrides) SetHeaders(h http.Header) {
+	for i, v := range o.fields() {
+		if *v != "" {
+			h.Set(responseOverrides[i].header, *v)
+		}
+	}
+}
+
+// ResponseOverridesOf returns the overrides in the query of r
+func ResponseOverridesOf(r *http.Request) ResponseOverrides {
+	var o ResponseOverrides
+	q := r.URL.Query()
+	for i, v := range o.fields() {
+		*v = q.Get(responseOverrides[i].param)
+	}
+	return o
+}
+
+// PresignGet returns a GET of rawURL presigned for expires whose response carries the
+// overrides, e.g. a Content-Disposition downloading the object under another name
+func (s *Signature) PresignGet(rawURL string, expires time.Duration, overrides ResponseOverrides) (*url.URL, error) {
+	r, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	q := r.URL.Query()
+	overrides.SetQuery(q)
+	r.URL.RawQuery = strings.Replace(q.Encode(), "+", "%20", -1)
+	return s.Presign(r, expires, nil)
+}
+
+// presigned is the signature of a presigned request
+type presigned struct {
+	signature     *Signature
+	signedHeaders map[string]bool
+	date          time.Time
+	expires       time.Duration
+	value         string
+}
+
+// IsPresigned reports whether r carries its signature in the query
+func IsPresigned(r *http.Request) bool {
+	return headerValue(r.Header, "Authorization") == "" && strings.Contains(r.URL.RawQuery, "X-Amz-Signature=")
+}
+
+// getPresigned parses the X-Amz- query parameters of r
+func getPresigned(r *http.Request) (*presigned, error) {
+	q := r.URL.Query()
+	var p *Profile
+	for _, profile := range Profiles {
+		if profile.Algorithm == q.Get("X-Amz-Algorithm") {
+			p = profile
+		}
+	}
+	if p == nil {
+		return nil, errors.New("unknown presign algorithm")
+	}
+	s, err := getCredential("Credential="+q.Get("X-Amz-Credential"), p)
+	if err != nil {
+		return nil, err
+	}
+	ps := &presigned{signature: s, signedHeaders: map[string]bool{}, value: q.Get("X-Amz-Signature")}
+	if ps.date, err = time.Parse(BasicDateFormat, q.Get("X-Amz-Date")); err != nil {
+		return nil, errors.New("wrong presign date")
+	}
+	seconds, err := strconv.ParseInt(q.Get("X-Amz-Expires"), 10, 64)
+	ps.expires = time.Duration(seconds) * time.Second
+	if err != nil || seconds <= 0 || ps.expires > MaxPresignExpires {
+		return nil, errors.New("wrong presign expiry")
+	}
+	for _, h := range strings.Split(q.Get("X-Amz-SignedHeaders"), ";") {
+		if h != "" {
+			ps.signedHeaders[strings.ToLower(h)] = true
+		}
+	}
+	if len(ps.signedHeaders) == 0 || ps.value == "" {
+		return nil, errors.New("wrong presign signature")
+	}
+	return ps, nil
+}
+
+// withoutSignature returns a raw query with the X-Amz-Signature parameters removed
+func withoutSignature(rawQuery string) string {
+	pairs := strings.Split(rawQuery, "&")
+	kept := pairs[:0]
+	for _, kv := range pairs {
+		if !strings.HasPrefix(kv, "X-Amz-Signature=") {
+			kept = append(kept, kv)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// presignedResultKey identifies a presigned request by what its signature covers and
+// the secret it verified with, so rotating the secret invalidates cached results
+func presignedResultKey(r *http.Request, signedHeaders map[string]bool, secretKey string) string {
+	h := sha256.New()
+	h.Write([]byte(secretKey))
+	h.Write([]byte{0})
+	h.Write([]byte(r.Method + "\n" + r.Host + "\n" + r.URL.EscapedPath() + "?" + r.URL.RawQuery + "\n"))
+	sc := getScratch()
+	defer putScratch(sc)
+	withHost := sc.signedKeys(r, signedHeaders)
+	h.Write(sc.appendCanonicalHeaders(nil, r, &defaultOptions, withHost))
+	return "sign4:presigned:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// verifyPresigned checks the query signature of r, caching the result in Verifier.Presigned
+func (v *Verifier) verifyPresigned(r *http.Request) (*Signature, error) {
+	ps, err := getPresigned(r)
+	if err != nil {
+		return nil, err
+	}
+	s := ps.signature
+	if v.AllowScope != nil && !v.AllowScope(s.Region, s.Service) {
+		return nil, ErrScopeNotAllowed
+	}
+	now := v.Options.now()
+	validUntil := ps.date.Add(ps.expires)
+	if !now.Before(validUntil) {
+		return nil, ErrPresignExpired
+	}
+	if v.MaxSkew > 0 && ps.date.Sub(now) > v.MaxSkew {
+		return nil, ErrRequestTimeSkewed
+	}
+	profile := s.Profile
+	s.Options = v.Options
+	s.Profile = profile
+	// the secret is looked up on cache hits too so a revoked key stops verifying
+	if s.SecretKey, err = v.SecretKey(s.AccessKey); err != nil {
+		return nil, err
+	}
+	var key string
+	if v.Presigned != nil {
+		key = presignedResultKey(r, ps.signedHeaders, s.SecretKey)
+		if b, ok, err := v.Presigned.Get(key); err == nil && ok && len(b) == 8 &&
+			now.Before(time.Unix(0, int64(binary.BigEndian.Uint64(b)))) {
+			return s, nil
+		}
+	}
+	unsigned := *r
+	u := *r.URL
+	u.RawQuery = withoutSignature(u.RawQuery)
+	unsigned.URL = &u
+	p := s.profile()
+	rules := s.rules()
+	sc := getScratch()
+	defer putScratch(sc)
+	sc.appendCanonicalRequest(&unsigned, &s.Options, rules, ps.signedHeaders, presignPayloadHash(rules))
+	sc.appendStringToSign(p, ps.date, s.Region, s.Service)
+	signingKey, err := s.signingKey(p, s.SecretKey, ps.date)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(sc.sign(signingKey), []byte(ps.value)) != 1 {
+		return nil, ErrSignatureMismatch
+	}
+	if v.Presigned != nil {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(validUntil.UnixNano()))
+		v.Presigned.SetWithTTL(key, b[:], validUntil.Sub(now))
+	}
+	return s, nil
+}
@@ -0,0 +1,72 @@
+package sign4
+
+// Profiles of the Signature Version 4 scheme used by other clouds and gateways
+
+import "strings"
+
+// Profile is a variant of the Signature Version 4 scheme
+type Profile struct {
+	// Algorithm starts the string to sign and the Authorization header
+	Algorithm string
+	// KeyPrefix is prepended to the secret key to derive the signing key
+	KeyPrefix string
+	// Terminator ends the credential scope
+	Terminator string
+	// DateHeader carries the request time
+	DateHeader string
+	// TokenHeader carries the session token
+	TokenHeader string
+	// PathStyle addresses buckets as endpoint/bucket/key instead of bucket.endpoint/key
+	PathStyle bool
+	// HostPort signs a default :80 or :443 port of the request host instead of dropping it
+	HostPort bool
+	// MinimalHeaders signs only host, content-md5, content-type and the x-amz- headers
+	// when no signed headers are given
+	MinimalHeaders bool
+}
+
+// AWS is the default profile, Huawei the SDK-HMAC-SHA256 variant of Huawei Cloud API Gateway
+var (
+	AWS = &Profile{
+		Algorithm:   "AWS4-HMAC-SHA256",
+		KeyPrefix:   "AWS4",
+		Terminator:  "aws4_request",
+		DateHeader:  "X-Amz-Date",
+		TokenHeader: "X-Amz-Security-Token",
+	}
+	Huawei = &Profile{
+		Algorithm:   "SDK-HMAC-SHA256",
+		KeyPrefix:   "SDK",
+		Terminator:  "sdk_request",
+		DateHeader:  "X-Sdk-Date",
+		TokenHeader: "X-Security-Token",
+	}
+)
+
+// Profiles are recognized when parsing Authorization headers
+var Profiles = []*Profile{AWS, Huawei}
+
+// UseProfile sets Options.Profile
+func UseProfile(p *Profile) Option {
+	return func(o *Options) {
+		o.Profile = p
+	}
+}
+
+// profile returns the profile of o, AWS when unset
+func (o *Options) profile() *Profile {
+	if o.Profile == nil {
+		return AWS
+	}
+	return o.Profile
+}
+
+// profileOf returns the profile whose algorithm starts the authorization header
+func profileOf(authHeader string) *Profile {
+	for _, p := range Profiles {
+		if strings.HasPrefix(authHeader, p.Algorithm+" ") {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package sign4_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestHuaweiProfile(t *testing.T) {
+	s := (&sign4.Signature{AccessKey: "devops", SecretKey: "secret", Region: "hz", Service: "dnsapi"}).
+		WithOptions(sign4.UseProfile(sign4.Huawei))
+	r, _ := http.NewRequest("GET", "https://dns.example.com/v2/zones?limit=10", nil)
+	r.Header.Set("X-Sdk-Date", "20180312T101010Z")
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "SDK-HMAC-SHA256 Credential=devops/20180312/hz/dnsapi/sdk_request, SignedHeaders=host;x-sdk-date, Signature=") {
+		t.Fatal("wrong authorization", auth)
+	}
+	if r.Header.Get("X-Amz-Date") != "" {
+		t.Fatal("aws date header set")
+	}
+
+	// derive the key by hand
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := []byte("SDKsecret")
+	for _, d := range []string{"20180312", "hz", "dnsapi", "sdk_request"} {
+		key = mac(key, d)
+	}
+	sts, err := s.GetStringToSign(r, map[string]bool{"host": true, "x-sdk-date": true})
+	if err != nil || !strings.HasPrefix(sts.StringToSign, "SDK-HMAC-SHA256\n20180312T101010Z\n20180312/hz/dnsapi/sdk_request\n") {
+		t.Fatal("wrong string to sign", sts, err)
+	}
+	if want := hex.EncodeToString(mac(key, sts.StringToSign)); !strings.HasSuffix(auth, "Signature="+want) {
+		t.Fatal("wrong signature", auth, want)
+	}
+
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	got, err := v.Verify(r)
+	if err != nil || got.Profile != sign4.Huawei {
+		t.Fatal("huawei request not verified", err)
+	}
+}
@@ -0,0 +1,112 @@
+// Package query builds and signs AWS query protocol requests, the Action and Version
+// form encoded POST bodies of STS, SQS, CloudWatch and other older APIs.
+package query
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/datastream/aws"
+)
+
+// ContentType is the content type of query protocol bodies
+const ContentType = "application/x-www-form-urlencoded; charset=utf-8"
+
+// Body returns the form encoded body of action, Action and Version come first
+// and params follow sorted by key
+func Body(action, version string, params url.Values) string {
+	body := "Action=" + url.QueryEscape(action) + "&Version=" + url.QueryEscape(version)
+	if p := params.Encode(); p != "" {
+		body += "&" + p
+	}
+	return body
+}
+
+// Error is an ErrorResponse returned by a query API
+type Error struct {
+	StatusCode int
+	Type       string `xml:"Error>Type"`
+	Code       string `xml:"Error>Code"`
+	Message    string `xml:"Error>Message"`
+	RequestID  string `xml:"RequestId"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("query: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Client calls one query API
+type Client struct {
+	Signature  *sign4.Signature
+	HTTPClient *http.Client
+	// Service is the signing name, sts, sqs, monitoring, ...
+	Service string
+	// Version is the API version sent with every action
+	Version string
+	// Endpoint overrides https://<service>.<region>.amazonaws.com
+	Endpoint string
+}
+
+// NewClient returns a client of the service API version signing with s
+func NewClient(s *sign4.Signature, service, version string) *Client {
+	return &Client{Signature: s, Service: service, Version: version}
+}
+
+// NewRequest returns the signed POST of an encoded body
+func (c *Client) NewRequest(ctx context.Context, body string) (*http.Request, error) {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", c.Service, c.Signature.Region)
+	}
+	r, err := http.NewRequest("POST", strings.TrimSuffix(endpoint, "/")+"/", strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Content-Type", ContentType)
+	if err := c.Signature.WithService(c.Service).SignRequest(r, nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Do calls action with params and decodes the XML response into out when it isn't nil
+func (c *Client) Do(ctx context.Context, action string, params url.Values, out interface{}) error {
+	return c.Post(ctx, Body(action, c.Version, params), out)
+}
+
+// Post sends an encoded body and decodes the XML response into out when it isn't nil,
+// failed calls return an *Error
+func (c *Client) Post(ctx context.Context, body string, out interface{}) error {
+	r, err := c.NewRequest(ctx, body)
+	if err != nil {
+		return err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode}
+		xml.Unmarshal(data, e)
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	return xml.Unmarshal(data, out)
+}
@@ -0,0 +1,83 @@
+package query_test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/query"
+)
+
+func TestBody(t *testing.T) {
+	body := query.Body("GetCallerIdentity", "2011-06-15", nil)
+	if body != "Action=GetCallerIdentity&Version=2011-06-15" {
+		t.Fatal("wrong body", body)
+	}
+	body = query.Body("SendMessage", "2012-11-05", url.Values{"QueueUrl": {"https://sqs/1/q"}, "MessageBody": {"a b&c"}})
+	if body != "Action=SendMessage&Version=2012-11-05&MessageBody=a+b%26c&QueueUrl=https%3A%2F%2Fsqs%2F1%2Fq" {
+		t.Fatal("wrong body", body)
+	}
+}
+
+func TestDo(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if r.Method != "POST" || r.Header.Get("Content-Type") != query.ContentType || string(b) != "Action=GetCallerIdentity&Version=2011-06-15" {
+			t.Error("wrong request", r.Method, r.Header, string(b))
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "/us-east-1/sts/aws4_request") {
+			t.Error("wrong scope", r.Header.Get("Authorization"))
+		}
+		w.Write([]byte(`<GetCallerIdentityResponse><GetCallerIdentityResult><Account>123456789012</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`))
+	}))
+	defer server.Close()
+	c := query.NewClient(s, "sts", "2011-06-15")
+	c.Endpoint = server.URL
+	var out struct {
+		XMLName xml.Name `xml:"GetCallerIdentityResponse"`
+		Account string   `xml:"GetCallerIdentityResult>Account"`
+	}
+	if err := c.Do(context.Background(), "GetCallerIdentity", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Account != "123456789012" {
+		t.Fatal("wrong response", out)
+	}
+	if s.Service != "" {
+		t.Fatal("signature modified", s.Service)
+	}
+
+	r, err := query.NewClient(s, "sqs", "2012-11-05").NewRequest(context.Background(), "Action=ListQueues&Version=2012-11-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.URL.String() != "https://sqs.us-east-1.amazonaws.com/" || r.ContentLength != 36 || r.Header.Get("Authorization") == "" {
+		t.Fatal("wrong request", r.URL, r.ContentLength, r.Header)
+	}
+}
+
+func TestError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>denied</Message></Error><RequestId>id</RequestId></ErrorResponse>`))
+	}))
+	defer server.Close()
+	c := query.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"}, "sts", "2011-06-15")
+	c.Endpoint = server.URL
+	err := c.Do(context.Background(), "AssumeRole", url.Values{"RoleArn": {"arn"}}, nil)
+	var e *query.Error
+	if !errors.As(err, &e) || e.StatusCode != 403 || e.Type != "Sender" || e.Code != "AccessDenied" || e.RequestID != "id" {
+		t.Fatal("wrong error", err)
+	}
+	if err.Error() != "query: 403 AccessDenied: denied" {
+		t.Fatal("wrong message", err)
+	}
+}
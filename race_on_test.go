@@ -0,0 +1,6 @@
+//go:build race
+
+package sign4_test
+
+// raceEnabled reports a build with the race detector, which adds allocations of its own
+const raceEnabled = true
@@ -0,0 +1,168 @@
+package sign4
+
+// Keeping secrets out of formatted values and diagnostic dumps
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Redacted replaces secrets in formatted values and dumps
+const Redacted = "<redacted>"
+
+// RedactedHeaders are the lower cased headers whose values RedactCanonicalRequest
+// replaces, besides the session token headers of Profiles
+var RedactedHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"x-amz-server-side-encryption-customer-key":             true,
+	"x-amz-copy-source-server-side-encryption-customer-key": true,
+}
+
+// Redact returns Redacted for a set secret and "" for an unset one, so that formatted
+// values still tell them apart
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// FormatRedacted formats v, a copy of a value with its secrets redacted, as f and verb
+// ask, for the Format methods of types holding secrets
+func FormatRedacted(f fmt.State, verb rune, v interface{}) {
+	format := "%"
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			format += string(flag)
+		}
+	}
+	fmt.Fprintf(f, format+string(verb), v)
+}
+
+// redactedSignature is what a Signature prints as: its scalar fields with the secrets
+// redacted, the Provider by its type. Pointers aren't kept, %s would print what they hold
+type redactedSignature struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Expiry       time.Time
+	Region       string
+	Service      string
+	Provider     string
+}
+
+func (s *Signature) redacted() redactedSignature {
+	r := redactedSignature{AccessKey: s.AccessKey, SecretKey: Redact(s.SecretKey), SessionToken: Redact(s.SessionToken),
+		Expiry: s.Expiry, Region: s.Region, Service: s.Service}
+	if s.Provider != nil {
+		r.Provider = fmt.Sprintf("%T", s.Provider)
+	}
+	return r
+}
+
+// Format prints s with its secret key and session token redacted
+func (s Signature) Format(f fmt.State, verb rune) {
+	FormatRedacted(f, verb, s.redacted())
+}
+
+type redactedCredentials Credentials
+
+// Format prints c with its secret key and session token redacted
+func (c Credentials) Format(f fmt.State, verb rune) {
+	c.SecretKey, c.SessionToken = Redact(c.SecretKey), Redact(c.SessionToken)
+	FormatRedacted(f, verb, redactedCredentials(c))
+}
+
+// Format prints c with its secret key and session token redacted
+func (c ByteCredentials) Format(f fmt.State, verb rune) {
+	FormatRedacted(f, verb, redactedCredentials{AccessKey: c.AccessKey, SecretKey: Redact(string(c.SecretKey)),
+		SessionToken: Redact(c.SessionToken), Expiry: c.Expiry})
+}
+
+type redactedTC3 TC3
+
+// Format prints s with its secret key and session token redacted
+func (s TC3) Format(f fmt.State, verb rune) {
+	s.SecretKey, s.SessionToken = Redact(s.SecretKey), Redact(s.SessionToken)
+	FormatRedacted(f, verb, redactedTC3(s))
+}
+
+// Format prints s with its secret and security token redacted
+func (s ACS3) Format(f fmt.State, verb rune) {
+	FormatRedacted(f, verb, struct{ AccessKeyID, AccessKeySecret, SecurityToken string }{
+		s.AccessKeyID, Redact(s.AccessKeySecret), Redact(s.SecurityToken)})
+}
+
+type redactedBCE BCE
+
+// Format prints s with its secret key and session token redacted
+func (s BCE) Format(f fmt.State, verb rune) {
+	s.SecretAccessKey, s.SessionToken = Redact(s.SecretAccessKey), Redact(s.SessionToken)
+	FormatRedacted(f, verb, redactedBCE(s))
+}
+
+// Format prints the signature and signed headers of p, not its template, which may
+// carry a session token
+func (p PreparedRequest) Format(f fmt.State, verb rune) {
+	FormatRedacted(f, verb, struct {
+		Signature     redactedSignature
+		SignedHeaders string
+	}{p.signature.redacted(), p.signedHeaders})
+}
+
+// RedactCanonicalRequest replaces the values of RedactedHeaders and session token headers
+// and parameters in a canonical request, for dumping it
+func RedactCanonicalRequest(canonicalRequest string) string {
+	lines := strings.Split(canonicalRequest, "\n")
+	for i, line := range lines {
+		if i == 2 {
+			lines[i] = redactQuery(line)
+			continue
+		}
+		if i < 3 || line == "" {
+			continue
+		}
+		if j := strings.IndexByte(line, ':'); j > 0 && redactedHeader(line[:j]) {
+			lines[i] = line[:j+1] + Redacted
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redactedHeader reports whether the values of the lower cased header name are redacted
+func redactedHeader(name string) bool {
+	if RedactedHeaders[name] {
+		return true
+	}
+	for _, p := range Profiles {
+		if strings.EqualFold(name, p.TokenHeader) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactQuery replaces the session token parameters of a canonical query
+func redactQuery(query string) string {
+	pairs := strings.Split(query, "&")
+	for i, kv := range pairs {
+		if j := strings.IndexByte(kv, '='); j > 0 && redactedHeader(kv[:j]) {
+			pairs[i] = kv[:j+1] + Redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactLine redacts a canonical request line of Explanation.Lines by its note
+func redactLine(note, line string) string {
+	switch {
+	case note == "query":
+		return redactQuery(line)
+	case strings.HasPrefix(note, "header ") && redactedHeader(note[7:]) && line != "":
+		return note[7:] + ":" + Redacted
+	}
+	return line
+}
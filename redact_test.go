@@ -0,0 +1,97 @@
+package sign4_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// recordingLogger formats every diagnostic it receives
+type recordingLogger struct{ lines []string }
+
+func (l *recordingLogger) Log(level sign4.LogLevel, msg string, keyvals ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprint(append([]interface{}{level, msg}, keyvals...)...))
+}
+
+func TestNoSecretsInOutput(t *testing.T) {
+	const secret, token = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "FQoGZXIvYXdzEXAMPLETOKEN"
+	date := time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC)
+	key, _ := sign4.GenerateSigningKey(secret, "us-east-1", "s3", date)
+	leaks := func(what, out string) {
+		t.Helper()
+		for _, s := range []string{secret, token, hex.EncodeToString(key), string(key)} {
+			if strings.Contains(out, s) {
+				t.Errorf("%s leaks a secret: %s", what, out)
+			}
+		}
+	}
+	logger := &recordingLogger{}
+	base := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: secret, SessionToken: token, Region: "us-east-1", Service: "s3"}
+	s := base.WithOptions(sign4.Deterministic(date), sign4.UseLogger(logger))
+	r, _ := http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/key", strings.NewReader("payload"))
+	r.Header.Set("X-Amz-Server-Side-Encryption-Customer-Key", "c3NlLWMga2V5")
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	prepared, _ := s.Prepare(r, nil)
+	var chunked bytes.Buffer
+	w, _ := s.NewChunkedWriter(&chunked, strings.Repeat("0", 64), date, 1024)
+	values := []interface{}{base, *base, s, sign4.Credentials{AccessKey: "AKIDEXAMPLE", SecretKey: secret, SessionToken: token},
+		&sign4.ByteCredentials{AccessKey: "AKIDEXAMPLE", SecretKey: []byte(secret), SessionToken: token},
+		sign4.TC3{SecretID: "id", SecretKey: secret, SessionToken: token},
+		&sign4.ACS3{AccessKeyID: "id", AccessKeySecret: secret, SecurityToken: token},
+		sign4.BCE{AccessKeyID: "id", SecretAccessKey: secret, SessionToken: token},
+		prepared, w}
+	for _, v := range values {
+		for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+			leaks(fmt.Sprintf("%T as %s", v, format), fmt.Sprintf(format, v))
+		}
+	}
+	if out := fmt.Sprintf("%+v", base); !strings.Contains(out, "AKIDEXAMPLE") || !strings.Contains(out, sign4.Redacted) {
+		t.Fatal("formatted signature lost its public fields", out)
+	}
+	wrong := &sign4.Verifier{SecretKey: func(string) (string, error) { return "other secret", nil }, Options: sign4.Options{Logger: logger}}
+	if _, err := wrong.Verify(r); err == nil {
+		t.Fatal("verified with the wrong secret")
+	} else {
+		leaks("verification error", err.Error())
+	}
+	expired := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: secret, Expiry: date, Region: "us-east-1", Service: "s3"}
+	if err := expired.SignRequest(r, nil); err == nil {
+		t.Fatal("signed with expired credentials")
+	} else {
+		leaks("expiry error", err.Error())
+	}
+	e, err := s.Explain(r, sign4.Expected{Authorization: strings.Repeat("0", 64)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaks("explanation", fmt.Sprintf("%+v", e))
+	if strings.Contains(e.CanonicalRequest, token) || strings.Contains(e.CanonicalRequest, "c3NlLWMga2V5") {
+		t.Fatal("explanation dumps the token or customer key", e.CanonicalRequest)
+	}
+	for _, line := range logger.lines {
+		leaks("log line", line)
+	}
+	if len(logger.lines) < 2 {
+		t.Fatal("diagnostics not logged", logger.lines)
+	}
+}
+
+func TestRedactCanonicalRequest(t *testing.T) {
+	canonical := "GET\n/key\nX-Amz-Credential=AKID%2F20110909&X-Amz-Security-Token=secret-token\n" +
+		"host:bucket.s3.amazonaws.com\nx-amz-date:20110909T233600Z\nx-amz-security-token:secret-token\n\n" +
+		"host;x-amz-date;x-amz-security-token\nUNSIGNED-PAYLOAD"
+	want := "GET\n/key\nX-Amz-Credential=AKID%2F20110909&X-Amz-Security-Token=<redacted>\n" +
+		"host:bucket.s3.amazonaws.com\nx-amz-date:20110909T233600Z\nx-amz-security-token:<redacted>\n\n" +
+		"host;x-amz-date;x-amz-security-token\nUNSIGNED-PAYLOAD"
+	if got := sign4.RedactCanonicalRequest(canonical); got != want {
+		t.Fatal("wrong redaction", got)
+	}
+}
@@ -0,0 +1,293 @@
+// Package redis is a minimal Redis client implementing sign4.Cache, so a fleet of
+// verifiers shares replay records and derived keys.
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxIdleConns bounds the connections kept for reuse
+const maxIdleConns = 8
+
+// Error is an error reply of the server
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// Client talks RESP to one server
+type Client struct {
+	// Addr is host:port of the server
+	Addr string
+	// Password, when set, is sent with AUTH on new connections
+	Password string
+	// DB is selected on new connections
+	DB int
+	// Prefix is prepended to every key
+	Prefix string
+	// Timeout bounds dialing and each command, 5 seconds when zero
+	Timeout time.Duration
+
+	mu   sync.Mutex
+	idle []*conn
+}
+
+// NewClient returns a client of the server at addr
+func NewClient(addr string) *Client {
+	return &Client{Addr: addr}
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 5 * time.Second
+}
+
+func (c *Client) get() (*conn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+	nc, err := net.DialTimeout("tcp", c.Addr, c.timeout())
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	var setup [][]string
+	if c.Password != "" {
+		setup = append(setup, []string{"AUTH", c.Password})
+	}
+	if c.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.DB)})
+	}
+	if len(setup) > 0 {
+		replies, err := c.roundTrip(cn, setup)
+		for i := 0; err == nil && i < len(replies); i++ {
+			if e, ok := replies[i].(Error); ok {
+				err = e
+			}
+		}
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= maxIdleConns {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+// Close closes the idle connections
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cn := range c.idle {
+		cn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+// Do sends commands in one write and returns their replies, error replies are
+// returned as Error values in the replies
+func (c *Client) Do(commands ...[]string) ([]interface{}, error) {
+	cn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	replies, err := c.roundTrip(cn, commands)
+	if err != nil {
+		// the connection may hold half a reply
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return replies, nil
+}
+
+func (c *Client) roundTrip(cn *conn, commands [][]string) ([]interface{}, error) {
+	cn.SetDeadline(time.Now().Add(c.timeout()))
+	for _, args := range commands {
+		writeCommand(cn.w, args)
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]interface{}, len(commands))
+	for i := range commands {
+		reply, err := readReply(cn.r)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+func writeCommand(w *bufio.Writer, args []string) {
+	w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		w.WriteString("$" + strconv.Itoa(len(a)) + "\r\n")
+		w.WriteString(a)
+		w.WriteString("\r\n")
+	}
+}
+
+var errProtocol = errors.New("redis: malformed reply")
+
+// readReply returns a string, int64, []byte, nil, []interface{} or Error
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errProtocol
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return Error(line), nil
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		a := make([]interface{}, n)
+		for i := range a {
+			if a[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	return nil, errProtocol
+}
+
+// do runs one command, an error reply is returned as the error
+func (c *Client) do(args ...string) (interface{}, error) {
+	replies, err := c.Do(args)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := replies[0].(Error); ok {
+		return nil, e
+	}
+	return replies[0], nil
+}
+
+// Get returns the value of key and whether it was found
+func (c *Client) Get(key string) ([]byte, bool, error) {
+	reply, err := c.do("GET", c.Prefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %v", reply)
+	}
+	return b, true, nil
+}
+
+// SetWithTTL stores value under key, expiring after ttl when it is positive
+func (c *Client) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", c.Prefix + key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(milliseconds(ttl), 10))
+	}
+	_, err := c.do(args...)
+	return err
+}
+
+// SetIfAbsent stores value under key with SET NX, reporting whether the key was absent
+func (c *Client) SetIfAbsent(key string, value []byte, ttl time.Duration) (bool, error) {
+	stored, err := c.SetIfAbsentBatch([]string{key}, value, ttl)
+	if err != nil {
+		return false, err
+	}
+	return stored[0], nil
+}
+
+// SetIfAbsentBatch sends a SET NX of every key in one pipeline, the server applies
+// them in order so a key repeated in the batch is only stored once
+func (c *Client) SetIfAbsentBatch(keys []string, value []byte, ttl time.Duration) ([]bool, error) {
+	commands := make([][]string, len(keys))
+	for i, key := range keys {
+		commands[i] = []string{"SET", c.Prefix + key, string(value), "NX"}
+		if ttl > 0 {
+			commands[i] = append(commands[i], "PX", strconv.FormatInt(milliseconds(ttl), 10))
+		}
+	}
+	replies, err := c.Do(commands...)
+	if err != nil {
+		return nil, err
+	}
+	stored := make([]bool, len(keys))
+	for i, reply := range replies {
+		switch reply := reply.(type) {
+		case Error:
+			return nil, reply
+		case string:
+			stored[i] = reply == "OK"
+		}
+	}
+	return stored, nil
+}
+
+// Delete removes key
+func (c *Client) Delete(key string) error {
+	_, err := c.do("DEL", c.Prefix+key)
+	return err
+}
+
+// milliseconds rounds ttl up, PX 0 is rejected by the server
+func milliseconds(ttl time.Duration) int64 {
+	return int64((ttl + time.Millisecond - 1) / time.Millisecond)
+}
@@ -0,0 +1,192 @@
+package redis_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/redis"
+)
+
+var _ sign4.Cache = &redis.Client{}
+
+// fakeServer answers AUTH, SELECT, GET, SET, SETNX-style SET NX and DEL from a map
+type fakeServer struct {
+	net.Listener
+	mu       sync.Mutex
+	values   map[string]string
+	commands [][]string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{Listener: l, values: map[string]string{}}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			line, _ = r.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			b := make([]byte, size+2)
+			io.ReadFull(r, b)
+			args[i] = string(b[:size])
+		}
+		c.Write([]byte(s.reply(args)))
+	}
+}
+
+func (s *fakeServer) reply(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, args)
+	switch strings.ToUpper(args[0]) {
+	case "AUTH":
+		if args[1] != "secret" {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		v, ok := s.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+	case "SET":
+		for _, a := range args[3:] {
+			if a == "NX" {
+				if _, ok := s.values[args[1]]; ok {
+					return "$-1\r\n"
+				}
+			}
+		}
+		s.values[args[1]] = args[2]
+		return "+OK\r\n"
+	case "DEL":
+		_, ok := s.values[args[1]]
+		delete(s.values, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func (s *fakeServer) last() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands[len(s.commands)-1]
+}
+
+func TestClient(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	c := redis.NewClient(server.Addr().String())
+	c.Password = "secret"
+	c.DB = 2
+	c.Prefix = "gw:"
+	defer c.Close()
+	if _, ok, err := c.Get("k"); ok || err != nil {
+		t.Fatal("missing key found", ok, err)
+	}
+	if server.commands[0][0] != "AUTH" || server.commands[1][0] != "SELECT" || server.commands[1][1] != "2" {
+		t.Fatal("connection not set up", server.commands)
+	}
+	if err := c.SetWithTTL("k", []byte("v\r\n1"), 1500*time.Microsecond); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(server.last(), " "); got != "SET gw:k v\r\n1 PX 2" {
+		t.Fatalf("wrong command %q", got)
+	}
+	if v, ok, err := c.Get("k"); !ok || err != nil || string(v) != "v\r\n1" {
+		t.Fatal("wrong value", string(v), ok, err)
+	}
+	if err := c.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := c.Get("k"); ok {
+		t.Fatal("key not deleted")
+	}
+	if n := len(server.commands); n != 7 {
+		t.Fatal("connection not reused", server.commands)
+	}
+	if _, err := c.Do([]string{"FLUSHALL"}); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := redis.NewClient(server.Addr().String())
+	bad.Password = "wrong"
+	if _, _, err := bad.Get("k"); err == nil || err.Error() != "redis: WRONGPASS invalid password" {
+		t.Fatal("wrong auth error", err)
+	}
+}
+
+var _ sign4.BatchReplayStore = &redis.Client{}
+
+func TestSetIfAbsentBatch(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	c := redis.NewClient(server.Addr().String())
+	defer c.Close()
+	if stored, err := c.SetIfAbsent("a", []byte{1}, time.Minute); !stored || err != nil {
+		t.Fatal("key not stored", err)
+	}
+	if got := strings.Join(server.last(), " "); got != "SET a \x01 NX PX 60000" {
+		t.Fatalf("wrong command %q", got)
+	}
+	stored, err := c.SetIfAbsentBatch([]string{"a", "b", "b", "c"}, []byte{1}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored[0] || !stored[1] || stored[2] || !stored[3] {
+		t.Fatal("wrong batch result", stored)
+	}
+	if n := len(server.commands); n != 5 {
+		t.Fatal("wrong command count", n)
+	}
+
+	// a verifier fleet sharing the server rejects a replay seen by another member
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	s.SignRequest(r, nil)
+	secret := func(string) (string, error) { return "secret", nil }
+	a := &sign4.Verifier{SecretKey: secret, Replay: c}
+	b := &sign4.Verifier{SecretKey: secret, Replay: redis.NewClient(server.Addr().String())}
+	if _, err := a.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+	if results := b.VerifyBatch([]*http.Request{r}, 1); results[0].Err != sign4.ErrReplayed {
+		t.Fatal("replay accepted by another verifier", results[0].Err)
+	}
+}
@@ -0,0 +1,86 @@
+package sign4
+
+// Replay protection, verified signatures are recorded in Verifier.Replay until they
+// could no longer pass the skew check
+
+import (
+	"net/http"
+	"time"
+)
+
+// ReplayStore is a Cache recording keys atomically, Verifier.Replay uses it when
+// the cache implements it so concurrent verifiers can't both accept a signature
+type ReplayStore interface {
+	// SetIfAbsent stores value under key for ttl unless key exists, reporting whether it stored it
+	SetIfAbsent(key string, value []byte, ttl time.Duration) (bool, error)
+}
+
+// BatchReplayStore records the keys of a batch in one round trip, VerifyBatch uses it
+// when Verifier.Replay implements it
+type BatchReplayStore interface {
+	// SetIfAbsentBatch is SetIfAbsent of each key in order, a key repeated in the
+	// batch is only stored the first time
+	SetIfAbsentBatch(keys []string, value []byte, ttl time.Duration) ([]bool, error)
+}
+
+func replayKey(signature string) string {
+	return "sign4:replay:" + signature
+}
+
+// replayTTL is how long a signature is kept, twice MaxSkew or 15 minutes without it
+func (v *Verifier) replayTTL() time.Duration {
+	if v.MaxSkew > 0 {
+		return 2 * v.MaxSkew
+	}
+	return 15 * time.Minute
+}
+
+// checkReplay records a verified signature, failing when it was recorded before
+func (v *Verifier) checkReplay(signature string) error {
+	key := replayKey(signature)
+	if store, ok := v.Replay.(ReplayStore); ok {
+		stored, err := store.SetIfAbsent(key, []byte{1}, v.replayTTL())
+		if err == nil && !stored {
+			err = ErrReplayed
+		}
+		return err
+	}
+	if _, seen, err := v.Replay.Get(key); err != nil {
+		return err
+	} else if seen {
+		return ErrReplayed
+	}
+	return v.Replay.SetWithTTL(key, []byte{1}, v.replayTTL())
+}
+
+// checkReplayBatch records the signatures of the verified results, failing those recorded before
+func (v *Verifier) checkReplayBatch(store BatchReplayStore, requests []*http.Request, results []VerifyResult) {
+	var keys []string
+	var verified []int
+	for n := range results {
+		// presigned URLs aren't replay checked, they have no Authorization to record
+		if results[n].Err != nil || IsPresigned(requests[n]) {
+			continue
+		}
+		_, authHeader, _, _ := GetSignature(requests[n])
+		signature, err := getSignatureValue(authHeader)
+		if err != nil {
+			results[n] = VerifyResult{Err: err}
+			continue
+		}
+		keys = append(keys, replayKey(signature))
+		verified = append(verified, n)
+	}
+	if len(keys) == 0 {
+		return
+	}
+	stored, err := store.SetIfAbsentBatch(keys, []byte{1}, v.replayTTL())
+	for i, n := range verified {
+		switch {
+		case err != nil:
+			results[n] = VerifyResult{Err: err}
+		case !stored[i]:
+			results[n] = VerifyResult{Err: ErrReplayed}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package sign4
+
+// Removing an earlier signature so retried requests are signed afresh
+
+import (
+	"net/http"
+	"strings"
+)
+
+// presignParams are the query parameters of a presigned URL
+var presignParams = []string{
+	"X-Amz-Algorithm=", "X-Amz-Credential=", "X-Amz-Date=", "X-Amz-Expires=",
+	"X-Amz-SignedHeaders=", "X-Amz-Security-Token=", "X-Amz-Signature=",
+}
+
+// IsSigned reports whether r carries a signature, in an Authorization header of one of
+// Profiles or in its query
+func IsSigned(r *http.Request) bool {
+	return profileOf(headerValue(r.Header, "Authorization")) != nil || strings.Contains(r.URL.RawQuery, "X-Amz-Signature=")
+}
+
+// Unsign removes the signature of a signed request: the Authorization headers, the date and
+// session token headers of Profiles and the presign query parameters. SignRequest calls it,
+// so a request signed again, e.g. by retry middleware, isn't signed over its old signature
+func Unsign(r *http.Request) {
+	if !IsSigned(r) {
+		return
+	}
+	r.Header.Del("Authorization")
+	r.Header.Del(AlternateAuthorizationHeader)
+	for _, p := range Profiles {
+		r.Header.Del(p.DateHeader)
+		r.Header.Del(p.TokenHeader)
+	}
+	if r.URL.RawQuery == "" {
+		return
+	}
+	pairs := strings.Split(r.URL.RawQuery, "&")
+	kept := pairs[:0]
+	for _, kv := range pairs {
+		if !isPresignParam(kv) {
+			kept = append(kept, kv)
+		}
+	}
+	r.URL.RawQuery = strings.Join(kept, "&")
+}
+
+func isPresignParam(kv string) bool {
+	for _, param := range presignParams {
+		if strings.HasPrefix(kv, param) {
+			return true
+		}
+	}
+	return false
+}
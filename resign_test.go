@@ -0,0 +1,58 @@
+package sign4_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+func TestSignRequestResign(t *testing.T) {
+	first := &sign4.Signature{AccessKey: "AKIDFIRST", SecretKey: "secret1", SessionToken: "token1", Region: "us-east-1", Service: "execute-api"}
+	first = first.WithOptions(sign4.Deterministic(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+	retry := &sign4.Signature{AccessKey: "AKIDRETRY", SecretKey: "secret2", SessionToken: "token2", Region: "us-east-1", Service: "execute-api"}
+	retry = retry.WithOptions(sign4.Deterministic(time.Date(2024, 1, 2, 3, 9, 0, 0, time.UTC)))
+	r, _ := http.NewRequest("GET", "https://api.example.com/items?page=2", nil)
+	if err := first.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := retry.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	fresh, _ := http.NewRequest("GET", "https://api.example.com/items?page=2", nil)
+	if err := retry.SignRequest(fresh, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Get("Authorization") != fresh.Header.Get("Authorization") {
+		t.Fatal("re-signed request differs from a fresh one", r.Header.Get("Authorization"), fresh.Header.Get("Authorization"))
+	}
+	if r.Header.Get("X-Amz-Security-Token") != "token2" || r.Header.Get("X-Amz-Date") != "20240102T030900Z" {
+		t.Fatal("stale token or date", r.Header)
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret2", nil }}
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal("re-signed request failed verification", err)
+	}
+}
+
+func TestUnsignPresigned(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", SessionToken: "token", Region: "us-east-1", Service: "s3"}
+	r, _ := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/key?versionId=3", nil)
+	u, err := s.Presign(r, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ = http.NewRequest("GET", u.String(), nil)
+	if !sign4.IsSigned(r) {
+		t.Fatal("presigned request not recognized")
+	}
+	sign4.Unsign(r)
+	if r.URL.RawQuery != "versionId=3" || sign4.IsSigned(r) {
+		t.Fatal("presign parameters kept", r.URL.RawQuery)
+	}
+	if err := s.SignRequest(r, nil); err != nil || strings.Contains(r.URL.RawQuery, "X-Amz-") {
+		t.Fatal("signing the presigned request failed", err, r.URL.RawQuery)
+	}
+}
@@ -0,0 +1,51 @@
+package sign4
+
+// Deriving the signing key of the next UTC day before midnight
+
+import (
+	"context"
+	"time"
+)
+
+// PrepareKey derives the signing key of s for the UTC day of t into its key cache ahead of
+// the first request of that day, the keys of the current day stay cached
+func (s *Signature) PrepareKey(t time.Time) error {
+	creds, err := s.Credentials()
+	if err != nil {
+		return err
+	}
+	p := s.profile()
+	if s.KeyCache == nil {
+		return signingKeys.prepare(p, creds.SecretKey, s.Region, s.Service, t, s.now())
+	}
+	_, err = s.signingKey(p, creds.SecretKey, t)
+	return err
+}
+
+// PreRotateKeys calls PrepareKey for the next UTC day lead before each midnight, by the
+// clock of s, until ctx is done, so the first requests after the date rolls don't derive
+// the key or race to cache it. Failures are logged to the Logger of s; run it on its own
+// goroutine, it returns ctx.Err()
+func (s *Signature) PreRotateKeys(ctx context.Context, lead time.Duration) error {
+	var prepared int64
+	for {
+		now := s.now()
+		day := utcDay(now) + 1
+		if day == prepared {
+			day++
+		}
+		midnight := time.Unix(day*86400, 0).UTC()
+		timer := time.NewTimer(midnight.Add(-lead).Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if err := s.PrepareKey(midnight); err != nil && s.Logger != nil {
+			s.Logger.Log(LogWarn, "preparing signing key failed", "region", s.Region, "service", s.Service,
+				"day", midnight.Format(BasicDateFormatShort), "error", err.Error())
+		}
+		prepared = day
+	}
+}
@@ -0,0 +1,43 @@
+package sign4_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// recordingCache passes the values stored in it to stored
+type recordingCache struct {
+	sign4.MemoryCache
+	stored chan []byte
+}
+
+func (c *recordingCache) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	c.stored <- value
+	return c.MemoryCache.SetWithTTL(key, value, ttl)
+}
+
+func TestPreRotateKeys(t *testing.T) {
+	cache := &recordingCache{stored: make(chan []byte, 1)}
+	s := (&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "kinesis"}).
+		WithOptions(sign4.UseKeyCache(cache), sign4.Deterministic(time.Date(2024, 3, 9, 23, 59, 30, 0, time.UTC)))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.PreRotateKeys(ctx, time.Minute) }()
+	select {
+	case key := <-cache.stored:
+		want, _ := sign4.GenerateSigningKey("secret", "us-east-1", "kinesis", time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC))
+		if !bytes.Equal(key, want) {
+			t.Fatal("wrong day prepared")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("next day key not prepared")
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,110 @@
+package sign4_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+func TestObjectURL(t *testing.T) {
+	for _, c := range []struct {
+		p             *sign4.Profile
+		endpoint, url string
+	}{
+		{sign4.AWS, "https://s3.us-west-2.amazonaws.com", "https://bucket.s3.us-west-2.amazonaws.com/a/b.txt"},
+		{sign4.Wasabi, "https://s3.wasabisys.com", "https://bucket.s3.wasabisys.com/a/b.txt"},
+		{sign4.MinIO, "http://localhost:9000", "http://localhost:9000/bucket/a/b.txt"},
+		{sign4.Ceph, "https://rgw.example.com/", "https://rgw.example.com/bucket/a/b.txt"},
+	} {
+		u, err := c.p.ObjectURL(c.endpoint, "bucket", "/a/b.txt")
+		if err != nil || u.String() != c.url {
+			t.Fatal("wrong object url", u, err, c.url)
+		}
+	}
+	if u, _ := sign4.B2.ObjectURL("https://s3.us-west-004.backblazeb2.com", "my.bucket", "k"); u.String() != "https://s3.us-west-004.backblazeb2.com/my.bucket/k" {
+		t.Fatal("dotted bucket not path-style", u)
+	}
+}
+
+func TestCompatibleProfiles(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "s3"}
+	sign := func(p *sign4.Profile, url string) *http.Request {
+		r, _ := http.NewRequest("GET", url, nil)
+		r.Header.Set("User-Agent", "agent")
+		r.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+		if err := s.WithOptions(sign4.UseProfile(p)).SignRequest(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	r := sign(sign4.AWS, "https://s3.amazonaws.com:443/bucket")
+	if r.Host != "s3.amazonaws.com" || !strings.Contains(r.Header.Get("Authorization"), "SignedHeaders=host;user-agent;x-amz-content-sha256;x-amz-date,") {
+		t.Fatal("wrong aws signing", r.Host, r.Header.Get("Authorization"))
+	}
+	if r = sign(sign4.MinIO, "http://localhost:80/bucket"); r.Host != "localhost:80" {
+		t.Fatal("minio dropped the port", r.Host)
+	}
+	for _, p := range []*sign4.Profile{sign4.Ceph, sign4.B2, sign4.Wasabi} {
+		r = sign(p, "https://gateway.example.com/bucket")
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date,") {
+			t.Fatal("wrong minimal headers", auth)
+		}
+	}
+}
+
+// TestMinIO runs against a local server when SIGN4_MINIO_ENDPOINT is set, e.g.
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	SIGN4_MINIO_ENDPOINT=http://localhost:9000 go test -run MinIO
+func TestMinIO(t *testing.T) {
+	endpoint := os.Getenv("SIGN4_MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("SIGN4_MINIO_ENDPOINT not set")
+	}
+	env := func(name, value string) string {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+		return value
+	}
+	s := (&sign4.Signature{
+		AccessKey: env("SIGN4_MINIO_ACCESS_KEY", "minioadmin"),
+		SecretKey: env("SIGN4_MINIO_SECRET_KEY", "minioadmin"),
+		Region:    env("SIGN4_MINIO_REGION", "us-east-1"),
+		Service:   "s3",
+	}).WithOptions(sign4.UseProfile(sign4.MinIO))
+	bucket := "sign4-" + time.Now().UTC().Format("20060102150405")
+	do := func(method, key, body string, want int) string {
+		u, err := sign4.MinIO.ObjectURL(endpoint, bucket, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, _ := http.NewRequest(method, u.String(), strings.NewReader(body))
+		if err := s.SignRequest(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s: %d %s", method, u, resp.StatusCode, data)
+		}
+		return string(data)
+	}
+	do("PUT", "", "", 200)
+	do("PUT", "dir/hello world.txt", "hello", 200)
+	if got := do("GET", "dir/hello world.txt", "", 200); got != "hello" {
+		t.Fatal("wrong object", got)
+	}
+	do("DELETE", "dir/hello world.txt", "", 204)
+	do("DELETE", "", "", 204)
+}
@@ -0,0 +1,202 @@
+// Package s3express signs requests to S3 Express One Zone directory buckets with
+// the session credentials of CreateSession.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_CreateSession.html
+package s3express
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// ServiceName is the signing name of directory bucket requests
+const ServiceName = "s3express"
+
+// Profile sends the session token as x-amz-s3session-token
+var Profile = &sign4.Profile{
+	Algorithm:   sign4.AWS.Algorithm,
+	KeyPrefix:   sign4.AWS.KeyPrefix,
+	Terminator:  sign4.AWS.Terminator,
+	DateHeader:  sign4.AWS.DateHeader,
+	TokenHeader: "X-Amz-S3session-Token",
+}
+
+// Session are the credentials of a CreateSession call
+type Session struct {
+	Credentials sign4.Credentials
+	Expiration  time.Time
+}
+
+// Error is an error returned by CreateSession
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("s3express: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Client creates and caches sessions per bucket, it signs requests with them
+type Client struct {
+	// Signature holds the credentials CreateSession is signed with
+	Signature  *sign4.Signature
+	HTTPClient *http.Client
+	// Endpoint overrides https://<bucket>.s3express-<zone>.<region>.amazonaws.com,
+	// buckets are then addressed path-style
+	Endpoint string
+	// Refresh is how long before its expiry a session is renewed, one minute when zero
+	Refresh time.Duration
+	// SessionMode is ReadWrite, the default, or ReadOnly
+	SessionMode string
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewClient returns a client creating sessions with s
+func NewClient(s *sign4.Signature) *Client {
+	return &Client{Signature: s}
+}
+
+// Zone returns the zone ID of a directory bucket name, e.g. usw2-az1 of bucket--usw2-az1--x-s3
+func Zone(bucket string) string {
+	name := strings.TrimSuffix(bucket, "--x-s3")
+	if name == bucket {
+		return ""
+	}
+	if i := strings.LastIndex(name, "--"); i >= 0 {
+		return name[i+2:]
+	}
+	return ""
+}
+
+// BucketURL returns the URL of bucket
+func (c *Client) BucketURL(bucket string) string {
+	if c.Endpoint != "" {
+		return strings.TrimSuffix(c.Endpoint, "/") + "/" + bucket
+	}
+	return fmt.Sprintf("https://%s.s3express-%s.%s.amazonaws.com", bucket, Zone(bucket), c.Signature.Region)
+}
+
+// bucketOf returns the directory bucket r is addressed to
+func bucketOf(r *http.Request) string {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	if i := strings.IndexByte(host, '.'); i > 0 && strings.HasSuffix(host[:i], "--x-s3") {
+		return host[:i]
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+// CreateSession returns new session credentials for bucket
+func (c *Client) CreateSession(ctx context.Context, bucket string) (*Session, error) {
+	if bucket == "" {
+		return nil, errors.New("s3express: missing bucket")
+	}
+	r, err := http.NewRequest("GET", c.BucketURL(bucket)+"/?session", nil)
+	if err != nil {
+		return nil, err
+	}
+	r = r.WithContext(ctx)
+	if c.SessionMode != "" {
+		r.Header.Set("X-Amz-Create-Session-Mode", c.SessionMode)
+	}
+	if err := c.Signature.WithService(ServiceName).SignRequest(r, nil); err != nil {
+		return nil, err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode}
+		xml.Unmarshal(body, e)
+		return nil, e
+	}
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"Credentials"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	creds := result.Credentials
+	if creds.AccessKeyID == "" || creds.SessionToken == "" {
+		return nil, errors.New("s3express: missing session credentials")
+	}
+	return &Session{
+		Credentials: sign4.Credentials{AccessKey: creds.AccessKeyID, SecretKey: creds.SecretAccessKey, SessionToken: creds.SessionToken, Expiry: creds.Expiration},
+		Expiration:  creds.Expiration,
+	}, nil
+}
+
+// Session returns the cached session of bucket, creating one when it is missing
+// or expires within Refresh
+func (c *Client) Session(ctx context.Context, bucket string) (*Session, error) {
+	refresh := c.Refresh
+	if refresh == 0 {
+		refresh = time.Minute
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s := c.sessions[bucket]; s != nil && time.Now().Add(refresh).Before(s.Expiration) {
+		return s, nil
+	}
+	s, err := c.CreateSession(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if c.sessions == nil {
+		c.sessions = make(map[string]*Session)
+	}
+	c.sessions[bucket] = s
+	return s, nil
+}
+
+// provider supplies the credentials of a session
+type provider sign4.Credentials
+
+func (p provider) Credentials() (sign4.Credentials, error) {
+	return sign4.Credentials(p), nil
+}
+
+// SignRequest signs r with the session of the bucket it is addressed to
+func (c *Client) SignRequest(r *http.Request, signedHeaders map[string]bool) error {
+	s, err := c.Session(r.Context(), bucketOf(r))
+	if err != nil {
+		return err
+	}
+	signer := &sign4.Signature{Region: c.Signature.Region, Service: ServiceName, Provider: provider(s.Credentials), Options: c.Signature.Options}
+	signer.Profile = Profile
+	return signer.SignRequest(r, signedHeaders)
+}
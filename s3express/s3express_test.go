@@ -0,0 +1,94 @@
+package s3express_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/s3express"
+)
+
+func TestZone(t *testing.T) {
+	if z := s3express.Zone("logs--usw2-az1--x-s3"); z != "usw2-az1" {
+		t.Fatal("wrong zone", z)
+	}
+	c := s3express.NewClient(&sign4.Signature{Region: "us-west-2"})
+	if u := c.BucketURL("logs--usw2-az1--x-s3"); u != "https://logs--usw2-az1--x-s3.s3express-usw2-az1.us-west-2.amazonaws.com" {
+		t.Fatal("wrong bucket url", u)
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	sessions := 0
+	lifetime := 5 * time.Minute
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/logs--use1-az4--x-s3/" || r.URL.RawQuery != "session" {
+			t.Error("wrong path", r.URL)
+		}
+		if auth := r.Header.Get("Authorization"); !strings.Contains(auth, "Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "/s3express/aws4_request") {
+			t.Error("wrong create session signature", auth)
+		}
+		if r.Header.Get("X-Amz-Create-Session-Mode") != "ReadOnly" {
+			t.Error("missing session mode")
+		}
+		sessions++
+		fmt.Fprintf(w, `<CreateSessionResult><Credentials><SessionToken>token%d</SessionToken><SecretAccessKey>secret</SecretAccessKey>`+
+			`<AccessKeyId>ASIA%d</AccessKeyId><Expiration>%s</Expiration></Credentials></CreateSessionResult>`,
+			sessions, sessions, time.Now().Add(lifetime).UTC().Format(time.RFC3339))
+	}))
+	defer server.Close()
+	c := s3express.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"})
+	c.Endpoint = server.URL
+	c.SessionMode = "ReadOnly"
+	sign := func() *http.Request {
+		r, _ := http.NewRequest("GET", c.BucketURL("logs--use1-az4--x-s3")+"/a.log", nil)
+		if err := c.SignRequest(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	r := sign()
+	sign()
+	if sessions != 1 {
+		t.Fatal("session not cached", sessions)
+	}
+	if r.Header.Get("X-Amz-S3session-Token") != "token1" || r.Header.Get("X-Amz-Security-Token") != "" {
+		t.Fatal("wrong token header", r.Header)
+	}
+	if auth := r.Header.Get("Authorization"); !strings.Contains(auth, "Credential=ASIA1/") ||
+		!strings.Contains(auth, "/us-east-1/s3express/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-s3session-token,") {
+		t.Fatal("wrong authorization", auth)
+	}
+
+	// sessions expiring within Refresh are renewed on every request
+	lifetime = 30 * time.Second
+	c = s3express.NewClient(c.Signature)
+	c.Endpoint = server.URL
+	c.SessionMode = "ReadOnly"
+	sign()
+	if _, err := c.Session(context.Background(), "logs--use1-az4--x-s3"); err != nil {
+		t.Fatal(err)
+	}
+	if sessions != 3 {
+		t.Fatal("expiring session not refreshed", sessions)
+	}
+}
+
+func TestCreateSessionError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+	}))
+	defer server.Close()
+	c := s3express.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"})
+	c.Endpoint = server.URL
+	_, err := c.CreateSession(context.Background(), "logs--use1-az4--x-s3")
+	if e, ok := err.(*s3express.Error); !ok || e.StatusCode != 403 || e.Code != "AccessDenied" {
+		t.Fatal("wrong error", err)
+	}
+}
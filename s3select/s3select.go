@@ -0,0 +1,248 @@
+// Package s3select runs S3 Select queries with SelectObjectContent signed with sign4
+// and reads the rows of the event stream response.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+package s3select
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/eventstream"
+)
+
+// ServiceName is the signing name of S3
+const ServiceName = "s3"
+
+// Error is an error returned by SelectObjectContent, before or in the stream
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("s3select: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// CSVInput describes CSV objects, FileHeaderInfo is NONE, USE or IGNORE
+type CSVInput struct {
+	FileHeaderInfo             string `xml:"FileHeaderInfo,omitempty"`
+	Comments                   string `xml:"Comments,omitempty"`
+	QuoteEscapeCharacter       string `xml:"QuoteEscapeCharacter,omitempty"`
+	RecordDelimiter            string `xml:"RecordDelimiter,omitempty"`
+	FieldDelimiter             string `xml:"FieldDelimiter,omitempty"`
+	QuoteCharacter             string `xml:"QuoteCharacter,omitempty"`
+	AllowQuotedRecordDelimiter bool   `xml:"AllowQuotedRecordDelimiter,omitempty"`
+}
+
+// JSONInput describes JSON objects, Type is DOCUMENT or LINES
+type JSONInput struct {
+	Type string `xml:"Type"`
+}
+
+// ParquetInput describes Parquet objects
+type ParquetInput struct{}
+
+// InputSerialization is the format of the object, one of CSV, JSON and Parquet is set,
+// CompressionType is NONE, GZIP or BZIP2
+type InputSerialization struct {
+	CompressionType string        `xml:"CompressionType,omitempty"`
+	CSV             *CSVInput     `xml:"CSV,omitempty"`
+	JSON            *JSONInput    `xml:"JSON,omitempty"`
+	Parquet         *ParquetInput `xml:"Parquet,omitempty"`
+}
+
+// CSVOutput formats rows as CSV, QuoteFields is ALWAYS or ASNEEDED
+type CSVOutput struct {
+	QuoteFields          string `xml:"QuoteFields,omitempty"`
+	QuoteEscapeCharacter string `xml:"QuoteEscapeCharacter,omitempty"`
+	RecordDelimiter      string `xml:"RecordDelimiter,omitempty"`
+	FieldDelimiter       string `xml:"FieldDelimiter,omitempty"`
+	QuoteCharacter       string `xml:"QuoteCharacter,omitempty"`
+}
+
+// JSONOutput formats rows as JSON
+type JSONOutput struct {
+	RecordDelimiter string `xml:"RecordDelimiter,omitempty"`
+}
+
+// OutputSerialization is the format of the rows, one of CSV and JSON is set
+type OutputSerialization struct {
+	CSV  *CSVOutput  `xml:"CSV,omitempty"`
+	JSON *JSONOutput `xml:"JSON,omitempty"`
+}
+
+// ScanRange limits the query to a byte range of the object, End zero is the end of the object
+type ScanRange struct {
+	Start int64 `xml:"Start"`
+	End   int64 `xml:"End,omitempty"`
+}
+
+// Input describes a query
+type Input struct {
+	Bucket string
+	Key    string
+	// Expression is the SQL expression
+	Expression string
+	Input      InputSerialization
+	Output     OutputSerialization
+	ScanRange  *ScanRange
+}
+
+// request is the SelectObjectContentRequest document
+type request struct {
+	XMLName             xml.Name            `xml:"http://s3.amazonaws.com/doc/2006-03-01/ SelectObjectContentRequest"`
+	Expression          string              `xml:"Expression"`
+	ExpressionType      string              `xml:"ExpressionType"`
+	InputSerialization  InputSerialization  `xml:"InputSerialization"`
+	OutputSerialization OutputSerialization `xml:"OutputSerialization"`
+	ScanRange           *ScanRange          `xml:"ScanRange,omitempty"`
+}
+
+// RequestBody returns the XML document of the query
+func RequestBody(in *Input) ([]byte, error) {
+	return xml.Marshal(&request{
+		Expression:          in.Expression,
+		ExpressionType:      "SQL",
+		InputSerialization:  in.Input,
+		OutputSerialization: in.Output,
+		ScanRange:           in.ScanRange,
+	})
+}
+
+// Stats are the byte counts of a finished query
+type Stats struct {
+	BytesScanned   int64 `xml:"BytesScanned"`
+	BytesProcessed int64 `xml:"BytesProcessed"`
+	BytesReturned  int64 `xml:"BytesReturned"`
+}
+
+// Results reads the rows of the Records events, io.EOF after the End event, a stream
+// ending before it fails with io.ErrUnexpectedEOF as the rows are then incomplete
+type Results struct {
+	StatusCode int
+	// Stats is set once the Stats event was read
+	Stats *Stats
+
+	body    io.ReadCloser
+	decoder *eventstream.Decoder
+	chunk   []byte
+	end     bool
+}
+
+// Read returns the bytes of the rows
+func (r *Results) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.end {
+			return 0, io.EOF
+		}
+		m, err := r.decoder.Decode()
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		if m.Headers.String(":message-type") == "error" {
+			return 0, &Error{StatusCode: r.StatusCode, Code: m.Headers.String(":error-code"), Message: m.Headers.String(":error-message")}
+		}
+		switch m.Headers.String(":event-type") {
+		case "Records":
+			r.chunk = m.Payload
+		case "Stats":
+			var stats struct {
+				Details Stats `xml:"Details"`
+			}
+			if err := xml.Unmarshal(m.Payload, &stats); err != nil {
+				return 0, err
+			}
+			r.Stats = &stats.Details
+		case "End":
+			r.end = true
+		}
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+// Close closes the response body
+func (r *Results) Close() error {
+	return r.body.Close()
+}
+
+// Client runs queries
+type Client struct {
+	Signature  *sign4.Signature
+	HTTPClient *http.Client
+	// Endpoint overrides https://<bucket>.s3.<region>.amazonaws.com, buckets are then
+	// addressed path-style
+	Endpoint string
+}
+
+// NewClient returns a client signing with s, the service name is forced to s3
+func NewClient(s *sign4.Signature) *Client {
+	return &Client{Signature: s}
+}
+
+// escapeKey escapes the segments of an object key
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// Select runs a query and returns its rows as they arrive
+func (c *Client) Select(ctx context.Context, in *Input) (*Results, error) {
+	if in.Bucket == "" || in.Key == "" {
+		return nil, errors.New("s3select: missing bucket or key")
+	}
+	if in.Expression == "" {
+		return nil, errors.New("s3select: missing expression")
+	}
+	body, err := RequestBody(in)
+	if err != nil {
+		return nil, err
+	}
+	u := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", in.Bucket, c.Signature.Region)
+	if c.Endpoint != "" {
+		u = strings.TrimSuffix(c.Endpoint, "/") + "/" + in.Bucket + "/"
+	}
+	r, err := http.NewRequest("POST", u+escapeKey(in.Key)+"?select&select-type=2", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Content-Type", "application/xml")
+	if err := c.Signature.WithService(ServiceName).SignRequest(r, nil); err != nil {
+		return nil, err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		e := &Error{StatusCode: resp.StatusCode}
+		xml.Unmarshal(data, e)
+		return nil, e
+	}
+	return &Results{StatusCode: resp.StatusCode, body: resp.Body, decoder: eventstream.NewDecoder(resp.Body)}, nil
+}
@@ -0,0 +1,92 @@
+package s3select_test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/eventstream"
+	"github.com/datastream/aws/s3select"
+)
+
+func event(w io.Writer, headers eventstream.Headers, payload string) {
+	b, _ := eventstream.Encode(&eventstream.Message{Headers: headers, Payload: []byte(payload)})
+	w.Write(b)
+}
+
+func records(w io.Writer, rows string) {
+	event(w, eventstream.Headers{{Name: ":event-type", Value: "Records"}, {Name: ":message-type", Value: "event"}}, rows)
+}
+
+func TestSelect(t *testing.T) {
+	verifier := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifier.Verify(r); err != nil {
+			t.Error(err)
+		}
+		if r.URL.EscapedPath() != "/logs/2024/a%20b.csv" || r.URL.RawQuery != "select&select-type=2" {
+			t.Error("wrong request", r.URL)
+		}
+		var req struct {
+			Expression     string
+			ExpressionType string
+			Input          struct {
+				CSV struct{ FileHeaderInfo string }
+			} `xml:"InputSerialization"`
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		if err := xml.Unmarshal(b, &req); err != nil || req.ExpressionType != "SQL" || req.Input.CSV.FileHeaderInfo != "USE" {
+			t.Error("wrong request body", string(b), err)
+		}
+		records(w, "a,1\n")
+		event(w, eventstream.Headers{{Name: ":event-type", Value: "Cont"}, {Name: ":message-type", Value: "event"}}, "")
+		records(w, "b,2\n")
+		if req.Expression == "truncated" {
+			return
+		}
+		if req.Expression == "fail" {
+			event(w, eventstream.Headers{{Name: ":message-type", Value: "error"}, {Name: ":error-code", Value: "CSVParsingError"}, {Name: ":error-message", Value: "bad row"}}, "")
+			return
+		}
+		event(w, eventstream.Headers{{Name: ":event-type", Value: "Stats"}, {Name: ":message-type", Value: "event"}},
+			"<Stats><Details><BytesScanned>100</BytesScanned><BytesProcessed>100</BytesProcessed><BytesReturned>8</BytesReturned></Details></Stats>")
+		event(w, eventstream.Headers{{Name: ":event-type", Value: "End"}, {Name: ":message-type", Value: "event"}}, "")
+	}))
+	defer server.Close()
+	c := s3select.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"})
+	c.Endpoint = server.URL
+	in := &s3select.Input{
+		Bucket:     "logs",
+		Key:        "2024/a b.csv",
+		Expression: "SELECT * FROM S3Object",
+		Input:      s3select.InputSerialization{CSV: &s3select.CSVInput{FileHeaderInfo: "USE"}},
+		Output:     s3select.OutputSerialization{CSV: &s3select.CSVOutput{}},
+	}
+	results, err := c.Select(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(results)
+	results.Close()
+	if err != nil || string(b) != "a,1\nb,2\n" || results.Stats == nil || results.Stats.BytesReturned != 8 {
+		t.Fatal("wrong results", string(b), results.Stats, err)
+	}
+
+	in.Expression = "truncated"
+	results, _ = c.Select(context.Background(), in)
+	if _, err := ioutil.ReadAll(results); err != io.ErrUnexpectedEOF {
+		t.Fatal("truncated stream not detected", err)
+	}
+	in.Expression = "fail"
+	results, _ = c.Select(context.Background(), in)
+	var e *s3select.Error
+	if _, err := ioutil.ReadAll(results); !errors.As(err, &e) || e.Code != "CSVParsingError" {
+		t.Fatal("wrong error", err)
+	}
+}
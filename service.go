@@ -0,0 +1,131 @@
+package sign4
+
+// Canonicalization rules of services, selected from Signature.Service
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ServiceRules are the canonicalization differences of a service, the zero value
+// double encodes and normalizes the path as most AWS services expect
+type ServiceRules struct {
+	// SingleEncoding signs the escaped path as sent instead of escaping it again
+	SingleEncoding bool
+	// NoPathNormalization keeps "." and ".." segments and repeated slashes
+	NoPathNormalization bool
+	// ContentSHA256 signs the payload hash as X-Amz-Content-Sha256, a value already in the
+	// header, e.g. UNSIGNED-PAYLOAD, is signed as the payload hash without reading the body
+	ContentSHA256 bool
+}
+
+// Services holds the rules of services that differ from the default, keyed by signing name;
+// entries may be added before signing starts
+var Services = map[string]ServiceRules{
+	"s3":               {SingleEncoding: true, NoPathNormalization: true, ContentSHA256: true},
+	"s3express":        {SingleEncoding: true, NoPathNormalization: true, ContentSHA256: true},
+	"s3-outposts":      {SingleEncoding: true, NoPathNormalization: true, ContentSHA256: true},
+	"s3-object-lambda": {SingleEncoding: true, NoPathNormalization: true, ContentSHA256: true},
+	"es":               {ContentSHA256: true},
+	"aoss":             {ContentSHA256: true},
+	"glacier":          {ContentSHA256: true},
+}
+
+// escapedPathRules canonicalize the path as escaped, the package level functions use them
+var escapedPathRules = ServiceRules{SingleEncoding: true, NoPathNormalization: true}
+
+// ErrPayloadHashMismatch is returned when the body doesn't hash to its X-Amz-Content-Sha256 header
+var ErrPayloadHashMismatch = errors.New("payload hash does not match")
+
+// UseServiceRules sets Options.ServiceRules
+func UseServiceRules(rules ServiceRules) Option {
+	return func(o *Options) {
+		o.ServiceRules = &rules
+	}
+}
+
+// rules returns the rules of s, Options.ServiceRules or those registered for its service
+func (s *Signature) rules() *ServiceRules {
+	if s.ServiceRules != nil {
+		return s.ServiceRules
+	}
+	rules := Services[s.Service]
+	return &rules
+}
+
+// uriEncode percent-encodes everything but the RFC 3986 unreserved characters, and '/' when keepSlash
+func uriEncode(s string, keepSlash bool) string {
+	const hexUpper = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isUnreserved(c) || keepSlash && c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexUpper[c>>4])
+		b.WriteByte(hexUpper[c&15])
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// canonicalPath returns the canonical URI of an escaped path under rules
+func canonicalPath(escaped string, rules *ServiceRules) string {
+	if escaped == "" {
+		escaped = "/"
+	}
+	if !rules.NoPathNormalization && (strings.Contains(escaped, "//") || strings.Contains(escaped, "/.")) {
+		cleaned := path.Clean(escaped)
+		if strings.HasSuffix(escaped, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		escaped = cleaned
+	}
+	if rules.SingleEncoding {
+		return escaped
+	}
+	for i := 0; i < len(escaped); i++ {
+		if c := escaped[i]; !isUnreserved(c) && c != '/' {
+			return uriEncode(escaped, true)
+		}
+	}
+	return escaped
+}
+
+// payloadHash returns the payload hash r is signed with, the X-Amz-Content-Sha256 header
+// of services whose rules want it and the empty payload hash of upgrade requests
+func (s *Signature) payloadHash(sc *scratch, r *http.Request) (string, error) {
+	if IsUpgrade(r) {
+		// the body of a handshake is the connection after it
+		return emptyPayloadHash, nil
+	}
+	if s.rules().ContentSHA256 {
+		if h := headerValue(r.Header, "X-Amz-Content-Sha256"); h != "" {
+			return h, nil
+		}
+	}
+	return sc.payloadHash(r, s.UnbufferedPayload)
+}
+
+// checkPayloadHash compares a hex X-Amz-Content-Sha256 header with the body of r
+func (s *Signature) checkPayloadHash(sc *scratch, r *http.Request) error {
+	h := headerValue(r.Header, "X-Amz-Content-Sha256")
+	if !s.rules().ContentSHA256 || len(h) != 64 {
+		return nil
+	}
+	sum, err := sc.payloadHash(r, s.UnbufferedPayload)
+	if err != nil {
+		return err
+	}
+	if sum != strings.ToLower(h) {
+		return ErrPayloadHashMismatch
+	}
+	return nil
+}
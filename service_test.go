@@ -0,0 +1,64 @@
+package sign4_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func TestServiceRules(t *testing.T) {
+	d := "20150830T123600Z"
+	canonicalURI := func(service, url string) string {
+		r, _ := http.NewRequest("GET", url, nil)
+		r.Header.Set("X-Amz-Date", d)
+		s := &sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: service}
+		got, err := s.GetStringToSign(r, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(got.CanonicalRequest, "\n")[1]
+	}
+	for _, c := range []struct{ service, url, uri string }{
+		{"execute-api", "https://api.example.com/a%20b/c", "/a%2520b/c"},
+		{"execute-api", "https://api.example.com/a/./b/../c//d/", "/a/c/d/"},
+		{"execute-api", "https://api.example.com", "/"},
+		{"s3", "https://bucket.s3.amazonaws.com/a%20b/./c//d", "/a%20b/./c//d"},
+		{"es", "https://search.example.com/_doc/a%2Fb", "/_doc/a%252Fb"},
+	} {
+		if got := canonicalURI(c.service, c.url); got != c.uri {
+			t.Errorf("%s %s: got %s want %s", c.service, c.url, got, c.uri)
+		}
+	}
+
+	s := &sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "es"}
+	r, _ := http.NewRequest("PUT", "https://search.example.com/index/_doc/1", strings.NewReader(`{"a":1}`))
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Header.Get("X-Amz-Content-Sha256"); len(h) != 64 || !strings.Contains(r.Header.Get("Authorization"), "x-amz-content-sha256") {
+		t.Fatal("payload hash header not signed", r.Header)
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+	r.Body = http.NoBody
+	if _, err := v.Verify(r); err != sign4.ErrPayloadHashMismatch {
+		t.Fatal("tampered body verified", err)
+	}
+
+	// a preset hash is signed without reading the body
+	s.Service = "s3"
+	r, _ = http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/key", strings.NewReader("data"))
+	r.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	r.Header.Set("X-Amz-Date", d)
+	got, _ := s.GetStringToSign(r, nil)
+	if !strings.HasSuffix(got.CanonicalRequest, "\nUNSIGNED-PAYLOAD") {
+		t.Fatal("preset hash not signed", got.CanonicalRequest)
+	}
+	if got, _ = s.WithOptions(sign4.UseServiceRules(sign4.ServiceRules{})).GetStringToSign(r, nil); strings.HasSuffix(got.CanonicalRequest, "\nUNSIGNED-PAYLOAD") {
+		t.Fatal("rules not overridden")
+	}
+}
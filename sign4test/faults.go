@@ -0,0 +1,161 @@
+package sign4test
+
+// Fault injection, signing that fails verification on a share of requests so
+// retry and alerting paths around auth failures can be exercised
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// DefaultFaultSkew is the clock skew of Faults when Skew is zero, beyond the
+// fifteen minutes AWS accepts
+const DefaultFaultSkew = time.Hour
+
+// FaultCounts are the faults injected so far
+type FaultCounts struct {
+	Requests  int
+	Corrupted int
+	Skewed    int
+	Dropped   int
+}
+
+// Faults is a sign4.Signer and http.RoundTripper breaking the signatures of Signer
+// on a share of requests, each rate is the probability from 0 to 1 of its fault
+type Faults struct {
+	Signer sign4.Signer
+	// CorruptSignature changes a digit of the signature
+	CorruptSignature float64
+	// SkewTime dates the request Skew away from Now before signing
+	SkewTime float64
+	Skew     time.Duration
+	// DropSignedHeader removes a signed header after signing, host and the date
+	// header are kept unless nothing else is signed
+	DropSignedHeader float64
+	// Base sends the requests of RoundTrip, it defaults to http.DefaultTransport
+	Base http.RoundTripper
+	// Rand and Now default to math/rand.Float64 and time.Now
+	Rand func() float64
+	Now  func() time.Time
+
+	mu     sync.Mutex
+	counts FaultCounts
+}
+
+var _ sign4.Signer = &Faults{}
+
+func (f *Faults) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	if f.Rand != nil {
+		return f.Rand() < rate
+	}
+	return rand.Float64() < rate
+}
+
+// SignRequest signs r with Signer and injects the faults rolled for it
+func (f *Faults) SignRequest(r *http.Request, signedHeaders map[string]bool) error {
+	f.mu.Lock()
+	skew, corrupt, drop := f.roll(f.SkewTime), f.roll(f.CorruptSignature), f.roll(f.DropSignedHeader)
+	f.counts.Requests++
+	f.mu.Unlock()
+	if skew {
+		now := time.Now
+		if f.Now != nil {
+			now = f.Now
+		}
+		d := f.Skew
+		if d == 0 {
+			d = DefaultFaultSkew
+		}
+		r.Header.Del("Date")
+		r.Header.Set("X-Amz-Date", now().Add(d).UTC().Format(sign4.BasicDateFormat))
+	}
+	if err := f.Signer.SignRequest(r, signedHeaders); err != nil {
+		return err
+	}
+	corrupt = corrupt && corruptSignature(r)
+	drop = drop && dropSignedHeader(r)
+	f.mu.Lock()
+	if skew {
+		f.counts.Skewed++
+	}
+	if corrupt {
+		f.counts.Corrupted++
+	}
+	if drop {
+		f.counts.Dropped++
+	}
+	f.mu.Unlock()
+	return nil
+}
+
+// RoundTrip signs a copy of r and sends it with Base
+func (f *Faults) RoundTrip(r *http.Request) (*http.Response, error) {
+	r2 := r.Clone(r.Context())
+	if r2.Host == "" {
+		r2.Host = r2.URL.Host
+	}
+	if err := f.SignRequest(r2, nil); err != nil {
+		return nil, err
+	}
+	if f.Base != nil {
+		return f.Base.RoundTrip(r2)
+	}
+	return http.DefaultTransport.RoundTrip(r2)
+}
+
+// Counts returns the faults injected so far
+func (f *Faults) Counts() FaultCounts {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts
+}
+
+// corruptSignature changes the last digit of the Authorization signature
+func corruptSignature(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.Contains(auth, "Signature=") || strings.HasSuffix(auth, "Signature=") {
+		return false
+	}
+	last := byte('0')
+	if auth[len(auth)-1] == '0' {
+		last = '1'
+	}
+	r.Header.Set("Authorization", auth[:len(auth)-1]+string(last))
+	return true
+}
+
+// dropSignedHeader removes the first signed header other than host and the date
+func dropSignedHeader(r *http.Request) bool {
+	_, _, signed, err := sign4.GetSignature(r)
+	if err != nil {
+		return false
+	}
+	var keys, dates []string
+	for k := range signed {
+		switch k {
+		case "host":
+		case "x-amz-date", "date":
+			dates = append(dates, k)
+		default:
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		keys = dates
+	}
+	if len(keys) == 0 {
+		return false
+	}
+	sort.Strings(keys)
+	r.Header.Del(keys[0])
+	return true
+}
@@ -0,0 +1,67 @@
+package sign4test_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/sign4test"
+)
+
+func TestFaults(t *testing.T) {
+	now := time.Now()
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }, MaxSkew: 15 * time.Minute}
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	verify := func(f *sign4test.Faults) error {
+		r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+		r.Header.Set("X-Amz-Meta-Name", "value")
+		if err := f.SignRequest(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		_, err := v.Verify(r)
+		return err
+	}
+	if err := verify(&sign4test.Faults{Signer: s}); err != nil {
+		t.Fatal("request without faults rejected", err)
+	}
+	if err := verify(&sign4test.Faults{Signer: s, CorruptSignature: 1}); err != sign4.ErrSignatureMismatch {
+		t.Fatal("corrupted signature accepted", err)
+	}
+	if err := verify(&sign4test.Faults{Signer: s, SkewTime: 1, Now: func() time.Time { return now }}); err != sign4.ErrRequestTimeSkewed {
+		t.Fatal("skewed request accepted", err)
+	}
+	if err := verify(&sign4test.Faults{Signer: s, DropSignedHeader: 1}); err != sign4.ErrSignatureMismatch {
+		t.Fatal("request with a dropped header accepted", err)
+	}
+
+	// a quarter of the requests through the transport fail
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.Verify(r); err != nil {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+	rolls := []float64{0.1, 0.9, 0.9, 0.9}
+	i := 0
+	f := &sign4test.Faults{Signer: s, CorruptSignature: 0.25, Rand: func() float64 {
+		i++
+		return rolls[(i-1)%len(rolls)]
+	}}
+	client := &http.Client{Transport: f}
+	forbidden := 0
+	for n := 0; n < 8; n++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusForbidden {
+			forbidden++
+		}
+	}
+	if c := f.Counts(); forbidden != 2 || c.Requests != 8 || c.Corrupted != 2 || c.Skewed != 0 {
+		t.Fatal("wrong fault share", forbidden, c)
+	}
+}
@@ -0,0 +1,139 @@
+package sign4test
+
+// Golden files lock in signing behavior across library upgrades
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+// UpdateEnv rewrites golden files in Check when set to a non-empty value
+const UpdateEnv = "SIGN4_UPDATE_GOLDEN"
+
+// Golden is a signed request with the values its signature was computed from
+type Golden struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Header holds the request headers after signing, without Authorization
+	Header           http.Header `json:"header"`
+	Body             []byte      `json:"body,omitempty"`
+	CanonicalRequest string      `json:"canonical_request"`
+	StringToSign     string      `json:"string_to_sign"`
+	Signature        string      `json:"signature"`
+	Authorization    string      `json:"authorization"`
+}
+
+// Record signs a copy of r with s and returns its golden, r should carry a date header
+// so that replays sign for the same time
+func Record(s *sign4.Signature, r *http.Request, signedHeaders map[string]bool) (*Golden, error) {
+	body, err := sign4.RequestPayload(r)
+	if err != nil {
+		return nil, err
+	}
+	signed := r.Clone(r.Context())
+	signed.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err := s.SignRequest(signed, signedHeaders); err != nil {
+		return nil, err
+	}
+	e, err := s.Explain(signed, sign4.Expected{})
+	if err != nil {
+		return nil, err
+	}
+	g := &Golden{
+		Method:           r.Method,
+		URL:              r.URL.String(),
+		Header:           signed.Header.Clone(),
+		Body:             body,
+		CanonicalRequest: e.CanonicalRequest,
+		StringToSign:     e.StringToSign,
+		Signature:        e.Signature,
+		Authorization:    signed.Header.Get("Authorization"),
+	}
+	g.Header.Del("Authorization")
+	return g, nil
+}
+
+// Request returns the request of g without its Authorization header
+func (g *Golden) Request() (*http.Request, error) {
+	r, err := http.NewRequest(g.Method, g.URL, bytes.NewReader(g.Body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header = g.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	return r, nil
+}
+
+// Replay signs the request of g with s and reports the first stage that diverges from g
+func (g *Golden) Replay(s *sign4.Signature) (*sign4.Explanation, error) {
+	r, err := g.Request()
+	if err != nil {
+		return nil, err
+	}
+	return s.Explain(r, sign4.Expected{
+		Authorization:    g.Authorization,
+		CanonicalRequest: g.CanonicalRequest,
+		StringToSign:     g.StringToSign,
+	})
+}
+
+// ReadGolden reads a golden file
+func ReadGolden(filename string) (*Golden, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	g := &Golden{}
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return g, nil
+}
+
+// WriteFile writes g as indented JSON
+func (g *Golden) WriteFile(filename string) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(g); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b.Bytes(), 0644)
+}
+
+// Check replays the golden file against s, it records r into the file when the file
+// is missing or UpdateEnv is set; a divergence fails t with the explanation
+func Check(t testing.TB, filename string, s *sign4.Signature, r *http.Request, signedHeaders map[string]bool) {
+	t.Helper()
+	if _, err := os.Stat(filename); os.IsNotExist(err) || os.Getenv(UpdateEnv) != "" {
+		g, err := Record(s, r, signedHeaders)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.WriteFile(filename); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	g, err := ReadGolden(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := g.Replay(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Stage != "" {
+		t.Errorf("%s: diverges at %s: %s\ncanonical request:\n%s\nexpected:\n%s", filename, e.Stage, e.Detail, e.CanonicalRequest, g.CanonicalRequest)
+	}
+}
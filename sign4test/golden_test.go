@@ -0,0 +1,48 @@
+package sign4test_test
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/sign4test"
+)
+
+var goldenSignature = &sign4.Signature{AccessKey: sign4test.AccessKey, SecretKey: sign4test.SecretKey, Region: sign4test.Region, Service: sign4test.Service}
+
+func goldenRequest() *http.Request {
+	r, _ := http.NewRequest("POST", "https://example.amazonaws.com/items?b=2&a=1", strings.NewReader(`{"id":1}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Amz-Date", "20150830T123600Z")
+	return r
+}
+
+func TestGolden(t *testing.T) {
+	// testdata/post.json locks in the signature of this version
+	sign4test.Check(t, filepath.Join("testdata", "post.json"), goldenSignature, goldenRequest(), nil)
+
+	filename := filepath.Join(t.TempDir(), "post.json")
+	sign4test.Check(t, filename, goldenSignature, goldenRequest(), nil)
+	g, err := sign4test.ReadGolden(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(g.CanonicalRequest, "POST\n/items\na=1&b=2\ncontent-type:application/json\n") ||
+		!strings.HasSuffix(g.Authorization, "Signature="+g.Signature) || string(g.Body) != `{"id":1}` {
+		t.Fatal("wrong golden", g)
+	}
+	sign4test.Check(t, filename, goldenSignature, goldenRequest(), nil)
+
+	g.Header.Set("Content-Type", "text/plain")
+	if e, err := g.Replay(goldenSignature); err != nil || e.Stage != sign4.StageCanonicalRequest {
+		t.Fatal("changed header not reported", e, err)
+	}
+	g.Header.Set("Content-Type", "application/json")
+	other := *goldenSignature
+	other.SecretKey = "other"
+	if e, err := g.Replay(&other); err != nil || e.Stage != sign4.StageSignature {
+		t.Fatal("changed key not reported", e, err)
+	}
+}
@@ -0,0 +1,142 @@
+// Package sign4test provides test doubles for code that signs or verifies
+// requests with sign4, and Faults, which breaks a share of signatures to exercise
+// the retry and alerting paths of clients.
+package sign4test
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// Keys and scope Signer signs with
+const (
+	AccessKey = "AKIDSIGN4TEST"
+	SecretKey = "sign4test"
+	Region    = "us-east-1"
+	Service   = "service"
+)
+
+// Date is the default signing time of Signer
+var Date = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+// Call is a request seen by a test double
+type Call struct {
+	Method        string
+	URL           string
+	Header        http.Header
+	SignedHeaders map[string]bool
+}
+
+func record(r *http.Request, signedHeaders map[string]bool) Call {
+	return Call{Method: r.Method, URL: r.URL.String(), Header: r.Header.Clone(), SignedHeaders: signedHeaders}
+}
+
+// Signer is a sign4.Signer recording its calls, it signs with the test keys and
+// Date unless the request is dated so signatures are the same on every run
+type Signer struct {
+	// Err is returned instead of signing when set
+	Err error
+	// Date overrides the package Date
+	Date time.Time
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ sign4.Signer = &Signer{}
+
+// SignRequest records r and sets its Authorization header
+func (s *Signer) SignRequest(r *http.Request, signedHeaders map[string]bool) error {
+	s.mu.Lock()
+	s.calls = append(s.calls, record(r, signedHeaders))
+	s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	if r.Header.Get("X-Amz-Date") == "" && r.Header.Get("Date") == "" {
+		d := s.Date
+		if d.IsZero() {
+			d = Date
+		}
+		r.Header.Set("X-Amz-Date", d.UTC().Format(sign4.BasicDateFormat))
+	}
+	signature := &sign4.Signature{AccessKey: AccessKey, SecretKey: SecretKey, Region: Region, Service: Service}
+	return signature.SignRequest(r, signedHeaders)
+}
+
+// Calls returns the requests signed so far, before they were signed
+func (s *Signer) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Verifier answers Verify with programmed outcomes instead of checking signatures
+type Verifier struct {
+	// Outcome decides for every request when set
+	Outcome func(r *http.Request) error
+	// Err is returned for access keys without an outcome, nil accepts them
+	Err error
+
+	mu       sync.Mutex
+	outcomes map[string]error
+	calls    []Call
+}
+
+// Accept makes requests of accessKey verify
+func (v *Verifier) Accept(accessKey string) {
+	v.set(accessKey, nil)
+}
+
+// Reject makes requests of accessKey fail with err, sign4.ErrSignatureMismatch when nil
+func (v *Verifier) Reject(accessKey string, err error) {
+	if err == nil {
+		err = sign4.ErrSignatureMismatch
+	}
+	v.set(accessKey, err)
+}
+
+func (v *Verifier) set(accessKey string, err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.outcomes == nil {
+		v.outcomes = make(map[string]error)
+	}
+	v.outcomes[accessKey] = err
+}
+
+// Verify has the signature of sign4.Verifier.Verify, requests without a parsable
+// Authorization header fail with the parse error
+func (v *Verifier) Verify(r *http.Request) (*sign4.Signature, error) {
+	v.mu.Lock()
+	v.calls = append(v.calls, record(r, nil))
+	v.mu.Unlock()
+	s, _, _, err := sign4.GetSignature(r)
+	if err != nil {
+		return nil, err
+	}
+	if v.Outcome != nil {
+		err = v.Outcome(r)
+	} else {
+		v.mu.Lock()
+		outcome, ok := v.outcomes[s.AccessKey]
+		v.mu.Unlock()
+		if err = v.Err; ok {
+			err = outcome
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Calls returns the requests verified so far
+func (v *Verifier) Calls() []Call {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]Call(nil), v.calls...)
+}
@@ -0,0 +1,75 @@
+package sign4test_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/sign4test"
+)
+
+func TestSigner(t *testing.T) {
+	s := &sign4test.Signer{}
+	sign := func() string {
+		r, _ := http.NewRequest("GET", "https://example.amazonaws.com/?a=1", nil)
+		if err := s.SignRequest(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		return r.Header.Get("Authorization")
+	}
+	first := sign()
+	if first != sign() {
+		t.Fatal("signatures differ between runs")
+	}
+	calls := s.Calls()
+	if len(calls) != 2 || calls[0].Method != "GET" || calls[0].URL != "https://example.amazonaws.com/?a=1" || calls[0].Header.Get("Authorization") != "" {
+		t.Fatal("wrong calls", calls)
+	}
+
+	// the signatures are real ones with the test keys
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/?a=1", nil)
+	s.SignRequest(r, nil)
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return sign4test.SecretKey, nil }}
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Err = errors.New("no credentials")
+	if err := s.SignRequest(r, nil); err != s.Err {
+		t.Fatal("error not returned", err)
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	v := &sign4test.Verifier{Err: sign4.ErrRequestTimeSkewed}
+	v.Accept(sign4test.AccessKey)
+	v.Reject("AKIDBAD", nil)
+	verify := func(accessKey string) error {
+		r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+		s := &sign4.Signature{AccessKey: accessKey, SecretKey: "x", Region: "us-east-1", Service: "service"}
+		s.SignRequest(r, nil)
+		_, err := v.Verify(r)
+		return err
+	}
+	if err := verify(sign4test.AccessKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify("AKIDBAD"); err != sign4.ErrSignatureMismatch {
+		t.Fatal("wrong rejection", err)
+	}
+	if err := verify("AKIDOTHER"); err != sign4.ErrRequestTimeSkewed {
+		t.Fatal("wrong default outcome", err)
+	}
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if _, err := v.Verify(r); err == nil {
+		t.Fatal("unsigned request verified")
+	}
+	if len(v.Calls()) != 4 {
+		t.Fatal("wrong calls", v.Calls())
+	}
+	v.Outcome = func(*http.Request) error { return nil }
+	if err := verify("AKIDBAD"); err != nil {
+		t.Fatal("outcome not used", err)
+	}
+}
@@ -0,0 +1,204 @@
+package sign4test
+
+// Interop test vectors, the cross product of a matrix of request edge cases signed
+// with the test keys, written in the aws-sig-v4-test-suite layout or as JSON
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// Host is the host of generated vectors
+const Host = "example.amazonaws.com"
+
+// Matrix holds the named variants of each part of a request, GenerateVectors signs
+// every combination. Empty dimensions take the variants of DefaultMatrix
+type Matrix struct {
+	Methods []string               `json:"methods"`
+	Paths   map[string]string      `json:"paths"`
+	Queries map[string]string      `json:"queries"`
+	Headers map[string]http.Header `json:"headers"`
+	Bodies  map[string]string      `json:"bodies"`
+}
+
+// DefaultMatrix covers the encoding, ordering and whitespace edge cases other
+// implementations most often get wrong
+var DefaultMatrix = Matrix{
+	Methods: []string{"GET", "POST"},
+	Paths: map[string]string{
+		"root":        "/",
+		"space":       "/example space/",
+		"unreserved":  "/-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+		"utf8":        "/ሴ",
+		"double-dots": "/a/../b//c",
+	},
+	Queries: map[string]string{
+		"none":        "",
+		"vanilla":     "Param1=value1",
+		"order-key":   "Param2=value2&Param1=value1",
+		"order-value": "Param1=value2&Param1=Value1",
+		"empty-key":   "Param1",
+		"reserved":    "Param1=a%20b%2Fc%3D&%E1%88%B4=%2A",
+	},
+	Headers: map[string]http.Header{
+		"none":          {},
+		"duplicate-key": {"My-Header1": {"value2", "value2", "value1"}},
+		"value-trim":    {"My-Header1": {" value1 "}, "My-Header2": {` "a   b   c" `}},
+		"mixed-case":    {"X-AMZ-meta-Name": {"Value"}},
+	},
+	Bodies: map[string]string{
+		"empty": "",
+		"form":  "Param1=value1",
+	},
+}
+
+// Vector is a signed request in its raw form with every intermediate value
+type Vector struct {
+	Name string `json:"name"`
+	// Request is the raw request the way .req files write it, the body after a blank line
+	Request          string `json:"request"`
+	CanonicalRequest string `json:"canonical_request"`
+	StringToSign     string `json:"string_to_sign"`
+	Signature        string `json:"signature"`
+	Authorization    string `json:"authorization"`
+}
+
+func sortedNames(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GenerateVectors signs every combination of m with s at date, the vectors are
+// named method-path-query-headers-body after the variants
+func GenerateVectors(s *sign4.Signature, date time.Time, m Matrix) ([]Vector, error) {
+	if len(m.Methods) == 0 {
+		m.Methods = DefaultMatrix.Methods
+	}
+	if len(m.Paths) == 0 {
+		m.Paths = DefaultMatrix.Paths
+	}
+	if len(m.Queries) == 0 {
+		m.Queries = DefaultMatrix.Queries
+	}
+	if len(m.Headers) == 0 {
+		m.Headers = DefaultMatrix.Headers
+	}
+	if len(m.Bodies) == 0 {
+		m.Bodies = DefaultMatrix.Bodies
+	}
+	headerNames := make([]string, 0, len(m.Headers))
+	for name := range m.Headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	var vectors []Vector
+	for _, method := range m.Methods {
+		for _, path := range sortedNames(m.Paths) {
+			for _, query := range sortedNames(m.Queries) {
+				for _, header := range headerNames {
+					for _, body := range sortedNames(m.Bodies) {
+						name := strings.Join([]string{strings.ToLower(method), path, query, header, body}, "-")
+						v, err := generateVector(s, date, name, method, m.Paths[path], m.Queries[query], m.Headers[header], m.Bodies[body])
+						if err != nil {
+							return nil, err
+						}
+						vectors = append(vectors, *v)
+					}
+				}
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func generateVector(s *sign4.Signature, date time.Time, name, method, path, query string, header http.Header, body string) (*Vector, error) {
+	target := "https://" + Host + path
+	if query != "" {
+		target += "?" + query
+	}
+	r, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		r.Header[key] = append([]string(nil), values...)
+	}
+	r.Header.Set("X-Amz-Date", date.UTC().Format(sign4.BasicDateFormat))
+	raw := rawRequest(r, body)
+	if err := s.SignRequest(r, nil); err != nil {
+		return nil, err
+	}
+	a, err := s.Artifacts(r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Vector{
+		Name:             name,
+		Request:          raw,
+		CanonicalRequest: a.CanonicalRequest,
+		StringToSign:     a.StringToSign,
+		Signature:        a.Signature,
+		Authorization:    r.Header.Get("Authorization"),
+	}, nil
+}
+
+// rawRequest writes r before signing, Host first and the other headers sorted
+func rawRequest(r *http.Request, body string) string {
+	var b strings.Builder
+	b.WriteString(r.Method + " " + r.URL.RequestURI() + " HTTP/1.1\n")
+	b.WriteString("Host:" + r.Host + "\n")
+	keys := make([]string, 0, len(r.Header))
+	for key := range r.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range r.Header[key] {
+			b.WriteString(key + ":" + value + "\n")
+		}
+	}
+	if body != "" {
+		b.WriteString("\n" + body)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// WriteSuite writes each vector as <dir>/<name>/<name>.req, .creq, .sts and .authz
+func WriteSuite(dir string, vectors []Vector) error {
+	for _, v := range vectors {
+		caseDir := filepath.Join(dir, v.Name)
+		if err := os.MkdirAll(caseDir, 0755); err != nil {
+			return err
+		}
+		files := map[string]string{".req": v.Request, ".creq": v.CanonicalRequest, ".sts": v.StringToSign, ".authz": v.Authorization}
+		for ext, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(caseDir, v.Name+ext), []byte(content), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalVectors returns the vectors as an indented JSON array
+func MarshalVectors(vectors []Vector) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(vectors)
+	return b.Bytes(), err
+}
@@ -0,0 +1,66 @@
+package sign4test_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/sign4test"
+)
+
+func TestGenerateVectors(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Region: "us-east-1", Service: "service"}
+	vectors, err := sign4test.GenerateVectors(s, sign4test.Date, sign4test.Matrix{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 2*5*6*4*2 {
+		t.Fatal("wrong vector count", len(vectors))
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return s.SecretKey, nil }}
+	for _, vector := range vectors {
+		head, body := vector.Request, ""
+		if i := strings.Index(head, "\n\n"); i >= 0 {
+			head, body = head[:i], head[i+2:]
+		}
+		r, err := http.ReadRequest(bufio.NewReader(strings.NewReader(strings.Replace(head, "\n", "\r\n", -1) + "\r\n\r\n")))
+		if err != nil {
+			t.Fatal(vector.Name, err)
+		}
+		r.Body, r.ContentLength = ioutil.NopCloser(strings.NewReader(body)), int64(len(body))
+		r.Header.Set("Authorization", vector.Authorization)
+		if _, err := v.Verify(r); err != nil {
+			t.Fatal(vector.Name, err)
+		}
+	}
+	if vectors[0].Name != "get-double-dots-empty-key-duplicate-key-empty" {
+		t.Fatal("wrong first vector", vectors[0].Name)
+	}
+
+	// the plain GET is the get-vanilla case of the published suite
+	dir := t.TempDir()
+	m := sign4test.Matrix{Methods: []string{"GET"}, Paths: map[string]string{"root": "/"}, Queries: map[string]string{"none": ""},
+		Headers: map[string]http.Header{"none": {}}, Bodies: map[string]string{"empty": ""}}
+	vectors, _ = sign4test.GenerateVectors(s, sign4test.Date, m)
+	if err := sign4test.WriteSuite(dir, vectors); err != nil {
+		t.Fatal(err)
+	}
+	authz, _ := ioutil.ReadFile(filepath.Join(dir, "get-root-none-none-empty", "get-root-none-none-empty.authz"))
+	want, _ := ioutil.ReadFile("../testdata/aws4_testsuite/get-vanilla/get-vanilla.authz")
+	if string(authz) != string(want) {
+		t.Fatalf("wrong authorization\n%s\n%s", authz, want)
+	}
+	b, err := sign4test.MarshalVectors(vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]string
+	if err := json.Unmarshal(b, &decoded); err != nil || decoded[0]["request"] != "GET / HTTP/1.1\nHost:example.amazonaws.com\nX-Amz-Date:20150830T123600Z" {
+		t.Fatal("wrong json", string(b), err)
+	}
+}
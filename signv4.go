@@ -6,7 +6,9 @@ import (
 	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -22,11 +24,21 @@ const (
 )
 
 func hmacsha256(key []byte, data string) ([]byte, error) {
-	h := hmac.New(sha256.New, []byte(key))
-	if _, err := h.Write([]byte(data)); err != nil {
+	h := hmac.New(sha256.New, key)
+	if _, err := io.WriteString(h, data); err != nil {
 		return nil, err
 	}
-	return h.Sum(nil), nil
+	return h.Sum(make([]byte, 0, sha256.Size)), nil
+}
+
+// hexString returns the lower case hex of b as a string with a single allocation
+func hexString(b []byte) string {
+	var buf [2 * sha256.Size]byte
+	if len(b) > sha256.Size {
+		return hex.EncodeToString(b)
+	}
+	n := hex.Encode(buf[:], b)
+	return string(buf[:n])
 }
 
 // Build a CanonicalRequest from a regular request string
@@ -40,12 +52,23 @@ func hmacsha256(key []byte, data string) ([]byte, error) {
 //  SignedHeaders + '\n' +
 //  HexEncode(Hash(RequestPayload))
 func CanonicalRequest(r *http.Request, signedHeaders map[string]bool) (string, error) {
-	data, err := RequestPayload(r)
+	sc := getScratch()
+	defer putScratch(sc)
+	hexencode, err := sc.payloadHash(r, false)
 	if err != nil {
 		return "", err
 	}
-	hexencode, err := HexEncodeSHA256Hash(data)
-	return fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n%s", r.Method, CanonicalURI(r), CanonicalQueryString(r), CanonicalHeaders(r, signedHeaders), SignedHeaders(r, signedHeaders), hexencode), err
+	sc.appendCanonicalRequest(r, &defaultOptions, &escapedPathRules, signedHeaders, hexencode)
+	return string(sc.buf), nil
+}
+
+// CanonicalRequestWithHash returns the canonical request ending in a payload hash the caller
+// already knows, e.g. the X-Amz-Content-Sha256 value or UNSIGNED-PAYLOAD; the body is not read
+func CanonicalRequestWithHash(r *http.Request, signedHeaders map[string]bool, payloadHash string) string {
+	sc := getScratch()
+	defer putScratch(sc)
+	sc.appendCanonicalRequest(r, &defaultOptions, &escapedPathRules, signedHeaders, payloadHash)
+	return string(sc.buf)
 }
 
 // CanonicalURI return request uri
@@ -53,59 +76,85 @@ func CanonicalURI(r *http.Request) string {
 	return r.URL.EscapedPath()
 }
 
-// CanonicalQueryString
+// CanonicalQueryString, empty values are rendered as key=
 func CanonicalQueryString(r *http.Request) string {
-	var a []string
-	for key, value := range r.URL.Query() {
+	return canonicalQueryString(r, false)
+}
+
+func canonicalQueryString(r *http.Request, bareEmptyKeys bool) string {
+	if r.URL.RawQuery == "" {
+		return ""
+	}
+	query := r.URL.Query()
+	n := 0
+	for _, value := range query {
+		n += len(value)
+	}
+	a := make([]string, 0, n)
+	size := 0
+	for key, value := range query {
 		k := url.QueryEscape(key)
 		for _, v := range value {
 			var kv string
-			if v == "" {
+			if v == "" && bareEmptyKeys {
 				kv = k
 			} else {
-				kv = fmt.Sprintf("%s=%s", k, url.QueryEscape(v))
+				kv = k + "=" + url.QueryEscape(v)
 			}
-			a = append(a, strings.Replace(kv, "+", "%20", -1))
+			kv = strings.Replace(kv, "+", "%20", -1)
+			size += len(kv) + 1
+			a = append(a, kv)
 		}
 	}
 	sort.Strings(a)
-	return fmt.Sprintf("%s", strings.Join(a, "&"))
+	var b strings.Builder
+	b.Grow(size)
+	for i, kv := range a {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(kv)
+	}
+	return b.String()
 }
 
-// CanonicalHeaders
-func CanonicalHeaders(r *http.Request, signedHeaders map[string]bool) string {
-	var a []string
-	for key, value := range r.Header {
-		if len(signedHeaders) == 0 || signedHeaders[strings.ToLower(key)] {
+// RawCanonicalQueryString sorts the parameters of a raw query without decoding them,
+// ';', repeated keys and percent-encodings are kept as sent, keys without a value get '='
+func RawCanonicalQueryString(rawQuery string) string {
+	return rawCanonicalQueryString(rawQuery, false)
+}
 
-			sort.Strings(value)
-			var q []string
-			for _, v := range value {
-				q = append(q, trimString(v))
-			}
-			a = append(a, strings.ToLower(key)+":"+strings.Join(q, ","))
+func rawCanonicalQueryString(rawQuery string, bareEmptyKeys bool) string {
+	var a []string
+	for _, kv := range strings.Split(rawQuery, "&") {
+		if kv == "" {
+			continue
 		}
-	}
-	if r.Header.Get("host") == "" || !signedHeaders["host"] {
-		a = append(a, "host:"+r.Host)
+		if !bareEmptyKeys && !strings.Contains(kv, "=") {
+			kv += "="
+		}
+		a = append(a, kv)
 	}
 	sort.Strings(a)
-	return fmt.Sprintf("%s\n", strings.Join(a, "\n"))
+	return strings.Join(a, "&")
+}
+
+// CanonicalHeaders
+func CanonicalHeaders(r *http.Request, signedHeaders map[string]bool) string {
+	sc := getScratch()
+	defer putScratch(sc)
+	withHost := sc.signedKeys(r, signedHeaders)
+	sc.buf = sc.appendCanonicalHeaders(sc.buf[:0], r, &defaultOptions, withHost)
+	return string(sc.buf)
 }
 
 // SignedHeaders
 func SignedHeaders(r *http.Request, signedHeaders map[string]bool) string {
-	var a []string
-	for key := range r.Header {
-		if len(signedHeaders) == 0 || signedHeaders[strings.ToLower(key)] {
-			a = append(a, strings.ToLower(key))
-		}
-	}
-	if r.Header.Get("host") == "" || !signedHeaders["host"] {
-		a = append(a, "host")
-	}
-	sort.Strings(a)
-	return fmt.Sprintf("%s", strings.Join(a, ";"))
+	sc := getScratch()
+	defer putScratch(sc)
+	withHost := sc.signedKeys(r, signedHeaders)
+	sc.buf = sc.appendSignedHeaders(sc.buf[:0], withHost)
+	return string(sc.buf)
 }
 
 // RequestPayload
@@ -120,64 +169,130 @@ func RequestPayload(r *http.Request) ([]byte, error) {
 
 // Return the Credential Scope. See http://docs.aws.amazon.com/general/latest/gr/sigv4-create-string-to-sign.html
 func CredentialScope(t time.Time, regionName, serviceName string) string {
-	return fmt.Sprintf("%s/%s/%s/aws4_request", t.UTC().Format(BasicDateFormatShort), regionName, serviceName)
+	var buf [64]byte
+	return string(appendScope(buf[:0], AWS, t, regionName, serviceName))
 }
 
 // Create a "String to Sign". See http://docs.aws.amazon.com/general/latest/gr/sigv4-create-string-to-sign.html
 func StringToSign(canonicalRequest, credentialScope string, t time.Time) string {
-	hash := sha256.New()
-	hash.Write([]byte(canonicalRequest))
-	return fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%x",
-		t.UTC().Format(BasicDateFormat), credentialScope, hash.Sum(nil))
+	sc := getScratch()
+	defer putScratch(sc)
+	sc.hash.Reset()
+	io.WriteString(sc.hash, canonicalRequest)
+	hex.Encode(sc.hex[:], sc.hash.Sum(sc.sum[:0]))
+	b := append(sc.sts[:0], "AWS4-HMAC-SHA256\n"...)
+	b = t.UTC().AppendFormat(b, BasicDateFormat)
+	b = append(b, '\n')
+	b = append(b, credentialScope...)
+	b = append(b, '\n')
+	sc.sts = append(b, sc.hex[:]...)
+	return string(sc.sts)
 }
 
 // Generate a "signing key" to sign the "String To Sign". See http://docs.aws.amazon.com/general/latest/gr/sigv4-calculate-signature.html
 func GenerateSigningKey(secretKey, regionName, serviceName string, t time.Time) ([]byte, error) {
+	return generateSigningKey(AWS, secretKey, regionName, serviceName, t)
+}
 
-	key := []byte("AWS4" + secretKey)
-	var err error
+func generateSigningKey(p *Profile, secretKey, regionName, serviceName string, t time.Time) ([]byte, error) {
+	// built in a []byte, a concatenated string would leave a copy of the secret that can't be wiped
+	key := make([]byte, 0, len(p.KeyPrefix)+len(secretKey))
+	key = append(append(key, p.KeyPrefix...), secretKey...)
 	dateStamp := t.UTC().Format(BasicDateFormatShort)
-	data := []string{dateStamp, regionName, serviceName, "aws4_request"}
+	data := []string{dateStamp, regionName, serviceName, p.Terminator}
 	for _, d := range data {
-		key, err = hmacsha256(key, d)
+		next, err := hmacsha256(key, d)
+		Zeroize(key)
 		if err != nil {
 			return nil, err
 		}
+		key = next
 	}
 	return key, nil
 }
 
 // Create the AWS Signature Version 4. See http://docs.aws.amazon.com/general/latest/gr/sigv4-calculate-signature.html
 func SignStringToSign(stringToSign string, signingKey []byte) (string, error) {
-	hm, err := hmacsha256(signingKey, stringToSign)
-	return fmt.Sprintf("%x", hm), err
+	h := hmac.New(sha256.New, signingKey)
+	if _, err := io.WriteString(h, stringToSign); err != nil {
+		return "", err
+	}
+	var sum [sha256.Size]byte
+	return hexString(h.Sum(sum[:0])), nil
 }
 
 // HexEncodeSHA256Hash return hexcode of sha256
 func HexEncodeSHA256Hash(body []byte) (string, error) {
-	hash := sha256.New()
-	if body == nil {
-		body = []byte("")
+	if len(body) == 0 {
+		return emptyPayloadHash, nil
 	}
-	_, err := hash.Write(body)
-	return fmt.Sprintf("%x", hash.Sum(nil)), err
+	sum := sha256.Sum256(body)
+	return hexString(sum[:]), nil
 }
 
 // Get the finalized value for the "Authorization" header. The signature parameter is the output from SignStringToSign
 func AuthHeaderValue(signature, accessKey, credentialScope, signedHeaders string) string {
-	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, credentialScope, signedHeaders, signature)
+	return "AWS4-HMAC-SHA256 Credential=" + accessKey + "/" + credentialScope + ", SignedHeaders=" + signedHeaders + ", Signature=" + signature
 }
 
-func trimString(s string) string {
-	var trimedString []byte
-	inQuote := false
+// FoldHeaderValue returns a header value as the strict canonical headers carry it (see
+// Options.StrictHeaderValues): without leading and trailing spaces and with runs of spaces
+// collapsed to one, inside double quotes as well. The lines of a value continued over
+// several lines (obs-fold) are folded each and joined by ',', as the SigV4 suite does
+func FoldHeaderValue(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.IndexByte(s, '\n') >= 0 {
+		lines := strings.Split(s, "\n")
+		for i, line := range lines {
+			lines[i] = FoldHeaderValue(line)
+		}
+		return strings.Join(lines, ",")
+	}
+	if !strings.Contains(s, "  ") {
+		return s
+	}
+	trimedString := make([]byte, 0, len(s))
 	var lastChar byte
+	for i := 0; i < len(s); i++ {
+		v := s[i]
+		if lastChar == ' ' && v == ' ' {
+			continue
+		}
+		trimedString = append(trimedString, v)
+		lastChar = v
+	}
+	return string(trimedString)
+}
+
+// CanonicalHeaderEntry returns the strict canonical headers line of a header, its lower cased
+// name and folded values joined by ',' in the order given, without the trailing newline
+func CanonicalHeaderEntry(name string, values []string) string {
+	b := appendLower(nil, name)
+	b = append(b, ':')
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, FoldHeaderValue(v)...)
+	}
+	return string(b)
+}
+
+// trimQuotedString trims s like FoldHeaderValue but keeps the spaces between double quotes
+func trimQuotedString(s string) string {
 	s = strings.TrimSpace(s)
-	for _, v := range []byte(s) {
-		if byte(v) == byte('"') {
+	if !strings.Contains(s, "  ") {
+		return s
+	}
+	trimedString := make([]byte, 0, len(s))
+	inQuote := false
+	var lastChar byte
+	for i := 0; i < len(s); i++ {
+		v := s[i]
+		if v == '"' {
 			inQuote = !inQuote
 		}
-		if lastChar == byte(' ') && byte(v) == byte(' ') && !inQuote {
+		if lastChar == ' ' && v == ' ' && !inQuote {
 			continue
 		}
 		trimedString = append(trimedString, v)
@@ -186,66 +301,376 @@ func trimString(s string) string {
 	return string(trimedString)
 }
 
+// Options tune how requests are signed
+type Options struct {
+	// UnbufferedPayload hashes the body from r.GetBody, or by seeking back a seekable body,
+	// instead of reading it into memory and replacing r.Body
+	UnbufferedPayload bool
+	// Profile selects a variant of the scheme, nil signs for AWS
+	Profile *Profile
+	// RawQuery canonicalizes URL.RawQuery as sent instead of decoding and re-encoding
+	// its parameters, for backends that compare the query byte for byte
+	RawQuery bool
+	// BareEmptyQueryKeys renders parameters with an empty value as key instead of key=,
+	// as gateways written against earlier versions of this package expect
+	BareEmptyQueryKeys bool
+	// StrictHeaderValues joins the values of a header in request order and collapses spaces
+	// inside double quotes too, as the SigV4 spec does, instead of sorting them and keeping
+	// quoted spaces as signers and verifiers on earlier versions of this package do
+	StrictHeaderValues bool
+	// Now is the clock undated requests are signed with, time.Now when nil
+	Now func() time.Time
+	// ServiceRules overrides the canonicalization rules registered in Services for the service
+	ServiceRules *ServiceRules
+	// GzipPayload compresses the body with GzipRequest before hashing it
+	GzipPayload bool
+	// ChunkedBody is how bodies of unknown length are signed, MaxBufferedBody limits
+	// ChunkedBuffer and defaults to DefaultMaxBufferedBody
+	ChunkedBody     ChunkedPolicy
+	MaxBufferedBody int64
+	// KeyCache shares derived signing keys, the process cache is used when nil
+	KeyCache Cache
+	// ClockOffset is added to the clock, for hosts whose clock is off from the
+	// service's, see SyncClock
+	ClockOffset time.Duration
+	// Logger receives diagnostics of signing and verification, nil logs nothing
+	Logger Logger
+	// RequireTLS refuses to sign http:// and ws:// requests, their signature and session
+	// token would cross the network in the clear; loopback hosts and the host names or
+	// *.domain patterns of TLSExemptHosts are still signed
+	RequireTLS     bool
+	TLSExemptHosts []string
+	// SignContentLength sets and signs Content-Length, signing fails with ErrUnknownLength
+	// for a body of unknown length unless its payload is UNSIGNED-PAYLOAD
+	SignContentLength bool
+}
+
+// now returns the time of the clock of o
+func (o *Options) now() time.Time {
+	if o.Now == nil {
+		return time.Now().Add(o.ClockOffset)
+	}
+	return o.Now().Add(o.ClockOffset)
+}
+
+// defaultOptions are used by the package level canonicalization functions
+var defaultOptions Options
+
+// Option changes signing options
+type Option func(*Options)
+
+// UnbufferedPayload sets Options.UnbufferedPayload
+func UnbufferedPayload(on bool) Option {
+	return func(o *Options) {
+		o.UnbufferedPayload = on
+	}
+}
+
+// RawQuery sets Options.RawQuery
+func RawQuery(on bool) Option {
+	return func(o *Options) {
+		o.RawQuery = on
+	}
+}
+
+// BareEmptyQueryKeys sets Options.BareEmptyQueryKeys
+func BareEmptyQueryKeys(on bool) Option {
+	return func(o *Options) {
+		o.BareEmptyQueryKeys = on
+	}
+}
+
+// StrictHeaderValues sets Options.StrictHeaderValues
+func StrictHeaderValues(on bool) Option {
+	return func(o *Options) {
+		o.StrictHeaderValues = on
+	}
+}
+
+// Deterministic pins Options.Now to t so that signing a request gives the same bytes on every run
+func Deterministic(t time.Time) Option {
+	return func(o *Options) {
+		o.Now = func() time.Time { return t }
+	}
+}
+
+// Credentials are the keys a request is signed with
+type Credentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// Expiry is when temporary credentials stop working, zero when they don't expire
+	Expiry time.Time
+}
+
+// CanExpire reports whether the credentials have an Expiry
+func (c Credentials) CanExpire() bool {
+	return !c.Expiry.IsZero()
+}
+
+// IsExpired reports whether the credentials expired
+func (c Credentials) IsExpired() bool {
+	return c.expiredAt(time.Now())
+}
+
+// ExpiresIn returns the time until the credentials expire, negative once they expired,
+// it is meaningless when CanExpire is false
+func (c Credentials) ExpiresIn() time.Duration {
+	return time.Until(c.Expiry)
+}
+
+func (c Credentials) expiredAt(t time.Time) bool {
+	return c.CanExpire() && !t.Before(c.Expiry)
+}
+
+// ExpiredCredentialsError is returned instead of signing with credentials known to be expired
+type ExpiredCredentialsError struct {
+	AccessKey string
+	Expiry    time.Time
+}
+
+func (e *ExpiredCredentialsError) Error() string {
+	return "credentials of " + e.AccessKey + " expired at " + e.Expiry.UTC().Format(time.RFC3339)
+}
+
+// CredentialsProvider supplies signing keys, e.g. from a source that rotates them
+type CredentialsProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// Signer signs requests, Signature and the signers of other clouds implement it
+type Signer interface {
+	SignRequest(r *http.Request, signedHeaders map[string]bool) error
+}
+
 // Signature AWS meta
 type Signature struct {
 	AccessKey string
 	SecretKey string
-	Region    string
-	Service   string
+	// SessionToken is sent as the token header of the profile, Expiry fails signing once passed
+	SessionToken string
+	Expiry       time.Time
+	Region       string
+	Service      string
+	// Provider supplies the keys instead of AccessKey and SecretKey when set,
+	// a session token it returns is sent as X-Amz-Security-Token
+	Provider CredentialsProvider
+	Options
 }
 
-// SignRequest set Authorization header
-func (s *Signature) SignRequest(r *http.Request, signedHeaders map[string]bool) error {
+// Credentials returns the signing keys of s, a Signature is itself a CredentialsProvider
+func (s *Signature) Credentials() (Credentials, error) {
+	if s.Provider != nil {
+		return s.Provider.Credentials()
+	}
+	return Credentials{AccessKey: s.AccessKey, SecretKey: s.SecretKey, SessionToken: s.SessionToken, Expiry: s.Expiry}, nil
+}
+
+// signingCredentials returns the credentials of s, an *ExpiredCredentialsError when they
+// expired by the clock of s
+func (s *Signature) signingCredentials() (Credentials, error) {
+	creds, err := s.Credentials()
+	if err != nil {
+		return creds, err
+	}
+	if creds.expiredAt(s.now()) {
+		return creds, &ExpiredCredentialsError{AccessKey: creds.AccessKey, Expiry: creds.Expiry}
+	}
+	return creds, nil
+}
+
+// derive returns a copy of s reading its keys from the credential source of s
+func (s *Signature) derive() *Signature {
+	d := &Signature{Region: s.Region, Service: s.Service, Provider: s.Provider, Options: s.Options}
+	if d.Provider == nil {
+		d.Provider = s
+	}
+	return d
+}
+
+// WithService returns a signer for service sharing the credentials of s
+func (s *Signature) WithService(service string) *Signature {
+	d := s.derive()
+	d.Service = service
+	return d
+}
+
+// WithRegion returns a signer for region sharing the credentials of s
+func (s *Signature) WithRegion(region string) *Signature {
+	d := s.derive()
+	d.Region = region
+	return d
+}
+
+// WithOptions returns a signer with opts applied sharing the credentials of s
+func (s *Signature) WithOptions(opts ...Option) *Signature {
+	d := s.derive()
+	for _, opt := range opts {
+		opt(&d.Options)
+	}
+	return d
+}
+
+// requestTime returns the signing time from the date header of p or the date header
+func requestTime(r *http.Request, p *Profile) (time.Time, error) {
 	var t time.Time
 	var err error
 	var dt string
-	if dt = r.Header.Get("x-amz-date"); dt != "" {
+	if dt = headerValue(r.Header, p.DateHeader); dt != "" {
 		t, err = time.Parse(BasicDateFormat, dt)
-	} else if dt = r.Header.Get("date"); dt != "" {
-		t, err = time.Parse(time.RFC1123, dt)
+	} else if dt = headerValue(r.Header, "date"); dt != "" {
+		// the Date header is an HTTP date or, as some SDKs send it, an ISO 8601 basic one
+		if t, err = http.ParseTime(dt); err != nil {
+			t, err = time.Parse(BasicDateFormat, dt)
+		}
 	}
 	if err != nil || dt == "" {
-		r.Header.Del("date")
-		t = time.Now()
-		r.Header.Set("x-amz-date", t.UTC().Format(BasicDateFormat))
+		return t, fmt.Errorf("fail to get date")
 	}
-	canonicalRequest, err := CanonicalRequest(r, signedHeaders)
+	return t, nil
+}
+
+// SignRequest set Authorization header, the signature of a request signed before is replaced
+func (s *Signature) SignRequest(r *http.Request, signedHeaders map[string]bool) error {
+	sc := getScratch()
+	defer putScratch(sc)
+	_, _, err := s.signRequest(sc, r, signedHeaders, nil)
+	return err
+}
+
+// signRequest signs r with sc, leaving the string to sign in sc.sts, and returns the
+// signing credentials and time. Undated requests are dated with batch when set, and its
+// credentials and key are used instead of reading them again
+func (s *Signature) signRequest(sc *scratch, r *http.Request, signedHeaders map[string]bool, batch *batchScope) (Credentials, time.Time, error) {
+	if err := s.checkTLS(r.URL); err != nil {
+		return Credentials{}, time.Time{}, err
+	}
+	var creds Credentials
+	var err error
+	if batch != nil {
+		creds = batch.creds
+	} else if creds, err = s.signingCredentials(); err != nil {
+		return creds, time.Time{}, err
+	}
+	p := s.profile()
+	if IsSigned(r) {
+		Unsign(r)
+		r.Header.Del(p.DateHeader)
+		r.Header.Del(p.TokenHeader)
+	}
+	if creds.SessionToken != "" && r.Header.Get(p.TokenHeader) == "" {
+		r.Header.Set(p.TokenHeader, creds.SessionToken)
+	}
+	t, err := requestTime(r, p)
 	if err != nil {
-		return err
+		r.Header.Del("date")
+		if batch != nil {
+			t = batch.t
+			r.Header.Set(p.DateHeader, batch.date)
+		} else {
+			t = s.now()
+			r.Header.Set(p.DateHeader, t.UTC().Format(BasicDateFormat))
+		}
+	}
+	p.canonicalHost(r)
+	signedHeaders = p.signedHeaders(r, signedHeaders)
+	if IsUpgrade(r) {
+		signedHeaders = upgradeSignedHeaders(r, signedHeaders)
+	}
+	if err := s.prepareChunked(r); err != nil {
+		return creds, t, err
+	}
+	if s.GzipPayload {
+		if err := GzipRequest(r); err != nil {
+			return creds, t, err
+		}
+	}
+	if s.SignContentLength {
+		if signedHeaders, err = signContentLength(r, signedHeaders); err != nil {
+			return creds, t, err
+		}
+	}
+	if s.rules().ContentSHA256 && r.Header.Get("X-Amz-Content-Sha256") == "" {
+		payloadHash, err := sc.payloadHash(r, s.UnbufferedPayload)
+		if err != nil {
+			return creds, t, err
+		}
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	var signature []byte
+	if batch != nil && utcDay(t) == utcDay(batch.t) {
+		signature, err = s.signatureWithKey(sc, r, signedHeaders, t, batch.key)
+	} else {
+		signature, err = s.signature(sc, r, signedHeaders, t, creds.SecretKey)
 	}
-	credentialScope := CredentialScope(t, s.Region, s.Service)
-	stringToSign := StringToSign(canonicalRequest, credentialScope, t)
-	key, err := GenerateSigningKey(s.SecretKey, s.Region, s.Service, t)
 	if err != nil {
-		return err
+		return creds, t, err
+	}
+	withHost := sc.signedKeys(r, signedHeaders)
+	b := append(sc.buf[:0], p.Algorithm...)
+	b = append(b, " Credential="...)
+	b = append(b, creds.AccessKey...)
+	b = append(b, '/')
+	b = appendScope(b, p, t, s.Region, s.Service)
+	b = append(b, ", SignedHeaders="...)
+	b = sc.appendSignedHeaders(b, withHost)
+	b = append(b, ", Signature="...)
+	sc.buf = append(b, signature...)
+	r.Header.Set("Authorization", string(sc.buf))
+	if s.Logger != nil {
+		s.logSigned(r, creds.AccessKey, string(sc.buf))
 	}
-	signature, err := SignStringToSign(stringToSign, key)
+	return creds, t, nil
+}
+
+// signature computes the hex signature of r with secretKey into sc
+func (s *Signature) signature(sc *scratch, r *http.Request, signedHeaders map[string]bool, t time.Time, secretKey string) ([]byte, error) {
+	key, err := s.signingKey(s.profile(), secretKey, t)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	signedHeadersstring := SignedHeaders(r, signedHeaders)
-	authValue := AuthHeaderValue(signature, s.AccessKey, credentialScope, signedHeadersstring)
-	r.Header.Set("Authorization", authValue)
-	return nil
+	return s.signatureWithKey(sc, r, signedHeaders, t, key)
 }
 
-func (s *Signature) GetStringToSign(r *http.Request, signedHeaders map[string]bool) (*string, error) {
-	var t time.Time
-	var err error
-	var dt string
-	if dt = r.Header.Get("x-amz-date"); dt != "" {
-		t, err = time.Parse(BasicDateFormat, dt)
-	} else if dt = r.Header.Get("date"); dt != "" {
-		t, err = time.Parse(time.RFC1123, dt)
+// signatureWithKey computes the hex signature of r with a derived key into sc
+func (s *Signature) signatureWithKey(sc *scratch, r *http.Request, signedHeaders map[string]bool, t time.Time, key *signingKey) ([]byte, error) {
+	payloadHash, err := s.payloadHash(sc, r)
+	if err != nil {
+		return nil, err
 	}
-	if err != nil || dt == "" {
-		return nil, fmt.Errorf("fail to get date")
+	sc.appendCanonicalRequest(r, &s.Options, s.rules(), signedHeaders, payloadHash)
+	sc.appendStringToSign(s.profile(), t, s.Region, s.Service)
+	return sc.sign(key), nil
+}
+
+// StringToSignResult holds the string to sign of a request and the values it is built from
+type StringToSignResult struct {
+	StringToSign     string
+	CanonicalRequest string
+	CredentialScope  string
+	// Time is the signing time, from the date header of the profile or the Date header
+	Time time.Time
+}
+
+// GetStringToSign returns the string to sign of a dated request
+func (s *Signature) GetStringToSign(r *http.Request, signedHeaders map[string]bool) (*StringToSignResult, error) {
+	p := s.profile()
+	t, err := requestTime(r, p)
+	if err != nil {
+		return nil, err
 	}
-	canonicalRequest, err := CanonicalRequest(r, signedHeaders)
+	sc := getScratch()
+	defer putScratch(sc)
+	payloadHash, err := s.payloadHash(sc, r)
 	if err != nil {
 		return nil, err
 	}
-	credentialScope := CredentialScope(t, s.Region, s.Service)
-	stringToSign := StringToSign(canonicalRequest, credentialScope, t)
-	return &stringToSign, nil
+	sc.appendCanonicalRequest(r, &s.Options, s.rules(), signedHeaders, payloadHash)
+	sc.appendStringToSign(p, t, s.Region, s.Service)
+	return &StringToSignResult{
+		StringToSign:     string(sc.sts),
+		CanonicalRequest: string(sc.buf),
+		CredentialScope:  string(appendScope(nil, p, t, s.Region, s.Service)),
+		Time:             t,
+	}, nil
 }
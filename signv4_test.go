@@ -3,9 +3,13 @@ package sign4_test
 import (
 	"github.com/datastream/aws"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -84,6 +88,8 @@ func TestAuthHeader(t *testing.T) {
 		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
 		Region:    "us-east-1",
 		Service:   "host",
+		// the 2011 test suite signs the escaped path once
+		Options: sign4.Options{ServiceRules: &sign4.ServiceRules{SingleEncoding: true}},
 	}
 	r, _ := http.NewRequest("GET", "http://host.foo.com/%20/foo", nil)
 	r.Header.Add("date", "Mon, 09 Sep 2011 23:36:00 GMT")
@@ -115,3 +121,292 @@ func TestPostHeader(t *testing.T) {
 		t.Fatal("wrong body")
 	}
 }
+
+func TestCanonicalQueryAndHeaders(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://host.foo.com/?b=2&a=x+y&empty", nil)
+	r.Header.Add("X-Multi", "b")
+	r.Header.Add("X-Multi", "  a   c  ")
+	v, _ := sign4.CanonicalRequest(r, make(map[string]bool))
+	if v != `GET
+/
+a=x%20y&b=2&empty=
+host:host.foo.com
+x-multi:a c,b
+
+host;x-multi
+e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855` {
+		t.Fatal("wrong canonicalrequest", v)
+	}
+	if r.Header["X-Multi"][0] != "b" {
+		t.Fatal("header values reordered")
+	}
+}
+
+func TestUnbufferedPayload(t *testing.T) {
+	s := sign4.Signature{
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:    "us-east-1",
+		Service:   "host",
+	}
+	buffered, _ := http.NewRequest("POST", "http://host.foo.com/", strings.NewReader("foo=bar"))
+	buffered.Header.Add("date", "Mon, 09 Sep 2011 23:36:00 GMT")
+	s.SignRequest(buffered, nil)
+
+	s.UnbufferedPayload = true
+	r, _ := http.NewRequest("POST", "http://host.foo.com/", strings.NewReader("foo=bar"))
+	r.Header.Add("date", "Mon, 09 Sep 2011 23:36:00 GMT")
+	body := r.Body
+	s.SignRequest(r, nil)
+	if r.Header.Get("authorization") != buffered.Header.Get("authorization") {
+		t.Fatal(r.Header.Get("authorization"), "miss match")
+	}
+	if r.Body != body {
+		t.Fatal("body replaced")
+	}
+
+	f, err := ioutil.TempFile("", "sign4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.WriteString("foo=bar")
+	f.Seek(0, io.SeekStart)
+	r, _ = http.NewRequest("POST", "http://host.foo.com/", f)
+	r.Header.Add("date", "Mon, 09 Sep 2011 23:36:00 GMT")
+	s.SignRequest(r, nil)
+	if r.Header.Get("authorization") != buffered.Header.Get("authorization") {
+		t.Fatal(r.Header.Get("authorization"), "miss match")
+	}
+	b, _ := ioutil.ReadAll(r.Body)
+	if string(b) != "foo=bar" {
+		t.Fatal("file not rewound", string(b))
+	}
+}
+
+func TestHashReaderAt(t *testing.T) {
+	defer func(size, ahead int) { sign4.HashChunkSize, sign4.HashReadAhead = size, ahead }(sign4.HashChunkSize, sign4.HashReadAhead)
+	sign4.HashChunkSize, sign4.HashReadAhead = 7, 3
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	expected, _ := sign4.HexEncodeSHA256Hash(data)
+	hash, err := sign4.HashReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil || hash != expected {
+		t.Fatal("wrong hash", hash, err)
+	}
+	if _, err := sign4.HashReaderAt(bytes.NewReader(data), int64(len(data))+3); err == nil {
+		t.Fatal("short read not reported")
+	}
+
+	f, err := ioutil.TempFile("", "sign4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.Write(data)
+	f.Seek(10, io.SeekStart)
+	r, _ := http.NewRequest("PUT", "http://host.foo.com/", f)
+	v, _ := sign4.CanonicalRequest(r, nil)
+	expected, _ = sign4.HexEncodeSHA256Hash(data[10:])
+	if !strings.HasSuffix(v, expected) {
+		t.Fatal("wrong file payload hash", v)
+	}
+	if r.Body != f {
+		t.Fatal("file body replaced")
+	}
+}
+
+type tokenProvider struct{}
+
+func (tokenProvider) Credentials() (sign4.Credentials, error) {
+	return sign4.Credentials{AccessKey: "AKIDTOKEN", SecretKey: "secret", SessionToken: "token"}, nil
+}
+
+func TestDerivedSignature(t *testing.T) {
+	base := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "es"}
+	s3 := base.WithService("s3").WithRegion("us-west-2").WithOptions(sign4.UnbufferedPayload(true))
+	if s3.AccessKey != "" || s3.SecretKey != "" || s3.Service != "s3" || s3.Region != "us-west-2" || !s3.UnbufferedPayload || base.Service != "es" {
+		t.Fatal("wrong derived signature", s3)
+	}
+	sign := func(s *sign4.Signature) string {
+		r, _ := http.NewRequest("GET", "https://bucket.s3.us-west-2.amazonaws.com/key", nil)
+		r.Header.Set("X-Amz-Date", "20150830T123600Z")
+		if err := s.SignRequest(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		return r.Header.Get("Authorization") + r.Header.Get("X-Amz-Security-Token")
+	}
+	if sign(s3) != sign(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-west-2", Service: "s3"}) {
+		t.Fatal("derived signature signs differently")
+	}
+	// derived signers follow key changes of their source
+	base.SecretKey = "rotated"
+	if sign(s3) != sign(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "rotated", Region: "us-west-2", Service: "s3"}) {
+		t.Fatal("derived signature kept the old key")
+	}
+	p := &sign4.Signature{Provider: tokenProvider{}, Region: "us-east-1", Service: "s3"}
+	if got := sign(p.WithRegion("us-west-2")); !strings.Contains(got, "Credential=AKIDTOKEN/") || !strings.HasSuffix(got, "token") {
+		t.Fatal("provider not used", got)
+	}
+}
+
+func TestRawQuery(t *testing.T) {
+	r, _ := http.NewRequest("GET", "https://api.example.com/?b=%2a&a=1;x&a=0&c", nil)
+	if q := sign4.RawCanonicalQueryString(r.URL.RawQuery); q != "a=0&a=1;x&b=%2a&c=" {
+		t.Fatal("wrong raw query", q)
+	}
+	s := (&sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "execute-api"}).
+		WithOptions(sign4.RawQuery(true))
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	if _, err := v.Verify(r); err != sign4.ErrSignatureMismatch {
+		t.Fatal("decoded query verified", err)
+	}
+	v.Options.RawQuery = true
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStrictHeaderValues(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://host.foo.com/", nil)
+	r.Header.Add("X-Multi", "b")
+	r.Header.Add("X-Multi", `  a   "x  y"  `)
+	r.Header.Set("X-Amz-Date", "20150830T123600Z")
+	signedHeaders := map[string]bool{"x-multi": true}
+	s := &sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	sts, _ := s.GetStringToSign(r, signedHeaders)
+	strict, _ := s.WithOptions(sign4.StrictHeaderValues(true)).GetStringToSign(r, signedHeaders)
+	d, _ := time.Parse(sign4.BasicDateFormat, "20150830T123600Z")
+	creq := func(values string) string {
+		c := "GET\n/\n\nhost:host.foo.com\nx-multi:" + values + "\n\nhost;x-multi\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		return sign4.StringToSign(c, sign4.CredentialScope(d, s.Region, s.Service), d)
+	}
+	if sts.StringToSign != creq(`a "x  y",b`) {
+		t.Fatal("header values not sorted")
+	}
+	if strict.StringToSign != creq(`b,a "x y"`) {
+		t.Fatal("header values not in request order")
+	}
+	if r.Header["X-Multi"][0] != "b" {
+		t.Fatal("header values reordered")
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	d := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	s := (&sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "service"}).
+		WithOptions(sign4.Deterministic(d))
+	sign := func() *http.Request {
+		r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+		if err := s.SignRequest(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	r := sign()
+	if r.Header.Get("X-Amz-Date") != "20150830T123600Z" || r.Header.Get("Authorization") != sign().Header.Get("Authorization") {
+		t.Fatal("signing not deterministic", r.Header)
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }, MaxSkew: time.Minute, Options: s.Options}
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+	v.Options.Now = nil
+	if _, err := v.Verify(r); err != sign4.ErrRequestTimeSkewed {
+		t.Fatal("skew not checked against the clock", err)
+	}
+}
+
+func TestGetStringToSign(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "service"}
+	want := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for _, date := range []string{"Sun, 30 Aug 2015 12:36:00 GMT", "20150830T123600Z"} {
+		r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+		r.Header.Set("Date", date)
+		got, err := s.GetStringToSign(r, nil)
+		if err != nil {
+			t.Fatal(date, err)
+		}
+		if !got.Time.Equal(want) || got.CredentialScope != "20150830/us-east-1/service/aws4_request" ||
+			!strings.HasPrefix(got.CanonicalRequest, "GET\n/\n\ndate:"+date+"\nhost:example.amazonaws.com\n") ||
+			got.StringToSign != sign4.StringToSign(got.CanonicalRequest, got.CredentialScope, got.Time) {
+			t.Fatal("wrong string to sign", date, got)
+		}
+	}
+}
+
+type unreadable struct{}
+
+func (unreadable) Read([]byte) (int, error) { return 0, fmt.Errorf("body read") }
+
+func TestCanonicalRequestWithHash(t *testing.T) {
+	r, _ := http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/key", ioutil.NopCloser(unreadable{}))
+	r.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	got := sign4.CanonicalRequestWithHash(r, nil, "UNSIGNED-PAYLOAD")
+	if got != "PUT\n/key\n\nhost:bucket.s3.amazonaws.com\nx-amz-content-sha256:UNSIGNED-PAYLOAD\n\nhost;x-amz-content-sha256\nUNSIGNED-PAYLOAD" {
+		t.Fatal("wrong canonical request", got)
+	}
+	if _, err := sign4.CanonicalRequest(r, nil); err == nil {
+		t.Fatal("body not read by CanonicalRequest")
+	}
+}
+
+func TestCanonicalHeaderEntry(t *testing.T) {
+	for in, want := range map[string]string{
+		"  value  ":         "value",
+		"a   b \t c":        "a b \t c",
+		`"quoted   string"`: `"quoted string"`,
+		"a\n  b  \r\n\tc":   "a,b,c",
+		"":                  "",
+	} {
+		if got := sign4.FoldHeaderValue(in); got != want {
+			t.Errorf("%q: got %q want %q", in, got, want)
+		}
+	}
+	if got := sign4.CanonicalHeaderEntry("X-Multi", []string{"b", "  a   c  "}); got != "x-multi:b,a c" {
+		t.Fatal("wrong entry", got)
+	}
+	r, _ := http.NewRequest("GET", "http://host.foo.com/", nil)
+	r.Header.Add("X-Multi", "b")
+	r.Header.Add("X-Multi", "  a   c  ")
+	r.Header.Set("X-Amz-Date", "20150830T123600Z")
+	s := &sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "service", Options: sign4.Options{StrictHeaderValues: true}}
+	sts, _ := s.GetStringToSign(r, map[string]bool{"x-multi": true})
+	if !strings.Contains(sts.CanonicalRequest, "\n"+sign4.CanonicalHeaderEntry("X-Multi", r.Header["X-Multi"])+"\n") {
+		t.Fatal("entry differs from the strict signer", sts.CanonicalRequest)
+	}
+}
+
+func TestExpiredCredentials(t *testing.T) {
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", SessionToken: "token", Expiry: now.Add(time.Minute),
+		Region: "us-east-1", Service: "service", Options: sign4.Options{Now: func() time.Time { return now }}}
+	creds, _ := s.Credentials()
+	if !creds.CanExpire() || creds.SessionToken != "token" {
+		t.Fatal("wrong credentials", creds)
+	}
+	if (sign4.Credentials{}).CanExpire() || (sign4.Credentials{}).IsExpired() {
+		t.Fatal("credentials without expiry expire")
+	}
+	if d := (sign4.Credentials{Expiry: time.Now().Add(time.Hour)}).ExpiresIn(); d <= 59*time.Minute || d > time.Hour {
+		t.Fatal("wrong expiry", d)
+	}
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err := s.SignRequest(r, nil); err != nil || r.Header.Get("X-Amz-Security-Token") != "token" {
+		t.Fatal("valid credentials not signed", err, r.Header)
+	}
+	now = now.Add(time.Minute)
+	r, _ = http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	err := s.SignRequest(r, nil)
+	var e *sign4.ExpiredCredentialsError
+	if !errors.As(err, &e) || e.AccessKey != "AKIDEXAMPLE" || r.Header.Get("Authorization") != "" {
+		t.Fatal("expired credentials signed", err)
+	}
+	if _, err := s.WithRegion("eu-west-1").Presign(r, time.Minute, nil); !errors.As(err, &e) {
+		t.Fatal("expired credentials presigned", err)
+	}
+}
@@ -0,0 +1,182 @@
+package sign4
+
+// Signature Version 4A: ECDSA P-256 signatures valid in a set of regions, with the key
+// derived from the secret key or held by a crypto.Signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// V4AAlgorithm starts the string to sign and the Authorization header of SigV4A
+const V4AAlgorithm = "AWS4-ECDSA-P256-SHA256"
+
+// RegionSetHeader carries the regions a SigV4A signature is valid in
+const RegionSetHeader = "X-Amz-Region-Set"
+
+// ErrV4AKey is returned when the public key of SigV4A.Signer isn't an ECDSA P-256 one
+var ErrV4AKey = errors.New("SigV4A signer key is not an ECDSA P-256 key")
+
+// SigV4A signs requests with AWS4-ECDSA-P256-SHA256, valid in every region of RegionSet,
+// e.g. for S3 Multi-Region Access Points
+type SigV4A struct {
+	// Signature supplies the credentials, the service and the options
+	Signature *Signature
+	// RegionSet lists the regions the signature is valid in, "*" for all of them, the
+	// region of Signature when empty
+	RegionSet []string
+	// Signer holds the private key in place of the one derived from the secret key, e.g. a
+	// PKCS #11, KMS or TPM key that is never in memory; the access key still names it
+	Signer crypto.Signer
+	// Rand supplies the ECDSA nonces, crypto/rand when nil
+	Rand io.Reader
+}
+
+// DeriveV4AKey derives the SigV4A key of a key pair: the NIST SP 800-108 HMAC-SHA256
+// counter mode KDF of "AWS4A"+secretKey, with the access key and an external counter as
+// context, taken plus one once it is below n-2 of P-256
+func DeriveV4AKey(accessKey, secretKey string) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	var max [32]byte
+	new(big.Int).Sub(curve.Params().N, big.NewInt(2)).FillBytes(max[:])
+	mac := hmac.New(sha256.New, []byte("AWS4A"+secretKey))
+	var candidate [sha256.Size]byte
+	for counter := 1; counter <= 0xff; counter++ {
+		// one block of the KDF: i, label, 0, context, the 256 bits length
+		mac.Reset()
+		mac.Write([]byte{0, 0, 0, 1})
+		mac.Write([]byte(V4AAlgorithm))
+		mac.Write([]byte{0})
+		mac.Write([]byte(accessKey))
+		mac.Write([]byte{byte(counter), 0, 0, 1, 0})
+		mac.Sum(candidate[:0])
+		if below(candidate[:], max[:]) {
+			d := new(big.Int).SetBytes(candidate[:])
+			Zeroize(candidate[:])
+			d.Add(d, big.NewInt(1))
+			k := &ecdsa.PrivateKey{D: d}
+			k.Curve = curve
+			k.X, k.Y = curve.ScalarBaseMult(d.Bytes())
+			return k, nil
+		}
+	}
+	return nil, errors.New("no SigV4A key derived from the secret key")
+}
+
+// below reports in constant time whether the big-endian a is less than b of its length
+func below(a, b []byte) bool {
+	borrow := 0
+	for i := len(a) - 1; i >= 0; i-- {
+		borrow = (int(a[i]) - int(b[i]) - borrow) >> 8 & 1
+	}
+	return borrow == 1
+}
+
+// key returns Signer after checking its curve, the key derived from creds otherwise
+func (s *SigV4A) key(creds Credentials) (crypto.Signer, error) {
+	if s.Signer == nil {
+		return DeriveV4AKey(creds.AccessKey, creds.SecretKey)
+	}
+	if pub, ok := s.Signer.Public().(*ecdsa.PublicKey); !ok || pub.Curve != elliptic.P256() {
+		return nil, ErrV4AKey
+	}
+	return s.Signer, nil
+}
+
+// SignRequest sets the X-Amz-Region-Set and AWS4-ECDSA-P256-SHA256 Authorization headers,
+// the date and token headers of SignRequest of Signature, and signs the region set too
+func (s *SigV4A) SignRequest(r *http.Request, signedHeaders map[string]bool) error {
+	sig := s.Signature
+	if err := sig.checkTLS(r.URL); err != nil {
+		return err
+	}
+	creds, err := sig.signingCredentials()
+	if err != nil {
+		return err
+	}
+	key, err := s.key(creds)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(headerValue(r.Header, "Authorization"), V4AAlgorithm+" ") {
+		r.Header.Del("Authorization")
+		r.Header.Del(AWS.DateHeader)
+		r.Header.Del(AWS.TokenHeader)
+	}
+	if creds.SessionToken != "" && r.Header.Get(AWS.TokenHeader) == "" {
+		r.Header.Set(AWS.TokenHeader, creds.SessionToken)
+	}
+	t, err := requestTime(r, AWS)
+	if err != nil {
+		r.Header.Del("date")
+		t = sig.now()
+		r.Header.Set(AWS.DateHeader, t.UTC().Format(BasicDateFormat))
+	}
+	regions := s.RegionSet
+	if len(regions) == 0 {
+		regions = []string{sig.Region}
+	}
+	r.Header.Set(RegionSetHeader, strings.Join(regions, ","))
+	if len(signedHeaders) != 0 && !signedHeaders["x-amz-region-set"] {
+		m := map[string]bool{"x-amz-region-set": true}
+		for k, v := range signedHeaders {
+			m[k] = v
+		}
+		signedHeaders = m
+	}
+	AWS.canonicalHost(r)
+	sc := getScratch()
+	defer putScratch(sc)
+	if sig.rules().ContentSHA256 && r.Header.Get("X-Amz-Content-Sha256") == "" {
+		payloadHash, err := sc.payloadHash(r, sig.UnbufferedPayload)
+		if err != nil {
+			return err
+		}
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	payloadHash, err := sig.payloadHash(sc, r)
+	if err != nil {
+		return err
+	}
+	sc.appendCanonicalRequest(r, &sig.Options, sig.rules(), signedHeaders, payloadHash)
+	scope := t.UTC().Format(BasicDateFormatShort) + "/" + sig.Service + "/" + AWS.Terminator
+	digest := sha256.Sum256([]byte(V4AStringToSign(string(sc.buf), scope, t)))
+	random := s.Rand
+	if random == nil {
+		random = rand.Reader
+	}
+	signature, err := key.Sign(random, digest[:], crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	withHost := sc.signedKeys(r, signedHeaders)
+	b := append(sc.buf[:0], V4AAlgorithm...)
+	b = append(b, " Credential="...)
+	b = append(b, creds.AccessKey...)
+	b = append(b, '/')
+	b = append(b, scope...)
+	b = append(b, ", SignedHeaders="...)
+	b = sc.appendSignedHeaders(b, withHost)
+	b = append(b, ", Signature="...)
+	sc.buf = append(b, hex.EncodeToString(signature)...)
+	r.Header.Set("Authorization", string(sc.buf))
+	return nil
+}
+
+// V4AStringToSign returns the SigV4A string to sign of a canonical request, the credential
+// scope has no region
+func V4AStringToSign(canonicalRequest, credentialScope string, t time.Time) string {
+	h := sha256.Sum256([]byte(canonicalRequest))
+	return V4AAlgorithm + "\n" + t.UTC().Format(BasicDateFormat) + "\n" + credentialScope + "\n" + hex.EncodeToString(h[:])
+}
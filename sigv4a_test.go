@@ -0,0 +1,115 @@
+package sign4_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+var _ sign4.Signer = &sign4.SigV4A{}
+
+// keySigner exposes only the public key of its key, as a PKCS #11 or KMS signer does
+type keySigner struct {
+	k *ecdsa.PrivateKey
+}
+
+func (s keySigner) Public() crypto.PublicKey {
+	return &s.k.PublicKey
+}
+
+func (s keySigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.k.Sign(rand, digest, opts)
+}
+
+func TestDeriveV4AKey(t *testing.T) {
+	// key pair of the SigV4A key derivation test of the AWS SDKs
+	k, err := sign4.DeriveV4AKey("AKISORANDOMAASORANDOM", "q+jcrXGc+0zWN6uzclKVhvMmUsIfRPa4rlRandom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, y := fmt.Sprintf("%X", k.X), fmt.Sprintf("%X", k.Y); x != "15D242CEEBF8D8169FD6A8B5A746C41140414C3B07579038DA06AF89190FFFCB" ||
+		y != "515242CEDD82E94799482E4C0514B505AFCCF2C0C98D6A553BF539F424C5EC0" {
+		t.Fatal("wrong derived key", x, y)
+	}
+}
+
+// verifyV4A checks the SigV4A signature of r over signedHeaders with pub
+func verifyV4A(t *testing.T, r *http.Request, signedHeaders map[string]bool, pub *ecdsa.PublicKey) bool {
+	t.Helper()
+	auth := r.Header.Get("Authorization")
+	signature, err := hex.DecodeString(auth[strings.LastIndex(auth, "Signature=")+len("Signature="):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	date, _ := time.Parse(sign4.BasicDateFormat, r.Header.Get("X-Amz-Date"))
+	r = r.Clone(r.Context())
+	r.Header.Del("Authorization")
+	creq := sign4.CanonicalRequestWithHash(r, signedHeaders, r.Header.Get("X-Amz-Content-Sha256"))
+	digest := sha256.Sum256([]byte(sign4.V4AStringToSign(creq, "20110909/s3/aws4_request", date)))
+	return ecdsa.VerifyASN1(pub, digest[:], signature)
+}
+
+func TestSigV4A(t *testing.T) {
+	s := &sign4.SigV4A{
+		Signature: (&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "s3"}).
+			WithOptions(sign4.Deterministic(time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC))),
+		RegionSet: []string{"us-east-1", "us-west-2"},
+	}
+	r, _ := http.NewRequest("GET", "https://mfzwi23gnjvgw.mrap.accesspoint.s3-global.amazonaws.com/key", nil)
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Get("X-Amz-Region-Set") != "us-east-1,us-west-2" {
+		t.Fatal("wrong region set", r.Header.Get("X-Amz-Region-Set"))
+	}
+	want := "AWS4-ECDSA-P256-SHA256 Credential=AKIDEXAMPLE/20110909/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-region-set, Signature="
+	if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, want) {
+		t.Fatal("wrong authorization", auth)
+	}
+	k, _ := sign4.DeriveV4AKey("AKIDEXAMPLE", "secret")
+	if !verifyV4A(t, r, nil, &k.PublicKey) {
+		t.Fatal("signature not verified with the derived key")
+	}
+	// signed again, the signature is replaced
+	if err := s.SignRequest(r, nil); err != nil || len(r.Header.Values("Authorization")) != 1 || !verifyV4A(t, r, nil, &k.PublicKey) {
+		t.Fatal("request not signed again", err)
+	}
+}
+
+func TestSigV4ASigner(t *testing.T) {
+	private, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s := &sign4.SigV4A{
+		Signature: (&sign4.Signature{AccessKey: "AKIDEXAMPLE", Region: "us-east-1", Service: "s3"}).
+			WithOptions(sign4.Deterministic(time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC))),
+		Signer: keySigner{private},
+	}
+	r, _ := http.NewRequest("GET", "https://mfzwi23gnjvgw.mrap.accesspoint.s3-global.amazonaws.com/key", nil)
+	signedHeaders := map[string]bool{"host": true}
+	if err := s.SignRequest(r, signedHeaders); err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Get("X-Amz-Region-Set") != "us-east-1" || !strings.Contains(r.Header.Get("Authorization"), "SignedHeaders=host;x-amz-region-set,") {
+		t.Fatal("region of the signature not signed as the region set", r.Header)
+	}
+	signedHeaders["x-amz-region-set"] = true
+	if !verifyV4A(t, r, signedHeaders, &private.PublicKey) {
+		t.Fatal("signature not verified with the key of the signer")
+	}
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	s.Signer = rsaKey
+	if err := s.SignRequest(r, nil); err != sign4.ErrV4AKey {
+		t.Fatal("rsa key used", err)
+	}
+}
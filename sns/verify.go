@@ -0,0 +1,226 @@
+// Package sns verifies the signature of Amazon SNS HTTP(S) notifications.
+//
+// See https://docs.aws.amazon.com/sns/latest/dg/sns-verify-signature-of-message.html
+package sns
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// Message types
+const (
+	TypeNotification             = "Notification"
+	TypeSubscriptionConfirmation = "SubscriptionConfirmation"
+	TypeUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+)
+
+// Message is the JSON document posted by SNS
+type Message struct {
+	Type             string `json:"Type"`
+	MessageId        string `json:"MessageId"`
+	Token            string `json:"Token,omitempty"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject,omitempty"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	SubscribeURL     string `json:"SubscribeURL,omitempty"`
+	UnsubscribeURL   string `json:"UnsubscribeURL,omitempty"`
+}
+
+// ParseMessage decodes a message body
+func ParseMessage(body []byte) (*Message, error) {
+	m := &Message{}
+	if err := json.Unmarshal(body, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// StringToSign builds the canonical string signed by SNS for the message type
+func (m *Message) StringToSign() (string, error) {
+	var fields [][2]string
+	switch m.Type {
+	case TypeNotification:
+		fields = [][2]string{{"Message", m.Message}, {"MessageId", m.MessageId}}
+		if m.Subject != "" {
+			fields = append(fields, [2]string{"Subject", m.Subject})
+		}
+		fields = append(fields, [][2]string{{"Timestamp", m.Timestamp}, {"TopicArn", m.TopicArn}, {"Type", m.Type}}...)
+	case TypeSubscriptionConfirmation, TypeUnsubscribeConfirmation:
+		fields = [][2]string{
+			{"Message", m.Message}, {"MessageId", m.MessageId}, {"SubscribeURL", m.SubscribeURL},
+			{"Timestamp", m.Timestamp}, {"Token", m.Token}, {"TopicArn", m.TopicArn}, {"Type", m.Type},
+		}
+	default:
+		return "", fmt.Errorf("sns: unknown message type %q", m.Type)
+	}
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString(f[0])
+		b.WriteByte('\n')
+		b.WriteString(f[1])
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// DefaultCertHost matches the hosts SNS serves signing certificates from
+var DefaultCertHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// Verifier checks message signatures, caching certificates by SigningCertURL
+type Verifier struct {
+	HTTPClient *http.Client
+	// CertHost validates the SigningCertURL host, defaults to DefaultCertHost
+	CertHost *regexp.Regexp
+	// Roots, when set, is used to verify the signing certificate chain
+	Roots *x509.CertPool
+
+	mu    sync.Mutex
+	certs map[string]*x509.Certificate
+}
+
+// NewVerifier returns a verifier using http.DefaultClient
+func NewVerifier() *Verifier {
+	return &Verifier{}
+}
+
+// Verify checks the signature of m
+func (v *Verifier) Verify(m *Message) error {
+	var hash crypto.Hash
+	switch m.SignatureVersion {
+	case "1":
+		if sign4.FIPS {
+			return fmt.Errorf("sns: signature version 1: %w", sign4.ErrNotApproved)
+		}
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return fmt.Errorf("sns: unsupported signature version %q", m.SignatureVersion)
+	}
+	stringToSign, err := m.StringToSign()
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return errors.New("sns: malformed signature")
+	}
+	cert, err := v.certificate(m.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("sns: signing certificate is not rsa")
+	}
+	var digest []byte
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum([]byte(stringToSign))
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(stringToSign))
+		digest = sum[:]
+	}
+	if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+		return errors.New("sns: signature mismatch")
+	}
+	return nil
+}
+
+// VerifyRequest parses and verifies the body of an SNS POST
+func (v *Verifier) VerifyRequest(r *http.Request) (*Message, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseMessage(body)
+	if err != nil {
+		return nil, err
+	}
+	if t := r.Header.Get("X-Amz-Sns-Message-Type"); t != "" && t != m.Type {
+		return nil, errors.New("sns: message type header mismatch")
+	}
+	return m, v.Verify(m)
+}
+
+func (v *Verifier) certificate(certURL string) (*x509.Certificate, error) {
+	u, err := url.Parse(certURL)
+	if err != nil || u.Scheme != "https" || !strings.HasSuffix(u.Path, ".pem") {
+		return nil, fmt.Errorf("sns: invalid signing certificate url %q", certURL)
+	}
+	host := v.CertHost
+	if host == nil {
+		host = DefaultCertHost
+	}
+	if !host.MatchString(u.Hostname()) || u.Port() != "" {
+		return nil, fmt.Errorf("sns: untrusted signing certificate host %q", u.Host)
+	}
+	v.mu.Lock()
+	cert, ok := v.certs[certURL]
+	v.mu.Unlock()
+	if ok && time.Now().Before(cert.NotAfter) {
+		return cert, nil
+	}
+	client := v.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sns: fetch signing certificate: %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("sns: signing certificate is not pem")
+	}
+	cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, errors.New("sns: signing certificate expired")
+	}
+	if v.Roots != nil {
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: v.Roots}); err != nil {
+			return nil, err
+		}
+	}
+	v.mu.Lock()
+	if v.certs == nil {
+		v.certs = make(map[string]*x509.Certificate)
+	}
+	v.certs[certURL] = cert
+	v.mu.Unlock()
+	return cert, nil
+}
@@ -0,0 +1,91 @@
+package sns_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/sns"
+)
+
+type certTransport struct {
+	pem     []byte
+	fetches int
+}
+
+func (c *certTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.fetches++
+	return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewReader(c.pem)), Request: r}, nil
+}
+
+func TestVerify(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	ct := &certTransport{pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+	v := sns.NewVerifier()
+	v.HTTPClient = &http.Client{Transport: ct}
+
+	m := &sns.Message{
+		Type:           sns.TypeNotification,
+		MessageId:      "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+		TopicArn:       "arn:aws:sns:us-west-2:123456789012:MyTopic",
+		Subject:        "My First Message",
+		Message:        "Hello world!",
+		Timestamp:      "2012-05-02T00:54:06.655Z",
+		SigningCertURL: "https://sns.us-west-2.amazonaws.com/SimpleNotificationService-f3ecfb7224c7233fe7bb5f59f96de52f.pem",
+	}
+	stringToSign, _ := m.StringToSign()
+	if stringToSign != "Message\nHello world!\nMessageId\n22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324\nSubject\nMy First Message\nTimestamp\n2012-05-02T00:54:06.655Z\nTopicArn\narn:aws:sns:us-west-2:123456789012:MyTopic\nType\nNotification\n" {
+		t.Fatal("wrong string to sign", stringToSign)
+	}
+	for _, version := range []string{"1", "2"} {
+		var sig []byte
+		if version == "1" {
+			sum := sha1.Sum([]byte(stringToSign))
+			sig, _ = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, sum[:])
+		} else {
+			sum := sha256.Sum256([]byte(stringToSign))
+			sig, _ = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		}
+		m.SignatureVersion = version
+		m.Signature = base64.StdEncoding.EncodeToString(sig)
+		if err := v.Verify(m); sign4.FIPS && version == "1" {
+			if !errors.Is(err, sign4.ErrNotApproved) {
+				t.Fatal("SHA1 signature accepted in FIPS mode", err)
+			}
+		} else if err != nil {
+			t.Fatal("signature version", version, err)
+		}
+	}
+	if ct.fetches != 1 {
+		t.Fatal("certificate not cached", ct.fetches)
+	}
+	m.Message = "tampered"
+	if err := v.Verify(m); err == nil {
+		t.Fatal("tampered message verified")
+	}
+	m.SigningCertURL = "https://sns.us-west-2.amazonaws.com.evil.example/cert.pem"
+	if err := v.Verify(m); err == nil {
+		t.Fatal("untrusted certificate host accepted")
+	}
+}
@@ -0,0 +1,74 @@
+package sts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/query"
+)
+
+// Identity is the caller of GetCallerIdentity
+type Identity struct {
+	Account string `xml:"Account"`
+	ARN     string `xml:"Arn"`
+	UserID  string `xml:"UserId"`
+}
+
+// GetCallerIdentity returns the identity the credentials of c belong to
+func (c *Client) GetCallerIdentity(ctx context.Context) (*Identity, error) {
+	var out struct {
+		Identity Identity `xml:"GetCallerIdentityResult"`
+	}
+	if err := c.do(ctx, "GetCallerIdentity", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out.Identity, nil
+}
+
+// CredentialsError is returned by WhoAmI when STS rejects the credentials
+type CredentialsError struct {
+	AccessKey string
+	// Reason explains the error code of Err
+	Reason string
+	Err    *query.Error
+}
+
+func (e *CredentialsError) Error() string {
+	return fmt.Sprintf("sts: credentials %s rejected, %s (%s)", e.AccessKey, e.Reason, e.Err.Code)
+}
+
+func (e *CredentialsError) Unwrap() error {
+	return e.Err
+}
+
+// rejections explain the error codes STS returns for bad credentials
+var rejections = map[string]string{
+	"InvalidClientTokenId":        "the access key does not exist or is inactive",
+	"UnrecognizedClientException": "the access key does not exist or is inactive",
+	"SignatureDoesNotMatch":       "the secret key does not belong to the access key",
+	"ExpiredToken":                "the session token expired",
+	"InvalidToken":                "the session token is malformed or belongs to other keys",
+	"RequestExpired":              "the request time is off, check the clock of the host",
+	"AccessDenied":                "the keys may not call sts:GetCallerIdentity",
+}
+
+// WhoAmI signs and sends GetCallerIdentity with s, to check credentials at startup before
+// real traffic fails
+func WhoAmI(ctx context.Context, s *sign4.Signature) (*Identity, error) {
+	return NewClient(s).WhoAmI(ctx)
+}
+
+// WhoAmI calls GetCallerIdentity, credentials STS rejects return a *CredentialsError naming the cause
+func (c *Client) WhoAmI(ctx context.Context) (*Identity, error) {
+	id, err := c.GetCallerIdentity(ctx)
+	var e *query.Error
+	if errors.As(err, &e) {
+		if reason, ok := rejections[e.Code]; ok {
+			creds, _ := c.Signature.Credentials()
+			return nil, &CredentialsError{AccessKey: creds.AccessKey, Reason: reason, Err: e}
+		}
+	}
+	return id, err
+}
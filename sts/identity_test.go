@@ -0,0 +1,48 @@
+package sts_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/sts"
+)
+
+func TestWhoAmI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != "Action=GetCallerIdentity&Version=2011-06-15" {
+			t.Error("wrong body", string(b))
+		}
+		s, _, _, _ := sign4.GetSignature(r)
+		if s.AccessKey != "AKIDVALID" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>InvalidClientTokenId</Code><Message>The security token included in the request is invalid.</Message></Error></ErrorResponse>`))
+			return
+		}
+		w.Write([]byte(`<GetCallerIdentityResponse><GetCallerIdentityResult>
+<Arn>arn:aws:iam::123456789012:user/deploy</Arn><UserId>AIDAEXAMPLE</UserId><Account>123456789012</Account>
+</GetCallerIdentityResult></GetCallerIdentityResponse>`))
+	}))
+	defer server.Close()
+	c := sts.NewClient(&sign4.Signature{AccessKey: "AKIDVALID", SecretKey: "secret", Region: "us-east-1"})
+	c.Endpoint = server.URL
+	id, err := c.WhoAmI(context.Background())
+	if err != nil || id.Account != "123456789012" || id.ARN != "arn:aws:iam::123456789012:user/deploy" || id.UserID != "AIDAEXAMPLE" {
+		t.Fatal("wrong identity", id, err)
+	}
+	c = sts.NewClient(&sign4.Signature{AccessKey: "AKIDREVOKED", SecretKey: "secret", Region: "us-east-1"})
+	c.Endpoint = server.URL
+	_, err = c.WhoAmI(context.Background())
+	var e *sts.CredentialsError
+	if !errors.As(err, &e) || e.AccessKey != "AKIDREVOKED" || e.Err.StatusCode != 403 {
+		t.Fatal("wrong error", err)
+	}
+	if err.Error() != "sts: credentials AKIDREVOKED rejected, the access key does not exist or is inactive (InvalidClientTokenId)" {
+		t.Fatal("wrong message", err)
+	}
+}
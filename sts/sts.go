@@ -0,0 +1,244 @@
+// Package sts obtains temporary credentials from the AWS Security Token Service.
+//
+// See https://docs.aws.amazon.com/STS/latest/APIReference/welcome.html
+package sts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/endpoints"
+	"github.com/datastream/aws/query"
+)
+
+// ServiceName is the signing name of STS and Version its API version
+const (
+	ServiceName = "sts"
+	Version     = "2011-06-15"
+)
+
+// GlobalEndpoint is the endpoint of STS in us-east-1 serving every region, as a Fallback
+const GlobalEndpoint = "https://sts.amazonaws.com"
+
+// Client calls STS, failed calls return a *query.Error
+type Client struct {
+	// Signature holds the long-term credentials the calls are signed with
+	Signature  *sign4.Signature
+	HTTPClient *http.Client
+	// Endpoint overrides the regional endpoint https://sts.<region>.<partition suffix>
+	Endpoint string
+	// Fallback are endpoints tried in order when a call fails with a network error or a
+	// server error, e.g. GlobalEndpoint or the endpoint of a nearby region. Calls to an AWS
+	// endpoint are signed for its region
+	Fallback []string
+	// Attempts is how many times a call is sent to an endpoint before the next is tried, one when zero
+	Attempts int
+}
+
+// NewClient returns a client signing with s
+func NewClient(s *sign4.Signature) *Client {
+	return &Client{Signature: s}
+}
+
+// Endpoints returns the endpoints calls are sent to in order, Endpoint or the regional
+// endpoint followed by Fallback
+func (c *Client) Endpoints() []string {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		suffix := "amazonaws.com"
+		if p, ok := endpoints.PartitionForRegion(c.Signature.Region); ok {
+			suffix = p.DNSSuffix
+		}
+		endpoint = "https://sts." + c.Signature.Region + "." + suffix
+	}
+	return append([]string{endpoint}, c.Fallback...)
+}
+
+func (c *Client) do(ctx context.Context, action string, params url.Values, out interface{}) error {
+	attempts := c.Attempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	var err error
+	for _, endpoint := range c.Endpoints() {
+		q := query.NewClient(c.signer(endpoint), ServiceName, Version)
+		q.HTTPClient = c.HTTPClient
+		q.Endpoint = endpoint
+		for i := 0; i < attempts; i++ {
+			if err = q.Do(ctx, action, params, out); err == nil || !retryable(err) || ctx.Err() != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// signer returns the signature of calls to endpoint, for the region of an AWS endpoint
+func (c *Client) signer(endpoint string) *sign4.Signature {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return c.Signature
+	}
+	_, region, err := endpoints.Infer(u.Host)
+	if err != nil || region == c.Signature.Region {
+		return c.Signature
+	}
+	return c.Signature.WithRegion(region)
+}
+
+// retryable reports whether err is a network or a server error another endpoint may not have
+func retryable(err error) bool {
+	var e *query.Error
+	if errors.As(err, &e) {
+		return e.StatusCode >= 500
+	}
+	var ue *url.Error
+	return errors.As(err, &ue)
+}
+
+// credentials is the Credentials element of STS results
+type credentials struct {
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+func (c credentials) value() (sign4.Credentials, error) {
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" || c.SessionToken == "" {
+		return sign4.Credentials{}, errors.New("sts: missing credentials in response")
+	}
+	return sign4.Credentials{AccessKey: c.AccessKeyID, SecretKey: c.SecretAccessKey, SessionToken: c.SessionToken, Expiry: c.Expiration}, nil
+}
+
+// Statement is a statement of a session policy, Effect defaults to Allow
+type Statement struct {
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource []string `json:"Resource"`
+}
+
+// Policy returns the JSON policy document of statements, for FederationTokenInput.Policy
+func Policy(statements ...Statement) string {
+	doc := struct {
+		Version   string      `json:"Version"`
+		Statement []Statement `json:"Statement"`
+	}{"2012-10-17", make([]Statement, len(statements))}
+	for i, s := range statements {
+		if s.Effect == "" {
+			s.Effect = "Allow"
+		}
+		doc.Statement[i] = s
+	}
+	b, _ := json.Marshal(doc)
+	return string(b)
+}
+
+// FederationTokenInput are the parameters of GetFederationToken. The permissions of the
+// token are those of the caller intersected with Policy and PolicyARNs, so a broad key
+// hands out credentials scoped down to the resources a worker needs
+type FederationTokenInput struct {
+	// Name of the federated user, 2 to 32 characters
+	Name string
+	// Policy is a JSON session policy, see the Policy function
+	Policy string
+	// PolicyARNs are managed policies used as session policies
+	PolicyARNs []string
+	// Duration of the token, 900 seconds to 36 hours, the STS default of 12 hours when zero
+	Duration time.Duration
+	// Tags are session tags passed to the federated user
+	Tags map[string]string
+}
+
+func (in *FederationTokenInput) params() url.Values {
+	params := url.Values{"Name": {in.Name}}
+	if in.Policy != "" {
+		params.Set("Policy", in.Policy)
+	}
+	for i, arn := range in.PolicyARNs {
+		params.Set("PolicyArns.member."+strconv.Itoa(i+1)+".arn", arn)
+	}
+	if in.Duration > 0 {
+		params.Set("DurationSeconds", strconv.FormatInt(int64(in.Duration/time.Second), 10))
+	}
+	keys := make([]string, 0, len(in.Tags))
+	for k := range in.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		n := "Tags.member." + strconv.Itoa(i+1)
+		params.Set(n+".Key", k)
+		params.Set(n+".Value", in.Tags[k])
+	}
+	return params
+}
+
+// FederatedUser identifies the user of a federation token
+type FederatedUser struct {
+	ID  string `xml:"FederatedUserId"`
+	ARN string `xml:"Arn"`
+}
+
+// GetFederationToken returns temporary credentials of a federated user
+func (c *Client) GetFederationToken(ctx context.Context, in FederationTokenInput) (sign4.Credentials, *FederatedUser, error) {
+	if in.Name == "" {
+		return sign4.Credentials{}, nil, errors.New("sts: missing federated user name")
+	}
+	var out struct {
+		Credentials   credentials   `xml:"GetFederationTokenResult>Credentials"`
+		FederatedUser FederatedUser `xml:"GetFederationTokenResult>FederatedUser"`
+	}
+	if err := c.do(ctx, "GetFederationToken", in.params(), &out); err != nil {
+		return sign4.Credentials{}, nil, err
+	}
+	creds, err := out.Credentials.value()
+	if err != nil {
+		return creds, nil, err
+	}
+	return creds, &out.FederatedUser, nil
+}
+
+// FederationProvider is a sign4.CredentialsProvider of federation tokens, the token is
+// cached and a new one requested when it expires within Refresh
+type FederationProvider struct {
+	Client *Client
+	Input  FederationTokenInput
+	// Refresh is how long before its expiry a token is replaced, one minute when zero
+	Refresh time.Duration
+
+	mu    sync.Mutex
+	creds sign4.Credentials
+}
+
+// NewFederationProvider returns a provider of tokens of in requested with c
+func NewFederationProvider(c *Client, in FederationTokenInput) *FederationProvider {
+	return &FederationProvider{Client: c, Input: in}
+}
+
+// Credentials returns the cached token, requesting a new one when it is due
+func (p *FederationProvider) Credentials() (sign4.Credentials, error) {
+	refresh := p.Refresh
+	if refresh == 0 {
+		refresh = time.Minute
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.creds.AccessKey != "" && time.Now().Add(refresh).Before(p.creds.Expiry) {
+		return p.creds, nil
+	}
+	creds, _, err := p.Client.GetFederationToken(context.Background(), p.Input)
+	if err != nil {
+		return sign4.Credentials{}, err
+	}
+	p.creds = creds
+	return creds, nil
+}
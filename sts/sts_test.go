@@ -0,0 +1,139 @@
+package sts_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/query"
+	"github.com/datastream/aws/sts"
+)
+
+func TestPolicy(t *testing.T) {
+	policy := sts.Policy(sts.Statement{Action: []string{"s3:GetObject"}, Resource: []string{"arn:aws:s3:::bucket/worker-1/*"}})
+	if policy != `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::bucket/worker-1/*"]}]}` {
+		t.Fatal("wrong policy", policy)
+	}
+}
+
+func TestFederationProvider(t *testing.T) {
+	calls := 0
+	expiration := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := ioutil.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(b))
+		if form.Get("Action") != "GetFederationToken" || form.Get("Name") != "worker-1" || form.Get("DurationSeconds") != "3600" ||
+			form.Get("PolicyArns.member.1.arn") != "arn:aws:iam::aws:policy/ReadOnlyAccess" || form.Get("Tags.member.1.Key") != "job" ||
+			!strings.Contains(form.Get("Policy"), "worker-1/*") {
+			t.Error("wrong request", form)
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=AKIDLONGTERM/") || !strings.Contains(r.Header.Get("Authorization"), "/sts/aws4_request") {
+			t.Error("wrong signature", r.Header.Get("Authorization"))
+		}
+		w.Write([]byte(`<GetFederationTokenResponse><GetFederationTokenResult>
+<Credentials><AccessKeyId>ASIAFEDERATED</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken><Expiration>` + expiration + `</Expiration></Credentials>
+<FederatedUser><FederatedUserId>123456789012:worker-1</FederatedUserId><Arn>arn:aws:sts::123456789012:federated-user/worker-1</Arn></FederatedUser>
+</GetFederationTokenResult></GetFederationTokenResponse>`))
+	}))
+	defer server.Close()
+	c := sts.NewClient(&sign4.Signature{AccessKey: "AKIDLONGTERM", SecretKey: "secret", Region: "us-east-1"})
+	c.Endpoint = server.URL
+	in := sts.FederationTokenInput{
+		Name:       "worker-1",
+		Policy:     sts.Policy(sts.Statement{Action: []string{"s3:GetObject"}, Resource: []string{"arn:aws:s3:::bucket/worker-1/*"}}),
+		PolicyARNs: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+		Duration:   time.Hour,
+		Tags:       map[string]string{"job": "export"},
+	}
+	_, user, err := c.GetFederationToken(context.Background(), in)
+	if err != nil || user.ARN != "arn:aws:sts::123456789012:federated-user/worker-1" {
+		t.Fatal("wrong federated user", user, err)
+	}
+	p := sts.NewFederationProvider(c, in)
+	worker := &sign4.Signature{Region: "us-east-1", Service: "s3", Provider: p}
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/worker-1/input", nil)
+		if err := worker.SignRequest(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		if r.Header.Get("X-Amz-Security-Token") != "token" || !strings.Contains(r.Header.Get("Authorization"), "Credential=ASIAFEDERATED/") {
+			t.Fatal("not signed with the federation token", r.Header)
+		}
+	}
+	if calls != 2 {
+		t.Fatal("token not cached", calls)
+	}
+	if _, _, err := c.GetFederationToken(context.Background(), sts.FederationTokenInput{}); err == nil {
+		t.Fatal("missing name accepted")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestEndpoints(t *testing.T) {
+	for region, want := range map[string]string{
+		"eu-west-1":     "https://sts.eu-west-1.amazonaws.com",
+		"cn-north-1":    "https://sts.cn-north-1.amazonaws.com.cn",
+		"us-gov-west-1": "https://sts.us-gov-west-1.amazonaws.com",
+	} {
+		c := sts.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: region})
+		if e := c.Endpoints(); len(e) != 1 || e[0] != want {
+			t.Fatal("wrong endpoints", region, e)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	var hosts []string
+	c := sts.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "eu-west-1"})
+	c.Fallback = []string{"https://sts.eu-central-1.amazonaws.com", sts.GlobalEndpoint}
+	c.Attempts = 2
+	c.HTTPClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		hosts = append(hosts, r.URL.Host)
+		auth := r.Header.Get("Authorization")
+		switch r.URL.Host {
+		case "sts.eu-west-1.amazonaws.com":
+			return nil, errors.New("connection refused")
+		case "sts.eu-central-1.amazonaws.com":
+			if !strings.Contains(auth, "/eu-central-1/sts/") {
+				t.Error("wrong scope", auth)
+			}
+			return &http.Response{StatusCode: 503, Body: ioutil.NopCloser(strings.NewReader(`<ErrorResponse><Error><Code>ServiceUnavailable</Code></Error></ErrorResponse>`))}, nil
+		}
+		if !strings.Contains(auth, "/us-east-1/sts/") {
+			t.Error("wrong scope", auth)
+		}
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(`<GetFederationTokenResponse><GetFederationTokenResult>
+<Credentials><AccessKeyId>ASIAGLOBAL</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken><Expiration>2030-01-01T00:00:00Z</Expiration></Credentials>
+</GetFederationTokenResult></GetFederationTokenResponse>`))}, nil
+	})}
+	creds, _, err := c.GetFederationToken(context.Background(), sts.FederationTokenInput{Name: "worker"})
+	if err != nil || creds.AccessKey != "ASIAGLOBAL" {
+		t.Fatal("fallback failed", creds, err)
+	}
+	if strings.Join(hosts, ",") != "sts.eu-west-1.amazonaws.com,sts.eu-west-1.amazonaws.com,sts.eu-central-1.amazonaws.com,sts.eu-central-1.amazonaws.com,sts.amazonaws.com" {
+		t.Fatal("wrong endpoint order", hosts)
+	}
+
+	hosts = nil
+	c.HTTPClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		hosts = append(hosts, r.URL.Host)
+		return &http.Response{StatusCode: 403, Body: ioutil.NopCloser(strings.NewReader(`<ErrorResponse><Error><Code>AccessDenied</Code></Error></ErrorResponse>`))}, nil
+	})}
+	var e *query.Error
+	if _, _, err := c.GetFederationToken(context.Background(), sts.FederationTokenInput{Name: "worker"}); !errors.As(err, &e) || len(hosts) != 1 {
+		t.Fatal("client error retried", err, hosts)
+	}
+}
@@ -0,0 +1,203 @@
+package sign4_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// suiteDir holds cases in the aws-sig-v4-test-suite layout, one directory per case
+// with <name>.req, <name>.creq, <name>.sts and <name>.authz files
+const suiteDir = "testdata/aws4_testsuite"
+
+// the request lines of the suite are unescaped, the escaping net/url applies to them is
+// the single encoding the suite expects
+var suiteSignature = sign4.Signature{
+	AccessKey: "AKIDEXAMPLE",
+	SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	Region:    "us-east-1",
+	Service:   "service",
+	Options:   sign4.Options{ServiceRules: &sign4.ServiceRules{SingleEncoding: true}},
+}
+
+// parseSuiteRequest reads a .req file, the request line keeps the raw path
+// so it can't go through http.ReadRequest
+func parseSuiteRequest(b []byte) (*http.Request, map[string]bool, error) {
+	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+	head, body := b, []byte(nil)
+	if i := bytes.Index(b, []byte("\n\n")); i >= 0 {
+		head, body = b[:i], b[i+2:]
+	}
+	s := bufio.NewScanner(bytes.NewReader(head))
+	s.Scan()
+	line := s.Text()
+	method := line[:strings.Index(line, " ")]
+	target := strings.TrimSuffix(line[len(method)+1:], " HTTP/1.1")
+	u := &url.URL{Scheme: "https", Path: target}
+	if i := strings.Index(target, "?"); i >= 0 {
+		u.Path, u.RawQuery = target[:i], target[i+1:]
+	}
+	var r *http.Request
+	var err error
+	if len(body) > 0 {
+		r, err = http.NewRequest(method, u.String(), bytes.NewReader(body))
+	} else {
+		r, err = http.NewRequest(method, u.String(), nil)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	r.URL = u
+	signedHeaders := map[string]bool{}
+	var last string
+	for s.Scan() {
+		line := s.Text()
+		if last != "" && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			// continuation lines stay in the value, the signer folds them
+			values := r.Header[last]
+			values[len(values)-1] += "\n" + line
+			continue
+		}
+		i := strings.Index(line, ":")
+		key, value := http.CanonicalHeaderKey(line[:i]), line[i+1:]
+		if key == "Host" {
+			r.Host = value
+		}
+		r.Header.Add(key, value)
+		signedHeaders[strings.ToLower(key)] = true
+		last = key
+	}
+	return r, signedHeaders, s.Err()
+}
+
+// suiteModes are the options the suite runs with and the cases each is known to fail
+// and why; a gap is run and skipped so that a fix shows up as an unexpected pass
+var suiteModes = []struct {
+	name    string
+	options []sign4.Option
+	gaps    map[string]string
+}{
+	{"strict", []sign4.Option{sign4.StrictHeaderValues(true)}, nil},
+	{"default", nil, map[string]string{
+		"get-header-key-duplicate":   "values are sorted",
+		"get-header-value-order":     "values are sorted",
+		"get-header-value-multiline": "continuation lines are kept",
+		"get-header-value-trim":      "spaces inside quotes are kept",
+	}},
+	{"s3", []sign4.Option{sign4.StrictHeaderValues(true), sign4.UseServiceRules(sign4.ServiceRules{SingleEncoding: true, NoPathNormalization: true})}, map[string]string{
+		"normalize-path/get-relative":            "s3 keys are signed as sent",
+		"normalize-path/get-relative-relative":   "s3 keys are signed as sent",
+		"normalize-path/get-slash":               "s3 keys are signed as sent",
+		"normalize-path/get-slash-dot-slash":     "s3 keys are signed as sent",
+		"normalize-path/get-slash-pointless-dot": "s3 keys are signed as sent",
+		"normalize-path/get-slashes":             "s3 keys are signed as sent",
+	}},
+}
+
+// suiteCases returns the directories of the cases under suiteDir, the published
+// suite groups some of them in subdirectories such as normalize-path
+func suiteCases() ([]string, error) {
+	var dirs []string
+	err := filepath.Walk(suiteDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && filepath.Ext(path) == ".req" {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return err
+	})
+	return dirs, err
+}
+
+// readSuiteFile reads the file with extension ext of the case in dir
+func readSuiteFile(t *testing.T, dir, ext string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, filepath.Base(dir)+ext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1))
+}
+
+// runSuiteCase returns the first step of the case in dir whose output differs from the suite
+func runSuiteCase(t *testing.T, s *sign4.Signature, dir string) error {
+	r, signedHeaders, err := parseSuiteRequest([]byte(readSuiteFile(t, dir, ".req")))
+	if err != nil {
+		return err
+	}
+	sts, err := s.GetStringToSign(r, signedHeaders)
+	if err != nil {
+		return err
+	}
+	if want := readSuiteFile(t, dir, ".creq"); sts.CanonicalRequest != want {
+		return fmt.Errorf("canonical request\n%s\nwant\n%s", sts.CanonicalRequest, want)
+	}
+	if want := readSuiteFile(t, dir, ".sts"); sts.StringToSign != want {
+		return fmt.Errorf("string to sign\n%s\nwant\n%s", sts.StringToSign, want)
+	}
+	if err := s.SignRequest(r, signedHeaders); err != nil {
+		return err
+	}
+	if authz, want := r.Header.Get("Authorization"), readSuiteFile(t, dir, ".authz"); authz != want {
+		return fmt.Errorf("authorization\n%s\nwant\n%s", authz, want)
+	}
+	return nil
+}
+
+func TestSuite(t *testing.T) {
+	dirs, err := suiteCases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range suiteModes {
+		mode := mode
+		s := suiteSignature.WithOptions(mode.options...)
+		for _, dir := range dirs {
+			dir := dir
+			name, _ := filepath.Rel(suiteDir, dir)
+			name = filepath.ToSlash(name)
+			t.Run(mode.name+"/"+name, func(t *testing.T) {
+				err := runSuiteCase(t, s, dir)
+				if gap, ok := mode.gaps[name]; ok {
+					if err == nil {
+						t.Fatal("known gap passes, remove it from suiteModes:", gap)
+					}
+					t.Skip("known gap, "+gap+":", err)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+}
+
+func TestSuiteEmptyQueryValue(t *testing.T) {
+	req := strings.Replace(readSuiteFile(t, filepath.Join(suiteDir, "get-vanilla-empty-query-key"), ".req"), "Param1=value1", "Param1", 1)
+	want := strings.Replace(readSuiteFile(t, filepath.Join(suiteDir, "get-vanilla-empty-query-key"), ".creq"), "Param1=value1", "Param1=", 1)
+	for _, raw := range []bool{false, true} {
+		r, signedHeaders, err := parseSuiteRequest([]byte(req))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := suiteSignature
+		s.RawQuery = raw
+		sts, _ := s.GetStringToSign(r, signedHeaders)
+		d, _ := time.Parse(sign4.BasicDateFormat, r.Header.Get("X-Amz-Date"))
+		if sts.StringToSign != sign4.StringToSign(want, sign4.CredentialScope(d, s.Region, s.Service), d) {
+			t.Fatal("empty value not rendered as key=, raw query", raw)
+		}
+		s.BareEmptyQueryKeys = true
+		bare := strings.Replace(want, "Param1=", "Param1", 1)
+		if sts, _ = s.GetStringToSign(r, signedHeaders); sts.StringToSign != sign4.StringToSign(bare, sign4.CredentialScope(d, s.Region, s.Service), d) {
+			t.Fatal("empty value not rendered bare, raw query", raw)
+		}
+	}
+}
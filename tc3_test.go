@@ -0,0 +1,54 @@
+package sign4_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+var _ sign4.Signer = &sign4.TC3{}
+
+func TestTC3(t *testing.T) {
+	// example of the Tencent Cloud API 3.0 signature documentation
+	body := `{"Limit": 1, "Filters": [{"Values": ["\u672a\u547d\u540d"], "Name": "instance-name"}]}`
+	r, _ := http.NewRequest("POST", "https://cvm.tencentcloudapi.com/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Header.Set("X-TC-Action", "DescribeInstances")
+	r.Header.Set("X-TC-Timestamp", "1551113065")
+	s := &sign4.TC3{SecretID: "AKIDEXAMPLE", SecretKey: "secret", Service: "cvm"}
+	signedHeaders := map[string]bool{"content-type": true, "host": true, "x-tc-action": true}
+	creq, err := s.CanonicalRequest(r, signedHeaders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha256.Sum256([]byte(creq)); hex.EncodeToString(sum[:]) != "7019a55be8395899b900fb5564e4200d984910f34794a27cb3fb7d10ff6a1e84" {
+		t.Fatal("wrong canonical request", creq)
+	}
+	if err := s.SignRequest(r, signedHeaders); err != nil {
+		t.Fatal(err)
+	}
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := mac(mac(mac([]byte("TC3secret"), "2019-02-25"), "cvm"), "tc3_request")
+	sts := "TC3-HMAC-SHA256\n1551113065\n2019-02-25/cvm/tc3_request\n7019a55be8395899b900fb5564e4200d984910f34794a27cb3fb7d10ff6a1e84"
+	want := "TC3-HMAC-SHA256 Credential=AKIDEXAMPLE/2019-02-25/cvm/tc3_request, SignedHeaders=content-type;host;x-tc-action, Signature=" + hex.EncodeToString(mac(key, sts))
+	if got := r.Header.Get("Authorization"); got != want {
+		t.Fatalf("wrong authorization\n%s\n%s", got, want)
+	}
+
+	r, _ = http.NewRequest("GET", "https://cvm.tencentcloudapi.com/?Limit=10&Offset=0", nil)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.SessionToken = "token"
+	s.SignRequest(r, nil)
+	if !strings.Contains(r.Header.Get("Authorization"), "SignedHeaders=content-type;host,") || r.Header.Get("X-TC-Timestamp") == "" || r.Header.Get("X-TC-Token") != "token" {
+		t.Fatal("wrong default signing", r.Header)
+	}
+}
@@ -0,0 +1,196 @@
+// Package timestream is a minimal Amazon Timestream write client signed with sign4.
+package timestream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/jsonrpc"
+)
+
+// ServiceName is the signing name of the Timestream write API
+const ServiceName = "timestream"
+
+// MaxRecordsPerWrite is the WriteRecords limit on records per call
+const MaxRecordsPerWrite = 100
+
+const targetPrefix = "Timestream_20181101"
+
+// Dimension describes a record dimension
+type Dimension struct {
+	Name               string `json:"Name"`
+	Value              string `json:"Value"`
+	DimensionValueType string `json:"DimensionValueType,omitempty"`
+}
+
+// MeasureValue is one measure of a MULTI measure record
+type MeasureValue struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+	Type  string `json:"Type"`
+}
+
+// Record is a Timestream record
+type Record struct {
+	Dimensions       []Dimension    `json:"Dimensions,omitempty"`
+	MeasureName      string         `json:"MeasureName,omitempty"`
+	MeasureValue     string         `json:"MeasureValue,omitempty"`
+	MeasureValueType string         `json:"MeasureValueType,omitempty"`
+	MeasureValues    []MeasureValue `json:"MeasureValues,omitempty"`
+	Time             string         `json:"Time,omitempty"`
+	TimeUnit         string         `json:"TimeUnit,omitempty"`
+	Version          int64          `json:"Version,omitempty"`
+}
+
+// WriteRecordsInput is the WriteRecords request
+type WriteRecordsInput struct {
+	DatabaseName     string   `json:"DatabaseName"`
+	TableName        string   `json:"TableName"`
+	CommonAttributes *Record  `json:"CommonAttributes,omitempty"`
+	Records          []Record `json:"Records"`
+}
+
+// RecordsIngested counts ingested records
+type RecordsIngested struct {
+	Total         int64 `json:"Total"`
+	MemoryStore   int64 `json:"MemoryStore"`
+	MagneticStore int64 `json:"MagneticStore"`
+}
+
+// RejectedRecord explains why a record was rejected
+type RejectedRecord struct {
+	RecordIndex     int    `json:"RecordIndex"`
+	Reason          string `json:"Reason"`
+	ExistingVersion int64  `json:"ExistingVersion"`
+}
+
+// Error is an error returned by the Timestream API
+type Error struct {
+	StatusCode      int
+	Code            string
+	Message         string
+	RejectedRecords []RejectedRecord
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("timestream: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Client writes records to Timestream, discovering the ingestion endpoint with DescribeEndpoints
+type Client struct {
+	Signature  *sign4.Signature
+	HTTPClient *http.Client
+	// DiscoveryEndpoint overrides https://ingest.timestream.<region>.amazonaws.com
+	DiscoveryEndpoint string
+
+	mu       sync.Mutex
+	address  string
+	deadline time.Time
+}
+
+// NewClient returns a client signing with s, the service name is forced to timestream
+func NewClient(s *sign4.Signature) *Client {
+	return &Client{Signature: s}
+}
+
+type endpoint struct {
+	Address              string `json:"Address"`
+	CachePeriodInMinutes int64  `json:"CachePeriodInMinutes"`
+}
+
+// Endpoint returns the cached ingestion endpoint address, calling DescribeEndpoints when it expired
+func (c *Client) Endpoint(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.address != "" && time.Now().Before(c.deadline) {
+		return c.address, nil
+	}
+	discovery := c.DiscoveryEndpoint
+	if discovery == "" {
+		discovery = fmt.Sprintf("https://ingest.timestream.%s.amazonaws.com", c.Signature.Region)
+	}
+	var out struct {
+		Endpoints []endpoint `json:"Endpoints"`
+	}
+	if err := c.call(ctx, discovery, "DescribeEndpoints", struct{}{}, &out); err != nil {
+		return "", err
+	}
+	if len(out.Endpoints) == 0 {
+		return "", errors.New("timestream: no endpoints discovered")
+	}
+	c.address = out.Endpoints[0].Address
+	c.deadline = time.Now().Add(time.Duration(out.Endpoints[0].CachePeriodInMinutes) * time.Minute)
+	return c.address, nil
+}
+
+// WriteRecords writes in.Records, split into calls of MaxRecordsPerWrite records
+func (c *Client) WriteRecords(ctx context.Context, in *WriteRecordsInput) (*RecordsIngested, error) {
+	total := &RecordsIngested{}
+	records := in.Records
+	for offset := 0; offset == 0 || offset < len(records); offset += MaxRecordsPerWrite {
+		end := offset + MaxRecordsPerWrite
+		if end > len(records) {
+			end = len(records)
+		}
+		batch := *in
+		batch.Records = records[offset:end]
+		ingested, err := c.writeBatch(ctx, &batch)
+		if err != nil {
+			var e *Error
+			if errors.As(err, &e) {
+				for i := range e.RejectedRecords {
+					e.RejectedRecords[i].RecordIndex += offset
+				}
+			}
+			return total, err
+		}
+		total.Total += ingested.Total
+		total.MemoryStore += ingested.MemoryStore
+		total.MagneticStore += ingested.MagneticStore
+	}
+	return total, nil
+}
+
+func (c *Client) writeBatch(ctx context.Context, in *WriteRecordsInput) (*RecordsIngested, error) {
+	address, err := c.Endpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		RecordsIngested RecordsIngested `json:"RecordsIngested"`
+	}
+	err = c.call(ctx, "https://"+address, "WriteRecords", in, &out)
+	var e *Error
+	if errors.As(err, &e) && e.StatusCode == http.StatusGone {
+		// endpoint moved, rediscover on the next call
+		c.mu.Lock()
+		c.address = ""
+		c.mu.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out.RecordsIngested, nil
+}
+
+func (c *Client) call(ctx context.Context, endpoint, operation string, in, out interface{}) error {
+	rpc := jsonrpc.NewClient(c.Signature, ServiceName, targetPrefix, "1.0")
+	rpc.HTTPClient = c.HTTPClient
+	rpc.Endpoint = endpoint
+	err := rpc.Do(ctx, operation, in, out)
+	var e *jsonrpc.Error
+	if errors.As(err, &e) {
+		var body struct {
+			RejectedRecords []RejectedRecord `json:"RejectedRecords"`
+		}
+		json.Unmarshal(e.Body, &body)
+		return &Error{StatusCode: e.StatusCode, Code: e.Code, Message: e.Message, RejectedRecords: body.RejectedRecords}
+	}
+	return err
+}
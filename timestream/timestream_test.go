@@ -0,0 +1,67 @@
+package timestream_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/timestream"
+)
+
+func TestWriteRecords(t *testing.T) {
+	var discovered, writes int
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "/us-east-1/timestream/aws4_request") {
+			t.Error("request not signed for timestream", r.Header.Get("Authorization"))
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "Timestream_20181101.DescribeEndpoints":
+			discovered++
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Endpoints": []map[string]interface{}{{"Address": server.Listener.Addr().String(), "CachePeriodInMinutes": 1440}},
+			})
+		case "Timestream_20181101.WriteRecords":
+			writes++
+			var in timestream.WriteRecordsInput
+			json.NewDecoder(r.Body).Decode(&in)
+			if in.DatabaseName != "db" || len(in.Records) > timestream.MaxRecordsPerWrite {
+				t.Error("wrong write request", in.DatabaseName, len(in.Records))
+			}
+			if writes == 3 {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"__type":"com.amazonaws.timestream.v20181101#RejectedRecordsException","message":"rejected","RejectedRecords":[{"RecordIndex":1,"Reason":"bad"}]}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"RecordsIngested": map[string]int{"Total": len(in.Records), "MemoryStore": len(in.Records)},
+			})
+		default:
+			t.Error("unexpected target", r.Header.Get("X-Amz-Target"))
+		}
+	}))
+	defer server.Close()
+	c := timestream.NewClient(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"})
+	c.HTTPClient = server.Client()
+	c.DiscoveryEndpoint = server.URL
+	in := &timestream.WriteRecordsInput{DatabaseName: "db", TableName: "table"}
+	for i := 0; i < 250; i++ {
+		in.Records = append(in.Records, timestream.Record{MeasureName: "cpu", MeasureValue: "1", MeasureValueType: "DOUBLE"})
+	}
+	ingested, err := c.WriteRecords(context.Background(), in)
+	var e *timestream.Error
+	if !errors.As(err, &e) || e.Code != "RejectedRecordsException" {
+		t.Fatal("expected rejected records error", err)
+	}
+	if e.RejectedRecords[0].RecordIndex != 201 {
+		t.Fatal("wrong rejected record index", e.RejectedRecords[0].RecordIndex)
+	}
+	if ingested.Total != 200 || discovered != 1 || writes != 3 {
+		t.Fatal("wrong batching", ingested.Total, discovered, writes)
+	}
+}
@@ -0,0 +1,58 @@
+package sign4
+
+// Refusing to sign requests that would carry their signature in the clear
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrInsecureTransport is returned when Options.RequireTLS refuses a plain http request
+var ErrInsecureTransport = errors.New("refusing to sign a request over plain http")
+
+// RequireTLS sets Options.RequireTLS, exempt hosts are added to Options.TLSExemptHosts
+func RequireTLS(exempt ...string) Option {
+	return func(o *Options) {
+		o.RequireTLS = true
+		o.TLSExemptHosts = append(o.TLSExemptHosts[:len(o.TLSExemptHosts):len(o.TLSExemptHosts)], exempt...)
+	}
+}
+
+// checkTLS applies CheckTLS when o.RequireTLS is set
+func (o *Options) checkTLS(u *url.URL) error {
+	if !o.RequireTLS {
+		return nil
+	}
+	return CheckTLS(u, o.TLSExemptHosts)
+}
+
+// CheckTLS returns ErrInsecureTransport for an http:// or ws:// URL of a host that is
+// neither a loopback host nor matches one of the exempt host names or *.domain patterns
+func CheckTLS(u *url.URL, exempt []string) error {
+	if u == nil {
+		return nil
+	}
+	if scheme := strings.ToLower(u.Scheme); scheme != "http" && scheme != "ws" {
+		return nil
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	for _, pattern := range exempt {
+		pattern = strings.ToLower(pattern)
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(host, pattern[1:]) && len(host) > len(pattern)-1 {
+				return nil
+			}
+		} else if host == pattern {
+			return nil
+		}
+	}
+	return ErrInsecureTransport
+}
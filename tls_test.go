@@ -0,0 +1,44 @@
+package sign4_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+func TestRequireTLS(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "s3"}
+	s = s.WithOptions(sign4.RequireTLS("minio.internal", "*.lab.example"))
+	for url, allowed := range map[string]bool{
+		"https://bucket.s3.amazonaws.com/key": true,
+		"http://bucket.s3.amazonaws.com/key":  false,
+		"http://localhost:9000/bucket/key":    true,
+		"http://127.0.0.1:9000/bucket/key":    true,
+		"http://[::1]:9000/bucket/key":        true,
+		"http://minio.internal:9000/b/k":      true,
+		"http://s3.lab.example/b/k":           true,
+		"http://lab.example/b/k":              false,
+		"ws://api.example.com/prod":           false,
+	} {
+		r, _ := http.NewRequest("GET", url, nil)
+		err := s.SignRequest(r, nil)
+		if (err == nil) != allowed || (!allowed && err != sign4.ErrInsecureTransport) {
+			t.Fatal("wrong TLS check of", url, err)
+		}
+		if !allowed && r.Header.Get("Authorization") != "" {
+			t.Fatal("refused request signed", url)
+		}
+		r, _ = http.NewRequest("GET", url, nil)
+		if _, err := s.Presign(r, time.Hour, nil); (err == nil) != allowed {
+			t.Fatal("wrong TLS check of presigned", url, err)
+		}
+	}
+	if _, err := s.PresignBatch("GET", []string{"https://b.s3.amazonaws.com/1", "http://b.s3.amazonaws.com/2"}, time.Hour); err != sign4.ErrInsecureTransport {
+		t.Fatal("batch presigned a plain http URL", err)
+	}
+	if _, err := s.PresignWebSocketConnect("http://abc.execute-api.us-east-1.amazonaws.com", "prod", nil, time.Minute); err != sign4.ErrInsecureTransport {
+		t.Fatal("ws:// URL presigned", err)
+	}
+}
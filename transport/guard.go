@@ -0,0 +1,39 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/datastream/aws"
+)
+
+type signedStateKey struct{}
+
+// SignedState returns the signed state a Transport with Snapshot attached to r, nil when there is none
+func SignedState(r *http.Request) *sign4.SignedState {
+	state, _ := r.Context().Value(signedStateKey{}).(*sign4.SignedState)
+	return state
+}
+
+// Guard fails requests whose signed headers changed since they were signed, it goes last
+// in the Base chain of a Transport with Snapshot so that middleware before it is checked
+type Guard struct {
+	// Base defaults to http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// RoundTrip returns a *sign4.ModifiedError instead of sending a request modified after signing,
+// requests without a signed state are sent as is
+func (g *Guard) RoundTrip(r *http.Request) (*http.Response, error) {
+	if state := SignedState(r); state != nil {
+		if err := state.Check(r); err != nil {
+			if r.Body != nil {
+				r.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	if g.Base != nil {
+		return g.Base.RoundTrip(r)
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
@@ -0,0 +1,154 @@
+package transport
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// maxLimitedKeys bounds the keys a FailureLimiter tracks, expired entries are
+// dropped when it is reached and the oldest unblocked ones when none expired
+const maxLimitedKeys = 10000
+
+// FailureLimiter blocks access keys that fail verification repeatedly
+type FailureLimiter struct {
+	// Failures within Window block the key, 10 when zero
+	Failures int
+	// Window is the period failures are counted over, one minute when zero
+	Window time.Duration
+	// Block is how long a key stays blocked, Window when zero
+	Block time.Duration
+	// Now returns the current time, time.Now when nil
+	Now func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]*failures
+}
+
+type failures struct {
+	count   int
+	start   time.Time
+	blocked time.Time
+}
+
+func (l *FailureLimiter) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+func (l *FailureLimiter) window() time.Duration {
+	if l.Window > 0 {
+		return l.Window
+	}
+	return time.Minute
+}
+
+// Allow reports whether key may be verified, a blocked key gets the time until it is released
+func (l *FailureLimiter) Allow(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f := l.keys[key]
+	if f == nil {
+		return 0, true
+	}
+	if wait := f.blocked.Sub(l.now()); wait > 0 {
+		return wait, false
+	}
+	return 0, true
+}
+
+// Fail records a failed verification of key
+func (l *FailureLimiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if l.keys == nil {
+		l.keys = make(map[string]*failures)
+	}
+	f := l.keys[key]
+	if f == nil || now.Sub(f.start) >= l.window() {
+		if f == nil && len(l.keys) >= maxLimitedKeys && !l.prune(now) {
+			// every tracked key is blocked
+			return
+		}
+		f = &failures{start: now, blocked: blockedUntil(f)}
+		l.keys[key] = f
+	}
+	f.count++
+	limit := l.Failures
+	if limit <= 0 {
+		limit = 10
+	}
+	if f.count >= limit {
+		block := l.Block
+		if block <= 0 {
+			block = l.window()
+		}
+		f.blocked = now.Add(block)
+		f.count = 0
+		f.start = now
+	}
+}
+
+// Succeed forgets the failures of key
+func (l *FailureLimiter) Succeed(key string) {
+	l.mu.Lock()
+	delete(l.keys, key)
+	l.mu.Unlock()
+}
+
+func blockedUntil(f *failures) time.Time {
+	if f == nil {
+		return time.Time{}
+	}
+	return f.blocked
+}
+
+// prune drops the expired entries, the oldest tenth of the ones that aren't blocked when
+// none expired, and reports whether there is room for a new key: blocked keys are never dropped
+func (l *FailureLimiter) prune(now time.Time) bool {
+	var unblocked []string
+	for k, f := range l.keys {
+		switch {
+		case now.Before(f.blocked):
+		case now.Sub(f.start) >= l.window():
+			delete(l.keys, k)
+		default:
+			unblocked = append(unblocked, k)
+		}
+	}
+	if len(l.keys) < maxLimitedKeys {
+		return true
+	}
+	if len(unblocked) == 0 {
+		return false
+	}
+	sort.Slice(unblocked, func(i, j int) bool { return l.keys[unblocked[i]].start.Before(l.keys[unblocked[j]].start) })
+	n := maxLimitedKeys / 10
+	if n > len(unblocked) {
+		n = len(unblocked)
+	}
+	for _, k := range unblocked[:n] {
+		delete(l.keys, k)
+	}
+	return true
+}
+
+// limitKey is the access key of the Authorization header, the client address
+// for requests without a parsable one
+func limitKey(r *http.Request) string {
+	if s, _, _, err := sign4.GetSignature(r); err == nil && s.AccessKey != "" {
+		return s.AccessKey
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,92 @@
+package transport_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/transport"
+)
+
+func TestFailureLimiter(t *testing.T) {
+	now := time.Date(2015, 8, 30, 12, 0, 0, 0, time.UTC)
+	l := &transport.FailureLimiter{Failures: 3, Window: time.Minute, Block: 5 * time.Minute, Now: func() time.Time { return now }}
+	for i := 0; i < 2; i++ {
+		l.Fail("AKID")
+	}
+	now = now.Add(2 * time.Minute)
+	l.Fail("AKID")
+	if _, ok := l.Allow("AKID"); !ok {
+		t.Fatal("failures outside the window blocked the key")
+	}
+	l.Fail("AKID")
+	l.Fail("AKID")
+	if wait, ok := l.Allow("AKID"); ok || wait != 5*time.Minute {
+		t.Fatal("key not blocked", wait, ok)
+	}
+	if _, ok := l.Allow("OTHER"); !ok {
+		t.Fatal("other key blocked")
+	}
+	now = now.Add(5 * time.Minute)
+	if _, ok := l.Allow("AKID"); !ok {
+		t.Fatal("key not released")
+	}
+	l.Fail("AKID")
+	l.Fail("AKID")
+	l.Succeed("AKID")
+	l.Fail("AKID")
+	if _, ok := l.Allow("AKID"); !ok {
+		t.Fatal("success did not reset the failures")
+	}
+}
+
+func TestFailureLimiterFull(t *testing.T) {
+	now := time.Date(2015, 8, 30, 12, 0, 0, 0, time.UTC)
+	l := &transport.FailureLimiter{Failures: 2, Window: time.Minute, Block: 5 * time.Minute, Now: func() time.Time { return now }}
+	l.Fail("AKID")
+	l.Fail("AKID")
+	// more keys failing than are tracked must not release the blocked one
+	for i := 0; i < 20000; i++ {
+		l.Fail(fmt.Sprint("KEY", i))
+	}
+	if _, ok := l.Allow("AKID"); ok {
+		t.Fatal("blocked key dropped when full")
+	}
+	l.Fail("KEY19999")
+	if _, ok := l.Allow("KEY19999"); ok {
+		t.Fatal("newest key not tracked when full")
+	}
+}
+
+func TestProxyLimiter(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	p := transport.NewProxy(target, &sign4.Signature{AccessKey: "AKIDUPSTREAM", SecretKey: "upstream", Region: "us-east-1", Service: "es"})
+	p.Verifier = &sign4.Verifier{SecretKey: func(string) (string, error) { return "client", nil }}
+	p.Limiter = &transport.FailureLimiter{Failures: 2, Block: time.Minute}
+	send := func(secret string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "http://proxy/_search", nil)
+		s := &sign4.Signature{AccessKey: "AKIDCLIENT", SecretKey: secret, Region: "us-east-1", Service: "es"}
+		s.SignRequest(r, nil)
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		return w
+	}
+	if w := send("client"); w.Code != 200 {
+		t.Fatal("valid request rejected", w.Code)
+	}
+	for i := 0; i < 2; i++ {
+		if w := send("wrong"); w.Code != http.StatusForbidden {
+			t.Fatal("wrong status", w.Code)
+		}
+	}
+	w := send("client")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Fatal("key not blocked", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/verify"
+)
+
+// Proxy forwards requests to Target, signing them with Transport
+type Proxy struct {
+	Target    *url.URL
+	Transport *Transport
+	// SessionToken is sent as X-Amz-Security-Token with temporary credentials
+	SessionToken string
+	// Verifier, when set, rejects inbound requests without a valid signature
+	Verifier *verify.Verifier
+	// Limiter, when set, blocks access keys after repeated verification failures
+	Limiter *FailureLimiter
+
+	// proxy is built on the first request, net/http serves them concurrently
+	once  sync.Once
+	proxy *httputil.ReverseProxy
+}
+
+// NewProxy returns a proxy to target signing with s
+func NewProxy(target *url.URL, s *sign4.Signature) *Proxy {
+	return &Proxy{Target: target, Transport: New(s)}
+}
+
+// ServeHTTP verifies the inbound signature when configured, then forwards the re-signed request
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.Verifier != nil && !p.verify(w, r) {
+		return
+	}
+	p.once.Do(func() {
+		p.proxy = &httputil.ReverseProxy{Director: p.direct, Transport: roundTripperFunc(p.roundTrip)}
+	})
+	p.proxy.ServeHTTP(w, r)
+}
+
+// verify writes the error response of requests failing verification or of blocked access keys
+func (p *Proxy) verify(w http.ResponseWriter, r *http.Request) bool {
+	var key string
+	if p.Limiter != nil {
+		key = limitKey(r)
+		if wait, ok := p.Limiter.Allow(key); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+			http.Error(w, "too many failed verifications", http.StatusTooManyRequests)
+			return false
+		}
+	}
+	if _, err := p.Verifier.Verify(r); err != nil {
+		if p.Limiter != nil {
+			p.Limiter.Fail(key)
+		}
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+	if p.Limiter != nil {
+		p.Limiter.Succeed(key)
+	}
+	return true
+}
+
+func (p *Proxy) direct(r *http.Request) {
+	r.URL.Scheme = p.Target.Scheme
+	r.URL.Host = p.Target.Host
+	if p.Target.Path != "" && p.Target.Path != "/" {
+		r.URL.Path = singleJoiningSlash(p.Target.Path, r.URL.Path)
+		r.URL.RawPath = ""
+	}
+	r.Host = p.Target.Host
+	// drop the inbound signature, the transport signs again
+	for _, h := range []string{"Authorization", "X-Amz-Date", "X-Amz-Security-Token", "X-Amz-Content-Sha256", "Date"} {
+		r.Header.Del(h)
+	}
+	if p.SessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", p.SessionToken)
+	}
+}
+
+// roundTrip drops the empty User-Agent set by httputil.ReverseProxy, net/http
+// would not send it and the signature would cover a missing header
+func (p *Proxy) roundTrip(r *http.Request) (*http.Response, error) {
+	if ua, ok := r.Header["User-Agent"]; ok && (len(ua) == 0 || ua[0] == "") {
+		r.Header.Del("User-Agent")
+	}
+	return p.Transport.RoundTrip(r)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func singleJoiningSlash(a, b string) string {
+	switch {
+	case a[len(a)-1] == '/' && len(b) > 0 && b[0] == '/':
+		return a + b[1:]
+	case a[len(a)-1] != '/' && (len(b) == 0 || b[0] != '/'):
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,88 @@
+package transport_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/transport"
+)
+
+func TestProxy(t *testing.T) {
+	upstreamKey := &sign4.Signature{AccessKey: "AKIDUPSTREAM", SecretKey: "upstream", Region: "eu-west-1", Service: "es"}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := &sign4.Verifier{SecretKey: func(string) (string, error) { return upstreamKey.SecretKey, nil }}
+		s, err := v.Verify(r)
+		if err != nil || s.AccessKey != "AKIDUPSTREAM" {
+			t.Error("upstream rejected request", err)
+		}
+		if r.Header.Get("X-Amz-Security-Token") != "token" {
+			t.Error("missing session token")
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL + "/base")
+	p := transport.NewProxy(target, upstreamKey)
+	p.SessionToken = "token"
+	inbound := &sign4.Signature{AccessKey: "AKIDCLIENT", SecretKey: "client", Region: "eu-west-1", Service: "es"}
+	p.Verifier = &sign4.Verifier{SecretKey: func(string) (string, error) { return inbound.SecretKey, nil }}
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	r, _ := http.NewRequest("POST", front.URL+"/_search", strings.NewReader(`{"query":{}}`))
+	inbound.SignRequest(r, nil)
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(b) != "/base/_search" {
+		t.Fatal("wrong response", resp.Status, string(b))
+	}
+
+	// inbound request without User-Agent
+	direct := transport.NewProxy(target, upstreamKey)
+	direct.SessionToken = "token"
+	rec := httptest.NewRecorder()
+	direct.ServeHTTP(rec, httptest.NewRequest("GET", "http://proxy/_search", nil))
+	if rec.Code != 200 {
+		t.Fatal("request without user agent failed", rec.Code)
+	}
+
+	r, _ = http.NewRequest("GET", front.URL+"/_search", nil)
+	resp, err = http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatal("unsigned inbound request forwarded", resp.Status)
+	}
+}
+
+func TestProxyConcurrent(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	p := &transport.Proxy{Target: target, Transport: transport.New(&sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "es"})}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest("GET", "http://proxy/", nil))
+			if rec.Code != 200 {
+				t.Error("request failed", rec.Code)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/datastream/aws"
+)
+
+// Route signs the requests whose host matches Hosts with Signature
+type Route struct {
+	// Hosts are patterns as in Transport.Hosts, a route without hosts matches every request
+	Hosts     []string
+	Signature *sign4.Signature
+	// SignedHeaders limits the signed headers, every header is signed when empty
+	SignedHeaders map[string]bool
+}
+
+// Router signs each request with the first route matching its host, so one client can
+// reach services with different scopes or credentials. Requests matching no route are
+// sent unsigned
+type Router struct {
+	Routes []Route
+	// Base defaults to http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// Handle adds a route signing the requests to hosts with s, routes are tried in the order added
+func (rt *Router) Handle(s *sign4.Signature, hosts ...string) *Router {
+	rt.Routes = append(rt.Routes, Route{Hosts: hosts, Signature: s})
+	return rt
+}
+
+// Client returns an http.Client using rt
+func (rt *Router) Client() *http.Client {
+	return &http.Client{Transport: rt}
+}
+
+// Match returns the route used for host, nil when none matches
+func (rt *Router) Match(host string) *Route {
+	for i := range rt.Routes {
+		route := &rt.Routes[i]
+		if len(route.Hosts) == 0 || MatchHost(route.Hosts, host) {
+			return route
+		}
+	}
+	return nil
+}
+
+// RoundTrip signs a copy of r with the route of its host and sends it
+func (rt *Router) RoundTrip(r *http.Request) (*http.Response, error) {
+	route := rt.Match(r.URL.Host)
+	if route == nil {
+		if rt.Base != nil {
+			return rt.Base.RoundTrip(r)
+		}
+		return http.DefaultTransport.RoundTrip(r)
+	}
+	t := Transport{Signature: route.Signature, SignedHeaders: route.SignedHeaders, Base: rt.Base}
+	return t.RoundTrip(r)
+}
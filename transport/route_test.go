@@ -0,0 +1,38 @@
+package transport_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/transport"
+)
+
+func TestRouter(t *testing.T) {
+	var sent *http.Request
+	rt := &transport.Router{Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		sent = r
+		return &http.Response{StatusCode: 200, Body: http.NoBody, Request: r}, nil
+	})}
+	rt.Handle(&sign4.Signature{AccessKey: "AKIDS3", SecretKey: "secret", Region: "us-east-1", Service: "s3"}, "*.s3.amazonaws.com", "s3.amazonaws.com").
+		Handle(&sign4.Signature{AccessKey: "AKIDES", SecretKey: "secret", Region: "eu-west-1", Service: "es"}, "*.es.amazonaws.com").
+		Handle(&sign4.Signature{AccessKey: "AKIDAPI", SecretKey: "secret", Region: "us-west-2", Service: "internal"}, "api.corp.example")
+	for url, want := range map[string]string{
+		"https://bucket.s3.amazonaws.com/key":                  "AKIDS3/s3",
+		"https://search-logs.eu-west-1.es.amazonaws.com/_bulk": "AKIDES/es",
+		"https://api.corp.example:8443/v1/items":               "AKIDAPI/internal",
+		"https://www.example.com/":                             "",
+	} {
+		r, _ := http.NewRequest("GET", url, nil)
+		if _, err := rt.RoundTrip(r); err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if s, _, _, err := sign4.GetSignature(sent); err == nil {
+			got = s.AccessKey + "/" + s.Service
+		}
+		if got != want {
+			t.Fatal("wrong route for", url, got)
+		}
+	}
+}
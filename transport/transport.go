@@ -0,0 +1,106 @@
+// Package transport provides an http.RoundTripper that signs requests with sign4.
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/datastream/aws"
+)
+
+// Transport signs every request before handing it to Base
+type Transport struct {
+	Signature *sign4.Signature
+	// SignedHeaders limits the signed headers, every header is signed when empty
+	SignedHeaders map[string]bool
+	// Hosts limits signing to requests whose host matches one of the patterns, others are
+	// sent unsigned; a pattern is a host name or *.domain for the names under domain.
+	// Every request is signed when empty
+	Hosts []string
+	// RequireTLS refuses to sign and send plain http requests to hosts other than loopback
+	// hosts and TLSExemptHosts, see sign4.CheckTLS
+	RequireTLS     bool
+	TLSExemptHosts []string
+	// Snapshot attaches the signed state of each request for a Guard in Base to check
+	Snapshot bool
+	// Base defaults to http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// AWSHosts are the patterns of the public AWS endpoints
+var AWSHosts = []string{"*.amazonaws.com", "*.amazonaws.com.cn", "*.api.aws"}
+
+// MatchHost reports whether host, with or without a port, matches one of patterns
+func MatchHost(patterns []string, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(host, pattern[1:]) && len(host) > len(pattern)-1 {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// New returns a transport signing with s
+func New(s *sign4.Signature) *Transport {
+	return &Transport{Signature: s}
+}
+
+// Client returns an http.Client using t
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// RoundTrip signs a copy of r and sends it, r is sent as is when its host isn't in Hosts
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if len(t.Hosts) > 0 && !MatchHost(t.Hosts, r.URL.Host) {
+		return t.base().RoundTrip(r)
+	}
+	if t.RequireTLS {
+		if err := sign4.CheckTLS(r.URL, t.TLSExemptHosts); err != nil {
+			if r.Body != nil {
+				r.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	r2 := r.Clone(r.Context())
+	if r2.Host == "" {
+		r2.Host = r2.URL.Host
+	}
+	if err := t.Signature.SignRequest(r2, t.SignedHeaders); err != nil {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+	if r.Body != nil && r2.Body != r.Body {
+		// the signer buffered the body into r2
+		r.Body.Close()
+	}
+	if t.Snapshot {
+		state, err := sign4.Snapshot(r2)
+		if err != nil {
+			return nil, err
+		}
+		r2 = r2.WithContext(context.WithValue(r2.Context(), signedStateKey{}, state))
+	}
+	return t.base().RoundTrip(r2)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
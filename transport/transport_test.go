@@ -0,0 +1,121 @@
+package transport_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/transport"
+)
+
+func TestTransport(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "eu-west-1", Service: "es"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ss, _, _, err := sign4.GetSignature(r)
+		if err != nil || ss.Service != "es" {
+			t.Error("request not signed", err)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer server.Close()
+	r, _ := http.NewRequest("POST", server.URL+"/_bulk", strings.NewReader("body"))
+	resp, err := transport.New(s).Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if string(b) != "body" {
+		t.Fatal("wrong body", string(b))
+	}
+	if r.Header.Get("Authorization") != "" {
+		t.Fatal("original request modified")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestTransportHosts(t *testing.T) {
+	var sent *http.Request
+	tr := transport.New(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "execute-api"})
+	tr.Hosts = append([]string{"vpce-0abc.execute-api.us-east-1.vpce.internal"}, transport.AWSHosts...)
+	tr.Base = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		sent = r
+		return &http.Response{StatusCode: 200, Body: http.NoBody, Request: r}, nil
+	})
+	for url, signed := range map[string]bool{
+		"https://abc.execute-api.us-east-1.amazonaws.com/prod":        true,
+		"https://VPCE-0abc.execute-api.us-east-1.vpce.internal:443/x": true,
+		"https://amazonaws.com/":                                      false,
+		"https://evilamazonaws.com/":                                  false,
+		"https://api.example.com/hook":                                false,
+	} {
+		r, _ := http.NewRequest("GET", url, nil)
+		if _, err := tr.RoundTrip(r); err != nil {
+			t.Fatal(err)
+		}
+		if (sent.Header.Get("Authorization") != "") != signed {
+			t.Fatal("wrong signing of", url, sent.Header)
+		}
+	}
+}
+
+func TestTransportGuard(t *testing.T) {
+	sent := false
+	tr := transport.New(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "execute-api"})
+	tr.Snapshot = true
+	var userAgent string
+	tr.Base = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		// middleware changing a signed header after signing
+		if userAgent != "" {
+			r.Header.Set("User-Agent", userAgent)
+		}
+		return (&transport.Guard{Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			sent = true
+			return &http.Response{StatusCode: 200, Body: http.NoBody, Request: r}, nil
+		})}).RoundTrip(r)
+	})
+	r, _ := http.NewRequest("GET", "https://abc.execute-api.us-east-1.amazonaws.com/prod", nil)
+	r.Header.Set("User-Agent", "client/1.0")
+	if _, err := tr.RoundTrip(r); err != nil || !sent {
+		t.Fatal("unmodified request not sent", err)
+	}
+	sent = false
+	userAgent = "client/1.0 retry/1"
+	var modified *sign4.ModifiedError
+	if _, err := tr.RoundTrip(r); !errors.As(err, &modified) || sent {
+		t.Fatal("modified request sent", err)
+	}
+}
+
+func TestTransportRequireTLS(t *testing.T) {
+	sent := 0
+	tr := transport.New(&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "execute-api"})
+	tr.RequireTLS = true
+	tr.Base = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		sent++
+		return &http.Response{StatusCode: 200, Body: http.NoBody, Request: r}, nil
+	})
+	r, _ := http.NewRequest("GET", "http://abc.execute-api.us-east-1.amazonaws.com/prod", nil)
+	if _, err := tr.RoundTrip(r); err != sign4.ErrInsecureTransport || sent != 0 {
+		t.Fatal("plain http request sent", err)
+	}
+	for _, url := range []string{"https://abc.execute-api.us-east-1.amazonaws.com/prod", "http://127.0.0.1:8080/prod"} {
+		r, _ := http.NewRequest("GET", url, nil)
+		if _, err := tr.RoundTrip(r); err != nil {
+			t.Fatal(url, err)
+		}
+	}
+	if sent != 2 {
+		t.Fatal("requests not sent", sent)
+	}
+}
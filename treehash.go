@@ -0,0 +1,104 @@
+package sign4
+
+// Glacier tree hashes, the sha256 of each MiB of a payload combined pairwise up to
+// a root, sent next to the linear hash of the payload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+)
+
+// TreeHashChunkSize is the size of the leaves of a tree hash, one MiB
+const TreeHashChunkSize = 1 << 20
+
+// TreeHash computes the linear and the tree sha256 of what is written to it
+type TreeHash struct {
+	linear hash.Hash
+	leaf   hash.Hash
+	n      int
+	leaves [][]byte
+}
+
+// NewTreeHash returns an empty TreeHash
+func NewTreeHash() *TreeHash {
+	return &TreeHash{linear: sha256.New(), leaf: sha256.New()}
+}
+
+// Write hashes p, it never fails
+func (h *TreeHash) Write(p []byte) (int, error) {
+	h.linear.Write(p)
+	written := len(p)
+	for len(p) > 0 {
+		k := TreeHashChunkSize - h.n
+		if k > len(p) {
+			k = len(p)
+		}
+		h.leaf.Write(p[:k])
+		p, h.n = p[k:], h.n+k
+		if h.n == TreeHashChunkSize {
+			h.leaves = append(h.leaves, h.leaf.Sum(nil))
+			h.leaf.Reset()
+			h.n = 0
+		}
+	}
+	return written, nil
+}
+
+// Linear returns the sha256 of the payload
+func (h *TreeHash) Linear() []byte {
+	return h.linear.Sum(nil)
+}
+
+// Tree returns the tree hash of the payload, the sha256 of an empty payload when nothing
+// was written
+func (h *TreeHash) Tree() []byte {
+	leaves := h.leaves
+	if h.n > 0 || len(leaves) == 0 {
+		leaves = append(leaves[:len(leaves):len(leaves)], h.leaf.Sum(nil))
+	}
+	return CombineTreeHashes(leaves...)
+}
+
+// SetHeaders sets X-Amz-Content-Sha256 and X-Amz-Sha256-Tree-Hash, signing with the
+// glacier rules then uses the linear hash without reading the body again
+func (h *TreeHash) SetHeaders(header http.Header) {
+	header.Set("X-Amz-Content-Sha256", hex.EncodeToString(h.Linear()))
+	header.Set("X-Amz-Sha256-Tree-Hash", hex.EncodeToString(h.Tree()))
+}
+
+// CombineTreeHashes returns the tree hash of consecutive hashes, e.g. the tree hashes
+// of the parts of a multipart upload whose part size is a power of two MiB
+func CombineTreeHashes(hashes ...[]byte) []byte {
+	if len(hashes) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+	level := append([][]byte(nil), hashes...)
+	for len(level) > 1 {
+		next := level[:0]
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			h := sha256.New()
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// HashTree returns the hex linear and tree hashes of r
+func HashTree(r io.Reader) (linear, tree string, err error) {
+	h := NewTreeHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(h.Linear()), hex.EncodeToString(h.Tree()), nil
+}
@@ -0,0 +1,68 @@
+package sign4_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/datastream/aws"
+)
+
+func sum(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+func TestTreeHash(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 7*sign4.TreeHashChunkSize/32)
+	var leaves [][]byte
+	for i := 0; i < len(payload); i += sign4.TreeHashChunkSize {
+		end := i + sign4.TreeHashChunkSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		leaves = append(leaves, sum(payload[i:end]))
+	}
+	// 3.5 MiB, the fourth leaf is half a chunk
+	expected := sum(sum(leaves[0], leaves[1]), sum(leaves[2], leaves[3]))
+	linear, tree, err := sign4.HashTree(bytes.NewReader(payload))
+	if err != nil || linear != hex.EncodeToString(sum(payload)) || tree != hex.EncodeToString(expected) {
+		t.Fatal("wrong hashes", linear, tree, err)
+	}
+
+	h := sign4.NewTreeHash()
+	for i := 0; i < 3*sign4.TreeHashChunkSize; i += 1000 {
+		end := i + 1000
+		if end > 3*sign4.TreeHashChunkSize {
+			end = 3 * sign4.TreeHashChunkSize
+		}
+		h.Write(payload[i:end])
+	}
+	if !bytes.Equal(h.Tree(), sum(sum(leaves[0], leaves[1]), leaves[2])) || !bytes.Equal(h.Tree(), h.Tree()) {
+		t.Fatal("wrong tree hash of an odd number of leaves")
+	}
+	if part := sign4.CombineTreeHashes(sum(leaves[0], leaves[1]), sum(leaves[2], leaves[3])); !bytes.Equal(part, expected) {
+		t.Fatal("wrong combined part hashes")
+	}
+	if _, tree, _ := sign4.HashTree(strings.NewReader("")); tree != hex.EncodeToString(sum()) {
+		t.Fatal("wrong empty tree hash", tree)
+	}
+
+	r, _ := http.NewRequest("POST", "https://glacier.us-east-1.amazonaws.com/-/vaults/v/archives", bytes.NewReader(payload))
+	h = sign4.NewTreeHash()
+	h.Write(payload)
+	h.SetHeaders(r.Header)
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "glacier"}
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(r.Header.Get("Authorization"), "x-amz-content-sha256;x-amz-date;x-amz-sha256-tree-hash") {
+		t.Fatal("tree hash headers not signed", r.Header)
+	}
+}
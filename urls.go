@@ -0,0 +1,103 @@
+package sign4
+
+// Presigned download links reused until shortly before they expire
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PresignedURLs hands out presigned GET URLs of the objects of a bucket, the URL of a key
+// is reused until less than Renew of its validity is left and presigned again then.
+// The zero value with Signature, Endpoint and Bucket set is ready to use
+type PresignedURLs struct {
+	Signature *Signature
+	// Endpoint and Bucket locate the objects, as in Profile.ObjectURL with the profile of Signature
+	Endpoint string
+	Bucket   string
+	// Expires is the validity of the URLs, an hour when zero
+	Expires time.Duration
+	// Renew is how long before expiry a URL is replaced, a tenth of Expires when zero
+	Renew time.Duration
+	// MaxEntries bounds the URLs kept, DefaultMaxCacheEntries when zero
+	MaxEntries int
+
+	mu   sync.Mutex
+	urls map[string]issuedURL
+}
+
+type issuedURL struct {
+	url     string
+	expires time.Time
+}
+
+func (m *PresignedURLs) expires() time.Duration {
+	if m.Expires > 0 {
+		return m.Expires
+	}
+	return time.Hour
+}
+
+func (m *PresignedURLs) renew() time.Duration {
+	if m.Renew > 0 {
+		return m.Renew
+	}
+	return m.expires() / 10
+}
+
+// GetURL returns a presigned URL of key valid for at least Renew
+func (m *PresignedURLs) GetURL(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.Signature.now()
+	if u, ok := m.urls[key]; ok && u.expires.Sub(now) > m.renew() {
+		return u.url, nil
+	}
+	object, err := m.Signature.profile().ObjectURL(m.Endpoint, m.Bucket, key)
+	if err != nil {
+		return "", err
+	}
+	r, err := http.NewRequest("GET", object.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	u, err := m.Signature.Presign(r, m.expires(), nil)
+	if err != nil {
+		return "", err
+	}
+	if m.urls == nil {
+		m.urls = make(map[string]issuedURL)
+	}
+	if _, ok := m.urls[key]; !ok {
+		m.evict(now)
+	}
+	m.urls[key] = issuedURL{url: u.String(), expires: now.Add(m.expires())}
+	return u.String(), nil
+}
+
+// Forget drops the URL of key, the next GetURL presigns a new one
+func (m *PresignedURLs) Forget(key string) {
+	m.mu.Lock()
+	delete(m.urls, key)
+	m.mu.Unlock()
+}
+
+// evict drops the URLs due for renewal when the manager is full and all URLs when none are
+func (m *PresignedURLs) evict(now time.Time) {
+	max := m.MaxEntries
+	if max <= 0 {
+		max = DefaultMaxCacheEntries
+	}
+	if len(m.urls) < max {
+		return
+	}
+	for k, u := range m.urls {
+		if u.expires.Sub(now) <= m.renew() {
+			delete(m.urls, k)
+		}
+	}
+	if len(m.urls) >= max {
+		m.urls = make(map[string]issuedURL)
+	}
+}
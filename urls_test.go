@@ -0,0 +1,41 @@
+package sign4_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+func TestPresignedURLs(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "s3"}
+	s = s.WithOptions(func(o *sign4.Options) { o.Now = func() time.Time { return now } })
+	m := &sign4.PresignedURLs{Signature: s, Endpoint: "https://s3.us-east-1.amazonaws.com", Bucket: "downloads", Expires: time.Hour, Renew: 5 * time.Minute}
+	first, err := m.GetURL("reports/2024.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(first)
+	if u.Host != "downloads.s3.us-east-1.amazonaws.com" || u.Path != "/reports/2024.pdf" || u.Query().Get("X-Amz-Expires") != "3600" {
+		t.Fatal("wrong URL", first)
+	}
+	now = now.Add(50 * time.Minute)
+	if again, _ := m.GetURL("reports/2024.pdf"); again != first {
+		t.Fatal("URL regenerated before renewal", again)
+	}
+	now = now.Add(6 * time.Minute)
+	renewed, _ := m.GetURL("reports/2024.pdf")
+	if renewed == first {
+		t.Fatal("URL not renewed 4 minutes before expiry")
+	}
+	if u, _ := url.Parse(renewed); u.Query().Get("X-Amz-Date") != "20240501T125600Z" {
+		t.Fatal("wrong renewal date", renewed)
+	}
+	m.Forget("reports/2024.pdf")
+	now = now.Add(time.Second)
+	if fresh, _ := m.GetURL("reports/2024.pdf"); fresh == renewed {
+		t.Fatal("forgotten URL reused")
+	}
+}
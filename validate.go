@@ -0,0 +1,105 @@
+package sign4
+
+// Checks of signer and verifier settings, to fail at startup instead of on the first request
+
+import (
+	"strings"
+	"time"
+)
+
+// ConfigError is returned by Validate for a setting that can't sign or verify a request,
+// Problem says what is wrong and how to fix it
+type ConfigError struct {
+	Field   string
+	Problem string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid " + e.Field + ": " + e.Problem
+}
+
+// ValidateAccessKey returns a *ConfigError when accessKey can't be sent in a credential
+// scope: it must be non empty printable ASCII without spaces, '/', ',' or '='
+func ValidateAccessKey(accessKey string) error {
+	if accessKey == "" {
+		return &ConfigError{Field: "AccessKey", Problem: "empty, set the access key id or a Provider"}
+	}
+	for i := 0; i < len(accessKey); i++ {
+		c := accessKey[i]
+		if c <= ' ' || c >= 0x7f || c == '/' || c == ',' || c == '=' {
+			return &ConfigError{Field: "AccessKey", Problem: "malformed, an access key id has no spaces, '/', ',' or '=', " +
+				"check it wasn't copied with the secret key or surrounding text"}
+		}
+	}
+	return nil
+}
+
+// ValidatePresignExpires returns a *ConfigError unless expires is in (0, MaxPresignExpires]
+func ValidatePresignExpires(expires time.Duration) error {
+	if expires <= 0 || expires > MaxPresignExpires {
+		return &ConfigError{Field: "expires", Problem: expires.String() + " out of range, presigned requests are valid from 1s to " +
+			MaxPresignExpires.String() + " (seven days)"}
+	}
+	return nil
+}
+
+// Validate checks the scope, the keys unless a Provider supplies them, and the options of s
+func (s *Signature) Validate() error {
+	if s.Region == "" {
+		return &ConfigError{Field: "Region", Problem: "empty, set the region of the endpoint, e.g. us-east-1"}
+	}
+	if s.Service == "" {
+		return &ConfigError{Field: "Service", Problem: "empty, set the signing name of the service, e.g. s3 or execute-api"}
+	}
+	if strings.ContainsAny(s.Region, "/ ") {
+		return &ConfigError{Field: "Region", Problem: "malformed, " + s.Region + " is a credential scope component without '/' or spaces"}
+	}
+	if strings.ContainsAny(s.Service, "/ ") {
+		return &ConfigError{Field: "Service", Problem: "malformed, " + s.Service + " is a credential scope component without '/' or spaces"}
+	}
+	if s.Provider == nil {
+		if err := ValidateAccessKey(s.AccessKey); err != nil {
+			return err
+		}
+		if s.SecretKey == "" {
+			return &ConfigError{Field: "SecretKey", Problem: "empty, set the secret access key or a Provider"}
+		}
+		if strings.TrimSpace(s.SecretKey) != s.SecretKey {
+			return &ConfigError{Field: "SecretKey", Problem: "has surrounding spaces, trim the value it was read from"}
+		}
+	}
+	if err := s.Options.Validate(); err != nil {
+		return err
+	}
+	if s.ChunkedBody == ChunkedUnsignedPayload && !s.rules().ContentSHA256 {
+		return &ConfigError{Field: "Options.ChunkedBody", Problem: "ChunkedUnsignedPayload needs a service that reads " +
+			"X-Amz-Content-Sha256, " + s.Service + " doesn't; buffer chunked bodies or set ServiceRules.ContentSHA256"}
+	}
+	return nil
+}
+
+// Validate checks the options that don't depend on the service
+func (o *Options) Validate() error {
+	if p := o.Profile; p != nil && (p.Algorithm == "" || p.KeyPrefix == "" || p.Terminator == "" || p.DateHeader == "") {
+		return &ConfigError{Field: "Options.Profile", Problem: "Algorithm, KeyPrefix, Terminator and DateHeader are required, " +
+			"start from a copy of AWS"}
+	}
+	if o.ChunkedBody < ChunkedBuffer || o.ChunkedBody > ChunkedReject {
+		return &ConfigError{Field: "Options.ChunkedBody", Problem: "unknown policy, use ChunkedBuffer, ChunkedUnsignedPayload or ChunkedReject"}
+	}
+	if o.MaxBufferedBody < 0 {
+		return &ConfigError{Field: "Options.MaxBufferedBody", Problem: "negative, zero uses DefaultMaxBufferedBody"}
+	}
+	return nil
+}
+
+// Validate checks that v can look up secret keys and that its options are valid
+func (v *Verifier) Validate() error {
+	if v.SecretKey == nil {
+		return &ConfigError{Field: "SecretKey", Problem: "nil, set the function returning the secret key of an access key"}
+	}
+	if v.MaxSkew < 0 {
+		return &ConfigError{Field: "MaxSkew", Problem: "negative, zero disables the check, AWS allows 15m"}
+	}
+	return v.Options.Validate()
+}
@@ -0,0 +1,76 @@
+package sign4_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+func TestValidate(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Region: "us-east-1", Service: "s3"}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for field, bad := range map[string]*sign4.Signature{
+		"Region":                  s.WithRegion(""),
+		"Service":                 s.WithService("s3/"),
+		"AccessKey":               {AccessKey: "AKID/20110909", SecretKey: "secret", Region: "us-east-1", Service: "s3"},
+		"SecretKey":               {AccessKey: "AKID", SecretKey: "secret\n", Region: "us-east-1", Service: "s3"},
+		"Options.ChunkedBody":     s.WithService("execute-api").WithOptions(sign4.UseChunkedPolicy(sign4.ChunkedUnsignedPayload)),
+		"Options.Profile":         s.WithOptions(func(o *sign4.Options) { o.Profile = &sign4.Profile{Algorithm: "X"} }),
+		"Options.MaxBufferedBody": s.WithOptions(func(o *sign4.Options) { o.MaxBufferedBody = -1 }),
+	} {
+		var ce *sign4.ConfigError
+		if err := bad.Validate(); !errors.As(err, &ce) || ce.Field != field {
+			t.Fatal("wrong error for", field, err)
+		}
+	}
+	if err := (&sign4.Signature{Region: "us-east-1", Service: "s3", Provider: s}).Validate(); err != nil {
+		t.Fatal("provider keys checked", err)
+	}
+	if err := s.WithOptions(sign4.UseChunkedPolicy(sign4.ChunkedUnsignedPayload)).Validate(); err != nil {
+		t.Fatal("unsigned payload refused for s3", err)
+	}
+
+	v := &sign4.Verifier{}
+	if err := v.Validate(); err == nil {
+		t.Fatal("verifier without keys accepted")
+	}
+	v.SecretKey = func(string) (string, error) { return "secret", nil }
+	v.MaxSkew = -time.Minute
+	if err := v.Validate(); err == nil {
+		t.Fatal("negative skew accepted")
+	}
+	v.MaxSkew = 15 * time.Minute
+	if err := v.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidatePresignExpires(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "s3"}
+	r, _ := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/key", nil)
+	var ce *sign4.ConfigError
+	if _, err := s.Presign(r, sign4.MaxPresignExpires+time.Second, nil); !errors.As(err, &ce) || ce.Field != "expires" {
+		t.Fatal("eight days accepted", err)
+	}
+	if err := sign4.ValidatePresignExpires(sign4.MaxPresignExpires); err != nil {
+		t.Fatal(err)
+	}
+	if err := sign4.ValidatePresignExpires(0); err == nil {
+		t.Fatal("zero expiry accepted")
+	}
+}
+
+func TestUnsignedPayloadTrailer(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1", Service: "s3"}
+	r := pipeRequest(t, "streamed body")
+	r.Header.Set("X-Amz-Trailer", sign4.ChecksumCRC32.Header())
+	var ce *sign4.ConfigError
+	if err := s.WithOptions(sign4.UseChunkedPolicy(sign4.ChunkedUnsignedPayload)).SignRequest(r, nil); !errors.As(err, &ce) {
+		t.Fatal("trailer sent with an unsigned payload", err)
+	}
+}
@@ -3,15 +3,18 @@ package sign4
 // Verify AWS Canonical Request For Signature Version 4
 
 import (
+	"crypto/subtle"
 	"errors"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Authorization: AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20110909/us-east-1/host/aws4_request, SignedHeaders=content-type;date;host, Signature=5a15b22cf462f047318703b92e6f4f38884e4a7ab7b1d6426ca46a8bd1c26cbc
 // Authorization: AWS4-HMAC-SHA256 Credential=devops/20180312/hz/dnsapi/aws4_request,SignedHeaders=Content-Length;Content-type;host;x-amz-date,Signature=8a31f6aaa5026579bb2cf20962768190fdd0b4846ed5c48842fa61936245e9c5
 func GetSignature(r *http.Request) (*Signature, string, map[string]bool, error) {
-	authHeader := r.Header.Get("Authorization")
+	authHeader := headerValue(r.Header, "Authorization")
 	return GetSignatureFromString(authHeader)
 }
 
@@ -20,7 +23,8 @@ func GetSignatureFromString(authHeader string) (*Signature, string, map[string]b
 	if len(authHeader) < 16 {
 		return nil, "", signedHeaders, errors.New("get authorization header failed")
 	}
-	if authHeader[:16] != "AWS4-HMAC-SHA256" {
+	p := profileOf(authHeader)
+	if p == nil {
 		return nil, "", signedHeaders, errors.New("get aws4-hmac-sha256 failed")
 	}
 	items := strings.Split(authHeader, " ")
@@ -37,7 +41,7 @@ func GetSignatureFromString(authHeader string) (*Signature, string, map[string]b
 	if len(pattens) != 4 {
 		return nil, "", signedHeaders, errors.New("wrong authorization header size")
 	}
-	signature, err := getCredential(pattens[1])
+	signature, err := getCredential(pattens[1], p)
 	if err != nil {
 		return nil, "", signedHeaders, errors.New("get authorization header signature failed")
 	}
@@ -45,18 +49,18 @@ func GetSignatureFromString(authHeader string) (*Signature, string, map[string]b
 	if err != nil {
 		return nil, "", signedHeaders, errors.New("get authorization header signedHeaders failed")
 	}
-	if pattens[3][:9] != "Signature" {
+	if !strings.HasPrefix(pattens[3], "Signature=") {
 		return nil, "", signedHeaders, errors.New("no signature")
 	}
 	return signature, authHeader, signedHeaders, nil
 }
-func getCredential(s string) (*Signature, error) {
+func getCredential(s string, p *Profile) (*Signature, error) {
 	// Check if the credential part has the correct length and format
 	if !strings.HasPrefix(s, "Credential=") {
 		return nil, errors.New("wrong credential part")
 	}
 	parts := strings.Split(s[11:], "/")
-	if len(parts) != 5 || parts[4] != "aws4_request" {
+	if len(parts) != 5 || parts[4] != p.Terminator {
 		return nil, errors.New("wrong credential part")
 	}
 
@@ -66,6 +70,9 @@ func getCredential(s string) (*Signature, error) {
 		Region:    parts[2],
 		Service:   parts[3],
 	}
+	if p != AWS {
+		ss.Profile = p
+	}
 	return ss, nil
 }
 func getSignedHeaders(s string) (map[string]bool, error) {
@@ -85,3 +92,208 @@ func getSignedHeaders(s string) (map[string]bool, error) {
 
 	return signedHeaders, nil
 }
+
+// getSignatureValue returns the hex signature of an authorization header
+func getSignatureValue(authHeader string) (string, error) {
+	i := strings.LastIndex(authHeader, "Signature=")
+	if i < 0 {
+		return "", errors.New("no signature")
+	}
+	return strings.TrimSpace(authHeader[i+10:]), nil
+}
+
+// ErrSignatureMismatch is returned when the presented signature doesn't match the request
+var ErrSignatureMismatch = errors.New("signature does not match")
+
+// ErrRequestTimeSkewed is returned when the request date is outside Verifier.MaxSkew
+var ErrRequestTimeSkewed = errors.New("request time too skewed")
+
+// ErrScopeNotAllowed is returned when Verifier.AllowScope rejects the credential scope
+var ErrScopeNotAllowed = errors.New("credential scope not allowed")
+
+// ErrReplayed is returned when Verifier.Replay has seen the signature before
+var ErrReplayed = errors.New("signature already used")
+
+// Verifier checks the Authorization header of requests
+type Verifier struct {
+	// SecretKey returns the secret key of an access key
+	SecretKey func(accessKey string) (string, error)
+	// MaxSkew rejects requests dated further from now, zero disables the check
+	MaxSkew time.Duration
+	// AllowScope rejects the credential scopes it returns false for, nil allows every scope
+	AllowScope func(region, service string) bool
+	// Options canonicalize requests as their signers did, the profile comes from the Authorization header
+	Options Options
+	// Replay, when set, rejects signatures verified before, they are kept for twice MaxSkew
+	// or 15 minutes without it
+	Replay Cache
+	// Presigned, when set, caches the valid presigned requests until they expire so a
+	// URL requested again skips recomputing its signature, presigned URLs aren't replay checked
+	Presigned Cache
+	// Alternates accepts a request whose Authorization doesn't verify when one of its
+	// AlternateAuthorizationHeader values does, for servers moving consumers to new keys
+	Alternates bool
+}
+
+// Verify recomputes the signature of r and returns the parsed signature with its secret key,
+// presigned requests are verified from their query
+func (v *Verifier) Verify(r *http.Request) (*Signature, error) {
+	s, err := v.verify(r)
+	if v.Alternates && fallsBack(err) && !IsPresigned(r) {
+		s, err = v.verifyAlternates(r, err)
+	}
+	if e, ok := err.(*secretKeyError); ok {
+		err = e.err
+	}
+	v.logVerified(r, s, err)
+	return s, err
+}
+
+func (v *Verifier) verify(r *http.Request) (*Signature, error) {
+	if IsPresigned(r) {
+		return v.verifyPresigned(r)
+	}
+	s, authHeader, headers, err := GetSignature(r)
+	if err != nil {
+		return nil, err
+	}
+	if v.AllowScope != nil && !v.AllowScope(s.Region, s.Service) {
+		return nil, ErrScopeNotAllowed
+	}
+	presented, err := getSignatureValue(authHeader)
+	if err != nil {
+		return nil, err
+	}
+	t, err := requestTime(r, s.profile())
+	if err != nil {
+		return nil, err
+	}
+	if v.MaxSkew > 0 {
+		if d := v.Options.now().Sub(t); d > v.MaxSkew || d < -v.MaxSkew {
+			return nil, ErrRequestTimeSkewed
+		}
+	}
+	profile := s.Profile
+	s.Options = v.Options
+	s.Profile = profile
+	s.SecretKey, err = v.SecretKey(s.AccessKey)
+	if err != nil {
+		return nil, &secretKeyError{err}
+	}
+	signedHeaders := make(map[string]bool, len(headers))
+	for k := range headers {
+		signedHeaders[strings.ToLower(k)] = true
+	}
+	sc := getScratch()
+	defer putScratch(sc)
+	expected, err := s.signature(sc, r, signedHeaders, t, s.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(expected, []byte(presented)) != 1 {
+		return nil, ErrSignatureMismatch
+	}
+	if err := s.checkPayloadHash(sc, r); err != nil {
+		return nil, err
+	}
+	if v.Replay != nil {
+		if err := v.checkReplay(presented); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// VerifyResult is the outcome of verifying one request
+type VerifyResult struct {
+	Signature *Signature
+	Err       error
+}
+
+// VerifyBatch verifies requests with at most workers goroutines, results are in request order
+func (v *Verifier) VerifyBatch(requests []*http.Request, workers int) []VerifyResult {
+	results := make([]VerifyResult, len(requests))
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(requests) {
+		workers = len(requests)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	// a batch store records the signatures of the batch in one call after verifying
+	batch, _ := v.Replay.(BatchReplayStore)
+	w := v
+	if batch != nil {
+		unrecorded := *v
+		unrecorded.Replay = nil
+		w = &unrecorded
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for n := range next {
+				results[n].Signature, results[n].Err = w.Verify(requests[n])
+			}
+		}()
+	}
+	for n := range requests {
+		next <- n
+	}
+	close(next)
+	wg.Wait()
+	if batch != nil {
+		v.checkReplayBatch(batch, requests, results)
+	}
+	return results
+}
+
+// ErrMalformedScope is returned when a credential scope isn't date/region/service/terminator
+var ErrMalformedScope = errors.New("malformed credential scope")
+
+// scopeKey returns the derived key of a credential scope of profile p
+func scopeKey(p *Profile, secretKey, scope string) (*signingKey, error) {
+	parts := strings.Split(scope, "/")
+	if len(parts) != 4 || parts[3] != p.Terminator {
+		return nil, ErrMalformedScope
+	}
+	t, err := time.Parse(BasicDateFormatShort, parts[0])
+	if err != nil {
+		return nil, ErrMalformedScope
+	}
+	return signingKeys.get(p, secretKey, parts[1], parts[2], t, time.Now())
+}
+
+// VerifyStringToSign checks the hex signature of a string to sign with the key derived
+// from secretKey for scope, the profile comes from the algorithm on its first line
+func VerifyStringToSign(stringToSign, signature, secretKey, scope string) error {
+	var p *Profile
+	for _, profile := range Profiles {
+		if strings.HasPrefix(stringToSign, profile.Algorithm+"\n") {
+			p = profile
+		}
+	}
+	if p == nil {
+		return errors.New("unknown string to sign algorithm")
+	}
+	key, err := scopeKey(p, secretKey, scope)
+	if err != nil {
+		return err
+	}
+	mac := key.mac()
+	mac.Write([]byte(stringToSign))
+	var sum [32]byte
+	expected := hexString(mac.Sum(sum[:0]))
+	key.put(mac)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(signature))) != 1 {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// VerifyCanonicalRequest checks the hex signature of an AWS4-HMAC-SHA256 canonical request
+// signed at t for scope
+func VerifyCanonicalRequest(canonicalRequest string, t time.Time, signature, secretKey, scope string) error {
+	return VerifyStringToSign(StringToSign(canonicalRequest, scope, t), signature, secretKey, scope)
+}
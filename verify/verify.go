@@ -0,0 +1,64 @@
+// Package verify checks the signatures of inbound AWS Signature Version 4 requests,
+// for servers, gateways and proxies that verify without signing.
+//
+// A signature is recomputed with the canonicalization of the signer, so the types of
+// this package are aliases of those of the root package and values pass freely
+// between the two:
+//
+//	v := &verify.Verifier{SecretKey: lookup, MaxSkew: 15 * time.Minute, Replay: &verify.MemoryCache{}}
+//	s, err := v.Verify(r)
+package verify
+
+import (
+	"net/http"
+
+	"github.com/datastream/aws"
+)
+
+// Verifier checks the Authorization header of requests and the query of presigned ones
+type Verifier = sign4.Verifier
+
+// Result is the outcome of verifying one request of Verifier.VerifyBatch
+type Result = sign4.VerifyResult
+
+// PostUpload is a form upload checked by Verifier.VerifyPostPolicy
+type PostUpload = sign4.PostUpload
+
+// Cache stores replay records and presigned results, see Verifier.Replay and Verifier.Presigned
+type Cache = sign4.Cache
+
+// MemoryCache is an in-process Cache, the zero value is ready to use
+type MemoryCache = sign4.MemoryCache
+
+// ReplayStore is a Cache recording replay keys atomically
+type ReplayStore = sign4.ReplayStore
+
+// BatchReplayStore records the replay keys of a batch in one round trip
+type BatchReplayStore = sign4.BatchReplayStore
+
+// Errors of Verifier.Verify, they are the errors of the root package
+var (
+	ErrSignatureMismatch   = sign4.ErrSignatureMismatch
+	ErrRequestTimeSkewed   = sign4.ErrRequestTimeSkewed
+	ErrScopeNotAllowed     = sign4.ErrScopeNotAllowed
+	ErrReplayed            = sign4.ErrReplayed
+	ErrPresignExpired      = sign4.ErrPresignExpired
+	ErrPayloadHashMismatch = sign4.ErrPayloadHashMismatch
+	ErrMalformedScope      = sign4.ErrMalformedScope
+	ErrPolicyExpired       = sign4.ErrPolicyExpired
+)
+
+// IsSigned reports whether r carries a signature in its Authorization header or query
+func IsSigned(r *http.Request) bool {
+	return sign4.IsSigned(r)
+}
+
+// IsPresigned reports whether r is signed in its query
+func IsPresigned(r *http.Request) bool {
+	return sign4.IsPresigned(r)
+}
+
+// GetSignature parses the Authorization header of r into its scope, signature and signed headers
+func GetSignature(r *http.Request) (*sign4.Signature, string, map[string]bool, error) {
+	return sign4.GetSignature(r)
+}
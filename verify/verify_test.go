@@ -0,0 +1,33 @@
+package verify_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+	"github.com/datastream/aws/verify"
+)
+
+func TestVerifier(t *testing.T) {
+	s := (&sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "execute-api"}).
+		WithOptions(sign4.Deterministic(time.Date(2011, 9, 9, 23, 36, 0, 0, time.UTC)))
+	r, _ := http.NewRequest("GET", "https://api.example.com/items", nil)
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !verify.IsSigned(r) || verify.IsPresigned(r) {
+		t.Fatal("signed request not recognized")
+	}
+	v := &verify.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }, Replay: &verify.MemoryCache{}}
+	if got, err := v.Verify(r); err != nil || got.AccessKey != "AKIDEXAMPLE" {
+		t.Fatal("not verified", err)
+	}
+	if _, err := v.Verify(r); err != verify.ErrReplayed {
+		t.Fatal("replay accepted", err)
+	}
+	results := v.VerifyBatch([]*http.Request{r}, 1)
+	if results[0].Err != sign4.ErrReplayed {
+		t.Fatal("errors differ from the root package", results[0].Err)
+	}
+}
@@ -1,9 +1,13 @@
 package sign4_test
 
 import (
+	"errors"
 	"github.com/datastream/aws"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestVerify(t *testing.T) {
@@ -43,3 +47,90 @@ func TestVerify(t *testing.T) {
 		t.Fatal("wrong authorization header", aa)
 	}
 }
+
+func TestVerifier(t *testing.T) {
+	s := sign4.Signature{
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:    "us-east-1",
+		Service:   "host",
+	}
+	v := &sign4.Verifier{SecretKey: func(accessKey string) (string, error) {
+		if accessKey != s.AccessKey {
+			return "", errors.New("unknown access key")
+		}
+		return s.SecretKey, nil
+	}}
+	var requests []*http.Request
+	for i := 0; i < 10; i++ {
+		r, _ := http.NewRequest("POST", "http://host.foo.com/", strings.NewReader("foo=bar"))
+		r.Header.Add("date", "Mon, 09 Sep 2011 23:36:00 GMT")
+		s.SignRequest(r, nil)
+		requests = append(requests, r)
+	}
+	requests[3].Body = ioutil.NopCloser(strings.NewReader("foo=baz"))
+	requests[5].Header.Set("Authorization", strings.Replace(requests[5].Header.Get("Authorization"), "AKIDEXAMPLE", "AKIDOTHER", 1))
+	results := v.VerifyBatch(requests, 4)
+	for i, result := range results {
+		switch i {
+		case 3:
+			if result.Err != sign4.ErrSignatureMismatch {
+				t.Fatal("tampered body verified", result.Err)
+			}
+		case 5:
+			if result.Err == nil {
+				t.Fatal("unknown access key verified")
+			}
+		default:
+			if result.Err != nil || result.Signature.AccessKey != s.AccessKey {
+				t.Fatal("failed to verify", i, result.Err)
+			}
+		}
+	}
+	v.MaxSkew = time.Minute
+	if _, err := v.Verify(requests[0]); err != sign4.ErrRequestTimeSkewed {
+		t.Fatal("expected skew error", err)
+	}
+	v.MaxSkew = 0
+	v.AllowScope = func(region, service string) bool { return region == "cn-north-1" }
+	if _, err := v.Verify(requests[0]); err != sign4.ErrScopeNotAllowed {
+		t.Fatal("expected scope error", err)
+	}
+}
+
+func TestGetSignatureFromStringMalformed(t *testing.T) {
+	for _, h := range []string{
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20110909/us-east-1/host/aws4_request, SignedHeaders=date;host, Sig",
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20110909/us-east-1/host, SignedHeaders=date;host, Signature=00",
+		"AWS4-HMAC-SHA256",
+	} {
+		if _, _, _, err := sign4.GetSignatureFromString(h); err == nil {
+			t.Fatal("malformed header accepted", h)
+		}
+	}
+}
+
+func TestVerifyStringToSign(t *testing.T) {
+	// get-vanilla of the AWS test suite
+	creq := "GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	scope := "20150830/us-east-1/service/aws4_request"
+	secret := "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	signature := "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	d := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	if err := sign4.VerifyCanonicalRequest(creq, d, signature, secret, scope); err != nil {
+		t.Fatal(err)
+	}
+	sts := sign4.StringToSign(creq, scope, d)
+	if err := sign4.VerifyStringToSign(sts, strings.ToUpper(signature), secret, scope); err != nil {
+		t.Fatal(err)
+	}
+	if err := sign4.VerifyStringToSign(sts, signature, "other", scope); err != sign4.ErrSignatureMismatch {
+		t.Fatal("wrong secret accepted", err)
+	}
+	if err := sign4.VerifyStringToSign(sts, signature, secret, "20150830/us-east-1/service"); err != sign4.ErrMalformedScope {
+		t.Fatal("malformed scope accepted", err)
+	}
+	if err := sign4.VerifyStringToSign("HMAC\n"+sts, signature, secret, scope); err == nil {
+		t.Fatal("unknown algorithm accepted")
+	}
+}
@@ -0,0 +1,111 @@
+package sign4
+
+// WebSocket handshakes, the upgrade headers are set by the websocket client and
+// proxies rewrite them, so they stay out of the signature
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// IsUpgrade reports whether r asks to switch protocols, as a websocket handshake does
+func IsUpgrade(r *http.Request) bool {
+	if headerValue(r.Header, "Upgrade") == "" {
+		return false
+	}
+	for _, token := range strings.Split(headerValue(r.Header, "Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+// isHandshakeHeader reports whether a lower case header belongs to the upgrade handshake
+func isHandshakeHeader(k string) bool {
+	return k == "connection" || k == "upgrade" || strings.HasPrefix(k, "sec-websocket-")
+}
+
+// upgradeSignedHeaders leaves Connection, Upgrade and the Sec-WebSocket- headers out
+// of the headers signed by default
+func upgradeSignedHeaders(r *http.Request, signedHeaders map[string]bool) map[string]bool {
+	if len(signedHeaders) != 0 {
+		return signedHeaders
+	}
+	m := map[string]bool{"host": true}
+	for key := range r.Header {
+		if k := strings.ToLower(key); !isHandshakeHeader(k) && !isPseudoHeader(k) {
+			m[k] = true
+		}
+	}
+	return m
+}
+
+// SignWebSocket returns header with the signature of a websocket handshake to u, for
+// dialers that build the upgrade request themselves. ws and wss sign like http and https
+func (s *Signature) SignWebSocket(u *url.URL, header http.Header) (http.Header, error) {
+	r, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		r.Header = header.Clone()
+	}
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	if err := s.SignRequest(r, nil); err != nil {
+		return nil, err
+	}
+	r.Header.Del("Connection")
+	r.Header.Del("Upgrade")
+	return r.Header, nil
+}
+
+// PresignWebSocketConnect returns the $connect URL of the stage of an API Gateway
+// WebSocket API signed in its query, for dialers that take a URL. endpoint is the
+// wss:// URL of the API with or without the stage, https endpoints are switched to wss.
+// The service defaults to execute-api and query adds parameters for the $connect route
+func (s *Signature) PresignWebSocketConnect(endpoint, stage string, query url.Values, expires time.Duration) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	case "wss", "ws":
+	default:
+		return "", errors.New("websocket endpoint is not a wss:// URL")
+	}
+	if stage = strings.Trim(stage, "/"); stage != "" && strings.Trim(u.Path, "/") != stage {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + stage
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawPath = ""
+	q := u.Query()
+	for k, v := range query {
+		q[k] = append(q[k], v...)
+	}
+	u.RawQuery = strings.Replace(q.Encode(), "+", "%20", -1)
+	signer := s
+	if s.Service == "" {
+		signer = s.derive()
+		signer.Service = "execute-api"
+	}
+	r, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	signed, err := signer.Presign(r, expires, nil)
+	if err != nil {
+		return "", err
+	}
+	return signed.String(), nil
+}
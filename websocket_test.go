@@ -0,0 +1,77 @@
+package sign4_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+func TestSignWebSocket(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1", Service: "execute-api"}
+	u, _ := url.Parse("wss://abc.execute-api.us-east-1.amazonaws.com:443/prod?room=1")
+	header, err := s.SignWebSocket(u, http.Header{"Origin": {"https://example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := header.Get("Authorization")
+	if !strings.Contains(auth, "SignedHeaders=host;origin;x-amz-date,") || header.Get("Upgrade") != "" {
+		t.Fatal("wrong handshake headers", header)
+	}
+	// the handshake as the server receives it, with the headers added by the dialer
+	r, _ := http.NewRequest("GET", "https://abc.execute-api.us-east-1.amazonaws.com/prod?room=1", strings.NewReader("frames"))
+	r.Header = header
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-Websocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	r.Header.Set("Sec-Websocket-Version", "13")
+	if !sign4.IsUpgrade(r) {
+		t.Fatal("handshake not recognized")
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ = http.NewRequest("GET", "https://abc.execute-api.us-east-1.amazonaws.com/prod", nil)
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-Websocket-Protocol", "chat")
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if a := r.Header.Get("Authorization"); !strings.Contains(a, "SignedHeaders=host;x-amz-date,") {
+		t.Fatal("handshake headers signed", a)
+	}
+}
+
+func TestPresignWebSocketConnect(t *testing.T) {
+	s := &sign4.Signature{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", Region: "us-east-1"}
+	signed, err := s.PresignWebSocketConnect("https://abc.execute-api.us-east-1.amazonaws.com/", "/prod/", url.Values{"room": {"a b"}}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(signed)
+	if u.Scheme != "wss" || u.Path != "/prod" || u.Query().Get("room") != "a b" ||
+		!strings.Contains(u.Query().Get("X-Amz-Credential"), "/us-east-1/execute-api/aws4_request") {
+		t.Fatal("wrong connect URL", signed)
+	}
+	if again, err := s.PresignWebSocketConnect("wss://abc.execute-api.us-east-1.amazonaws.com/prod", "prod", nil, time.Minute); err != nil || !strings.HasPrefix(again, "wss://abc.execute-api.us-east-1.amazonaws.com/prod?") {
+		t.Fatal("stage added twice", again, err)
+	}
+	// the handshake as the server receives it
+	u.Scheme = "https"
+	r, _ := http.NewRequest("GET", u.String(), nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "secret", nil }}
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PresignWebSocketConnect("ftp://abc/", "prod", nil, time.Minute); err == nil {
+		t.Fatal("non websocket endpoint accepted")
+	}
+}
@@ -0,0 +1,83 @@
+package sign4
+
+// Wiping secret and derived key material from memory
+
+import (
+	"errors"
+	"runtime"
+	"time"
+	"unsafe"
+)
+
+// ErrZeroized is returned by credentials read after they were wiped
+var ErrZeroized = errors.New("credentials were zeroized")
+
+// Zeroize overwrites b with zeros
+func Zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
+
+// Zeroizer is implemented by signers and credential providers that can wipe their keys
+type Zeroizer interface {
+	Zeroize()
+}
+
+// ByteCredentials is a CredentialsProvider keeping its secret key in a []byte. The
+// Credentials it returns share that memory instead of copying it into a string, so
+// Zeroize wipes the secret everywhere signing passed it; they must not be kept past it
+type ByteCredentials struct {
+	AccessKey    string
+	SecretKey    []byte
+	SessionToken string
+	Expiry       time.Time
+}
+
+// Credentials returns the keys of c, ErrZeroized once they were wiped
+func (c *ByteCredentials) Credentials() (Credentials, error) {
+	if len(c.SecretKey) == 0 {
+		return Credentials{}, ErrZeroized
+	}
+	return Credentials{AccessKey: c.AccessKey, SecretKey: bytesString(c.SecretKey), SessionToken: c.SessionToken, Expiry: c.Expiry}, nil
+}
+
+// Zeroize wipes the secret key of c and the derived keys of it in the process key cache,
+// call it once c no longer signs
+func (c *ByteCredentials) Zeroize() {
+	if len(c.SecretKey) == 0 {
+		return
+	}
+	signingKeys.forget(bytesString(c.SecretKey))
+	Zeroize(c.SecretKey)
+	c.SecretKey = nil
+	c.SessionToken = ""
+}
+
+// bytesString returns b as a string sharing its memory
+func bytesString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// Zeroize drops the derived keys of the secret of s from the process key cache and
+// Options.KeyCache, wiping those it holds, and zeroizes a Provider that can be. Signers
+// returned by WithRegion, WithService and WithOptions share the credentials of s, so
+// zeroizing one of them wipes the provider of all. A secret set in SecretKey is only
+// cleared, a string can't be overwritten; use ByteCredentials for secrets to be wiped
+func (s *Signature) Zeroize() {
+	if creds, err := s.Credentials(); err == nil && creds.SecretKey != "" {
+		signingKeys.forget(creds.SecretKey)
+		if s.KeyCache != nil {
+			// shared keys are kept for two days
+			p, now := s.profile(), s.now()
+			for _, d := range []time.Duration{-24 * time.Hour, 0, 24 * time.Hour} {
+				s.KeyCache.Delete(sharedKeyID(p, creds.SecretKey, s.Region, s.Service, now.Add(d)))
+			}
+		}
+	}
+	if z, ok := s.Provider.(Zeroizer); ok {
+		z.Zeroize()
+	}
+	s.SecretKey, s.SessionToken = "", ""
+}
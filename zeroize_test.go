@@ -0,0 +1,57 @@
+package sign4_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/datastream/aws"
+)
+
+// mapCache is a Cache exposing its entries
+type mapCache map[string][]byte
+
+func (c mapCache) Get(key string) ([]byte, bool, error) {
+	v, ok := c[key]
+	return v, ok, nil
+}
+
+func (c mapCache) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	c[key] = value
+	return nil
+}
+
+func (c mapCache) Delete(key string) error {
+	delete(c, key)
+	return nil
+}
+
+func TestZeroize(t *testing.T) {
+	secret := []byte("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	creds := &sign4.ByteCredentials{AccessKey: "AKIDEXAMPLE", SecretKey: secret}
+	cache := mapCache{}
+	s := (&sign4.Signature{Provider: creds, Region: "us-east-1", Service: "s3"}).WithOptions(sign4.UseKeyCache(cache))
+	r, _ := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/key", nil)
+	if err := s.SignRequest(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	v := &sign4.Verifier{SecretKey: func(string) (string, error) { return "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", nil }}
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal("signed with the byte secret failed verification", err)
+	}
+	if len(cache) != 1 {
+		t.Fatal("derived key not cached", len(cache))
+	}
+	s.Zeroize()
+	if !bytes.Equal(secret, make([]byte, len(secret))) {
+		t.Fatal("secret not wiped")
+	}
+	if len(cache) != 0 {
+		t.Fatal("derived key kept in the cache")
+	}
+	if err := s.SignRequest(r, nil); err != sign4.ErrZeroized {
+		t.Fatal("signed after zeroizing", err)
+	}
+	s.Zeroize()
+}